@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // embed the timezone database: the runtime image does not include it
 
 	"github.com/tdeslauriers/carapace/pkg/config"
 	"github.com/tdeslauriers/pixie/internal/gallery"
@@ -0,0 +1,277 @@
+// Command pixie-admin runs administrative tasks against the gallery's database and object storage bucket.
+//
+// Usage:
+//
+//	pixie-admin backup -out <file|->
+//	pixie-admin restore -in <file|->
+//
+// backup writes a versioned tar archive of every table, with encrypted fields kept encrypted, and every object
+// in the bucket, with a checksummed manifest.  restore rehydrates an empty database and bucket from such an archive
+// and verifies the restored row counts and object checksums.  The gallery service should be stopped during a restore:
+// restored objects raise the same bucket notifications as uploads.
+//
+// Configuration is loaded from the same environment variables as the gallery service.
+// Logs are written to stderr so an archive can be written to stdout.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/config"
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/pixie/internal/backup"
+	"github.com/tdeslauriers/pixie/internal/multipart"
+	"github.com/tdeslauriers/pixie/internal/util"
+)
+
+func main() {
+
+	// set logging to json format on stderr: stdout may be the archive
+	jsonHandler := slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{
+		Level: slog.LevelInfo,
+	})
+	slog.SetDefault(slog.New(jsonHandler).
+		With(slog.String(util.ServiceKey, util.ServiceGallery)))
+
+	// create a logger for the admin command
+	logger := slog.Default().
+		With(slog.String(util.PackageKey, util.PackageMain)).
+		With(slog.String(util.ComponentKey, util.ComponentAdmin))
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	// create a context that is cancelled when a shutdown signal is received
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var err error
+	switch os.Args[1] {
+	case "backup":
+		err = runBackup(ctx, os.Args[2:], logger)
+	case "restore":
+		err = runRestore(ctx, os.Args[2:], logger)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to run %s", os.Args[1]), "err", err.Error())
+		stop()
+		os.Exit(1)
+	}
+}
+
+// usage is a helper which prints the command's usage to stderr.
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage:")
+	fmt.Fprintln(os.Stderr, "  pixie-admin backup -out <file|->")
+	fmt.Fprintln(os.Stderr, "  pixie-admin restore -in <file|->")
+}
+
+// runBackup runs the backup subcommand, writing the archive to a new file or stdout.
+func runBackup(ctx context.Context, args []string, logger *slog.Logger) error {
+
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("out", "", "path of the backup archive to create, or - for stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return fmt.Errorf("-out is required")
+	}
+
+	svc, closeDb, err := newBackupService()
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	var w io.Writer = os.Stdout
+	if *out != "-" {
+		// never overwrite an existing archive
+		f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return fmt.Errorf("failed to create backup archive: %v", err)
+		}
+		w = f
+
+		// remove a partial archive so it cannot be mistaken for a backup
+		defer func() {
+			if f != nil {
+				f.Close()
+				os.Remove(*out)
+			}
+		}()
+
+		m, err := svc.Backup(ctx, w)
+		if err != nil {
+			return err
+		}
+
+		if err := f.Sync(); err != nil {
+			return fmt.Errorf("failed to sync backup archive: %v", err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to close backup archive: %v", err)
+		}
+		f = nil
+
+		logBackup(logger, "backed up", m, *out)
+		return nil
+	}
+
+	m, err := svc.Backup(ctx, w)
+	if err != nil {
+		return err
+	}
+
+	logBackup(logger, "backed up", m, "stdout")
+	return nil
+}
+
+// runRestore runs the restore subcommand, reading the archive from a file or stdin.
+func runRestore(ctx context.Context, args []string, logger *slog.Logger) error {
+
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	in := fs.String("in", "", "path of the backup archive to restore, or - for stdin")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	svc, closeDb, err := newBackupService()
+	if err != nil {
+		return err
+	}
+	defer closeDb()
+
+	var r io.Reader = os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return fmt.Errorf("failed to open backup archive: %v", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	m, err := svc.Restore(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	logBackup(logger, "restored", m, *in)
+	return nil
+}
+
+// logBackup is a helper which logs a summary of a backup manifest.
+func logBackup(logger *slog.Logger, action string, m *backup.Manifest, archive string) {
+
+	var rows int64
+	for _, t := range m.Tables {
+		rows += t.Rows
+	}
+
+	logger.Info(fmt.Sprintf("successfully %s %d rows in %d tables and %d objects", action, rows, len(m.Tables), len(m.Objects)),
+		"archive", archive,
+		"format_version", m.FormatVersion,
+		"created_at", m.CreatedAt)
+}
+
+// newBackupService is a helper which loads the gallery's config and connects to its database and object storage
+// bucket in the same way as the gallery service, returning the backup service and a func to close the database.
+func newBackupService() (backup.Service, func() error, error) {
+
+	// service definition & requirements: only the database and object storage are used,
+	// the s2s client certs are the object storage client's certs
+	def := config.SvcDefinition{
+		ServiceName: util.ServiceGallery,
+		Tls:         config.MutualTls,
+		Requires: config.Requires{
+			S2sClient:     true,
+			Db:            true,
+			ObjectStorage: true,
+		},
+	}
+
+	config, err := config.Load(def)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load %s gallery service config: %v", util.ServiceGallery, err)
+	}
+
+	// minio client
+	objStorageConfig := storage.Config{
+		Url:       config.ObjectStorage.Url,
+		Bucket:    config.ObjectStorage.Bucket,
+		AccessKey: config.ObjectStorage.AccessKey,
+		SecretKey: config.ObjectStorage.SecretKey,
+	}
+
+	clientPki := &connect.Pki{
+		CertFile: *config.Certs.ClientCert,
+		KeyFile:  *config.Certs.ClientKey,
+		CaFiles:  []string{*config.Certs.ClientCa},
+	}
+
+	// tls config for minio client
+	minioTlsConfig, err := connect.NewTlsClientConfig(clientPki).Build()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to configure minio client tls: %v", err)
+	}
+	minioTlsConfig.InsecureSkipVerify = true // skip cert verification for minio client since minio may be using self-signed certs
+
+	// signed urls are not used, so the expiry is nominal
+	objStore, err := storage.New(objStorageConfig, minioTlsConfig, 10*time.Minute)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create object storage service: %v", err)
+	}
+
+	// restored objects are streamed rather than buffered in memory
+	multipartStore, err := multipart.New(objStorageConfig, minioTlsConfig, util.MultipartPartUrlExpiry)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create multipart upload object storage service: %v", err)
+	}
+
+	// db client
+	dbClientPki := &connect.Pki{
+		CertFile: *config.Certs.DbClientCert,
+		KeyFile:  *config.Certs.DbClientKey,
+		CaFiles:  []string{*config.Certs.DbCaCert},
+	}
+
+	dbClientConfig, err := connect.NewTlsClientConfig(dbClientPki).Build()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to configure database client tls: %v", err)
+	}
+
+	// db config
+	dbUrl := data.DbUrl{
+		Name:     config.Database.Name,
+		Addr:     config.Database.Url,
+		Username: config.Database.Username,
+		Password: config.Database.Password,
+	}
+
+	db, err := data.NewSqlDbConnector(dbUrl, dbClientConfig).Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to database: %v", err)
+	}
+
+	return backup.NewService(db, config.ObjectStorage.Bucket, objStore, multipartStore), db.Close, nil
+}
@@ -1,12 +1,15 @@
 package album
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
 	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
 
@@ -21,32 +24,128 @@ type AlbumRepository interface {
 	// has permission to view.
 	FindAllowedAlbumsData(psMap map[string]exo.PermissionRecord) ([]api.AlbumImageRecord, error)
 
-	// FindAlbumImagesData retrieves all image meta data for images in the specified album by slug index.
+	// FindAlbumCovers retrieves a single cover album-image record for each of the albums by uuid.
+	// Note: the cover is the most recently added image the user has access to see via permissions.
+	FindAlbumCovers(albumIds []string, psMap map[string]exo.PermissionRecord) ([]api.AlbumImageRecord, error)
+
+	// FindAlbumImagesData retrieves all image meta data for images in the specified album by slug index,
+	// optionally filtered to an inclusive year-month range of the sortable image date key.
 	// Note: it only returns meta data the user has access to see via permissions
 	FindAlbumImagesData(
 		albumSlugIndex string,
 		psMap map[string]exo.PermissionRecord,
+		from, to string,
 	) ([]api.AlbumImageRecord, error)
 
+	// FindAlbumHead retrieves a single album-image record of the album by slug index the user has permission to
+	// view, for the album's fields: the album's selected cover image, if the user may view it.
+	// Returns nil and no error if the user may view no records of the album.
+	FindAlbumHead(albumSlugIndex string, psMap map[string]exo.PermissionRecord) (*api.AlbumImageRecord, error)
+
+	// CountAlbumImages counts the images in the album by slug index the user has permission to view,
+	// optionally filtered to an inclusive year-month range of the sortable image date key.
+	CountAlbumImages(albumSlugIndex string, psMap map[string]exo.PermissionRecord, from, to string) (int, error)
+
+	// FindAlbumImagesPage retrieves a page of the album-image records of the images in the album by slug index
+	// the user has permission to view, after the cursor in the page query's sort order, optionally filtered to
+	// an inclusive year-month range of the sortable image date key.
+	// Note: the limit is passed separately so the caller can ask for an extra record to detect further pages.
+	FindAlbumImagesPage(
+		albumSlugIndex string,
+		psMap map[string]exo.PermissionRecord,
+		from, to string,
+		q api.PageQuery,
+		cursor *api.PageCursor,
+		limit int,
+	) ([]api.AlbumImageRecord, error)
+
+	// FindAllowedAlbumsPage retrieves a page of the album records the user has permission to view by upload date,
+	// after the cursor, oldest first, or newest first if desc is true.
+	// Note: smart albums are left out unless the user is a curator, see BuildAlbumsPageQuery.
+	FindAllowedAlbumsPage(psMap map[string]exo.PermissionRecord, desc bool, cursor *api.PageCursor, limit int) ([]api.AlbumRecord, error)
+
+	// CountAllowedAlbums counts the albums the user has permission to view.
+	// Note: smart albums are left out unless the user is a curator, see BuildAlbumsCountQuery.
+	CountAllowedAlbums(psMap map[string]exo.PermissionRecord) (int, error)
+
+	// FindTimelineImages retrieves a page of the image records with an image date the user has
+	// permission to view, newest first, or oldest first if the query direction is newer.
+	// Note: the limit is passed separately so the caller can ask for an extra record to detect further pages.
+	FindTimelineImages(
+		psMap map[string]exo.PermissionRecord,
+		q api.TimelineQuery,
+		cursor *api.TimelineCursor,
+		limit int,
+	) ([]api.ImageRecord, error)
+
+	// FindTimelineCounts retrieves the number of images with an image date the user has permission to view
+	// for each month, newest first, optionally filtered to an inclusive year-month range.
+	FindTimelineCounts(psMap map[string]exo.PermissionRecord, from, to string) ([]api.TimelineMonthCount, error)
+
+	// FindMemoryCandidates retrieves the published, non-archived image records the user has permission to view
+	// which were taken in one of the months, eg "06", of any year up to and including the latest date key.
+	FindMemoryCandidates(psMap map[string]exo.PermissionRecord, months []string, latest string) ([]api.ImageRecord, error)
+
 	// AlbumExists checks if an album exists by its slug index.
 	AlbumExists(slugIndex string) (bool, error)
 
-	// AlbumIsArchived checks if an album exists but is archived by its slug index.
+	// AlbumIsArchived checks if an album exists but is archived, or is a sub-album of an archived album, by its slug index.
 	AlbumIsArchived(slugIndex string) (bool, error)
 
+	// FindAllAlbums retrieves all album records, regardless of permissions or archive status.
+	// Note: it is not filtered by permissions, so callers must filter the albums before returning them.
+	FindAllAlbums() ([]api.AlbumRecord, error)
+
+	// FindAlbumImageCounts retrieves the number of images the user has permission to view directly in each album.
+	FindAlbumImageCounts(psMap map[string]exo.PermissionRecord) ([]AlbumImageCount, error)
+
+	// UpdateAlbumParent updates the parent album of an album by its uuid.  An empty parent id moves it to the top level.
+	UpdateAlbumParent(albumId, parentId string, updatedAt data.CustomTime) error
+
 	// InsertAlbum inserts an album record into the database.
 	InsertAlbum(album api.AlbumRecord) error
 
-	// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database.
-	InsertAlbumImageXref(xref api.AlbumImageXref) error
+	// InsertAlbumImageXrefsTx inserts album_image xref records for each of the images in a single statement
+	// within a unit of work, skipping any images already in the album.
+	InsertAlbumImageXrefsTx(ctx context.Context, tx transaction.Tx, albumId string, imageIds []string, createdAt data.CustomTime) error
+
+	// InsertInheritedImagePermissionsTx grants each of the images not yet in the album the album's permissions,
+	// if the album passes them on to images added to it, in a single statement within a unit of work.
+	// Permissions an image already has are skipped.
+	InsertInheritedImagePermissionsTx(ctx context.Context, tx transaction.Tx, albumId string, imageIds []string, createdAt data.CustomTime) error
 
-	// UpdateAlbum updates an existing album record in the database.
+	// UpdateAlbum updates an existing album record in the database if it is still at the record's version,
+	// incrementing the version.  Returns false if the version is stale, ie, no record was updated.
 	// Note: not all fields are allowed to be updated.  Also, relevant fields need to be encrypted
 	// before calling this method.
-	UpdateAlbum(album api.AlbumRecord) error
+	UpdateAlbum(album api.AlbumRecord) (bool, error)
 
 	// DeleteAlbumImageXrefs deletes all records in the album_image xref table asssociated with an image uuid.
 	DeleteAlbumImageXrefs(imageId string) error
+
+	// FindAlbumImagePositions retrieves the positions of all of the images in an album by the album's slug index,
+	// in album order: manually ordered images first, then by image date.
+	// Note: it is not filtered by permissions, so it must only be used for curation.
+	FindAlbumImagePositions(albumSlugIndex string) ([]AlbumImagePosition, error)
+
+	// UpdateAlbumImagePositions updates the positions of the album_image xref records in a single statement.
+	UpdateAlbumImagePositions(positions []AlbumImagePosition) error
+
+	// FindSharedAlbumImageXrefs retrieves the album's album_image xref records whose image is also in another album.
+	FindSharedAlbumImageXrefs(albumId string) ([]api.AlbumImageXref, error)
+
+	// UpdateSubAlbumParentsTx moves all of an album's sub-albums to a new parent album, or to the top level if empty,
+	// within a unit of work.
+	UpdateSubAlbumParentsTx(ctx context.Context, tx transaction.Tx, albumId, parentId string, updatedAt data.CustomTime) error
+
+	// DeleteAlbumTx deletes an album record by its uuid, along with its album_image, album_permission,
+	// and smart_album records, within a unit of work.  The images themselves are not deleted.
+	DeleteAlbumTx(ctx context.Context, tx transaction.Tx, albumId string) error
+
+	// DeleteImagesTx deletes image records by uuid, along with their album_image and image_permission records,
+	// and clears any album covers they were selected as, within a unit of work.
+	// Note: the image files must be removed from object storage separately.
+	DeleteImagesTx(ctx context.Context, tx transaction.Tx, imageIds []string) error
 }
 
 // NewAlbumRepository creates a new instance of AlbumRepository interface, returning
@@ -112,21 +211,61 @@ func (a *albumAdapter) FindAllowedAlbumsData(psMap map[string]exo.PermissionReco
 	return data.SelectRecords[api.AlbumImageRecord](a.db, qry, args...)
 }
 
-// FindAlbumImagesData retrieves all image meta data for images in the specified album by slug index.
+// FindAlbumCovers retrieves a single cover album-image record for each of the albums by uuid.
+// Note: the cover is the most recently added image the user has access to see via permissions.
+func (a *albumAdapter) FindAlbumCovers(albumIds []string, psMap map[string]exo.PermissionRecord) ([]api.AlbumImageRecord, error) {
+
+	if len(albumIds) == 0 {
+		return []api.AlbumImageRecord{}, nil
+	}
+
+	// build the album covers query with the users permissions
+	qry, err := BuildAlbumCoversQuery(psMap, len(albumIds))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build album covers query: %v", err)
+	}
+
+	// album uuids first, then the permissions uuids, in the order they appear in the query
+	args := make([]interface{}, 0, len(albumIds)+len(psMap))
+	for _, id := range albumIds {
+		args = append(args, id)
+	}
+	// if user is curator, no need to filter by permissions
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+
+	// execute query
+	return data.SelectRecords[api.AlbumImageRecord](a.db, qry, args...)
+}
+
+// FindAlbumImagesData retrieves all image meta data for images in the specified album by slug index,
+// optionally filtered to an inclusive year-month range of the sortable image date key.
 // Note: it only returns meta data the user has access to see via permissions
 func (a *albumAdapter) FindAlbumImagesData(
 	albumSlugIndex string,
 	psMap map[string]exo.PermissionRecord,
+	from, to string,
 ) ([]api.AlbumImageRecord, error) {
 
 	// build the album query with the users permissions
-	qry, err := BuildAlbumImagesQuery(psMap)
+	qry, err := BuildAlbumImagesQuery(psMap, from, to)
 	if err != nil {
 		return nil, errors.New("failed to create query for album slug")
 	}
 
+	// execute query
+	return data.SelectRecords[api.AlbumImageRecord](a.db, qry, albumImagesArgs(albumSlugIndex, psMap, from, to)...)
+}
+
+// albumImagesArgs is a helper which builds the args for a query built by BuildAlbumImagesQuery,
+// in the order they appear in the query: slug index, permissions, date range.
+func albumImagesArgs(albumSlugIndex string, psMap map[string]exo.PermissionRecord, from, to string) []interface{} {
+
 	// convert the permissions map into a variatic slice of interface{}, ie args ...interface{}
-	args := make([]interface{}, 0, len(psMap)+1) // capacity needs to include the slug index
+	args := make([]interface{}, 0, len(psMap)+3) // capacity needs to include the slug index and date range
 	args = append(args, albumSlugIndex)          // index in first args position
 	// if user is curator, no need to filter by permissions
 	if _, ok := psMap["CURATOR"]; !ok {
@@ -136,8 +275,263 @@ func (a *albumAdapter) FindAlbumImagesData(
 		}
 	}
 
+	// date range last, in the order they appear in the query
+	if from != "" {
+		args = append(args, from)
+	}
+	if to != "" {
+		args = append(args, to)
+	}
+
+	return args
+}
+
+// FindAlbumHead retrieves a single album-image record of the album by slug index the user has permission to
+// view, for the album's fields: the album's selected cover image, if the user may view it.
+// Returns nil and no error if the user may view no records of the album.
+func (a *albumAdapter) FindAlbumHead(albumSlugIndex string, psMap map[string]exo.PermissionRecord) (*api.AlbumImageRecord, error) {
+
+	// the head is found regardless of any date range so the album is found even if the range excludes every image
+	base, err := BuildAlbumImagesQuery(psMap, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to build album images query: %v", err)
+	}
+
+	return selectAlbumHead(a.db, base, albumImagesArgs(albumSlugIndex, psMap, "", ""))
+}
+
+// CountAlbumImages counts the images in the album by slug index the user has permission to view,
+// optionally filtered to an inclusive year-month range of the sortable image date key.
+func (a *albumAdapter) CountAlbumImages(albumSlugIndex string, psMap map[string]exo.PermissionRecord, from, to string) (int, error) {
+
+	base, err := BuildAlbumImagesQuery(psMap, from, to)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build album images query: %v", err)
+	}
+
+	return selectCount(a.db, BuildAlbumImagesCountQuery(base), albumImagesArgs(albumSlugIndex, psMap, from, to))
+}
+
+// FindAlbumImagesPage retrieves a page of the album-image records of the images in the album by slug index
+// the user has permission to view, after the cursor in the page query's sort order, optionally filtered to
+// an inclusive year-month range of the sortable image date key.
+func (a *albumAdapter) FindAlbumImagesPage(
+	albumSlugIndex string,
+	psMap map[string]exo.PermissionRecord,
+	from, to string,
+	q api.PageQuery,
+	cursor *api.PageCursor,
+	limit int,
+) ([]api.AlbumImageRecord, error) {
+
+	base, err := BuildAlbumImagesQuery(psMap, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build album images query: %v", err)
+	}
+
+	return selectAlbumImagesPage(a.db, base, albumImagesArgs(albumSlugIndex, psMap, from, to), q, cursor, limit)
+}
+
+// FindAllowedAlbumsPage retrieves a page of the album records the user has permission to view by upload date,
+// after the cursor, oldest first, or newest first if desc is true.
+func (a *albumAdapter) FindAllowedAlbumsPage(
+	psMap map[string]exo.PermissionRecord,
+	desc bool,
+	cursor *api.PageCursor,
+	limit int,
+) ([]api.AlbumRecord, error) {
+
+	qry, err := BuildAlbumsPageQuery(psMap, desc, cursor != nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build album page query: %v", err)
+	}
+
+	// args in the order they appear in the query: permissions, cursor, limit
+	args := make([]interface{}, 0, len(psMap)+3)
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+	if cursor != nil {
+		args = append(args, cursor.CreatedAt, cursor.Id)
+	}
+	args = append(args, limit)
+
+	return data.SelectRecords[api.AlbumRecord](a.db, qry, args...)
+}
+
+// CountAllowedAlbums counts the albums the user has permission to view.
+func (a *albumAdapter) CountAllowedAlbums(psMap map[string]exo.PermissionRecord) (int, error) {
+
+	qry, err := BuildAlbumsCountQuery(psMap)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build album count query: %v", err)
+	}
+
+	args := make([]interface{}, 0, len(psMap))
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+
+	return selectCount(a.db, qry, args)
+}
+
+// selectAlbumHead is a helper which retrieves the head record of an album-image query, see BuildAlbumHeadQuery.
+// Returns nil and no error if the query has no records.
+func selectAlbumHead(db *sql.DB, base string, args []interface{}) (*api.AlbumImageRecord, error) {
+
+	records, err := data.SelectRecords[api.AlbumImageRecord](db, BuildAlbumHeadQuery(base), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	return &records[0], nil
+}
+
+// selectAlbumImagesPage is a helper which retrieves a page of the images of an album-image query,
+// see BuildAlbumImagesPageQuery.  The args are those of the wrapped query.
+func selectAlbumImagesPage(
+	db *sql.DB,
+	base string,
+	args []interface{},
+	q api.PageQuery,
+	cursor *api.PageCursor,
+	limit int,
+) ([]api.AlbumImageRecord, error) {
+
+	qry, err := BuildAlbumImagesPageQuery(base, q.Sort, q.Desc, cursor != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if cursor != nil {
+		args = append(args, AlbumImagesCursorArgs(q.Sort, *cursor)...)
+	}
+	args = append(args, limit)
+
+	return data.SelectRecords[api.AlbumImageRecord](db, qry, args...)
+}
+
+// selectCount is a helper which executes a count query, see RecordCount.
+func selectCount(db *sql.DB, qry string, args []interface{}) (int, error) {
+
+	counts, err := data.SelectRecords[RecordCount](db, qry, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(counts) == 0 {
+		return 0, nil
+	}
+
+	return counts[0].Count, nil
+}
+
+// FindTimelineImages retrieves a page of the image records with an image date the user has
+// permission to view, newest first, or oldest first if the query direction is newer.
+// Note: the limit is passed separately so the caller can ask for an extra record to detect further pages.
+func (a *albumAdapter) FindTimelineImages(
+	psMap map[string]exo.PermissionRecord,
+	q api.TimelineQuery,
+	cursor *api.TimelineCursor,
+	limit int,
+) ([]api.ImageRecord, error) {
+
+	newer := q.Direction == api.TimelineNewer
+
+	// build the timeline query with the users permissions
+	qry, err := BuildTimelineQuery(psMap, q.From, q.To, q.At, cursor != nil, newer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build timeline query: %v", err)
+	}
+
+	// args in the order they appear in the query
+	args := timelineFilterArgs(psMap, q.From, q.To)
+	if q.At != "" {
+		args = append(args, q.At)
+	}
+	if cursor != nil {
+		args = append(args, cursor.DateKey, cursor.CreatedAt, cursor.Id)
+	}
+	args = append(args, limit)
+
 	// execute query
-	return data.SelectRecords[api.AlbumImageRecord](a.db, qry, args...)
+	return data.SelectRecords[api.ImageRecord](a.db, qry, args...)
+}
+
+// FindTimelineCounts retrieves the number of images with an image date the user has permission to view
+// for each month, newest first, optionally filtered to an inclusive year-month range.
+func (a *albumAdapter) FindTimelineCounts(psMap map[string]exo.PermissionRecord, from, to string) ([]api.TimelineMonthCount, error) {
+
+	// build the timeline counts query with the users permissions
+	qry, err := BuildTimelineCountsQuery(psMap, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build timeline counts query: %v", err)
+	}
+
+	// execute query
+	return data.SelectRecords[api.TimelineMonthCount](a.db, qry, timelineFilterArgs(psMap, from, to)...)
+}
+
+// FindMemoryCandidates retrieves the published, non-archived image records the user has permission to view
+// which were taken in one of the months, eg "06", of any year up to and including the latest date key.
+func (a *albumAdapter) FindMemoryCandidates(
+	psMap map[string]exo.PermissionRecord,
+	months []string,
+	latest string,
+) ([]api.ImageRecord, error) {
+
+	// build the memories query with the users permissions
+	qry, err := BuildMemoriesQuery(psMap, len(months))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build memories query: %v", err)
+	}
+
+	// args in the order they appear in the query: months, latest date key, permissions
+	args := make([]interface{}, 0, len(months)+len(psMap)+1)
+	for _, m := range months {
+		args = append(args, m)
+	}
+	args = append(args, latest)
+	// if user is curator, no need to filter by permissions
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+
+	// execute query
+	return data.SelectRecords[api.ImageRecord](a.db, qry, args...)
+}
+
+// timelineFilterArgs is a helper which builds the args for the timeline filters,
+// in the order they appear in the query: permission uuids, from, to.
+func timelineFilterArgs(psMap map[string]exo.PermissionRecord, from, to string) []interface{} {
+
+	args := make([]interface{}, 0, len(psMap)+6) // capacity includes the date range, at, cursor, and limit
+	// if user is curator, no need to filter by permissions
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+
+	// a range from the start of a year includes the images known only to that year
+	if from != "" {
+		args = append(args, api.ImageDateKeyLowerBound(from))
+	}
+	if to != "" {
+		args = append(args, to)
+	}
+
+	return args
 }
 
 // AlbumExists checks if an album exists by its slug index.
@@ -153,20 +547,84 @@ func (a *albumAdapter) AlbumExists(slugIndex string) (bool, error) {
 	return data.SelectExists(a.db, qry, slugIndex)
 }
 
-// AlbumIsArchived checks if an album exists but is archived by its slug index.
+// AlbumIsArchived checks if an album exists but is archived, or is a sub-album of an archived album, by its slug index.
 func (a *albumAdapter) AlbumIsArchived(slugIndex string) (bool, error) {
 
+	// walk up the album's parents: archiving a parent album hides all of its children
 	qry := `
+		WITH RECURSIVE ancestors AS (
+			SELECT uuid, parent_uuid, is_archived
+			FROM album
+			WHERE slug_index = ?
+			UNION
+			SELECT p.uuid, p.parent_uuid, p.is_archived
+			FROM album p
+				INNER JOIN ancestors c ON p.uuid = c.parent_uuid
+		)
 		SELECT EXISTS (
 			SELECT 1
-			FROM album
-			WHERE slug_index = ? 
-				AND is_archived = TRUE
+			FROM ancestors
+			WHERE is_archived = TRUE
 		)`
 
 	return data.SelectExists(a.db, qry, slugIndex)
 }
 
+// FindAllAlbums retrieves all album records, regardless of permissions or archive status.
+// Note: it is not filtered by permissions, so callers must filter the albums before returning them.
+func (a *albumAdapter) FindAllAlbums() ([]api.AlbumRecord, error) {
+
+	qry := `
+		SELECT
+			uuid,
+			title,
+			description,
+			slug,
+			slug_index,
+			created_at,
+			updated_at,
+			is_archived,
+			cover_image_uuid,
+			parent_uuid,
+			version
+		FROM album`
+
+	return data.SelectRecords[api.AlbumRecord](a.db, qry)
+}
+
+// FindAlbumImageCounts retrieves the number of images the user has permission to view directly in each album.
+func (a *albumAdapter) FindAlbumImageCounts(psMap map[string]exo.PermissionRecord) ([]AlbumImageCount, error) {
+
+	// build the counts query with the users permissions
+	qry, err := BuildAlbumImageCountsQuery(psMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build album image counts query: %v", err)
+	}
+
+	// if user is curator, no need to filter by permissions
+	args := make([]interface{}, 0, len(psMap))
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+
+	return data.SelectRecords[AlbumImageCount](a.db, qry, args...)
+}
+
+// UpdateAlbumParent updates the parent album of an album by its uuid.  An empty parent id moves it to the top level.
+func (a *albumAdapter) UpdateAlbumParent(albumId, parentId string, updatedAt data.CustomTime) error {
+
+	qry := `
+		UPDATE album
+		SET
+			parent_uuid = ?,
+			updated_at = ?
+		WHERE uuid = ?`
+
+	return data.UpdateRecord(a.db, qry, parentId, updatedAt, albumId)
+}
+
 // InsertAlbum inserts an album record into the database.
 func (a *albumAdapter) InsertAlbum(album api.AlbumRecord) error {
 
@@ -179,30 +637,107 @@ func (a *albumAdapter) InsertAlbum(album api.AlbumRecord) error {
 			slug_index,
 			created_at,
 			updated_at,
-			is_archived
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+			is_archived,
+			cover_image_uuid,
+			parent_uuid,
+			version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	return data.InsertRecord(a.db, qry, album)
 }
 
-// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database.
-func (a *albumAdapter) InsertAlbumImageXref(xref api.AlbumImageXref) error {
+// InsertAlbumImageXrefsTx inserts album_image xref records for each of the images in a single statement
+// within a unit of work, skipping any images already in the album.
+func (a *albumAdapter) InsertAlbumImageXrefsTx(
+	ctx context.Context,
+	tx transaction.Tx,
+	albumId string,
+	imageIds []string,
+	createdAt data.CustomTime,
+) error {
+
+	if len(imageIds) == 0 {
+		return nil
+	}
 
-	qry := `
+	var qb strings.Builder
+	qb.WriteString(`
 		INSERT INTO album_image (
-			id,
 			album_uuid,
 			image_uuid,
 			created_at
-		) VALUES (?, ?, ?, ?)`
+		) VALUES `)
+
+	args := make([]interface{}, 0, len(imageIds)*3)
+	for i, id := range imageIds {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("(?, ?, ?)")
+		args = append(args, albumId, id, createdAt)
+	}
+
+	// the pair is unique, so images already in the album are left as they are, including their position
+	qb.WriteString(`
+		ON DUPLICATE KEY UPDATE id = id`)
+
+	return transaction.UpdateRecord(ctx, tx, qb.String(), args...)
+}
+
+// InsertInheritedImagePermissionsTx grants each of the images not yet in the album the album's permissions,
+// if the album passes them on to images added to it, in a single statement within a unit of work.
+// Permissions an image already has are skipped.
+// Note: it must run before the images are added to the album: images already in it have already inherited
+// the album's permissions, and re-granting them would undo any permission a curator has since removed.
+func (a *albumAdapter) InsertInheritedImagePermissionsTx(
+	ctx context.Context,
+	tx transaction.Tx,
+	albumId string,
+	imageIds []string,
+	createdAt data.CustomTime,
+) error {
+
+	if len(imageIds) == 0 {
+		return nil
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+		INSERT INTO image_permission (
+			image_uuid,
+			permission_uuid,
+			created_at
+		)
+		SELECT i.uuid, ap.permission_uuid, ?
+		FROM image i
+			INNER JOIN album_permission ap ON ap.album_uuid = ?
+			INNER JOIN album a ON ap.album_uuid = a.uuid
+		WHERE a.inherit_permissions = TRUE
+			AND NOT EXISTS (
+				SELECT 1
+				FROM album_image ai
+				WHERE ai.album_uuid = ap.album_uuid
+					AND ai.image_uuid = i.uuid
+			)
+			AND i.uuid IN (`)
+	writePlaceholders(&qb, len(imageIds))
+	qb.WriteString(`)
+		ON DUPLICATE KEY UPDATE id = id`) // no-op update to skip permissions the image already has
+
+	args := make([]interface{}, 0, len(imageIds)+2)
+	args = append(args, createdAt, albumId)
+	for _, id := range imageIds {
+		args = append(args, id)
+	}
 
-	return data.InsertRecord(a.db, qry, xref)
+	return transaction.UpdateRecord(ctx, tx, qb.String(), args...)
 }
 
-// UpdateAlbum updates an existing album record in the database.
+// UpdateAlbum updates an existing album record in the database if it is still at the record's version,
+// incrementing the version.  Returns false if the version is stale, ie, no record was updated.
 // Note: not all fields are allowed to be updated.  Also, relevant fields need to be encrypted
 // before calling this method.
-func (a *albumAdapter) UpdateAlbum(album api.AlbumRecord) error {
+func (a *albumAdapter) UpdateAlbum(album api.AlbumRecord) (bool, error) {
 
 	qry := `
 		UPDATE album
@@ -210,18 +745,33 @@ func (a *albumAdapter) UpdateAlbum(album api.AlbumRecord) error {
 			title = ?,
 			description = ?,
 			is_archived = ?,
-			updated_at = ?
-		WHERE slug_index = ?`
-
-	return data.UpdateRecord(
-		a.db,
+			cover_image_uuid = ?,
+			updated_at = ?,
+			version = version + 1
+		WHERE slug_index = ?
+			AND version = ?`
+
+	// data.UpdateRecord does not return the result, which is needed to detect a stale version
+	result, err := a.db.Exec(
 		qry,
-		album.Title,       // to update
-		album.Description, // to update
-		album.IsArchived,  // to update
-		album.UpdatedAt,   // to update
-		album.SlugIndex,   // where clause
+		album.Title,        // to update
+		album.Description,  // to update
+		album.IsArchived,   // to update
+		album.CoverImageId, // to update
+		album.UpdatedAt,    // to update
+		album.SlugIndex,    // where clause
+		album.Version,      // where clause
 	)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
 }
 
 // DeleteAlbumImageXrefs deletes all records in the album_image xref table asssociated with an image uuid.
@@ -233,3 +783,146 @@ func (a *albumAdapter) DeleteAlbumImageXrefs(imageId string) error {
 
 	return data.DeleteRecord(a.db, qry, imageId)
 }
+
+// FindAlbumImagePositions retrieves the positions of all of the images in an album by the album's slug index,
+// in album order: manually ordered images first, then by image date.
+// Note: it is not filtered by permissions, so it must only be used for curation.
+func (a *albumAdapter) FindAlbumImagePositions(albumSlugIndex string) ([]AlbumImagePosition, error) {
+
+	qry := `
+		SELECT
+			ai.id,
+			ai.image_uuid,
+			i.slug_index,
+			ai.position
+		FROM album_image ai
+			LEFT OUTER JOIN album a ON ai.album_uuid = a.uuid
+			LEFT OUTER JOIN image i ON ai.image_uuid = i.uuid
+		WHERE a.slug_index = ?
+		ORDER BY ai.position = 0, ai.position, i.image_date_key, i.created_at, i.uuid`
+
+	return data.SelectRecords[AlbumImagePosition](a.db, qry, albumSlugIndex)
+}
+
+// UpdateAlbumImagePositions updates the positions of the album_image xref records in a single statement
+// so that an album is never left partially reordered.
+func (a *albumAdapter) UpdateAlbumImagePositions(positions []AlbumImagePosition) error {
+
+	if len(positions) == 0 {
+		return nil
+	}
+
+	// build the case statement and the in clause as variables/params
+	var qb strings.Builder
+	qb.WriteString(`
+		UPDATE album_image
+		SET position = CASE id`)
+
+	args := make([]interface{}, 0, len(positions)*3)
+	for _, p := range positions {
+		qb.WriteString(" WHEN ? THEN ?")
+		args = append(args, p.XrefId, p.Position)
+	}
+
+	qb.WriteString(`
+			ELSE position
+		END
+		WHERE id IN (`)
+	for i, p := range positions {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+		args = append(args, p.XrefId)
+	}
+	qb.WriteString(")")
+
+	return data.UpdateRecord(a.db, qb.String(), args...)
+}
+
+// FindSharedAlbumImageXrefs retrieves the album's album_image xref records whose image is also in another album.
+func (a *albumAdapter) FindSharedAlbumImageXrefs(albumId string) ([]api.AlbumImageXref, error) {
+
+	qry := `
+		SELECT
+			ai.id,
+			ai.album_uuid,
+			ai.image_uuid,
+			ai.created_at
+		FROM album_image ai
+		WHERE ai.album_uuid = ?
+			AND EXISTS (
+				SELECT 1
+				FROM album_image other
+				WHERE other.image_uuid = ai.image_uuid
+					AND other.album_uuid <> ai.album_uuid
+			)`
+
+	return data.SelectRecords[api.AlbumImageXref](a.db, qry, albumId)
+}
+
+// UpdateSubAlbumParentsTx moves all of an album's sub-albums to a new parent album, or to the top level if empty,
+// within a unit of work.
+func (a *albumAdapter) UpdateSubAlbumParentsTx(ctx context.Context, tx transaction.Tx, albumId, parentId string, updatedAt data.CustomTime) error {
+
+	qry := `
+		UPDATE album SET
+			parent_uuid = ?,
+			updated_at = ?
+		WHERE parent_uuid = ?`
+
+	return transaction.UpdateRecord(ctx, tx, qry, parentId, updatedAt, albumId)
+}
+
+// DeleteAlbumTx deletes an album record by its uuid, along with its album_image, album_permission,
+// and smart_album records, within a unit of work.  The images themselves are not deleted.
+// Note: the xref records are deleted first so the foreign keys are satisfied.
+func (a *albumAdapter) DeleteAlbumTx(ctx context.Context, tx transaction.Tx, albumId string) error {
+
+	for _, qry := range []string{
+		`DELETE FROM album_image WHERE album_uuid = ?`,
+		`DELETE FROM album_permission WHERE album_uuid = ?`,
+		`DELETE FROM smart_album WHERE album_uuid = ?`,
+		`DELETE FROM album WHERE uuid = ?`,
+	} {
+		if err := transaction.DeleteRecord(ctx, tx, qry, albumId); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeleteImagesTx deletes image records by uuid, along with their album_image and image_permission records,
+// and clears any album covers they were selected as, within a unit of work.
+// Note: the image files must be removed from object storage separately.
+func (a *albumAdapter) DeleteImagesTx(ctx context.Context, tx transaction.Tx, imageIds []string) error {
+
+	if len(imageIds) == 0 {
+		return nil
+	}
+
+	var in strings.Builder
+	args := make([]interface{}, 0, len(imageIds))
+	for i, id := range imageIds {
+		if i > 0 {
+			in.WriteString(", ")
+		}
+		in.WriteString("?")
+		args = append(args, id)
+	}
+
+	// the xref records are deleted first so the foreign keys are satisfied
+	for _, qry := range []string{
+		`UPDATE album SET cover_image_uuid = '' WHERE cover_image_uuid IN (` + in.String() + `)`,
+		`DELETE FROM album_image WHERE image_uuid IN (` + in.String() + `)`,
+		`DELETE FROM image_permission WHERE image_uuid IN (` + in.String() + `)`,
+		`DELETE FROM image WHERE uuid IN (` + in.String() + `)`,
+	} {
+		if err := transaction.DeleteRecord(ctx, tx, qry, args...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
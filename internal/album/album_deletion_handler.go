@@ -0,0 +1,172 @@
+package album
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// scopes required to delete albums, and, in delete_images mode, the images which belong to no other album
+var (
+	deleteAlbumAllowed       = []string{"d:pixie:*", "d:pixie:albums:*"}
+	deleteAlbumImagesAllowed = []string{"d:pixie:*", "d:pixie:images:*"}
+)
+
+// handleDeleteAlbum handles deleting an album, unlinking, moving, or deleting its images according to the
+// requested mode, or reporting what would happen in a dry run.
+func (h *albumHandler) handleDeleteAlbum(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(deleteAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(deleteAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	q, err := parseAlbumDeletionQuery(r)
+	if err != nil {
+		log.Error("failed to parse album deletion query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := q.Validate(); err != nil {
+		log.Error("failed to validate album deletion query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// deleting images also requires the scope to delete images, even for a dry run,
+	// so the dry run reports what the user is actually allowed to do
+	if q.Mode == api.AlbumDeleteImages {
+		if _, err := h.iam.BuildAuthorized(deleteAlbumImagesAllowed, iamToken); err != nil {
+			log.Error("failed to validate iam token for deleting images", "err", err.Error())
+			connect.RespondAuthFailure(connect.User, err, w)
+			return
+		}
+	}
+
+	// only curators may delete albums
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "delete albums", w) {
+		log.Error(fmt.Sprintf("user is not permitted to delete album slug %s", slug))
+		return
+	}
+
+	result, err := h.svc.DeleteAlbum(ctx, slug, *q)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to delete album slug %s", slug), "err", err.Error())
+		h.respondAlbumDeletionErr(err, slug, w)
+		return
+	}
+
+	if result.Applied {
+		log.Info(fmt.Sprintf("successfully deleted album slug %s in mode %s", slug, q.Mode))
+	} else {
+		log.Info(fmt.Sprintf("successfully previewed deleting album slug %s in mode %s", slug, q.Mode))
+	}
+
+	connect.SendJsonSuccess(w, http.StatusOK, result)
+}
+
+// parseAlbumDeletionQuery is a helper which parses the album deletion query parameters from the request.
+func parseAlbumDeletionQuery(r *http.Request) (*api.AlbumDeletionQuery, error) {
+
+	params := r.URL.Query()
+
+	q := &api.AlbumDeletionQuery{
+		Mode:       params.Get("mode"),
+		TargetSlug: params.Get("target"),
+		Confirm:    params.Get("confirm"),
+	}
+
+	if d := params.Get("dry_run"); d != "" {
+		dryRun, err := strconv.ParseBool(d)
+		if err != nil {
+			return nil, fmt.Errorf("dry_run must be true or false")
+		}
+		q.DryRun = dryRun
+	}
+
+	return q, nil
+}
+
+// respondAlbumDeletionErr is a helper which maps album deletion service errors to http responses.
+func (h *albumHandler) respondAlbumDeletionErr(err error, slug string, w http.ResponseWriter) {
+
+	switch {
+	case strings.Contains(err.Error(), "was not found"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("album %s was not found", slug),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "year album"),
+		strings.Contains(err.Error(), "smart album"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusConflict,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "does not exist"),
+		strings.Contains(err.Error(), "cannot move"),
+		strings.Contains(err.Error(), "invalid"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	default:
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to delete album",
+		}
+		e.SendJsonErr(w)
+	}
+}
@@ -0,0 +1,314 @@
+package album
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/data"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/transaction"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// DeleteAlbum implements the Service interface method to delete an album, unlinking, moving, or deleting its
+// images according to the query's mode, and moving its sub-albums up to its parent.
+func (s *albumService) DeleteAlbum(ctx context.Context, slug string, q api.AlbumDeletionQuery) (*api.AlbumDeletion, error) {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for DeleteAlbum")
+	}
+
+	// redundant checks, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	// curator access returns every image, and the album row even if it has no images
+	curator := map[string]exo.PermissionRecord{util.PermissionCurator: {}}
+	records, err := s.db.FindAlbumImagesData(slugIndex, curator, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve album-image records for album slug %s: %v", slug, err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("album %s was not found", slug)
+	}
+
+	album := &api.Album{
+		Id:          records[0].AlbumId,
+		Title:       records[0].AlbumTitle,
+		Description: records[0].AlbumDescription,
+		Slug:        records[0].AlbumSlug,
+		ParentId:    records[0].AlbumParentId,
+	}
+	if err := s.cryptor.DecryptAlbum(album); err != nil {
+		return nil, fmt.Errorf("failed to decrypt album record '%s': %v", album.Id, err)
+	}
+
+	result := &api.AlbumDeletion{
+		AlbumSlug:            slug,
+		AlbumTitle:           album.Title,
+		Mode:                 q.Mode,
+		TargetSlug:           q.TargetSlug,
+		Unlinked:             []api.AlbumDeletionImage{},
+		Moved:                []api.AlbumDeletionImage{},
+		Deleted:              []api.AlbumDeletionImage{},
+		SubAlbums:            []string{},
+		ConfirmationRequired: api.IsYearAlbumTitle(album.Title),
+	}
+
+	// year albums are generated by the image pipeline, and images are linked to them by their image date,
+	// so they must be confirmed by title to protect against deleting them by mistake
+	if result.ConfirmationRequired && !q.DryRun && q.Confirm != album.Title {
+		return nil, fmt.Errorf("album %s is a year album: its deletion must be confirmed with its title", slug)
+	}
+
+	// the target album must exist and be able to hold images
+	var targetId string
+	if q.Mode == api.AlbumDeleteMove {
+		if q.TargetSlug == slug {
+			return nil, fmt.Errorf("album %s cannot move its images to itself", slug)
+		}
+
+		targetIndex, err := s.indexer.ObtainBlindIndex(q.TargetSlug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain blind index for target album slug '%s': %v", q.TargetSlug, err)
+		}
+
+		target, err := s.db.FindAlbumImagesData(targetIndex, curator, "", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve target album slug %s: %v", q.TargetSlug, err)
+		}
+		if len(target) == 0 {
+			return nil, fmt.Errorf("target album slug '%s' does not exist", q.TargetSlug)
+		}
+		targetId = target[0].AlbumId
+
+		if smart, err := s.smart.FindSmartAlbum(targetIndex); err != nil {
+			return nil, fmt.Errorf("failed to retrieve rules of target album slug %s: %v", q.TargetSlug, err)
+		} else if smart != nil {
+			return nil, fmt.Errorf("target album %s is a smart album: it cannot hold images", q.TargetSlug)
+		}
+	}
+
+	images, cmds, err := s.decryptDeletionImages(records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt images of album slug %s: %v", slug, err)
+	}
+
+	// sort the images into what happens to them
+	switch q.Mode {
+	case api.AlbumDeleteUnlink:
+		result.Unlinked = images
+	case api.AlbumDeleteMove:
+		result.Moved = images
+	case api.AlbumDeleteImages:
+		shared, err := s.db.FindSharedAlbumImageXrefs(album.Id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve images of album slug %s in other albums: %v", slug, err)
+		}
+
+		inOtherAlbums := make(map[string]bool, len(shared))
+		for _, x := range shared {
+			inOtherAlbums[x.ImageId] = true
+		}
+
+		for _, img := range images {
+			if inOtherAlbums[img.Id] {
+				result.Unlinked = append(result.Unlinked, img)
+			} else {
+				result.Deleted = append(result.Deleted, img)
+			}
+		}
+	}
+
+	// sub-albums are kept, moved up to the deleted album's parent
+	subAlbums, err := s.findSubAlbums(album.Id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve sub-albums of album slug %s: %v", slug, err)
+	}
+	result.SubAlbums = subAlbums
+
+	if q.DryRun {
+		log.Info(fmt.Sprintf("dry run of deleting album slug %s in mode %s", slug, q.Mode))
+		return result, nil
+	}
+
+	now := data.CustomTime{Time: time.Now().UTC()}
+
+	// images, sub-albums, and the album are changed in one unit of work, so a failure leaves
+	// the album in place to retry; image files are only queued for deletion once it commits
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+
+		if len(result.Moved) > 0 {
+			ids := make([]string, 0, len(result.Moved))
+			for _, img := range result.Moved {
+				ids = append(ids, img.Id)
+			}
+			if err := s.linkAlbumImages(ctx, tx, targetId, ids, now); err != nil {
+				return fmt.Errorf("failed to move images of album slug %s to album slug %s: %v", slug, q.TargetSlug, err)
+			}
+		}
+
+		if len(result.Deleted) > 0 {
+			ids := make([]string, 0, len(result.Deleted))
+			for _, img := range result.Deleted {
+				ids = append(ids, img.Id)
+			}
+			for batch := range slices.Chunk(ids, albumImageBatchSize) {
+				if err := s.db.DeleteImagesTx(ctx, tx, batch); err != nil {
+					return fmt.Errorf("failed to delete images of album slug %s: %v", slug, err)
+				}
+			}
+		}
+
+		if len(result.SubAlbums) > 0 {
+			if err := s.db.UpdateSubAlbumParentsTx(ctx, tx, album.Id, album.ParentId, now); err != nil {
+				return fmt.Errorf("failed to move sub-albums of album slug %s: %v", slug, err)
+			}
+		}
+
+		if err := s.db.DeleteAlbumTx(ctx, tx, album.Id); err != nil {
+			return fmt.Errorf("failed to delete album slug %s: %v", slug, err)
+		}
+
+		// the image records are gone once committed, so send their files to the deletion pipeline
+		for _, img := range result.Deleted {
+			cmd := cmds[img.Id]
+			tx.AfterCommit(func() {
+				log.Info("sending deletion command to deletion queue", "slug", img.Slug, "id", img.Id)
+				s.delete <- cmd
+			})
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	result.Applied = true
+
+	log.Info(fmt.Sprintf("deleted album slug %s in mode %s: %d images unlinked, %d moved, %d deleted, %d sub-albums moved",
+		slug, q.Mode, len(result.Unlinked), len(result.Moved), len(result.Deleted), len(result.SubAlbums)))
+
+	return result, nil
+}
+
+// decryptDeletionImages is a helper which decrypts the images of an album being deleted, returning them
+// in album order, along with the deletion pipeline command for each, by image id.
+// Note: an album with no images returns a single row with empty image fields, which is skipped.
+func (s *albumService) decryptDeletionImages(records []api.AlbumImageRecord) ([]api.AlbumDeletionImage, map[string]pipeline.DeletionCmd, error) {
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		errCh  = make(chan error, len(records))
+		images = make([]api.AlbumDeletionImage, 0, len(records))
+		cmds   = make(map[string]pipeline.DeletionCmd, len(records))
+	)
+
+	for _, r := range records {
+		if r.ImageId == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(r api.AlbumImageRecord) {
+			defer wg.Done()
+
+			fields := make([]string, 0, 4)
+			for _, ciphertext := range []string{r.ImageTitle, r.ImageSlug, r.FileName, r.ObjectKey} {
+				plaintext, err := s.cryptor.DecryptImageField(ciphertext)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to decrypt image '%s': %v", r.ImageId, err)
+					return
+				}
+				fields = append(fields, plaintext)
+			}
+
+			mu.Lock()
+			images = append(images, api.AlbumDeletionImage{
+				Id:    r.ImageId,
+				Title: fields[0],
+				Slug:  fields[1],
+			})
+			cmds[r.ImageId] = pipeline.DeletionCmd{
+				Id:        r.ImageId,
+				FileName:  fields[2],
+				FileType:  r.FileType,
+				Slug:      fields[1],
+				ObjectKey: fields[3],
+			}
+			mu.Unlock()
+		}(r)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, nil, errors.Join(errs...)
+	}
+
+	// restore the album's image order: decryption is concurrent
+	order := make(map[string]int, len(records))
+	for i, r := range records {
+		order[r.ImageId] = i
+	}
+	slices.SortFunc(images, func(a, b api.AlbumDeletionImage) int {
+		return order[a.Id] - order[b.Id]
+	})
+
+	return images, cmds, nil
+}
+
+// findSubAlbums is a helper which returns the slugs of an album's direct sub-albums, sorted.
+func (s *albumService) findSubAlbums(albumId string) ([]string, error) {
+
+	all, err := s.db.FindAllAlbums()
+	if err != nil {
+		return nil, err
+	}
+
+	slugs := make([]string, 0)
+	for _, a := range all {
+		if a.ParentId != albumId {
+			continue
+		}
+		if err := s.cryptor.DecryptAlbumRecord(&a); err != nil {
+			return nil, fmt.Errorf("failed to decrypt album record '%s': %v", a.Id, err)
+		}
+		slugs = append(slugs, a.Slug)
+	}
+
+	slices.SortFunc(slugs, strings.Compare)
+
+	return slugs, nil
+}
@@ -0,0 +1,185 @@
+package album
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// HandleExport is the concrete implementation of the interface method which handles streaming a zip archive
+// of the images in an album the user may view, as originals or renditions of a chosen width, with a json manifest.
+func (h *albumHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	if r.Method != http.MethodGet {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	q, err := parseAlbumExportQuery(r)
+	if err != nil {
+		log.Error("failed to parse album export query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := q.Validate(); err != nil {
+		log.Error("failed to validate album export query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get the user's permissions
+	ps, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to retrieve permissions for user", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	export, err := h.svc.BuildAlbumExport(ctx, slug, ps, *q)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to build export of album '%s'", slug), "err", err.Error())
+		h.respondAlbumExportErr(err, w)
+		return
+	}
+
+	// once streaming starts, the status and headers have been written and errors can only be logged
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.FileName}))
+	w.Header().Set("Cache-Control", "private, no-store")
+	w.WriteHeader(http.StatusOK)
+
+	if err := h.svc.WriteAlbumExport(ctx, export, w); err != nil {
+		log.Error(fmt.Sprintf("failed while streaming export of album '%s'", slug), "err", err.Error())
+		return
+	}
+
+	log.Info(fmt.Sprintf("successfully exported %d images of album '%s'", len(export.Manifest.Images), slug),
+		"omitted", len(export.Manifest.Omitted))
+}
+
+// parseAlbumExportQuery is a helper which parses the album export query parameters from the request,
+// eg, ?original=true or ?width=1920.
+func parseAlbumExportQuery(r *http.Request) (*api.AlbumExportQuery, error) {
+
+	params := r.URL.Query()
+
+	q := &api.AlbumExportQuery{}
+
+	if o := params.Get("original"); o != "" {
+		original, err := strconv.ParseBool(o)
+		if err != nil {
+			return nil, fmt.Errorf("original must be true or false")
+		}
+		q.Original = original
+	}
+
+	if wd := params.Get("width"); wd != "" {
+		width, err := strconv.Atoi(wd)
+		if err != nil {
+			return nil, fmt.Errorf("width must be a number")
+		}
+		q.Width = width
+	}
+
+	return q, nil
+}
+
+// respondAlbumExportErr is a helper which maps album export service errors to http responses.
+func (h *albumHandler) respondAlbumExportErr(err error, w http.ResponseWriter) {
+
+	switch {
+	case strings.Contains(err.Error(), "no access"),
+		strings.Contains(err.Error(), "does not have permission"),
+		strings.Contains(err.Error(), "archived"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusForbidden,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "not found"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusNotFound,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "invalid"),
+		strings.Contains(err.Error(), "not valid"),
+		strings.Contains(err.Error(), "must be"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	default:
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to export album",
+		}
+		e.SendJsonErr(w)
+	}
+}
@@ -0,0 +1,409 @@
+package album
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// AlbumExport is a model which represents an album export resolved for a user: the images the user may export,
+// in album order, with the object storage keys of their files and their names in the zip archive.
+type AlbumExport struct {
+	FileName string                  // name of the zip archive, from the album title
+	Manifest api.AlbumExportManifest // images are added as their files are written to the archive
+
+	files []albumExportFile
+}
+
+// albumExportFile is a model which represents an image file to be written to an album export archive.
+type albumExportFile struct {
+	image     api.AlbumExportImage
+	objectKey string
+	modified  time.Time
+}
+
+// BuildAlbumExport implements the Service interface method to resolve the export of an album for a user:
+// the images the user may view, and, for originals or renditions wider than view-only users are allowed,
+// may download.  Images the user may view but not export are listed as omitted in the manifest.
+func (s *albumService) BuildAlbumExport(
+	ctx context.Context,
+	slug string,
+	psMap map[string]exo.PermissionRecord,
+	q api.AlbumExportQuery,
+) (*AlbumExport, error) {
+
+	// redundant checks, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	// only stored renditions from the resolution ladder may be exported
+	if !q.Original && !slices.Contains(util.ResolutionWidthsImages, q.Width) {
+		return nil, fmt.Errorf("rendition width %d is not valid: must be one of %v", q.Width, util.ResolutionWidthsImages)
+	}
+
+	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	// smart albums' images are evaluated from their rules rather than their album-image xrefs
+	smart, err := s.smart.FindSmartAlbum(slugIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if album slug %s is a smart album: %v", slug, err)
+	}
+
+	var records []api.AlbumImageRecord
+	if smart != nil {
+		records, err = s.findSmartAlbumImages(*smart, psMap, "", "")
+	} else {
+		records, err = s.db.FindAlbumImagesData(slugIndex, psMap, "", "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve album-image records for album slug %s: %v", slug, err)
+	}
+
+	if len(records) == 0 {
+		if exists, err := s.db.AlbumExists(slugIndex); err != nil {
+			return nil, fmt.Errorf("failed to check if album %s exists: %v", slug, err)
+		} else if !exists {
+			return nil, fmt.Errorf("album %s was not found", slug)
+		}
+
+		if archived, err := s.db.AlbumIsArchived(slugIndex); err != nil {
+			return nil, fmt.Errorf("failed to check if album %s has been archived: %v", slug, err)
+		} else if archived {
+			return nil, fmt.Errorf("album %s is archived", slug)
+		}
+
+		return nil, fmt.Errorf("user has no access to any images in album %s", slug)
+	}
+
+	album := &api.Album{
+		Id:          records[0].AlbumId,
+		Title:       records[0].AlbumTitle,
+		Description: records[0].AlbumDescription,
+		Slug:        records[0].AlbumSlug,
+	}
+	if err := s.cryptor.DecryptAlbum(album); err != nil {
+		return nil, fmt.Errorf("failed to decrypt album record '%s': %v", album.Id, err)
+	}
+
+	images, err := s.decryptExportImages(records)
+	if err != nil {
+		return nil, err
+	}
+
+	// originals, and renditions wider than view-only users are allowed, require download rights
+	requiresDownload := q.Original || q.Width > s.viewOnlyMaxWidth
+	downloadable, err := s.findDownloadableImages(images, psMap, requiresDownload)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &AlbumExport{
+		FileName: util.DownloadFileName(album.Title, "", album.Slug, ".zip"),
+		Manifest: api.AlbumExportManifest{
+			AlbumSlug:        album.Slug,
+			AlbumTitle:       album.Title,
+			AlbumDescription: album.Description,
+			Original:         q.Original,
+			Width:            q.Width,
+			ExportedAt:       time.Now().UTC().Format(time.RFC3339),
+		},
+	}
+
+	// names in the archive must be unique, regardless of case for case-insensitive file systems
+	names := map[string]struct{}{strings.ToLower(api.AlbumExportManifestName): {}}
+	for _, img := range images {
+
+		meta := api.AlbumExportImage{
+			Slug:        img.Slug,
+			Title:       img.Title,
+			Description: img.Description,
+			Width:       img.Width,
+			Height:      img.Height,
+			ImageDate:   img.ImageDate,
+			Date:        img.Date,
+		}
+
+		if _, ok := downloadable[img.Id]; requiresDownload && !ok {
+			meta.Reason = "user does not have permission to download this image"
+			export.Manifest.Omitted = append(export.Manifest.Omitted, meta)
+			continue
+		}
+
+		// renditions are generated by the pipeline, so unprocessed images only have their originals
+		if !q.Original && (img.Width <= 0 || img.Height <= 0) {
+			meta.Reason = "image has not been processed yet"
+			export.Manifest.Omitted = append(export.Manifest.Omitted, meta)
+			continue
+		}
+
+		dir, _, ext, _, err := pipeline.ParseObjectKey(img.ObjectKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object key for image '%s': %v", img.Slug, err)
+		}
+
+		key := img.ObjectKey
+		meta.FileType = img.FileType
+		if !q.Original {
+			// renditions are always re-encoded as jpeg by the pipeline, regardless of the extension
+			key = fmt.Sprintf("%s/%s_w%d%s", dir, img.Slug, q.Width, ext)
+			meta.FileType = "image/jpeg"
+			ext = ".jpg"
+		}
+
+		meta.FileName = uniqueExportFileName(util.DownloadFileName(img.Title, img.ImageDate, img.Slug, ext), names)
+
+		modified, err := time.Parse(time.RFC3339, img.ImageDate)
+		if err != nil {
+			modified = time.Now().UTC()
+		}
+
+		export.files = append(export.files, albumExportFile{
+			image:     meta,
+			objectKey: key,
+			modified:  modified,
+		})
+	}
+
+	// the user may view the album, but may not export anything from it
+	if len(export.files) == 0 && requiresDownload {
+		return nil, fmt.Errorf("user does not have permission to download any images in album %s", slug)
+	}
+
+	return export, nil
+}
+
+// WriteAlbumExport implements the Service interface method to stream the album export as a zip archive
+// to w, reading each image file straight from object storage, followed by the json manifest.
+// Image files which cannot be read are listed as omitted in the manifest rather than failing the export.
+func (s *albumService) WriteAlbumExport(ctx context.Context, export *AlbumExport, w io.Writer) error {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for WriteAlbumExport")
+	}
+
+	if export == nil {
+		return fmt.Errorf("album export is required")
+	}
+
+	zw := zip.NewWriter(w)
+
+	export.Manifest.Images = make([]api.AlbumExportImage, 0, len(export.files))
+	for _, f := range export.files {
+
+		// stop if the client has gone away
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// once a file's entry is started, a failure leaves the archive corrupt, so it cannot be skipped
+		started := false
+		if err := s.store.WithObject(ctx, f.objectKey, func(r storage.ReadSeekCloser) error {
+			started = true
+
+			// image files are already compressed, so they are stored as is
+			fw, err := zw.CreateHeader(&zip.FileHeader{
+				Name:     f.image.FileName,
+				Method:   zip.Store,
+				Modified: f.modified,
+			})
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(fw, r)
+			return err
+		}); err != nil {
+			if started {
+				return fmt.Errorf("failed to write image '%s' to album export: %v", f.image.Slug, err)
+			}
+
+			log.Error(fmt.Sprintf("failed to read image '%s' file '%s' for album export", f.image.Slug, f.objectKey), "err", err.Error())
+
+			omitted := f.image
+			omitted.FileName = ""
+			omitted.FileType = ""
+			omitted.Reason = "image file could not be read from object storage"
+			export.Manifest.Omitted = append(export.Manifest.Omitted, omitted)
+			continue
+		}
+
+		export.Manifest.Images = append(export.Manifest.Images, f.image)
+	}
+
+	// the manifest is written last so it reflects the files actually in the archive
+	mw, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     api.AlbumExportManifestName,
+		Method:   zip.Deflate,
+		Modified: time.Now().UTC(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create album export manifest: %v", err)
+	}
+
+	enc := json.NewEncoder(mw)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(export.Manifest); err != nil {
+		return fmt.Errorf("failed to write album export manifest: %v", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish album export archive: %v", err)
+	}
+
+	return nil
+}
+
+// decryptExportImages is a helper which concurrently decrypts the album-image records' image fields,
+// one per image, in album order.
+// Note: like building an album's image data, records with empty image fields are skipped.
+func (s *albumService) decryptExportImages(records []api.AlbumImageRecord) ([]api.ImageData, error) {
+
+	seen := make(map[string]struct{}, len(records))
+	images := make([]api.ImageData, 0, len(records))
+	dated := make([]api.AlbumImageRecord, 0, len(records)) // the date precision of each image, which is not encrypted
+	for _, r := range records {
+		if r.ImageId == "" {
+			continue
+		}
+		if _, ok := seen[r.ImageId]; ok {
+			continue
+		}
+		seen[r.ImageId] = struct{}{}
+
+		images = append(images, api.ImageData{
+			Id:          r.ImageId,
+			Title:       r.ImageTitle,
+			Description: r.ImageDescription,
+			FileName:    r.FileName,
+			FileType:    r.FileType,
+			ObjectKey:   r.ObjectKey,
+			Slug:        r.ImageSlug,
+			Width:       r.Width,
+			Height:      r.Height,
+			Size:        r.Size,
+			ImageDate:   r.ImageDate,
+			CreatedAt:   r.ImageCreatedAt,
+			UpdatedAt:   r.ImageUpdatedAt,
+
+			BlurHash:      r.BlurHash,
+			DominantColor: r.DominantColor,
+		})
+		dated = append(dated, r)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		errCh = make(chan error, len(images))
+	)
+
+	for i := range images {
+		wg.Add(1)
+		go func(img *api.ImageData, r api.AlbumImageRecord) {
+			defer wg.Done()
+
+			if err := s.cryptor.DecryptImageData(img); err != nil {
+				errCh <- fmt.Errorf("failed to decrypt image data '%s': %v", img.Id, err)
+				return
+			}
+
+			// the structured date can only be built from the decrypted image date
+			img.Date = api.BuildPartialDate(img.ImageDate, r.ImageDatePrecision, r.ImageDateEndYear)
+		}(&images[i], dated[i])
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for err := range errCh {
+			errs = append(errs, err)
+		}
+		return nil, fmt.Errorf("failed to decrypt album export images: %v", errs)
+	}
+
+	return images, nil
+}
+
+// findDownloadableImages is a helper which returns the set of image ids the user may download the originals of,
+// if download rights are required: curators and holders of the download original permission may download any image.
+func (s *albumService) findDownloadableImages(
+	images []api.ImageData,
+	psMap map[string]exo.PermissionRecord,
+	required bool,
+) (map[string]struct{}, error) {
+
+	downloadable := make(map[string]struct{}, len(images))
+	if !required {
+		return downloadable, nil
+	}
+
+	_, curator := psMap[util.PermissionCurator]
+	_, downloader := psMap[util.PermissionDownloadOriginal]
+	if curator || downloader {
+		for _, img := range images {
+			downloadable[img.Id] = struct{}{}
+		}
+		return downloadable, nil
+	}
+
+	ids := make([]string, 0, len(images))
+	for _, img := range images {
+		ids = append(ids, img.Id)
+	}
+
+	found, err := s.export.FindDownloadableImageIds(ids, psMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check which album images are downloadable: %v", err)
+	}
+
+	return found, nil
+}
+
+// uniqueExportFileName is a helper which numbers a file name if it is already taken in the archive,
+// eg, "2024-07-04 Fireworks (2).jpg", and records it as taken.
+func uniqueExportFileName(name string, taken map[string]struct{}) string {
+
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := taken[strings.ToLower(unique)]; !ok {
+			break
+		}
+		unique = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+
+	taken[strings.ToLower(unique)] = struct{}{}
+
+	return unique
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +14,8 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
 	"github.com/tdeslauriers/carapace/pkg/data"
 	"github.com/tdeslauriers/carapace/pkg/jwt"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/validate"
 	"github.com/tdeslauriers/pixie/internal/permission"
 	"github.com/tdeslauriers/pixie/internal/util"
 	"github.com/tdeslauriers/pixie/pkg/api"
@@ -29,6 +32,24 @@ type Handler interface {
 
 	// HandleAlbums handles requests related to albums.
 	HandleAlbums(w http.ResponseWriter, r *http.Request)
+
+	// HandleOrder handles requests to manually order the images within an album.
+	HandleOrder(w http.ResponseWriter, r *http.Request)
+
+	// HandleParent handles requests to move an album into a parent album, or to the top level.
+	HandleParent(w http.ResponseWriter, r *http.Request)
+
+	// HandlePermissions handles requests to view and change the permissions granted at the album level.
+	HandlePermissions(w http.ResponseWriter, r *http.Request)
+
+	// HandleSmartRules handles requests to view, set, and remove the rules of a smart album.
+	HandleSmartRules(w http.ResponseWriter, r *http.Request)
+
+	// HandleFreeze handles requests to freeze a smart album into a regular album.
+	HandleFreeze(w http.ResponseWriter, r *http.Request)
+
+	// HandleExport handles requests to download the images in an album the user may view as a zip archive.
+	HandleExport(w http.ResponseWriter, r *http.Request)
 }
 
 // NewHandler creates a new Handler instance and returns a pointer to the concrete implementation.
@@ -83,6 +104,9 @@ func (h *albumHandler) HandleAlbums(w http.ResponseWriter, r *http.Request) {
 		case "staged":
 			h.handleGetStagedImages(w, r)
 			return
+		case "tree":
+			h.handleGetAlbumTree(w, r)
+			return
 		default:
 			h.handleGetAlbum(w, r)
 			return
@@ -93,6 +117,9 @@ func (h *albumHandler) HandleAlbums(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		h.handleUpdateAlbum(w, r)
 		return
+	case http.MethodDelete:
+		h.handleDeleteAlbum(w, r)
+		return
 
 	default:
 		// Handle unsupported methods
@@ -152,6 +179,57 @@ func (h *albumHandler) handleGetAlbums(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// parse pagination parameters, if present
+	page, err := util.ParsePageQuery(r)
+	if err != nil {
+		log.Error("failed to parse album page query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// paginated response: a page of albums with a single cover image each
+	if page != nil {
+		result, err := h.svc.GetAllowedAlbumsPage(ctx, ps, *page)
+		if err != nil {
+			log.Error("failed to retrieve page of albums", "err", err.Error())
+			if strings.Contains(err.Error(), "not valid") ||
+				strings.Contains(err.Error(), "must be") ||
+				strings.Contains(err.Error(), "not well-formed") {
+				e := connect.ErrorHttp{
+					StatusCode: http.StatusUnprocessableEntity,
+					Message:    err.Error(),
+				}
+				e.SendJsonErr(w)
+				return
+			}
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "failed to retrieve albums",
+			}
+			e.SendJsonErr(w)
+			return
+		}
+
+		log.Info(fmt.Sprintf("successfully retrieved page of %d of %d albums", len(result.Albums), result.Total))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if err := json.NewEncoder(w).Encode(result); err != nil {
+			log.Error("failed to encode album page to json", "err", err.Error())
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "failed to encode album page to json",
+			}
+			e.SendJsonErr(w)
+			return
+		}
+		return
+	}
+
 	_, albums, err := h.svc.GetAllowedAlbumsData(ctx, ps)
 	if err != nil {
 		log.Error("failed to retrieve albums", "err", err.Error())
@@ -179,6 +257,75 @@ func (h *albumHandler) handleGetAlbums(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// handleGetAlbumTree handles the retrieval of the album tree: the albums a user has permission to view,
+// nested under their parent albums, with image counts.
+func (h *albumHandler) handleGetAlbumTree(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get the user's permissions
+	ps, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to retrieve permissions for user", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	tree, err := h.svc.GetAlbumTree(ctx, ps)
+	if err != nil {
+		log.Error("failed to retrieve album tree", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve album tree",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	log.Info(fmt.Sprintf("successfully retrieved album tree with %d top level albums", len(tree)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(tree); err != nil {
+		log.Error("failed to encode album tree to json", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to encode album tree to json",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
 // handleGetAlbum handles the retrieval of a specific album record.
 func (h *albumHandler) handleGetAlbum(w http.ResponseWriter, r *http.Request) {
 
@@ -233,8 +380,20 @@ func (h *albumHandler) handleGetAlbum(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// parse pagination parameters for the album's images, if present
+	page, err := util.ParsePageQuery(r)
+	if err != nil {
+		log.Error("failed to parse album images page query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
 	// retrieve the album record
-	album, err := h.svc.GetAlbumBySlug(ctx, slug, ps)
+	album, err := h.svc.GetAlbumBySlug(ctx, slug, ps, page)
 	if err != nil {
 		log.Error(fmt.Sprintf("failed to retrieve album '%s' for user", slug), "err", err.Error())
 		switch {
@@ -253,7 +412,10 @@ func (h *albumHandler) handleGetAlbum(w http.ResponseWriter, r *http.Request) {
 			}
 			e.SendJsonErr(w)
 			return
-		case strings.Contains(err.Error(), "invalid"):
+		case strings.Contains(err.Error(), "invalid"),
+			strings.Contains(err.Error(), "not valid"),
+			strings.Contains(err.Error(), "must be"),
+			strings.Contains(err.Error(), "not well-formed"):
 			e := connect.ErrorHttp{
 				StatusCode: http.StatusUnprocessableEntity,
 				Message:    err.Error(),
@@ -272,6 +434,8 @@ func (h *albumHandler) handleGetAlbum(w http.ResponseWriter, r *http.Request) {
 
 	log.Info(fmt.Sprintf("successfully retrieved album '%s'", album.Title))
 
+	// the version is returned as the ETag so it can be sent back as If-Match when editing the album
+	w.Header().Set("ETag", util.VersionETag(album.Version))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	if err := json.NewEncoder(w).Encode(album); err != nil {
@@ -420,6 +584,28 @@ func (h *albumHandler) handleUpdateAlbum(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// edits must be based on the current version of the album, sent back as If-Match
+	if r.Header.Get("If-Match") == "" {
+		log.Error(fmt.Sprintf("missing If-Match header for update of album slug %s", slug))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusPreconditionRequired,
+			Message:    "If-Match header with the album's ETag is required",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	version, err := util.ParseIfMatch(r)
+	if err != nil {
+		log.Error("failed to parse If-Match header", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
 	// decode the request body into an cmd record
 	var cmd api.AlbumUpdateCmd
 	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
@@ -456,7 +642,7 @@ func (h *albumHandler) handleUpdateAlbum(w http.ResponseWriter, r *http.Request)
 	}
 
 	// lookup the existing album record to ensure it exists
-	existing, err := h.svc.GetAlbumBySlug(ctx, slug, ps)
+	existing, err := h.svc.GetAlbumBySlug(ctx, slug, ps, nil)
 	if err != nil {
 		log.Error("failed to retrieve existing album", "err", err.Error())
 		e := connect.ErrorHttp{
@@ -467,20 +653,59 @@ func (h *albumHandler) handleUpdateAlbum(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// the edit was based on a stale read of the album
+	if existing.Version != version {
+		log.Error(fmt.Sprintf("If-Match version %d does not match album slug %s version %d", version, slug, existing.Version))
+		h.respondAlbumPreconditionFailed(existing, w)
+		return
+	}
+
+	// the cover image must be one of the album's images
+	if cmd.CoverImageId != "" && !slices.ContainsFunc(existing.Images, func(img api.ImageData) bool {
+		return img.Id == cmd.CoverImageId
+	}) {
+		log.Error(fmt.Sprintf("cover image %s is not in album slug %s", cmd.CoverImageId, slug))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    "cover image must be an image in the album",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
 	// build the updated album record
 	// only certain fields can be updated; other fields are immutable and will be ignored
 	updated := api.AlbumRecord{
-		Id:          existing.Id, // immutable
-		Title:       cmd.Title,
-		Description: cmd.Description,
-		IsArchived:  cmd.IsArchived,
-		Slug:        existing.Slug, // slug is immutable -> needed to get blind index
-		UpdatedAt:   data.CustomTime{Time: time.Now().UTC()},
+		Id:           existing.Id, // immutable
+		Title:        cmd.Title,
+		Description:  cmd.Description,
+		IsArchived:   cmd.IsArchived,
+		Slug:         existing.Slug, // slug is immutable -> needed to get blind index
+		UpdatedAt:    data.CustomTime{Time: time.Now().UTC()},
+		CoverImageId: cmd.CoverImageId,
+		Version:      version, // the update only applies if the album is still at this version
 	}
 
 	// update the album record in the database
 	if err := h.svc.UpdateAlbum(updated); err != nil {
 		log.Error("failed to update album record", "err", err.Error())
+
+		// the album was changed between reading and updating it: respond with the current album
+		if strings.Contains(err.Error(), "is stale") {
+			current, err := h.svc.GetAlbumBySlug(ctx, slug, ps, nil)
+			if err != nil {
+				log.Error("failed to retrieve current album", "err", err.Error())
+				e := connect.ErrorHttp{
+					StatusCode: http.StatusInternalServerError,
+					Message:    "failed to retrieve current album",
+				}
+				e.SendJsonErr(w)
+				return
+			}
+			h.respondAlbumPreconditionFailed(current, w)
+			return
+		}
+
 		e := connect.ErrorHttp{
 			StatusCode: http.StatusInternalServerError,
 			Message:    err.Error(),
@@ -510,6 +735,12 @@ func (h *albumHandler) handleUpdateAlbum(w http.ResponseWriter, r *http.Request)
 			slog.Bool("updated_is_archived", updated.IsArchived))
 	}
 
+	if updated.CoverImageId != existing.CoverImageId {
+		changes = append(changes,
+			slog.String("previous_cover_image_id", existing.CoverImageId),
+			slog.String("updated_cover_image_id", updated.CoverImageId))
+	}
+
 	if len(changes) > 0 {
 		log = log.With(changes...)
 		log.Info(fmt.Sprintf("successfully updated album slug %s", slug))
@@ -517,10 +748,527 @@ func (h *albumHandler) handleUpdateAlbum(w http.ResponseWriter, r *http.Request)
 		log.Warn(fmt.Sprintf("update command executed, but no changes were made to album slug %s", slug))
 	}
 
+	// respond 204 No Content with the new version's ETag for the next edit
+	w.Header().Set("ETag", util.VersionETag(version+1))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// respondAlbumPreconditionFailed is a helper which responds 412 with the current album and its ETag,
+// so the client can reapply its edit to the current version.
+func (h *albumHandler) respondAlbumPreconditionFailed(current *api.Album, w http.ResponseWriter) {
+
+	w.Header().Set("ETag", util.VersionETag(current.Version))
+	connect.SendJsonSuccess(w, http.StatusPreconditionFailed, current)
+}
+
+// HandleOrder is the concrete implementation of the interface method which handles requests
+// to manually order the images within an album.
+func (h *albumHandler) HandleOrder(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodPut:
+		h.handleOrderAlbum(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleOrderAlbum handles moving images to a new position in the manual order of an album.
+func (h *albumHandler) handleOrderAlbum(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(editAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(editAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// decode the request body into an cmd record
+	var cmd api.AlbumOrderCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode album order command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode album order command",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the order command
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate album order command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := h.svc.ReorderAlbumImages(ctx, slug, cmd); err != nil {
+		log.Error(fmt.Sprintf("failed to reorder images in album slug %s", slug), "err", err.Error())
+		switch {
+		case strings.Contains(err.Error(), "was not found"):
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusNotFound,
+				Message:    fmt.Sprintf("album %s was not found", slug),
+			}
+			e.SendJsonErr(w)
+			return
+		case strings.Contains(err.Error(), "is not in album"):
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusUnprocessableEntity,
+				Message:    err.Error(),
+			}
+			e.SendJsonErr(w)
+			return
+		default:
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "failed to reorder album images",
+			}
+			e.SendJsonErr(w)
+			return
+		}
+	}
+
+	log.Info(fmt.Sprintf("successfully moved %d images in album slug %s", len(cmd.Images), slug))
+
 	// respond 204 No Content
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// HandleParent is the concrete implementation of the interface method which handles requests
+// to move an album into a parent album, or to the top level.
+func (h *albumHandler) HandleParent(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodPut:
+		h.handleMoveAlbum(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleMoveAlbum handles moving an album into a parent album, or to the top level.
+func (h *albumHandler) handleMoveAlbum(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(editAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(editAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// decode the request body into an cmd record
+	var cmd api.AlbumParentCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode album parent command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode album parent command",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the parent command
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate album parent command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := h.svc.MoveAlbum(ctx, slug, cmd); err != nil {
+		log.Error(fmt.Sprintf("failed to move album slug %s", slug), "err", err.Error())
+		switch {
+		case strings.Contains(err.Error(), "was not found"):
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusNotFound,
+				Message:    fmt.Sprintf("album %s was not found", slug),
+			}
+			e.SendJsonErr(w)
+			return
+		case strings.Contains(err.Error(), "does not exist") || strings.Contains(err.Error(), "cannot be moved"):
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusUnprocessableEntity,
+				Message:    err.Error(),
+			}
+			e.SendJsonErr(w)
+			return
+		default:
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusInternalServerError,
+				Message:    "failed to move album",
+			}
+			e.SendJsonErr(w)
+			return
+		}
+	}
+
+	log.Info(fmt.Sprintf("successfully moved album slug %s to parent '%s'", slug, cmd.ParentSlug))
+
+	// respond 204 No Content
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandlePermissions is the concrete implementation of the interface method which handles requests
+// to view and change the permissions granted at the album level.
+func (h *albumHandler) HandlePermissions(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGetAlbumPermissions(w, r)
+		return
+	case http.MethodPut:
+		h.handleUpdateAlbumPermissions(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleGetAlbumPermissions handles the retrieval of the permissions granted at the album level.
+func (h *albumHandler) handleGetAlbumPermissions(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// only curators may view the album's permissions
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "manage album permissions", w) {
+		log.Error(fmt.Sprintf("user is not permitted to view the permissions of album slug %s", slug))
+		return
+	}
+
+	album, err := h.svc.GetAlbumMembers(ctx, slug)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to retrieve album slug %s", slug), "err", err.Error())
+		h.respondAlbumPermissionsErr(err, slug, w)
+		return
+	}
+
+	records, inherit, err := h.perms.GetAlbumPermissions(album.Id)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to retrieve permissions for album slug %s", slug), "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve album permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	ps, _ := permission.MapPermissionRecordsToApi(records) // cannot fail
+	if ps == nil {
+		ps = []exo.Permission{}
+	}
+
+	log.Info(fmt.Sprintf("successfully retrieved %d permissions for album slug %s", len(ps), slug))
+
+	connect.SendJsonSuccess(w, http.StatusOK, api.AlbumPermissions{
+		AlbumSlug:          slug,
+		InheritPermissions: inherit,
+		Permissions:        ps,
+	})
+}
+
+// handleUpdateAlbumPermissions handles replacing the permissions granted at the album level, optionally
+// applying the change to the album's existing images, or previewing which images would be affected.
+func (h *albumHandler) handleUpdateAlbumPermissions(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(editAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(editAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// decode the request body into an cmd record
+	var cmd api.AlbumPermissionsCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode album permissions command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode album permissions command",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the permissions command
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate album permissions command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// only curators may change the album's permissions
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "manage album permissions", w) {
+		log.Error(fmt.Sprintf("user is not permitted to change the permissions of album slug %s", slug))
+		return
+	}
+
+	// every image in the album is needed, regardless of whether it is published or archived
+	album, err := h.svc.GetAlbumMembers(ctx, slug)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to retrieve album slug %s", slug), "err", err.Error())
+		h.respondAlbumPermissionsErr(err, slug, w)
+		return
+	}
+
+	change, err := h.perms.UpdateAlbumPermissions(ctx, album.Id, album.Images, cmd)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to update permissions for album slug %s", slug), "err", err.Error())
+		h.respondAlbumPermissionsErr(err, slug, w)
+		return
+	}
+	change.AlbumSlug = slug
+
+	// audit log
+	log = log.With(
+		slog.Int("permissions_added", len(change.Added)),
+		slog.Int("permissions_removed", len(change.Removed)),
+		slog.Int("images_affected", len(change.Images)),
+		slog.Bool("inherit_permissions", change.InheritPermissions),
+	)
+	if change.Applied {
+		log.Info(fmt.Sprintf("successfully updated permissions for album slug %s", slug))
+	} else {
+		log.Info(fmt.Sprintf("successfully previewed permissions change for album slug %s", slug))
+	}
+
+	connect.SendJsonSuccess(w, http.StatusOK, change)
+}
+
+// isCurator is a helper which checks the user holds the curator permission to perform the action,
+// responding with the appropriate error if they do not, or if it cannot be determined.
+func (h *albumHandler) isCurator(ctx context.Context, username, action string, w http.ResponseWriter) bool {
+
+	ps, _, err := h.perms.GetPatronPermissions(ctx, username)
+	if err != nil {
+		h.logger.Error(fmt.Sprintf("failed to retrieve permissions for user '%s': %v", username, err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve permissions",
+		}
+		e.SendJsonErr(w)
+		return false
+	}
+
+	if _, ok := ps[util.PermissionCurator]; !ok {
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusForbidden,
+			Message:    fmt.Sprintf("only curators may %s", action),
+		}
+		e.SendJsonErr(w)
+		return false
+	}
+
+	return true
+}
+
+// respondAlbumPermissionsErr is a helper which maps album permission service errors to http responses.
+func (h *albumHandler) respondAlbumPermissionsErr(err error, slug string, w http.ResponseWriter) {
+
+	switch {
+	case strings.Contains(err.Error(), "was not found"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("album %s was not found", slug),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "does not exist"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	default:
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to process album permissions",
+		}
+		e.SendJsonErr(w)
+	}
+}
+
 // handleCreateAlbum handles the creation of a new album record.
 func (h *albumHandler) handleCreateAlbum(w http.ResponseWriter, r *http.Request) {
 
@@ -5,7 +5,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,6 +20,7 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/validate"
 	"github.com/tdeslauriers/pixie/internal/crypt"
 	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
@@ -31,36 +35,113 @@ type Service interface {
 
 	// GetAllowedAlbumsData returns a map ([id]album) and a list of albums that the user is allowed to
 	// view based on their permissions.
-	// Note, these records include a single image record for the album cover.
+	// Note, these records include a single image record for the album cover: the selected cover if the user
+	// has permission to view it, otherwise the most recent image in the album the user has permission to view.
 	GetAllowedAlbumsData(ctx context.Context, psMap map[string]exo.PermissionRecord) (map[string]api.Album, []api.Album, error)
 
+	// GetAllowedAlbumsPage returns a page of the albums the user is allowed to view based on their permissions,
+	// sorted by the page query, along with the total number of albums the user is allowed to view.
+	// Note, these records include a single image record for the album cover.
+	GetAllowedAlbumsPage(ctx context.Context, psMap map[string]exo.PermissionRecord, page api.PageQuery) (*api.AlbumPage, error)
+
+	// GetAlbumTree returns the albums the user is allowed to view as a tree of top level albums and their
+	// sub-albums, each with the number of images the user is allowed to view in it and in its sub-albums.
+	// Note: parent albums are included if the user may view images in any of their sub-albums.
+	GetAlbumTree(ctx context.Context, psMap map[string]exo.PermissionRecord) ([]api.AlbumNode, error)
+
 	// GetAlbum returns a specific album record by its slug, and
 	// a slice of the thumbnail images for the album.
+	// If page is not nil, only that page of the album's images is returned, along with the total count.
 	// Note: username is required to check permissions for each of the album's associated images.
-	GetAlbumBySlug(ctx context.Context, slug string, psMap map[string]exo.PermissionRecord) (*api.Album, error)
+	GetAlbumBySlug(ctx context.Context, slug string, psMap map[string]exo.PermissionRecord, page *api.PageQuery) (*api.Album, error)
+
+	// GetAlbumMembers returns an album by its slug, regardless of permissions or whether it has any images,
+	// along with every image in the album, eg, to apply album-level changes to its images.
+	// Note: only the album's id and slug, and the images' ids, slugs, and titles are populated: no signed urls.
+	GetAlbumMembers(ctx context.Context, slug string) (*api.Album, error)
+
+	// GetTimeline returns a page of the images with an image date the user is allowed to view based on their
+	// permissions, grouped by year and month, newest first, along with per-month counts for the whole timeline.
+	// Note: it does not depend on images having been linked to their year albums.
+	GetTimeline(ctx context.Context, psMap map[string]exo.PermissionRecord, q api.TimelineQuery) (*api.Timeline, error)
+
+	// GetMemories returns the published, non-archived images the user is allowed to view which were taken
+	// on the same date as day, plus or minus window days, in earlier years, grouped by year.
+	// Note: the day's location is the timezone "today" was determined in; image dates are compared by calendar date.
+	GetMemories(ctx context.Context, psMap map[string]exo.PermissionRecord, day time.Time, window int) (*api.Memories, error)
 
 	// CreateAlbum creates a new album record in the database, ecrypots sensitive fields, and
 	// returns a pointer to the created album record,
 	// or returns an error if the creation fails.
 	CreateAlbum(album api.AddAlbumCmd) (*api.AlbumRecord, error)
 
-	// UpdateAlbum updates an existing album record in the database.
+	// UpdateAlbum updates an existing album record in the database, incrementing its version.
+	// Returns an error if the record's version is stale, ie, the album was changed since it was read.
 	UpdateAlbum(updated api.AlbumRecord) error
 
-	// InsertAlbumImageXref creates a new record in the album_image xref table to associate an image with an album.
-	InsertAlbumImageXref(albumId, imageId string) error
+	// InsertAlbumImageXref creates a new record in the album_image xref table to associate an image with an album,
+	// granting the image the album's permissions if the album passes them on to images added to it.
+	InsertAlbumImageXref(ctx context.Context, albumId, imageId string) error
 
 	// DeleteAlbumImageXref deletes all records in the album_image xref table asssociated with an image uuid.
 	DeleteAlbumImageXrefs(imageId string) error
+
+	// MoveAlbum moves an album into a parent album, or to the top level if the command's parent slug is empty.
+	MoveAlbum(ctx context.Context, slug string, cmd api.AlbumParentCmd) error
+
+	// ReorderAlbumImages moves the images in the order command, in order, to directly after the command's
+	// after image, or to the start of the album, and persists the resulting manual order of the album's images.
+	ReorderAlbumImages(ctx context.Context, slug string, cmd api.AlbumOrderCmd) error
+
+	// GetSmartAlbum returns the stored rules of a smart album by its slug.
+	GetSmartAlbum(ctx context.Context, slug string) (*api.SmartAlbum, error)
+
+	// SetSmartAlbumRules sets the rules of a smart album: an empty album becomes a smart album,
+	// or an existing smart album's rules are replaced.
+	// Note: a smart album's images are evaluated from its rules whenever it is read, filtered by the reader's permissions.
+	SetSmartAlbumRules(ctx context.Context, slug string, cmd api.SmartAlbumRulesCmd) (*api.SmartAlbum, error)
+
+	// RemoveSmartAlbumRules removes the rules of a smart album, leaving it an empty regular album.
+	RemoveSmartAlbumRules(ctx context.Context, slug string) error
+
+	// FreezeSmartAlbum freezes a smart album into a regular album containing every image matching its rules.
+	FreezeSmartAlbum(ctx context.Context, slug string) (*api.SmartAlbumFreeze, error)
+
+	// DeleteAlbum deletes an album, unlinking, moving, or deleting its images according to the query's mode,
+	// and moves its sub-albums up to its parent.  If the query is a dry run, only reports what would happen.
+	// Note: images are only deleted if they belong to no other album; their files are removed by the deletion pipeline.
+	DeleteAlbum(ctx context.Context, slug string, q api.AlbumDeletionQuery) (*api.AlbumDeletion, error)
+
+	// BuildAlbumExport resolves the export of an album for a user: the originals, or renditions of the query's width,
+	// of the images the user may view and, for originals and wide renditions, download, with their file names in the archive.
+	// Note: errors are returned before anything is written, so they can be mapped to an http status.
+	BuildAlbumExport(ctx context.Context, slug string, psMap map[string]exo.PermissionRecord, q api.AlbumExportQuery) (*AlbumExport, error)
+
+	// WriteAlbumExport streams the album export to w as a zip archive of the image files, read straight from
+	// object storage without temp files, followed by a json manifest of the images' metadata.
+	WriteAlbumExport(ctx context.Context, export *AlbumExport, w io.Writer) error
 }
 
 // NewService creates a new album service and provides a pointer to a concrete implementation.
-func NewService(sql *sql.DB, i data.Indexer, c data.Cryptor, o storage.ObjectStorage) Service {
+func NewService(
+	sql *sql.DB,
+	i data.Indexer,
+	c data.Cryptor,
+	o storage.ObjectStorage,
+	viewOnlyMaxWidth int,
+	dq chan pipeline.DeletionCmd,
+) Service {
 	return &albumService{
 		db:      NewAlbumRepository(sql),
+		smart:   NewSmartRepository(sql),
+		export:  NewExportRepository(sql),
+		uow:     transaction.NewUnitOfWork(sql),
 		indexer: i,
 		cryptor: crypt.NewCryptor(c),
 		store:   o,
+		delete:  dq,
+
+		viewOnlyMaxWidth: viewOnlyMaxWidth,
 
 		logger: slog.Default().
 			With(slog.String(util.ComponentKey, util.ComponentAlbumSerivce)).
@@ -73,242 +154,1537 @@ var _ Service = (*albumService)(nil)
 // albumService implements the Service interface for managing album records.
 type albumService struct {
 	db      AlbumRepository
+	smart   SmartRepository
+	export  ExportRepository
+	uow     transaction.UnitOfWork
 	indexer data.Indexer
 	cryptor crypt.Cryptor
 	store   storage.ObjectStorage
+	delete  chan pipeline.DeletionCmd
+
+	viewOnlyMaxWidth int // widest resolution, in pixels, served to users who may not download an image
 
 	logger *slog.Logger
 }
 
-// GetAllowedAlbums implements the Service interface method to retrieve all album records a user has permission to view.
-// this method must consider the users permissions, and
-// the images attached to the albums premissions and only return an album if the user is
-// authorized to view at least one image in the album.
-// Also returns a map for convenience lookups by consuming functions.
-func (s *albumService) GetAllowedAlbums(psMap map[string]exo.PermissionRecord) (map[string]api.AlbumRecord, []api.AlbumRecord, error) {
+// GetAllowedAlbums implements the Service interface method to retrieve all album records a user has permission to view.
+// this method must consider the users permissions, and
+// the images attached to the albums premissions and only return an album if the user is
+// authorized to view at least one image in the album.
+// Also returns a map for convenience lookups by consuming functions.
+func (s *albumService) GetAllowedAlbums(psMap map[string]exo.PermissionRecord) (map[string]api.AlbumRecord, []api.AlbumRecord, error) {
+
+	// get allowwed albums based on permissions
+	albums, err := s.db.FindAllowedAlbums(psMap)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve allowed albums from database: %v", err)
+	}
+
+	// curators may view every album, but anyone else may only view the smart albums
+	// whose rules match an image they have permission to view
+	if _, ok := psMap[util.PermissionCurator]; !ok {
+		albums, err = s.withVisibleSmartAlbums(albums, psMap)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// handle no albums found
+	if len(albums) == 0 {
+		return nil, []api.AlbumRecord{}, nil
+	}
+
+	aMap, err := s.decryptAlbumRecords(albums)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return aMap, albums, nil
+}
+
+// decryptAlbumRecords is a helper which decrypts the album records in place, removing their blind indexes.
+// Also returns a map of the decrypted albums by slug for convenience lookups.
+func (s *albumService) decryptAlbumRecords(albums []api.AlbumRecord) (map[string]api.AlbumRecord, error) {
+
+	// decrypt the album records
+	var (
+		wg    sync.WaitGroup
+		errCh = make(chan error, len(albums))
+
+		// build the map oppotunistically while looping through the albums for decryption
+		aMap = make(map[string]api.AlbumRecord, len(albums))
+		mu   sync.Mutex
+	)
+
+	for i := range albums {
+		wg.Add(1)
+		go func(a *api.AlbumRecord) {
+			defer wg.Done()
+			if err := s.cryptor.DecryptAlbumRecord(a); err != nil {
+				errCh <- fmt.Errorf("failed to decrypt album record '%s': %v", a.Id, err)
+			}
+			// also need to remove the blind index from the album record
+			a.SlugIndex = ""
+
+			// add to the map
+			mu.Lock()
+			aMap[a.Slug] = *a
+			mu.Unlock()
+		}(&albums[i])
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	// return errors if any decryption failed
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to decrypt one or more album records: %v", errors.Join(errs...))
+		}
+	}
+
+	return aMap, nil
+}
+
+// GetAllowedAlbumsData implements the Service interface method to retrieve all albums a user has permission to view,
+// each with its cover image.
+func (s *albumService) GetAllowedAlbumsData(ctx context.Context, psMap map[string]exo.PermissionRecord) (map[string]api.Album, []api.Album, error) {
+
+	// get the decrypted albums the user has permission to view
+	_, records, err := s.GetAllowedAlbums(psMap)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(records) == 0 {
+		return nil, []api.Album{}, nil
+	}
+
+	// add the cover image for each album
+	albums, err := s.buildAlbumCovers(ctx, psMap, records)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	albumsMap := make(map[string]api.Album, len(albums))
+	for _, a := range albums {
+		albumsMap[a.Id] = a
+	}
+
+	return albumsMap, albums, nil
+}
+
+// GetAlbumTree implements the Service interface method to retrieve the albums the user has permission to view
+// as a tree of top level albums and their sub-albums, with permission-aware image counts.
+func (s *albumService) GetAlbumTree(ctx context.Context, psMap map[string]exo.PermissionRecord) ([]api.AlbumNode, error) {
+
+	// get all albums and the per-album counts of images the user may view concurrently
+	var (
+		wg      sync.WaitGroup
+		records []api.AlbumRecord
+		counts  []AlbumImageCount
+		recErr  error
+		cntErr  error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		records, recErr = s.db.FindAllAlbums()
+	}()
+	go func() {
+		defer wg.Done()
+		counts, cntErr = s.db.FindAlbumImageCounts(psMap)
+	}()
+	wg.Wait()
+
+	if recErr != nil {
+		return nil, fmt.Errorf("failed to retrieve albums from database: %v", recErr)
+	}
+
+	if cntErr != nil {
+		return nil, fmt.Errorf("failed to retrieve album image counts from database: %v", cntErr)
+	}
+
+	// decrypt the album records
+	var (
+		decryptWg sync.WaitGroup
+		errCh     = make(chan error, len(records))
+	)
+
+	for i := range records {
+		decryptWg.Add(1)
+		go func(a *api.AlbumRecord) {
+			defer decryptWg.Done()
+			if err := s.cryptor.DecryptAlbumRecord(a); err != nil {
+				errCh <- fmt.Errorf("failed to decrypt album record '%s': %v", a.Id, err)
+			}
+			// also need to remove the blind index from the album record
+			a.SlugIndex = ""
+		}(&records[i])
+	}
+
+	decryptWg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, fmt.Errorf("failed to decrypt one or more album records: %v", errors.Join(errs...))
+	}
+
+	// index the albums by id and by parent
+	byId := make(map[string]api.AlbumRecord, len(records))
+	for _, r := range records {
+		byId[r.Id] = r
+	}
+
+	children := make(map[string][]string, len(records))
+	var roots []string
+	for _, r := range records {
+		if _, ok := byId[r.ParentId]; r.ParentId == "" || !ok {
+			roots = append(roots, r.Id)
+			continue
+		}
+		children[r.ParentId] = append(children[r.ParentId], r.Id)
+	}
+
+	imageCounts := make(map[string]int, len(counts))
+	for _, c := range counts {
+		imageCounts[c.AlbumId] = c.Count
+	}
+
+	// smart albums are counted from the images matching their rules
+	smart, err := s.evaluateSmartAlbums(psMap, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate smart album image counts: %v", err)
+	}
+	for id, records := range smart {
+		imageCounts[id] = len(records)
+	}
+
+	_, isCurator := psMap["CURATOR"]
+
+	// build the tree depth first so sub-albums' image counts roll up to their parents
+	visited := make(map[string]bool, len(records))
+	var build func(id string) (api.AlbumNode, bool)
+	build = func(id string) (api.AlbumNode, bool) {
+
+		// guard against cycles in the parent links
+		if visited[id] {
+			return api.AlbumNode{}, false
+		}
+		visited[id] = true
+
+		r := byId[id]
+
+		// archiving a parent album hides all of its sub-albums
+		if r.IsArchived && !isCurator {
+			return api.AlbumNode{}, false
+		}
+
+		node := api.AlbumNode{
+			Album: api.Album{
+				Id:           r.Id,
+				Title:        r.Title,
+				Description:  r.Description,
+				Slug:         r.Slug,
+				CreatedAt:    r.CreatedAt,
+				UpdatedAt:    r.UpdatedAt,
+				IsArchived:   r.IsArchived,
+				CoverImageId: r.CoverImageId,
+				ParentId:     r.ParentId,
+				Version:      r.Version,
+			},
+			ImageCount: imageCounts[r.Id],
+		}
+		node.TotalImageCount = node.ImageCount
+
+		for _, childId := range children[id] {
+			if child, ok := build(childId); ok {
+				node.Children = append(node.Children, child)
+				node.TotalImageCount += child.TotalImageCount
+			}
+		}
+		sortAlbumNodes(node.Children)
+
+		// only show albums with images the user may view in them or in their sub-albums, eg, collections
+		if !isCurator && node.TotalImageCount == 0 {
+			return api.AlbumNode{}, false
+		}
+
+		// the selected cover may not be visible to the user
+		if !isCurator {
+			node.CoverImageId = ""
+		}
+
+		return node, true
+	}
+
+	tree := make([]api.AlbumNode, 0, len(roots))
+	for _, id := range roots {
+		if node, ok := build(id); ok {
+			tree = append(tree, node)
+		}
+	}
+	sortAlbumNodes(tree)
+
+	return tree, nil
+}
+
+// sortAlbumNodes is a helper which sorts album nodes alphabetically by title.
+func sortAlbumNodes(nodes []api.AlbumNode) {
+	slices.SortFunc(nodes, func(a, b api.AlbumNode) int {
+		return util.CompareSortKeys(strings.ToLower(a.Title), a.Id, strings.ToLower(b.Title), b.Id, false)
+	})
+}
+
+// MoveAlbum implements the Service interface method to move an album into a parent album, or to the top level.
+// An album cannot be moved into itself or any of its sub-albums.
+func (s *albumService) MoveAlbum(ctx context.Context, slug string, cmd api.AlbumParentCmd) error {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for MoveAlbum")
+	}
+
+	// validate the album slug and parent command
+	// redundant check, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	if cmd.ParentSlug == slug {
+		return fmt.Errorf("album cannot be moved into itself or one of its sub-albums")
+	}
+
+	// get album indexes: slugs are encrypted
+	albumIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	var parentIndex string
+	if cmd.ParentSlug != "" {
+		parentIndex, err = s.indexer.ObtainBlindIndex(cmd.ParentSlug)
+		if err != nil {
+			return fmt.Errorf("failed to obtain blind index for album slug '%s': %v", cmd.ParentSlug, err)
+		}
+	}
+
+	// all albums are needed to check the move would not create a cycle
+	albums, err := s.db.FindAllAlbums()
+	if err != nil {
+		return fmt.Errorf("failed to retrieve albums from database: %v", err)
+	}
+
+	var album, parent *api.AlbumRecord
+	byId := make(map[string]api.AlbumRecord, len(albums))
+	for i := range albums {
+		byId[albums[i].Id] = albums[i]
+		switch albums[i].SlugIndex {
+		case albumIndex:
+			album = &albums[i]
+		case parentIndex:
+			parent = &albums[i]
+		}
+	}
+
+	if album == nil {
+		return fmt.Errorf("album %s was not found", slug)
+	}
+
+	parentId := ""
+	if cmd.ParentSlug != "" {
+		if parent == nil {
+			return fmt.Errorf("parent album %s does not exist", cmd.ParentSlug)
+		}
+		parentId = parent.Id
+
+		// walk up from the new parent: the album must not be one of its ancestors
+		for id, depth := parentId, 0; id != "" && depth <= len(albums); id, depth = byId[id].ParentId, depth+1 {
+			if id == album.Id {
+				return fmt.Errorf("album cannot be moved into itself or one of its sub-albums")
+			}
+		}
+	}
+
+	if album.ParentId == parentId {
+		log.Warn(fmt.Sprintf("move command executed, but album slug %s is already in the requested parent", slug))
+		return nil
+	}
+
+	if err := s.db.UpdateAlbumParent(album.Id, parentId, data.CustomTime{Time: time.Now().UTC()}); err != nil {
+		return fmt.Errorf("failed to update parent of album slug %s: %v", slug, err)
+	}
+
+	log.Info(fmt.Sprintf("moved album slug %s from parent '%s' to parent '%s'", slug, album.ParentId, parentId))
+
+	return nil
+}
+
+// GetAlbumMembers implements the Service interface method to retrieve an album by its slug, regardless of
+// permissions or whether it has any images, along with the id, slug, and title of every image in the album.
+func (s *albumService) GetAlbumMembers(ctx context.Context, slug string) (*api.Album, error) {
+
+	// vadidate the slug is well formed
+	// redundant check, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	// get album index
+	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	// curator access returns every image, and the album row even if it has no images
+	curator := map[string]exo.PermissionRecord{util.PermissionCurator: {}}
+	records, err := s.db.FindAlbumImagesData(slugIndex, curator, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve album-image records for album slug %s: %v", slug, err)
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("album %s was not found", slug)
+	}
+
+	album := &api.Album{
+		Id:     records[0].AlbumId,
+		Slug:   slug,
+		Images: make([]api.ImageData, 0, len(records)),
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		errCh = make(chan error, len(records))
+	)
+
+	for _, r := range records {
+
+		// an album with no images returns a single row with empty image fields
+		if r.ImageId == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(r api.AlbumImageRecord) {
+			defer wg.Done()
+
+			title, err := s.cryptor.DecryptImageField(r.ImageTitle)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decrypt title of image '%s': %v", r.ImageId, err)
+				return
+			}
+
+			imageSlug, err := s.cryptor.DecryptImageField(r.ImageSlug)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decrypt slug of image '%s': %v", r.ImageId, err)
+				return
+			}
+
+			mu.Lock()
+			album.Images = append(album.Images, api.ImageData{
+				Id:    r.ImageId,
+				Title: title,
+				Slug:  imageSlug,
+			})
+			mu.Unlock()
+		}(r)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, fmt.Errorf("failed to decrypt album %s images: %v", slug, errors.Join(errs...))
+	}
+
+	// restore the album's image order: decryption is concurrent
+	order := make(map[string]int, len(records))
+	for i, r := range records {
+		order[r.ImageId] = i
+	}
+	slices.SortFunc(album.Images, func(a, b api.ImageData) int {
+		return order[a.Id] - order[b.Id]
+	})
+
+	return album, nil
+}
+
+// GetAlbumBySlug implements the Service interface method to retrieve a specific album record by its slug.
+// It also retrieves a slice of the thumbnail images for the album.
+func (s *albumService) GetAlbumBySlug(
+	ctx context.Context,
+	slug string,
+	psMap map[string]exo.PermissionRecord,
+	page *api.PageQuery,
+) (*api.Album, error) {
+
+	// vadidate the slug is well formed
+	// redundant check, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	// get album index
+	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	// optional image date range filter, applied in the database
+	var from, to string
+	if page != nil {
+		if err := util.ValidatePageQuery(page, api.SortPosition); err != nil {
+			return nil, err
+		}
+		from, to = api.ImageDateKeyLowerBound(page.From), page.To
+	}
+
+	// smart albums' images are evaluated from their rules rather than their album-image xrefs
+	smart, err := s.smart.FindSmartAlbum(slugIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if album slug %s is a smart album: %v", slug, err)
+	}
+
+	// pages are sorted and sliced in the database, except by title, since titles are encrypted,
+	// or if a smart album's untitled rule must be applied to the decrypted titles
+	if page != nil && page.Sort != api.SortTitle {
+		var (
+			filter   SmartAlbumFilter
+			untitled bool
+		)
+		if smart != nil {
+			rules, f, err := s.decryptSmartAlbumFilter(*smart)
+			if err != nil {
+				return nil, fmt.Errorf("failed to build smart album filter for album slug %s: %v", slug, err)
+			}
+			filter, untitled = f, rules.Untitled
+		}
+
+		if !untitled {
+			return s.getAlbumImagesPage(ctx, slug, slugIndex, smart, filter, psMap, *page, from, to)
+		}
+	}
+
+	findImages := func(from, to string) ([]api.AlbumImageRecord, error) {
+		if smart != nil {
+			return s.findSmartAlbumImages(*smart, psMap, from, to)
+		}
+		return s.db.FindAlbumImagesData(slugIndex, psMap, from, to)
+	}
+
+	// get the album-image records for the album by slug index
+	records, err := findImages(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve album-image records for album slug %s: %v", slug, err)
+	}
+
+	// a date range may legitimately exclude every image: re-check without it so the
+	// album is still returned, empty, rather than reported as inaccessible
+	rangeExcludedAll := false
+	if len(records) == 0 && (from != "" || to != "") {
+		records, err = findImages("", "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve album-image records for album slug %s: %v", slug, err)
+		}
+		rangeExcludedAll = len(records) > 0
+	}
+
+	// seocondary check to ensure we have records
+	if len(records) == 0 {
+		return nil, s.albumAccessError(slug, slugIndex)
+	}
+
+	// build the album modelfrom the first record
+	// Images slice will be populated below after additional operations
+	album, err := s.buildAlbum(records[0])
+	if err != nil {
+		return nil, err
+	}
+
+	// only return the selected cover if the user has permission to view it
+	for _, r := range records {
+		if r.AlbumCoverId != "" && r.ImageId == r.AlbumCoverId {
+			album.CoverImageId = r.AlbumCoverId
+			break
+		}
+	}
+
+	// only the album is needed if the date range excluded all of its images
+	if rangeExcludedAll {
+		album.Images = []api.ImageData{}
+		return album, nil
+	}
+
+	// if a page was requested, only build the image data for the images on that page
+	if page != nil {
+		total, next, err := s.pageAlbumImages(&records, *page)
+		if err != nil {
+			return nil, err
+		}
+
+		album.TotalImages = total
+		album.NextCursor = next
+	}
+
+	// set the images slice on the album
+	album.Images, err = s.buildAlbumImages(ctx, slug, records)
+	if err != nil {
+		return nil, err
+	}
+
+	return album, nil
+}
+
+// getAlbumImagesPage is a helper which retrieves an album with a page of its images, sorted and sliced in the database
+// by a keyset query rather than loading every image the user may view.  If smart is not nil, the images are those
+// matching the smart album's filter.
+// Note: the page query must already be validated, and cannot be sorted by title: titles are encrypted.
+func (s *albumService) getAlbumImagesPage(
+	ctx context.Context,
+	slug, slugIndex string,
+	smart *SmartAlbumRecord,
+	f SmartAlbumFilter,
+	psMap map[string]exo.PermissionRecord,
+	page api.PageQuery,
+	from, to string,
+) (*api.Album, error) {
+
+	var cursor *api.PageCursor
+	if page.Cursor != "" {
+		c, err := api.DecodePageCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+
+	// the head record shows the user may view the album, regardless of the date range,
+	// and holds the album's fields and, if the user may view it, its selected cover
+	var (
+		head *api.AlbumImageRecord
+		err  error
+	)
+	if smart != nil {
+		head, err = s.smart.FindSmartAlbumHead(smart.AlbumId, f, psMap)
+	} else {
+		head, err = s.db.FindAlbumHead(slugIndex, psMap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve album-image record for album slug %s: %v", slug, err)
+	}
+
+	if head == nil {
+		return nil, s.albumAccessError(slug, slugIndex)
+	}
+
+	album, err := s.buildAlbum(*head)
+	if err != nil {
+		return nil, err
+	}
+
+	if head.AlbumCoverId != "" && head.ImageId == head.AlbumCoverId {
+		album.CoverImageId = head.AlbumCoverId
+	}
+
+	// count the images in the date range, and get the page, plus one record to detect a next page
+	var (
+		total   int
+		records []api.AlbumImageRecord
+	)
+	if smart != nil {
+		ranged := narrowSmartAlbumFilter(f, from, to)
+		if total, err = s.smart.CountSmartAlbumImages(smart.AlbumId, ranged, psMap); err == nil && total > 0 {
+			records, err = s.smart.FindSmartAlbumImagesPage(smart.AlbumId, ranged, psMap, page, cursor, page.Limit+1)
+		}
+	} else {
+		if total, err = s.db.CountAlbumImages(slugIndex, psMap, from, to); err == nil && total > 0 {
+			records, err = s.db.FindAlbumImagesPage(slugIndex, psMap, from, to, page, cursor, page.Limit+1)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve page of album-image records for album slug %s: %v", slug, err)
+	}
+
+	album.TotalImages = total
+
+	if len(records) > page.Limit {
+		records = records[:page.Limit]
+
+		offset := len(records)
+		if cursor != nil {
+			offset += cursor.Offset
+		}
+
+		last := records[len(records)-1]
+		album.NextCursor = api.PageCursor{
+			Sort:      page.Sort,
+			Desc:      page.Desc,
+			Id:        last.ImageId,
+			Offset:    offset,
+			DateKey:   last.ImageDateKey,
+			CreatedAt: last.ImageCreatedAt,
+			Position:  last.ImagePosition,
+		}.Encode()
+	}
+
+	album.Images, err = s.buildAlbumImages(ctx, slug, records)
+	if err != nil {
+		return nil, err
+	}
+
+	return album, nil
+}
+
+// buildAlbum is a helper which builds the decrypted album model from the album fields of an album-image record.
+func (s *albumService) buildAlbum(r api.AlbumImageRecord) (*api.Album, error) {
+
+	album := &api.Album{
+		Id:          r.AlbumId,
+		Title:       r.AlbumTitle,
+		Description: r.AlbumDescription,
+		Slug:        r.AlbumSlug,
+		CreatedAt:   r.AlbumCreatedAt,
+		UpdatedAt:   r.AlbumUpdatedAt,
+		IsArchived:  r.AlbumIsArchived,
+		ParentId:    r.AlbumParentId,
+		Version:     r.AlbumVersion,
+	}
+
+	// decrypt the album record
+	if err := s.cryptor.DecryptAlbum(album); err != nil {
+		return nil, fmt.Errorf("failed to decrypt album record '%s': %v", album.Id, err)
+	}
+
+	return album, nil
+}
+
+// albumAccessError is a helper which builds the error for an album the user may view no records of:
+// whether it does not exist, is archived, or the user has no access to any of its images.
+func (s *albumService) albumAccessError(slug, slugIndex string) error {
+
+	// check if the album exists at all
+	if exists, err := s.db.AlbumExists(slugIndex); err != nil {
+		return fmt.Errorf("failed to check if album %s exists: %v", slug, err)
+	} else if !exists {
+		// album exists, but user has no permissions to view any images in the album
+		return fmt.Errorf("album %s was not found", slug)
+	}
+
+	// check if album archived but is archived
+	if archived, err := s.db.AlbumIsArchived(slugIndex); err != nil {
+		return fmt.Errorf("failed to check if album %s has been archived: %v", slug, err)
+	} else if archived {
+		// album is archived
+		return fmt.Errorf("album %s is archived", slug)
+	}
+
+	// this could be permissions, images could be archived, or unpublished.   Could also be no images in album.
+	return fmt.Errorf("user has no access to any images in album %s", slug)
+}
+
+// buildAlbumImages is a helper which builds the album's images slice from the records, in the records' order.
+// Includes decryption of sensitive fields and getting presigned links to the image thumbnails.
+func (s *albumService) buildAlbumImages(ctx context.Context, slug string, records []api.AlbumImageRecord) ([]api.ImageData, error) {
+
+	images, err := s.buildImageData(ctx, records)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build image data for album slug %s: %v", slug, err)
+	}
+
+	// image data is built concurrently, so restore the album order, or the sort order of the page
+	order := make(map[string]int, len(records))
+	for i, r := range records {
+		if _, ok := order[r.ImageId]; !ok {
+			order[r.ImageId] = i
+		}
+	}
+	slices.SortFunc(images, func(a, b api.ImageData) int {
+		return order[a.Id] - order[b.Id]
+	})
+
+	return images, nil
+}
+
+// pageAlbumImages is a helper which sorts the album-image records by the page query and replaces them
+// with the requested page.  Returns the total number of images and the cursor for the next page.
+// Only used when the page cannot be sorted in the database: by title, or for a smart album's untitled rule.
+// Note: titles are encrypted, so they are decrypted individually so only the page's records are fully decrypted.
+func (s *albumService) pageAlbumImages(records *[]api.AlbumImageRecord, page api.PageQuery) (int, string, error) {
+
+	if err := util.ValidatePageQuery(&page, api.SortPosition); err != nil {
+		return 0, "", err
+	}
+
+	// one record per image: curators may also have album rows with no images
+	seen := make(map[string]struct{}, len(*records))
+	deduped := make([]api.AlbumImageRecord, 0, len(*records))
+	for _, r := range *records {
+		if r.ImageId == "" {
+			continue
+		}
+		if _, ok := seen[r.ImageId]; ok {
+			continue
+		}
+		seen[r.ImageId] = struct{}{}
+		deduped = append(deduped, r)
+	}
+
+	// build the sort keys
+	keys := make(map[string]string, len(deduped))
+	switch page.Sort {
+	case api.SortUploadDate:
+		for _, r := range deduped {
+			keys[r.ImageId] = r.ImageCreatedAt
+		}
+	case api.SortImageDate:
+		// the plaintext year-month date key, then upload date within the month
+		for _, r := range deduped {
+			keys[r.ImageId] = r.ImageDateKey + "|" + r.ImageCreatedAt
+		}
+	case api.SortPosition:
+		// manually ordered images first, then by image date, same as the sql order
+		for _, r := range deduped {
+			if r.ImagePosition > 0 {
+				keys[r.ImageId] = fmt.Sprintf("0|%010d", r.ImagePosition)
+			} else {
+				keys[r.ImageId] = "1|" + r.ImageDateKey + "|" + r.ImageCreatedAt
+			}
+		}
+	default:
+		var (
+			wg    sync.WaitGroup
+			mu    sync.Mutex
+			errCh = make(chan error, len(deduped))
+		)
+
+		for _, r := range deduped {
+			wg.Add(1)
+			go func(r api.AlbumImageRecord) {
+				defer wg.Done()
+
+				key, err := s.cryptor.DecryptImageField(r.ImageTitle)
+				if err != nil {
+					errCh <- fmt.Errorf("failed to decrypt %s sort key for image '%s': %v", page.Sort, r.ImageId, err)
+					return
+				}
+
+				mu.Lock()
+				keys[r.ImageId] = strings.ToLower(key)
+				mu.Unlock()
+			}(r)
+		}
+
+		wg.Wait()
+		close(errCh)
+
+		if len(errCh) > 0 {
+			var errs []error
+			for e := range errCh {
+				errs = append(errs, e)
+			}
+			return 0, "", fmt.Errorf("failed to build album image sort keys: %v", errors.Join(errs...))
+		}
+	}
+
+	slices.SortFunc(deduped, func(a, b api.AlbumImageRecord) int {
+		return util.CompareSortKeys(keys[a.ImageId], a.ImageId, keys[b.ImageId], b.ImageId, page.Desc)
+	})
+
+	paged, next, err := util.Paginate(deduped, func(r api.AlbumImageRecord) string { return r.ImageId }, page)
+	if err != nil {
+		return 0, "", err
+	}
+
+	*records = paged
+
+	return len(deduped), next, nil
+}
+
+// GetAllowedAlbumsPage implements the Service interface method to retrieve a page of the albums a user has
+// permission to view, each with a single cover image, sorted by the page query.
+func (s *albumService) GetAllowedAlbumsPage(
+	ctx context.Context,
+	psMap map[string]exo.PermissionRecord,
+	page api.PageQuery,
+) (*api.AlbumPage, error) {
+
+	if err := util.ValidatePageQuery(&page, api.SortTitle); err != nil {
+		return nil, err
+	}
+
+	// albums do not have a capture date or position of their own
+	if page.Sort == api.SortImageDate || page.Sort == api.SortPosition {
+		return nil, fmt.Errorf("sort '%s' is not valid for album listings", page.Sort)
+	}
+
+	if page.From != "" || page.To != "" {
+		return nil, fmt.Errorf("image date range is not valid for album listings")
+	}
+
+	// upload date pages are sorted and sliced in the database, but titles are encrypted
+	if page.Sort == api.SortUploadDate {
+		return s.getAllowedAlbumsUploadPage(ctx, psMap, page)
+	}
+
+	// get the decrypted albums the user has permission to view
+	// note: only album fields, which is far smaller than every album x image record
+	_, albums, err := s.GetAllowedAlbums(psMap)
+	if err != nil {
+		return nil, err
+	}
+
+	// sort the albums by title
+	slices.SortFunc(albums, func(a, b api.AlbumRecord) int {
+		return util.CompareSortKeys(strings.ToLower(a.Title), a.Id, strings.ToLower(b.Title), b.Id, page.Desc)
+	})
+
+	paged, next, err := util.Paginate(albums, func(a api.AlbumRecord) string { return a.Id }, page)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &api.AlbumPage{
+		Albums:     make([]api.Album, 0, len(paged)),
+		NextCursor: next,
+		Total:      len(albums),
+	}
+
+	if len(paged) == 0 {
+		return result, nil
+	}
+
+	// get a cover image for each album on the page
+	albumsWithCovers, err := s.buildAlbumCovers(ctx, psMap, paged)
+	if err != nil {
+		return nil, err
+	}
+	result.Albums = albumsWithCovers
+
+	return result, nil
+}
+
+// getAllowedAlbumsUploadPage is a helper which retrieves a page of the albums a user has permission to view by
+// upload date, sorted and sliced in the database by a keyset query rather than loading every album the user may view.
+// Note: smart albums are visible by their rules rather than their image xrefs, so for anyone but curators,
+// the visible smart albums are evaluated separately and merged into the page.
+func (s *albumService) getAllowedAlbumsUploadPage(
+	ctx context.Context,
+	psMap map[string]exo.PermissionRecord,
+	page api.PageQuery,
+) (*api.AlbumPage, error) {
+
+	var cursor *api.PageCursor
+	if page.Cursor != "" {
+		c, err := api.DecodePageCursor(page.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+
+	// get the page, plus one record to detect a next page
+	albums, err := s.db.FindAllowedAlbumsPage(psMap, page.Desc, cursor, page.Limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve page of allowed albums from database: %v", err)
+	}
+
+	total, err := s.db.CountAllowedAlbums(psMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count allowed albums in database: %v", err)
+	}
+
+	// merge in the visible smart albums after the cursor, in the same order as the database
+	if _, ok := psMap[util.PermissionCurator]; !ok {
+		smart, err := s.withVisibleSmartAlbums(nil, psMap)
+		if err != nil {
+			return nil, err
+		}
+		total += len(smart)
+
+		for _, a := range smart {
+			if cursor == nil || compareUploadKeys(albumUploadKey(a), a.Id, cursor.CreatedAt, cursor.Id, page.Desc) > 0 {
+				albums = append(albums, a)
+			}
+		}
+		slices.SortFunc(albums, func(a, b api.AlbumRecord) int {
+			return compareUploadKeys(albumUploadKey(a), a.Id, albumUploadKey(b), b.Id, page.Desc)
+		})
+	}
+
+	result := &api.AlbumPage{
+		Albums: []api.Album{},
+		Total:  total,
+	}
+
+	if len(albums) > page.Limit {
+		albums = albums[:page.Limit]
+
+		offset := len(albums)
+		if cursor != nil {
+			offset += cursor.Offset
+		}
+
+		last := albums[len(albums)-1]
+		result.NextCursor = api.PageCursor{
+			Sort:      page.Sort,
+			Desc:      page.Desc,
+			Id:        last.Id,
+			Offset:    offset,
+			CreatedAt: albumUploadKey(last),
+		}.Encode()
+	}
+
+	if len(albums) == 0 {
+		return result, nil
+	}
+
+	// only the albums on the page are decrypted
+	if _, err := s.decryptAlbumRecords(albums); err != nil {
+		return nil, err
+	}
+
+	// get a cover image for each album on the page
+	result.Albums, err = s.buildAlbumCovers(ctx, psMap, albums)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// albumUploadKey is a helper which formats an album's upload date as the keyset sort key of the album upload date
+// page query, see BuildAlbumsPageQuery.
+func albumUploadKey(a api.AlbumRecord) string {
+	return a.CreatedAt.UTC().Format("2006-01-02 15:04:05")
+}
+
+// compareUploadKeys is a helper which compares two upload date keyset sort keys in the same order as the database:
+// by upload date, then id, both reversed if desc.
+func compareUploadKeys(aKey, aId, bKey, bId string, desc bool) int {
+
+	c := strings.Compare(aKey, bKey)
+	if c == 0 {
+		c = strings.Compare(aId, bId)
+	}
+
+	if desc {
+		return -c
+	}
+
+	return c
+}
+
+// buildAlbumCovers is a helper which builds the api albums from decrypted album records, each with its cover image:
+// the selected cover if the user has permission to view it, otherwise the most recent image the user has permission
+// to view.  The order of the album records is preserved.
+func (s *albumService) buildAlbumCovers(
+	ctx context.Context,
+	psMap map[string]exo.PermissionRecord,
+	records []api.AlbumRecord,
+) ([]api.Album, error) {
+
+	if len(records) == 0 {
+		return []api.Album{}, nil
+	}
+
+	ids := make([]string, 0, len(records))
+	for _, a := range records {
+		ids = append(ids, a.Id)
+	}
+
+	covers, err := s.db.FindAlbumCovers(ids, psMap)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve album covers from database: %v", err)
+	}
+
+	// smart album covers come from the images matching their rules
+	listed := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		listed[id] = true
+	}
+	smart, err := s.evaluateSmartAlbums(psMap, listed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate smart album covers: %v", err)
+	}
+	if len(smart) > 0 {
+		covers = slices.DeleteFunc(covers, func(c api.AlbumImageRecord) bool {
+			_, ok := smart[c.AlbumId]
+			return ok
+		})
+		for _, records := range smart {
+			if cover, ok := smartAlbumCover(records); ok {
+				covers = append(covers, cover)
+			}
+		}
+	}
+
+	images, err := s.buildImageData(ctx, covers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build album cover image data: %v", err)
+	}
+
+	// map the built images back to their albums
+	imagesById := make(map[string]api.ImageData, len(images))
+	for _, img := range images {
+		imagesById[img.Id] = img
+	}
+
+	var (
+		coverByAlbum = make(map[string]api.ImageData, len(covers))
+		selected     = make(map[string]bool, len(covers)) // album id -> cover is the curator selected cover
+	)
+	for _, c := range covers {
+		if img, ok := imagesById[c.ImageId]; ok {
+			coverByAlbum[c.AlbumId] = img
+			selected[c.AlbumId] = c.AlbumCoverId != "" && c.AlbumCoverId == c.ImageId
+		}
+	}
+
+	albums := make([]api.Album, 0, len(records))
+	for _, a := range records {
+		album := api.Album{
+			Id:          a.Id,
+			Title:       a.Title,
+			Description: a.Description,
+			Slug:        a.Slug,
+			CreatedAt:   a.CreatedAt,
+			UpdatedAt:   a.UpdatedAt,
+			IsArchived:  a.IsArchived,
+			ParentId:    a.ParentId,
+			Version:     a.Version,
+		}
+
+		// for CURATOR, may have albums with no images
+		if cover, ok := coverByAlbum[a.Id]; ok {
+			album.Cover = &cover
+			album.Images = []api.ImageData{cover} // listings have always returned the cover as the only image
+			if selected[a.Id] {
+				album.CoverImageId = cover.Id
+			}
+		}
+
+		albums = append(albums, album)
+	}
+
+	return albums, nil
+}
+
+// GetTimeline implements the Service interface method to retrieve a page of the timeline of images the user
+// has permission to view, grouped by year and month, newest first, along with per-month counts.
+func (s *albumService) GetTimeline(
+	ctx context.Context,
+	psMap map[string]exo.PermissionRecord,
+	q api.TimelineQuery,
+) (*api.Timeline, error) {
+
+	// validate the query
+	// redundant check, but good practice
+	if err := q.Validate(); err != nil {
+		return nil, err
+	}
+
+	limit := q.Limit
+	if limit == 0 {
+		limit = api.DefaultPageLimit
+	}
+
+	var cursor *api.TimelineCursor
+	if q.Cursor != "" {
+		c, err := api.DecodeTimelineCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursor = c
+	}
+
+	// jumping to a month always pages towards older images from there
+	newer := q.Direction == api.TimelineNewer && q.At == ""
+	if !newer {
+		q.Direction = api.TimelineOlder
+	}
+
+	// get the page and the per-month counts concurrently
+	var (
+		wg      sync.WaitGroup
+		records []api.ImageRecord
+		counts  []api.TimelineMonthCount
+		pageErr error
+		cntErr  error
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		// one extra record to detect if there is a further page
+		records, pageErr = s.db.FindTimelineImages(psMap, q, cursor, limit+1)
+	}()
+	go func() {
+		defer wg.Done()
+		counts, cntErr = s.db.FindTimelineCounts(psMap, q.From, q.To)
+	}()
+	wg.Wait()
+
+	if pageErr != nil {
+		return nil, fmt.Errorf("failed to retrieve timeline images from database: %v", pageErr)
+	}
+
+	if cntErr != nil {
+		return nil, fmt.Errorf("failed to retrieve timeline month counts from database: %v", cntErr)
+	}
+
+	more := len(records) > limit
+	if more {
+		records = records[:limit]
+	}
+
+	// newer pages are queried oldest first: restore newest first order
+	if newer {
+		slices.Reverse(records)
+	}
+
+	timeline := &api.Timeline{
+		Months: []api.TimelineMonth{},
+		Counts: counts,
+	}
+
+	for _, c := range counts {
+		timeline.Total += c.Count
+	}
 
-	// get allowwed albums based on permissions
-	albums, err := s.db.FindAllowedAlbums(psMap)
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to retrieve allowed albums from database: %v", err)
+	if timeline.Counts == nil {
+		timeline.Counts = []api.TimelineMonthCount{}
 	}
 
-	// handle no albums found
-	if len(albums) == 0 {
-		return nil, []api.AlbumRecord{}, nil
+	// set the cursors for the pages either side of this one
+	if len(records) == 0 {
+		// nothing further in this direction: allow paging back the way the client came
+		if newer {
+			timeline.OlderCursor = q.Cursor
+		} else {
+			timeline.NewerCursor = q.Cursor
+		}
+		return timeline, nil
 	}
 
-	// decrypt the album records
-	var (
-		wg    sync.WaitGroup
-		errCh = make(chan error, len(albums))
+	newest, oldest := timelineCursor(records[0]), timelineCursor(records[len(records)-1])
+	if newer {
+		timeline.OlderCursor = oldest
+		if more {
+			timeline.NewerCursor = newest
+		}
+	} else {
+		if more {
+			timeline.OlderCursor = oldest
+		}
+		if cursor != nil || q.At != "" {
+			timeline.NewerCursor = newest
+		}
+	}
 
-		// build the map oppotunistically while looping through the albums for decryption
-		aMap = make(map[string]api.AlbumRecord, len(albums))
-		mu   sync.Mutex
-	)
+	// build the tiles for the page
+	images := make([]api.ImageData, 0, len(records))
+	dating := make([]imageDating, 0, len(records))
+	for _, r := range records {
+		dating = append(dating, imageDating{precision: r.ImageDatePrecision, endYear: r.ImageDateEndYear})
+		images = append(images, api.ImageData{
+			Id:          r.Id,
+			Title:       r.Title,
+			Description: r.Description,
+			FileName:    r.FileName,
+			FileType:    r.FileType,
+			ObjectKey:   r.ObjectKey,
+			Slug:        r.Slug,
+			Width:       r.Width,
+			Height:      r.Height,
+			Size:        r.Size,
+			ImageDate:   r.ImageDate,
+			CreatedAt:   r.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:   r.UpdatedAt.Format(time.RFC3339),
+			IsArchived:  r.IsArchived,
+			IsPublished: r.IsPublished,
+
+			BlurHash:      r.BlurHash,
+			DominantColor: r.DominantColor,
+		})
+	}
 
-	for i := range albums {
-		wg.Add(1)
-		go func(a *api.AlbumRecord) {
-			defer wg.Done()
-			if err := s.cryptor.DecryptAlbumRecord(a); err != nil {
-				errCh <- fmt.Errorf("failed to decrypt album record '%s': %v", a.Id, err)
-			}
-			// also need to remove the blind index from the album record
-			a.SlugIndex = ""
+	built, err := s.buildImageTiles(ctx, images, dating)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build timeline image data: %v", err)
+	}
 
-			// add to the map
-			mu.Lock()
-			aMap[a.Slug] = *a
-			mu.Unlock()
-		}(&albums[i])
+	// tiles are built concurrently, so restore the timeline order
+	byId := make(map[string]api.ImageData, len(built))
+	for _, img := range built {
+		byId[img.Id] = img
 	}
 
-	wg.Wait()
-	close(errCh)
+	// group by month, preserving the newest first order
+	for _, r := range records {
 
-	// return errors if any decryption failed
-	if len(errCh) > 0 {
-		var errs []error
-		for e := range errCh {
-			errs = append(errs, e)
+		img, ok := byId[r.Id]
+		if !ok {
+			continue
 		}
-		if len(errs) > 0 {
-			return nil, nil, fmt.Errorf("failed to decrypt one or more album records: %v", errors.Join(errs...))
+
+		last := len(timeline.Months) - 1
+		if last < 0 || timeline.Months[last].DateKey != r.ImageDateKey {
+
+			// images known only to the year, or less precisely, are grouped at the start of their year, ie, month 0
+			year, month, err := api.ParseImageDateKey(r.ImageDateKey)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse image date key '%s' for image '%s': %v", r.ImageDateKey, r.Id, err)
+			}
+
+			timeline.Months = append(timeline.Months, api.TimelineMonth{
+				DateKey: r.ImageDateKey,
+				Year:    year,
+				Month:   month,
+				Images:  []api.ImageData{},
+			})
+			last++
 		}
+
+		timeline.Months[last].Images = append(timeline.Months[last].Images, img)
 	}
 
-	return aMap, albums, nil
+	return timeline, nil
 }
 
-// GetAllowedAlbumsData implements the Service interface method to retrieve all album-image records a user has permission to view.
-func (s *albumService) GetAllowedAlbumsData(ctx context.Context, psMap map[string]exo.PermissionRecord) (map[string]api.Album, []api.Album, error) {
+// GetMemories implements the Service interface method to retrieve the "on this day" memories feed:
+// images the user has permission to view taken on the same date, plus or minus window days, in earlier years.
+func (s *albumService) GetMemories(
+	ctx context.Context,
+	psMap map[string]exo.PermissionRecord,
+	day time.Time,
+	window int,
+) (*api.Memories, error) {
+
+	if window < 0 || window > api.MemoriesMaxWindowDays {
+		return nil, fmt.Errorf("window must be between 0 and %d days", api.MemoriesMaxWindowDays)
+	}
+
+	// calendar date only: image dates are compared by their recorded calendar date
+	anchor := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+
+	memories := &api.Memories{
+		Date:     anchor.Format(time.DateOnly),
+		Timezone: day.Location().String(),
+		Window:   window,
+		Years:    []api.MemoriesYear{},
+	}
 
-	// retrieve the album-image records
-	records, err := s.db.FindAllowedAlbumsData(psMap)
+	// the months the window spans, eg, "06", to narrow the candidates in the database
+	months := make([]string, 0, 2)
+	for offset := -window; offset <= window; offset++ {
+		m := anchor.AddDate(0, 0, offset).Format("01")
+		if !slices.Contains(months, m) {
+			months = append(months, m)
+		}
+	}
+
+	// the latest possible memory is the end of the window one year ago
+	latest := anchor.AddDate(-1, 0, window).Format(api.ImageDateKeyLayout)
+
+	candidates, err := s.db.FindMemoryCandidates(psMap, months, latest)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to retrieve allowed album-image records from database: %v", err)
+		return nil, fmt.Errorf("failed to retrieve memory candidates from database: %v", err)
 	}
 
-	if len(records) == 0 {
-		return nil, []api.Album{}, nil
+	if len(candidates) == 0 {
+		return memories, nil
 	}
 
-	// build the albums slice from the records
-	var (
-		wg    sync.WaitGroup
-		errCh = make(chan error, len(records))
+	// decrypt only the image dates of the candidates to find the exact matches
+	type match struct {
+		record   api.ImageRecord
+		taken    time.Time
+		yearsAgo int
+	}
 
-		// build the map oppotunistically while looping through the albums for decryption
-		albumsMap = make(map[string]api.Album, len(records))
-		albums    = make([]api.Album, 0, len(records))
-		mu        sync.Mutex
+	var (
+		wg      sync.WaitGroup
+		matchCh = make(chan match, len(candidates))
+		errCh   = make(chan error, len(candidates))
 	)
 
-	for _, r := range records {
+	for _, c := range candidates {
 		wg.Add(1)
-		go func(r api.AlbumImageRecord, wg *sync.WaitGroup) {
+		go func(r api.ImageRecord) {
 			defer wg.Done()
 
-			mu.Lock()
-			if _, ok := albumsMap[r.AlbumId]; ok {
-				mu.Unlock()
-				return // already loaded/processed
+			imageDate, err := s.cryptor.DecryptImageField(r.ImageDate)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decrypt image date for image '%s': %v", r.Id, err)
+				return
 			}
-			// mark as seen before unlocking to avoid races
-			albumsMap[r.AlbumId] = api.Album{} // placeholder
-			mu.Unlock()
 
-			// // build the album model from the record
-			album := api.Album{
-				Id:          r.AlbumId,
-				Title:       r.AlbumTitle,
-				Description: r.AlbumDescription,
-				Slug:        r.AlbumSlug,
-				CreatedAt:   r.AlbumCreatedAt,
-				UpdatedAt:   r.AlbumUpdatedAt,
-				IsArchived:  r.AlbumIsArchived,
+			taken, err := time.Parse(time.RFC3339, imageDate)
+			if err != nil {
+				s.logger.Warn(fmt.Sprintf("image '%s' has an image date which is not RFC3339, skipping memory", r.Id))
+				return
 			}
 
-			if err := s.cryptor.DecryptAlbum(&album); err != nil {
-				errCh <- fmt.Errorf("failed to decrypt album record '%s': %v", album.Id, err)
+			// redundant check, the query only selects them: only dates known to the day can be memories
+			if d := api.BuildPartialDate(imageDate, r.ImageDatePrecision, r.ImageDateEndYear); d == nil || !d.IsDayKnown() {
 				return
 			}
 
-			// // check if image fields are present
-			// for CURATOR, may have albums with no images
-			if r.ImageId != "" {
-				image, err := s.buildImageData(ctx, []api.AlbumImageRecord{r})
-				if err != nil {
-					errCh <- fmt.Errorf("failed to build image data for album '%s': %v", album.Id, err)
-					return
-				}
-				album.Images = image
+			if yearsAgo, ok := memoryYearsAgo(anchor, taken, window); ok {
+				matchCh <- match{record: r, taken: taken, yearsAgo: yearsAgo}
 			}
-
-			mu.Lock()
-			albumsMap[album.Id] = album
-			albums = append(albums, album)
-			mu.Unlock()
-		}(r, &wg)
+		}(c)
 	}
 
 	wg.Wait()
+	close(matchCh)
 	close(errCh)
 
-	// return errors if any decryption or build operation failed
 	if len(errCh) > 0 {
 		var errs []error
 		for e := range errCh {
 			errs = append(errs, e)
 		}
-		if len(errs) > 0 {
-			return nil, nil, fmt.Errorf("failed build album + image data: %v", errors.Join(errs...))
-		}
+		return nil, fmt.Errorf("failed to decrypt memory candidates: %v", errors.Join(errs...))
 	}
 
-	return albumsMap, albums, nil
-}
+	matches := make([]match, 0, len(matchCh))
+	for m := range matchCh {
+		matches = append(matches, m)
+	}
 
-// GetAlbumBySlug implements the Service interface method to retrieve a specific album record by its slug.
-// It also retrieves a slice of the thumbnail images for the album.
-func (s *albumService) GetAlbumBySlug(
-	ctx context.Context,
-	slug string,
-	psMap map[string]exo.PermissionRecord,
-) (*api.Album, error) {
+	if len(matches) == 0 {
+		return memories, nil
+	}
 
-	// vadidate the slug is well formed
-	// redundant check, but good practice
-	if err := validate.ValidateUuid(slug); err != nil {
-		return nil, fmt.Errorf("invalid album slug: %s", slug)
+	// most recent year first, then oldest image first within the year
+	slices.SortFunc(matches, func(a, b match) int {
+		if a.yearsAgo != b.yearsAgo {
+			return a.yearsAgo - b.yearsAgo
+		}
+		if c := a.taken.Compare(b.taken); c != 0 {
+			return c
+		}
+		return strings.Compare(a.record.Id, b.record.Id)
+	})
+
+	// build the tiles for the matches
+	images := make([]api.ImageData, 0, len(matches))
+	dating := make([]imageDating, 0, len(matches))
+	for _, m := range matches {
+		r := m.record
+		dating = append(dating, imageDating{precision: r.ImageDatePrecision, endYear: r.ImageDateEndYear})
+		images = append(images, api.ImageData{
+			Id:          r.Id,
+			Title:       r.Title,
+			Description: r.Description,
+			FileName:    r.FileName,
+			FileType:    r.FileType,
+			ObjectKey:   r.ObjectKey,
+			Slug:        r.Slug,
+			Width:       r.Width,
+			Height:      r.Height,
+			Size:        r.Size,
+			ImageDate:   r.ImageDate,
+			CreatedAt:   r.CreatedAt.Format(time.RFC3339),
+			UpdatedAt:   r.UpdatedAt.Format(time.RFC3339),
+			IsArchived:  r.IsArchived,
+			IsPublished: r.IsPublished,
+
+			BlurHash:      r.BlurHash,
+			DominantColor: r.DominantColor,
+		})
 	}
 
-	// get album index
-	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	built, err := s.buildImageTiles(ctx, images, dating)
 	if err != nil {
-		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+		return nil, fmt.Errorf("failed to build memories image data: %v", err)
 	}
 
-	// get the album-image records for the album by slug index
-	records, err := s.db.FindAlbumImagesData(slugIndex, psMap)
-	if err != nil {
-		return nil, fmt.Errorf("failed to retrieve album-image records for album slug %s: %v", slug, err)
+	// tiles are built concurrently, so restore the order
+	byId := make(map[string]api.ImageData, len(built))
+	for _, img := range built {
+		byId[img.Id] = img
 	}
 
-	// seocondary check to ensure we have records
-	if len(records) == 0 {
+	// group by year
+	for _, m := range matches {
 
-		// check if the album exists at all
-		if exists, err := s.db.AlbumExists(slugIndex); err != nil {
-			return nil, fmt.Errorf("failed to check if album %s exists: %v", slug, err)
-		} else if !exists {
-			// album exists, but user has no permissions to view any images in the album
-			return nil, fmt.Errorf("album %s was not found", slug)
+		img, ok := byId[m.record.Id]
+		if !ok {
+			continue
 		}
 
-		// check if album archived but is archived
-		if archived, err := s.db.AlbumIsArchived(slugIndex); err != nil {
-			return nil, fmt.Errorf("failed to check if album %s has been archived: %v", slug, err)
-		} else if archived {
-			// album is archived
-			return nil, fmt.Errorf("album %s is archived", slug)
+		last := len(memories.Years) - 1
+		if last < 0 || memories.Years[last].YearsAgo != m.yearsAgo {
+			memories.Years = append(memories.Years, api.MemoriesYear{
+				Year:     anchor.Year() - m.yearsAgo,
+				YearsAgo: m.yearsAgo,
+				Images:   []api.ImageData{},
+			})
+			last++
 		}
 
-		// this could be permissions, images could be archived, or unpublished.   Could also be no images in album.
-		return nil, fmt.Errorf("user has no access to any images in album %s", slug)
+		memories.Years[last].Images = append(memories.Years[last].Images, img)
 	}
 
-	// build the album modelfrom the first record
-	album := &api.Album{
-		Id:          records[0].AlbumId,
-		Title:       records[0].AlbumTitle,
-		Description: records[0].AlbumDescription,
-		Slug:        records[0].AlbumSlug,
-		CreatedAt:   records[0].AlbumCreatedAt,
-		UpdatedAt:   records[0].AlbumUpdatedAt,
-		IsArchived:  records[0].AlbumIsArchived,
-		// Images slice will be populated below after additional operations
-	}
+	return memories, nil
+}
 
-	// decrypt the album record
-	if err := s.cryptor.DecryptAlbum(album); err != nil {
-		return nil, fmt.Errorf("failed to decrypt album record '%s': %v", album.Id, err)
-	}
+// memoryYearsAgo is a helper which determines if an image taken at the given time is a memory of the anchor date,
+// ie, within window days of the anchor date in an earlier year, and if so, how many years ago.
+// Note: windows can cross new year, so the year of the memory may differ from the year the image was taken.
+func memoryYearsAgo(anchor, taken time.Time, window int) (int, bool) {
 
-	// build the images slice from the records
-	// includes decryption of sensitive fields and
-	// getting presigned links to the image thumbnails
-	images, err := s.buildImageData(ctx, records)
-	if err != nil {
-		return nil, fmt.Errorf("failed to build image data for album slug %s: %v", slug, err)
+	date := time.Date(taken.Year(), taken.Month(), taken.Day(), 0, 0, 0, 0, time.UTC)
+	limit := time.Duration(window) * 24 * time.Hour
+
+	years := anchor.Year() - date.Year()
+	for k := max(years-1, 1); k <= years+1; k++ {
+
+		diff := date.Sub(anchor.AddDate(-k, 0, 0))
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff <= limit {
+			return k, true
+		}
 	}
 
-	// set the images slice on the album
-	album.Images = images
+	return 0, false
+}
 
-	return album, nil
+// timelineCursor is a helper which builds the opaque timeline cursor positioned at an image record.
+func timelineCursor(r api.ImageRecord) string {
+	return api.TimelineCursor{
+		DateKey:   r.ImageDateKey,
+		CreatedAt: r.CreatedAt.UTC().Format("2006-01-02 15:04:05"),
+		Id:        r.Id,
+	}.Encode()
 }
 
 // buildImageData takes the fields from a AlbumImageRecords and builds an slice of decrypted ImageData structs
@@ -331,52 +1707,94 @@ func (s *albumService) buildImageData(ctx context.Context, records []api.AlbumIm
 
 	// build the image data slice
 	images := make([]api.ImageData, 0, len(records))
+	dating := make([]imageDating, 0, len(records))
+	for i, r := range records {
+
+		// possible all fields will be empty if no images are attached to the album
+		// if the id is empty, very likely all fields are empty
+		if r.ImageId == "" {
+			log.Warn(fmt.Sprintf("image index[%d] fields are empty for album %s: %s", i, r.AlbumId, r.AlbumTitle))
+			continue
+		}
+
+		dating = append(dating, imageDating{precision: r.ImageDatePrecision, endYear: r.ImageDateEndYear})
+		images = append(images, api.ImageData{
+			Id:          r.ImageId,
+			Title:       r.ImageTitle,
+			Description: r.ImageDescription,
+			FileName:    r.FileName,
+			FileType:    r.FileType,
+			ObjectKey:   r.ObjectKey,
+			Slug:        r.ImageSlug,
+			Width:       r.Width,
+			Height:      r.Height,
+			Size:        r.Size,
+			// ImageTargets: to be populated below
+			// BlurUrl: to be populated below
+			ImageDate:   r.ImageDate,
+			CreatedAt:   r.ImageCreatedAt,
+			UpdatedAt:   r.ImageUpdatedAt,
+			IsArchived:  r.ImageIsArchived,
+			IsPublished: r.ImageIsPublished,
+
+			BlurHash:      r.BlurHash,
+			DominantColor: r.DominantColor,
+		})
+	}
+
+	return s.buildImageTiles(ctx, images, dating)
+}
+
+// imageDating is the unencrypted precision and end year of an image's date, which are needed
+// to build the structured date once the image date has been decrypted.
+type imageDating struct {
+	precision string
+	endYear   int
+}
+
+// buildImageTiles takes encrypted ImageData structs and concurrently decrypts them and gets the
+// presigned URLs for their thumbnail/tile images and legacy blur placeholder.
+// dating is the precision and end year of each image's date, in the same order as images.
+// Note: the returned slice is not in the same order as the input.
+func (s *albumService) buildImageTiles(ctx context.Context, images []api.ImageData, dating []imageDating) ([]api.ImageData, error) {
+
+	if len(dating) != len(images) {
+		return nil, fmt.Errorf("image dating count %d does not match image count %d", len(dating), len(images))
+	}
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for buildImageTiles")
+	}
+
+	if len(images) == 0 {
+		return []api.ImageData{}, nil
+	}
 
 	var (
 		wg    sync.WaitGroup
-		imgCh = make(chan api.ImageData, len(records))
-		errCh = make(chan error, len(records))
+		imgCh = make(chan api.ImageData, len(images))
+		errCh = make(chan error, len(images))
 	)
 
-	for i, r := range records {
+	for i := range images {
 		wg.Add(1)
-		go func(i int, r api.AlbumImageRecord) {
+		go func(img *api.ImageData, d imageDating) {
 			defer wg.Done()
 
-			// build the image data struct
-			img := &api.ImageData{
-				Id:          r.ImageId,
-				Title:       r.ImageTitle,
-				Description: r.ImageDescription,
-				FileName:    r.FileName,
-				FileType:    r.FileType,
-				ObjectKey:   r.ObjectKey,
-				Slug:        r.ImageSlug,
-				Width:       r.Width,
-				Height:      r.Height,
-				Size:        r.Size,
-				// ImageTargets: to be populated below
-				// BlurUrl: to be populated below
-				ImageDate:   r.ImageDate,
-				CreatedAt:   r.ImageCreatedAt,
-				UpdatedAt:   r.ImageUpdatedAt,
-				IsArchived:  r.ImageIsArchived,
-				IsPublished: r.ImageIsPublished,
-			}
-
-			// possible all fields will be empty if no images are attached to the album
-			// if the id is empty, very likely all fields are empty
-			if img.Id == "" {
-				log.Warn(fmt.Sprintf("image index[%d] fields are empty for album %s: %s", i, r.AlbumId, r.AlbumTitle))
-				return
-			}
-
 			// decrypt the sensitive fields in the image data
 			if err := s.cryptor.DecryptImageData(img); err != nil {
 				errCh <- fmt.Errorf("failed to decrypt image data '%s': %v", img.Id, err)
 				return
 			}
 
+			// the structured date can only be built from the decrypted image date
+			img.Date = api.BuildPartialDate(img.ImageDate, d.precision, d.endYear)
+
 			// get the directory of the image object key
 			dir, _, ext, slug, err := pipeline.ParseObjectKey(img.ObjectKey)
 			if err != nil {
@@ -405,25 +1823,28 @@ func (s *albumService) buildImageData(ctx context.Context, records []api.AlbumIm
 			}
 
 			// get the signed URL for the blur placeholder image
-			targetsWg.Add(1)
-			go func() {
-				defer targetsWg.Done()
+			// only needed for legacy images: the inline blur hash replaces it once computed by the pipeline
+			if img.BlurHash == "" {
+				targetsWg.Add(1)
+				go func() {
+					defer targetsWg.Done()
 
-				blurKey := fmt.Sprintf("%s/%s_blur%s", dir, slug, ext)
+					blurKey := fmt.Sprintf("%s/%s_blur%s", dir, slug, ext)
 
-				url, err := s.store.GetSignedUrl(ctx, blurKey)
-				if err != nil {
-					log.Error(fmt.Sprintf("failed to get signed URL for blur object key '%s': %v", blurKey, err))
-					return
-				}
+					url, err := s.store.GetSignedUrl(ctx, blurKey)
+					if err != nil {
+						log.Error(fmt.Sprintf("failed to get signed URL for blur object key '%s': %v", blurKey, err))
+						return
+					}
 
-				if url == nil || url.String() == "" {
-					targetsErrCh <- fmt.Errorf("received empty signed URL for blur object key '%s'", blurKey)
-					return
-				}
+					if url == nil || url.String() == "" {
+						targetsErrCh <- fmt.Errorf("received empty signed URL for blur object key '%s'", blurKey)
+						return
+					}
 
-				blurCh <- url.String()
-			}()
+					blurCh <- url.String()
+				}()
+			}
 
 			// wait for all goroutines to finish
 			targetsWg.Wait()
@@ -464,14 +1885,14 @@ func (s *albumService) buildImageData(ctx context.Context, records []api.AlbumIm
 			// front end will need to gracefully handle missing images
 			if len(blurCh) > 0 {
 				img.BlurUrl = <-blurCh
-			} else {
+			} else if img.BlurHash == "" {
 				log.Error(fmt.Sprintf("no blur signed url found for image '%s'", img.Slug))
 			}
 
 			// send to the channel
 			imgCh <- *img
 
-		}(i, r)
+		}(&images[i], dating[i])
 	}
 
 	wg.Wait()
@@ -491,12 +1912,13 @@ func (s *albumService) buildImageData(ctx context.Context, records []api.AlbumIm
 		}
 	}
 
+	built := make([]api.ImageData, 0, len(images))
 	for img := range imgCh {
-		images = append(images, img)
+		built = append(built, img)
 	}
 
 	// return the images slice
-	return images, nil
+	return built, nil
 }
 
 // CreateAlbum implements the Service interface method to create a new album record in the database.
@@ -541,6 +1963,7 @@ func (s *albumService) CreateAlbum(cmd api.AddAlbumCmd) (*api.AlbumRecord, error
 		CreatedAt:   data.CustomTime{Time: now},
 		UpdatedAt:   data.CustomTime{Time: now},
 		IsArchived:  cmd.IsArchived,
+		Version:     1,
 	}
 	// encrypt the sensitive fields in the album record
 	if err := s.cryptor.EncryptAlbumRecord(album); err != nil {
@@ -586,14 +2009,22 @@ func (s *albumService) UpdateAlbum(updated api.AlbumRecord) error {
 	}
 
 	// build the update query
-	if err := s.db.UpdateAlbum(updated); err != nil {
+	// the update only applies if the album is still at the version the edit was based on
+	ok, err := s.db.UpdateAlbum(updated)
+	if err != nil {
 		return fmt.Errorf("failed to update album record '%s': %v", updated.Id, err)
 	}
 
+	if !ok {
+		return fmt.Errorf("album record '%s' version %d is stale: it was changed by someone else", updated.Id, updated.Version)
+	}
+
 	return nil
 }
 
-func (s *albumService) InsertAlbumImageXref(albumId, imageId string) error {
+// InsertAlbumImageXref is the concrete implementation of the interface method which associates an image
+// with an album, granting the image the album's permissions if the album passes them on.
+func (s *albumService) InsertAlbumImageXref(ctx context.Context, albumId, imageId string) error {
 
 	// validate the album id and image id
 	// redundant check, but good practice
@@ -604,17 +2035,36 @@ func (s *albumService) InsertAlbumImageXref(albumId, imageId string) error {
 		return fmt.Errorf("invalid image id: %s", imageId)
 	}
 
-	// build the xref record
-	xref := api.AlbumImageXref{
-		Id:        0, // auto-increment
-		AlbumId:   albumId,
-		ImageId:   imageId,
-		CreatedAt: data.CustomTime{Time: time.Now().UTC()},
+	now := data.CustomTime{Time: time.Now().UTC()}
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+		return s.linkAlbumImages(ctx, tx, albumId, []string{imageId}, now)
+	}); err != nil {
+		return fmt.Errorf("failed to insert album-image xref record into database: %v", err)
 	}
 
-	// insert the xref record into the database
-	if err := s.db.InsertAlbumImageXref(xref); err != nil {
-		return fmt.Errorf("failed to insert album-image xref record into database: %v", err)
+	return nil
+}
+
+// albumImageBatchSize is the most images added to an album in a single statement, eg, when a smart album
+// is frozen, keeping statements well within placeholder limits.
+const albumImageBatchSize = 500
+
+// linkAlbumImages is a helper which adds images to an album within a unit of work, granting the images not
+// yet in the album the album's permissions if it passes them on.  Every path adding images to an album goes
+// through it, so an image never joins an album without the permissions it inherits.
+func (s *albumService) linkAlbumImages(ctx context.Context, tx transaction.Tx, albumId string, imageIds []string, now data.CustomTime) error {
+
+	// in batches to bound the size of each statement
+	for batch := range slices.Chunk(imageIds, albumImageBatchSize) {
+
+		// permissions are inherited before the images are added, since images already in the album are skipped
+		if err := s.db.InsertInheritedImagePermissionsTx(ctx, tx, albumId, batch, now); err != nil {
+			return fmt.Errorf("failed to grant %d images the permissions of album '%s': %v", len(batch), albumId, err)
+		}
+
+		if err := s.db.InsertAlbumImageXrefsTx(ctx, tx, albumId, batch, now); err != nil {
+			return fmt.Errorf("failed to add %d images to album '%s': %v", len(batch), albumId, err)
+		}
 	}
 
 	return nil
@@ -658,5 +2108,126 @@ func (s *albumService) DeleteAlbumImageXrefs(imageId string) error {
 		return fmt.Errorf("failed to delete album-image xref record from database: %v", err)
 	}
 
+	return nil
+}
+
+// ReorderAlbumImages implements the Service interface method to manually order the images within an album.
+// The listed images are moved, in order, to directly after the command's after image, or to the start of the
+// album; all other images keep their relative order.  Every image in the album is then given a position so
+// the album order is stable, and images added later, with no position, sort after them by image date.
+func (s *albumService) ReorderAlbumImages(ctx context.Context, slug string, cmd api.AlbumOrderCmd) error {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for ReorderAlbumImages")
+	}
+
+	// validate the album slug and order command
+	// redundant check, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return err
+	}
+
+	// get album index
+	albumIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	// get the current order of all of the album's images
+	current, err := s.db.FindAlbumImagePositions(albumIndex)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve image positions for album slug %s: %v", slug, err)
+	}
+
+	if len(current) == 0 {
+		if exists, err := s.db.AlbumExists(albumIndex); err != nil {
+			return fmt.Errorf("failed to check if album %s exists: %v", slug, err)
+		} else if !exists {
+			return fmt.Errorf("album %s was not found", slug)
+		}
+		return fmt.Errorf("image slug %s is not in album %s", cmd.Images[0], slug)
+	}
+
+	// image slugs are encrypted, so look up the album's images by their slugs' blind indexes
+	byIndex := make(map[string]int, len(current))
+	for i, p := range current {
+		byIndex[p.ImageSlugIndex] = i
+	}
+
+	moved := make([]AlbumImagePosition, 0, len(cmd.Images))
+	isMoved := make(map[int]bool, len(cmd.Images))
+	for _, imgSlug := range cmd.Images {
+		index, err := s.indexer.ObtainBlindIndex(imgSlug)
+		if err != nil {
+			return fmt.Errorf("failed to obtain blind index for image slug '%s': %v", imgSlug, err)
+		}
+
+		i, ok := byIndex[index]
+		if !ok {
+			return fmt.Errorf("image slug %s is not in album %s", imgSlug, slug)
+		}
+
+		moved = append(moved, current[i])
+		isMoved[i] = true
+	}
+
+	afterId := ""
+	if cmd.After != "" {
+		index, err := s.indexer.ObtainBlindIndex(cmd.After)
+		if err != nil {
+			return fmt.Errorf("failed to obtain blind index for image slug '%s': %v", cmd.After, err)
+		}
+
+		i, ok := byIndex[index]
+		if !ok {
+			return fmt.Errorf("after image slug %s is not in album %s", cmd.After, slug)
+		}
+		afterId = current[i].ImageId
+	}
+
+	// build the new order: the images which are not moved, with the moved images inserted after the after image
+	ordered := make([]AlbumImagePosition, 0, len(current))
+	if afterId == "" {
+		ordered = append(ordered, moved...)
+	}
+	for i, p := range current {
+		if isMoved[i] {
+			continue
+		}
+		ordered = append(ordered, p)
+		if p.ImageId == afterId {
+			ordered = append(ordered, moved...)
+		}
+	}
+
+	// only update the positions that changed
+	var changed []AlbumImagePosition
+	for i := range ordered {
+		if ordered[i].Position != i+1 {
+			ordered[i].Position = i + 1
+			changed = append(changed, ordered[i])
+		}
+	}
+
+	if len(changed) == 0 {
+		log.Warn(fmt.Sprintf("order command executed, but no image positions changed in album slug %s", slug))
+		return nil
+	}
+
+	if err := s.db.UpdateAlbumImagePositions(changed); err != nil {
+		return fmt.Errorf("failed to update image positions for album slug %s: %v", slug, err)
+	}
+
+	log.Info(fmt.Sprintf("updated %d image positions in album slug %s", len(changed), slug))
+
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,79 @@
+package album
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+)
+
+// ExportRepository is the interface for data operations related to exporting albums.
+type ExportRepository interface {
+
+	// FindDownloadableImageIds retrieves the uuids of the images, of those given, for which at least one
+	// of the user's permissions is marked as allowed to download the untouched original.
+	FindDownloadableImageIds(imageIds []string, psMap map[string]exo.PermissionRecord) (map[string]struct{}, error)
+}
+
+// NewExportRepository creates a new instance of ExportRepository.
+func NewExportRepository(db *sql.DB) ExportRepository {
+	return &exportAdapter{
+		db: db,
+	}
+}
+
+var _ ExportRepository = (*exportAdapter)(nil) // compile-time interface check
+
+// exportAdapter is a concrete implementation of ExportRepository.
+type exportAdapter struct {
+	db *sql.DB
+}
+
+// downloadableImage is a model which represents the uuid of an image the user may download the original of.
+type downloadableImage struct {
+	ImageId string `db:"image_uuid"`
+}
+
+// FindDownloadableImageIds retrieves the uuids of the images, of those given, for which at least one
+// of the user's permissions is marked as allowed to download the untouched original.
+func (e *exportAdapter) FindDownloadableImageIds(imageIds []string, psMap map[string]exo.PermissionRecord) (map[string]struct{}, error) {
+
+	downloadable := make(map[string]struct{})
+
+	// no permissions means nothing could be marked downloadable
+	if len(imageIds) == 0 || len(psMap) == 0 {
+		return downloadable, nil
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+		SELECT DISTINCT ip.image_uuid
+		FROM image_permission ip
+		WHERE ip.can_download = TRUE
+			AND ip.image_uuid IN (`)
+	writePlaceholders(&qb, len(imageIds))
+	qb.WriteString(`)
+			AND ip.permission_uuid IN (`)
+	writePlaceholders(&qb, len(psMap))
+	qb.WriteString(")")
+
+	args := make([]interface{}, 0, len(imageIds)+len(psMap))
+	for _, id := range imageIds {
+		args = append(args, id)
+	}
+	for _, p := range psMap {
+		args = append(args, p.Id)
+	}
+
+	records, err := data.SelectRecords[downloadableImage](e.db, qb.String(), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, r := range records {
+		downloadable[r.ImageId] = struct{}{}
+	}
+
+	return downloadable, nil
+}
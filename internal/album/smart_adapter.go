@@ -0,0 +1,277 @@
+package album
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// SmartRepository is the interface for data operations related to smart albums.
+type SmartRepository interface {
+
+	// FindSmartAlbum retrieves the smart album record of an album by the album's slug index.
+	// Returns nil and no error if the album is not a smart album.
+	FindSmartAlbum(albumSlugIndex string) (*SmartAlbumRecord, error)
+
+	// FindSmartAlbums retrieves all smart album records.
+	FindSmartAlbums() ([]SmartAlbumRecord, error)
+
+	// FindSmartAlbumImagesData retrieves the album-image records of the images matching a smart album's rules
+	// which the user has access to see via permissions.
+	FindSmartAlbumImagesData(albumId string, f SmartAlbumFilter, psMap map[string]exo.PermissionRecord) ([]api.AlbumImageRecord, error)
+
+	// FindSmartAlbumHead retrieves a single album-image record of the images matching a smart album's rules which
+	// the user has access to see via permissions, for the album's fields: the album's selected cover image, if it is
+	// one of them.  Returns nil and no error if there are no such records.
+	FindSmartAlbumHead(albumId string, f SmartAlbumFilter, psMap map[string]exo.PermissionRecord) (*api.AlbumImageRecord, error)
+
+	// CountSmartAlbumImages counts the images matching a smart album's rules which the user has access to see via permissions.
+	CountSmartAlbumImages(albumId string, f SmartAlbumFilter, psMap map[string]exo.PermissionRecord) (int, error)
+
+	// FindSmartAlbumImagesPage retrieves a page of the album-image records of the images matching a smart album's
+	// rules which the user has access to see via permissions, after the cursor in the page query's sort order.
+	// Note: the limit is passed separately so the caller can ask for an extra record to detect further pages.
+	FindSmartAlbumImagesPage(
+		albumId string,
+		f SmartAlbumFilter,
+		psMap map[string]exo.PermissionRecord,
+		q api.PageQuery,
+		cursor *api.PageCursor,
+		limit int,
+	) ([]api.AlbumImageRecord, error)
+
+	// PermissionExists checks if a permission exists by its slug index.
+	PermissionExists(slugIndex string) (bool, error)
+
+	// UpsertSmartAlbum inserts a smart album record, or updates the rules of an existing one.
+	// Note: the rules must be encrypted before calling this method.
+	UpsertSmartAlbum(record SmartAlbumRecord) error
+
+	// DeleteSmartAlbum deletes the smart album record of an album by the album's uuid, making it a regular album.
+	DeleteSmartAlbum(albumId string) error
+}
+
+// NewSmartRepository creates a new instance of SmartRepository.
+func NewSmartRepository(db *sql.DB) SmartRepository {
+	// implementation details
+	return &smartAdapter{
+		db: db,
+	}
+}
+
+var _ SmartRepository = (*smartAdapter)(nil) // compile-time interface check
+
+// smartAdapter is a concrete implementation of SmartRepository.
+type smartAdapter struct {
+	db *sql.DB
+}
+
+// FindSmartAlbum retrieves the smart album record of an album by the album's slug index.
+// Returns nil and no error if the album is not a smart album.
+func (s *smartAdapter) FindSmartAlbum(albumSlugIndex string) (*SmartAlbumRecord, error) {
+
+	qry := `
+		SELECT
+			sa.album_uuid,
+			sa.rules,
+			sa.created_at,
+			sa.updated_at
+		FROM smart_album sa
+			INNER JOIN album a ON sa.album_uuid = a.uuid
+		WHERE a.slug_index = ?`
+
+	record, err := data.SelectOneRecord[SmartAlbumRecord](s.db, qry, albumSlugIndex)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// FindSmartAlbums retrieves all smart album records.
+func (s *smartAdapter) FindSmartAlbums() ([]SmartAlbumRecord, error) {
+
+	qry := `
+		SELECT
+			album_uuid,
+			rules,
+			created_at,
+			updated_at
+		FROM smart_album`
+
+	return data.SelectRecords[SmartAlbumRecord](s.db, qry)
+}
+
+// FindSmartAlbumImagesData retrieves the album-image records of the images matching a smart album's rules
+// which the user has access to see via permissions.
+func (s *smartAdapter) FindSmartAlbumImagesData(
+	albumId string,
+	f SmartAlbumFilter,
+	psMap map[string]exo.PermissionRecord,
+) ([]api.AlbumImageRecord, error) {
+
+	// build the smart album query with the users permissions
+	qry, err := BuildSmartAlbumImagesQuery(psMap, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build smart album images query: %v", err)
+	}
+
+	// execute query
+	return data.SelectRecords[api.AlbumImageRecord](s.db, qry, smartAlbumImagesArgs(albumId, f, psMap)...)
+}
+
+// FindSmartAlbumHead retrieves a single album-image record of the images matching a smart album's rules which
+// the user has access to see via permissions, for the album's fields: the album's selected cover image, if it is
+// one of them.  Returns nil and no error if there are no such records.
+func (s *smartAdapter) FindSmartAlbumHead(
+	albumId string,
+	f SmartAlbumFilter,
+	psMap map[string]exo.PermissionRecord,
+) (*api.AlbumImageRecord, error) {
+
+	base, err := BuildSmartAlbumImagesQuery(psMap, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build smart album images query: %v", err)
+	}
+
+	return selectAlbumHead(s.db, base, smartAlbumImagesArgs(albumId, f, psMap))
+}
+
+// CountSmartAlbumImages counts the images matching a smart album's rules which the user has access to see via permissions.
+func (s *smartAdapter) CountSmartAlbumImages(
+	albumId string,
+	f SmartAlbumFilter,
+	psMap map[string]exo.PermissionRecord,
+) (int, error) {
+
+	base, err := BuildSmartAlbumImagesQuery(psMap, f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to build smart album images query: %v", err)
+	}
+
+	return selectCount(s.db, BuildAlbumImagesCountQuery(base), smartAlbumImagesArgs(albumId, f, psMap))
+}
+
+// FindSmartAlbumImagesPage retrieves a page of the album-image records of the images matching a smart album's
+// rules which the user has access to see via permissions, after the cursor in the page query's sort order.
+func (s *smartAdapter) FindSmartAlbumImagesPage(
+	albumId string,
+	f SmartAlbumFilter,
+	psMap map[string]exo.PermissionRecord,
+	q api.PageQuery,
+	cursor *api.PageCursor,
+	limit int,
+) ([]api.AlbumImageRecord, error) {
+
+	base, err := BuildSmartAlbumImagesQuery(psMap, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build smart album images query: %v", err)
+	}
+
+	return selectAlbumImagesPage(s.db, base, smartAlbumImagesArgs(albumId, f, psMap), q, cursor, limit)
+}
+
+// smartAlbumImagesArgs is a helper which builds the args for a query built by BuildSmartAlbumImagesQuery,
+// in the order they appear in the query: rules, album uuid, permissions.
+func smartAlbumImagesArgs(albumId string, f SmartAlbumFilter, psMap map[string]exo.PermissionRecord) []interface{} {
+
+	args := smartAlbumFilterArgs(f)
+	args = append(args, albumId)
+	// if user is curator, no need to filter by permissions
+	if _, ok := psMap["CURATOR"]; !ok {
+		for _, p := range psMap {
+			args = append(args, p.Id)
+		}
+	}
+
+	return args
+}
+
+// smartAlbumFilterArgs is a helper which builds the args for the smart album rules,
+// in the order they appear in the query, see writeSmartAlbumRules.
+func smartAlbumFilterArgs(f SmartAlbumFilter) []interface{} {
+
+	args := make([]interface{}, 0,
+		len(f.FileTypes)+len(f.PermissionIndexes)+len(f.InAlbumIndexes)+len(f.NotInAlbumIndexes)+6)
+
+	if f.From != "" {
+		args = append(args, api.ImageDateKeyLowerBound(f.From))
+	}
+	if f.To != "" {
+		args = append(args, f.To)
+	}
+
+	for _, ft := range f.FileTypes {
+		args = append(args, ft)
+	}
+
+	if f.Published != nil {
+		args = append(args, *f.Published)
+	}
+	if f.Archived != nil {
+		args = append(args, *f.Archived)
+	}
+	if f.HasGps != nil {
+		args = append(args, *f.HasGps)
+	}
+
+	for _, idx := range f.PermissionIndexes {
+		args = append(args, idx)
+	}
+	for _, idx := range f.InAlbumIndexes {
+		args = append(args, idx)
+	}
+	for _, idx := range f.NotInAlbumIndexes {
+		args = append(args, idx)
+	}
+
+	return args
+}
+
+// PermissionExists checks if a permission exists by its slug index.
+func (s *smartAdapter) PermissionExists(slugIndex string) (bool, error) {
+
+	qry := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM permission
+			WHERE slug_index = ?
+		)`
+
+	return data.SelectExists(s.db, qry, slugIndex)
+}
+
+// UpsertSmartAlbum inserts a smart album record, or updates the rules of an existing one.
+// Note: the rules must be encrypted before calling this method.
+func (s *smartAdapter) UpsertSmartAlbum(record SmartAlbumRecord) error {
+
+	qry := `
+		INSERT INTO smart_album (
+			album_uuid,
+			rules,
+			created_at,
+			updated_at
+		) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE
+			rules = VALUES(rules),
+			updated_at = VALUES(updated_at)`
+
+	return data.InsertRecord(s.db, qry, record)
+}
+
+// DeleteSmartAlbum deletes the smart album record of an album by the album's uuid, making it a regular album.
+func (s *smartAdapter) DeleteSmartAlbum(albumId string) error {
+
+	qry := `
+		DELETE FROM smart_album
+		WHERE album_uuid = ?`
+
+	return data.DeleteRecord(s.db, qry, albumId)
+}
@@ -0,0 +1,368 @@
+package album
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// HandleSmartRules is the concrete implementation of the interface method which handles requests
+// to view, set, and remove the rules of a smart album.
+func (h *albumHandler) HandleSmartRules(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGetSmartAlbum(w, r)
+		return
+	case http.MethodPut:
+		h.handleSetSmartAlbumRules(w, r)
+		return
+	case http.MethodDelete:
+		h.handleRemoveSmartAlbumRules(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// HandleFreeze is the concrete implementation of the interface method which handles requests
+// to freeze a smart album into a regular album.
+func (h *albumHandler) HandleFreeze(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodPost:
+		h.handleFreezeSmartAlbum(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleGetSmartAlbum handles the retrieval of the stored rules of a smart album.
+func (h *albumHandler) handleGetSmartAlbum(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// the rules may reference images and albums the user cannot otherwise see
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "manage smart albums", w) {
+		log.Error(fmt.Sprintf("user is not permitted to view the rules of album slug %s", slug))
+		return
+	}
+
+	smart, err := h.svc.GetSmartAlbum(ctx, slug)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to retrieve rules of smart album slug %s", slug), "err", err.Error())
+		h.respondSmartAlbumErr(err, slug, w)
+		return
+	}
+
+	log.Info(fmt.Sprintf("successfully retrieved rules of smart album slug %s", slug))
+
+	connect.SendJsonSuccess(w, http.StatusOK, smart)
+}
+
+// handleSetSmartAlbumRules handles setting the rules of a smart album, making an empty album a smart album.
+func (h *albumHandler) handleSetSmartAlbumRules(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(editAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(editAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// decode the request body into an cmd record
+	var cmd api.SmartAlbumRulesCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode smart album rules command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode smart album rules command",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the rules command
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate smart album rules command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// only curators may set the rules: they select from every image, whatever its permissions
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "manage smart albums", w) {
+		log.Error(fmt.Sprintf("user is not permitted to set the rules of album slug %s", slug))
+		return
+	}
+
+	smart, err := h.svc.SetSmartAlbumRules(ctx, slug, cmd)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to set rules of smart album slug %s", slug), "err", err.Error())
+		h.respondSmartAlbumErr(err, slug, w)
+		return
+	}
+
+	// audit log
+	log.Info(fmt.Sprintf("successfully set rules of smart album slug %s", slug))
+
+	connect.SendJsonSuccess(w, http.StatusOK, smart)
+}
+
+// handleRemoveSmartAlbumRules handles removing the rules of a smart album, leaving it an empty regular album.
+func (h *albumHandler) handleRemoveSmartAlbumRules(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(editAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(editAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "manage smart albums", w) {
+		log.Error(fmt.Sprintf("user is not permitted to remove the rules of album slug %s", slug))
+		return
+	}
+
+	if err := h.svc.RemoveSmartAlbumRules(ctx, slug); err != nil {
+		log.Error(fmt.Sprintf("failed to remove rules of smart album slug %s", slug), "err", err.Error())
+		h.respondSmartAlbumErr(err, slug, w)
+		return
+	}
+
+	// audit log
+	log.Info(fmt.Sprintf("successfully removed rules of smart album slug %s", slug))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleFreezeSmartAlbum handles freezing a smart album into a regular album of the images matching its rules.
+func (h *albumHandler) handleFreezeSmartAlbum(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(editAlbumAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(editAlbumAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("album slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// freezing adds every matching image, whatever its permissions
+	if !h.isCurator(ctx, authedUser.Claims.Subject, "manage smart albums", w) {
+		log.Error(fmt.Sprintf("user is not permitted to freeze album slug %s", slug))
+		return
+	}
+
+	frozen, err := h.svc.FreezeSmartAlbum(ctx, slug)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to freeze smart album slug %s", slug), "err", err.Error())
+		h.respondSmartAlbumErr(err, slug, w)
+		return
+	}
+
+	// audit log
+	log.Info(fmt.Sprintf("successfully froze smart album slug %s with %d images", slug, frozen.ImageCount))
+
+	connect.SendJsonSuccess(w, http.StatusOK, frozen)
+}
+
+// respondSmartAlbumErr is a helper which maps smart album service errors to http responses.
+func (h *albumHandler) respondSmartAlbumErr(err error, slug string, w http.ResponseWriter) {
+
+	switch {
+	case strings.Contains(err.Error(), "was not found"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusNotFound,
+			Message:    fmt.Sprintf("album %s was not found", slug),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "is not a smart album"),
+		strings.Contains(err.Error(), "only an empty album"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusConflict,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	case strings.Contains(err.Error(), "does not exist"),
+		strings.Contains(err.Error(), "cannot reference itself"),
+		strings.Contains(err.Error(), "invalid"):
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+	default:
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to process smart album",
+		}
+		e.SendJsonErr(w)
+	}
+}
@@ -0,0 +1,519 @@
+package album
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/data"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/transaction"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// GetSmartAlbum implements the Service interface method to retrieve the stored rules of a smart album by its slug.
+func (s *albumService) GetSmartAlbum(ctx context.Context, slug string) (*api.SmartAlbum, error) {
+
+	record, err := s.findSmartAlbum(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := s.cryptor.DecryptSmartAlbumRules(record.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt rules of smart album slug %s: %v", slug, err)
+	}
+
+	return &api.SmartAlbum{
+		AlbumSlug: slug,
+		Rules:     *rules,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}, nil
+}
+
+// SetSmartAlbumRules implements the Service interface method to set the rules of a smart album.
+// Only an empty album may be made a smart album, so no images are hidden by the rules replacing its contents.
+func (s *albumService) SetSmartAlbumRules(ctx context.Context, slug string, cmd api.SmartAlbumRulesCmd) (*api.SmartAlbum, error) {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for SetSmartAlbumRules")
+	}
+
+	// redundant check, but good practice
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+
+	// regardless of permissions, the album must exist and, unless it is already smart, be empty
+	album, err := s.GetAlbumMembers(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+
+	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	existing, err := s.smart.FindSmartAlbum(slugIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve smart album record for album slug %s: %v", slug, err)
+	}
+
+	if existing == nil && len(album.Images) > 0 {
+		return nil, fmt.Errorf("album %s has images: only an empty album can become a smart album", slug)
+	}
+
+	// a smart album cannot select by its own membership: it has none
+	if slices.Contains(cmd.Rules.InAlbumSlugs, slug) || slices.Contains(cmd.Rules.NotInAlbumSlugs, slug) {
+		return nil, fmt.Errorf("smart album %s cannot reference itself", slug)
+	}
+
+	// check the referenced albums and permissions exist so a typo does not silently match nothing
+	if _, err := s.buildSmartAlbumFilter(cmd.Rules, true); err != nil {
+		return nil, err
+	}
+
+	encrypted, err := s.cryptor.EncryptSmartAlbumRules(cmd.Rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt rules of smart album slug %s: %v", slug, err)
+	}
+
+	now := data.CustomTime{Time: time.Now().UTC()}
+	record := SmartAlbumRecord{
+		AlbumId:   album.Id,
+		Rules:     encrypted,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if existing != nil {
+		record.CreatedAt = existing.CreatedAt
+	}
+
+	if err := s.smart.UpsertSmartAlbum(record); err != nil {
+		return nil, fmt.Errorf("failed to save rules of smart album slug %s: %v", slug, err)
+	}
+
+	log.Info(fmt.Sprintf("set rules of smart album slug %s", slug))
+
+	return &api.SmartAlbum{
+		AlbumSlug: slug,
+		Rules:     cmd.Rules,
+		CreatedAt: record.CreatedAt,
+		UpdatedAt: record.UpdatedAt,
+	}, nil
+}
+
+// RemoveSmartAlbumRules implements the Service interface method to remove the rules of a smart album,
+// leaving it an empty regular album.
+func (s *albumService) RemoveSmartAlbumRules(ctx context.Context, slug string) error {
+
+	record, err := s.findSmartAlbum(slug)
+	if err != nil {
+		return err
+	}
+
+	if err := s.smart.DeleteSmartAlbum(record.AlbumId); err != nil {
+		return fmt.Errorf("failed to remove rules of smart album slug %s: %v", slug, err)
+	}
+
+	return nil
+}
+
+// FreezeSmartAlbum implements the Service interface method to freeze a smart album into a regular album:
+// every image currently matching its rules, regardless of permissions, is added to the album and the rules removed.
+func (s *albumService) FreezeSmartAlbum(ctx context.Context, slug string) (*api.SmartAlbumFreeze, error) {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for FreezeSmartAlbum")
+	}
+
+	record, err := s.findSmartAlbum(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	// curator access returns every matching image, whatever its permissions or state
+	curator := map[string]exo.PermissionRecord{util.PermissionCurator: {}}
+	records, err := s.findSmartAlbumImages(*record, curator, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate rules of smart album slug %s: %v", slug, err)
+	}
+
+	imageIds := make([]string, 0, len(records))
+	for _, r := range records {
+		// a smart album matching no images returns a single row with empty image fields
+		if r.ImageId != "" {
+			imageIds = append(imageIds, r.ImageId)
+		}
+	}
+
+	// add the images before removing the rules so a failure leaves the album smart, and can be retried
+	now := data.CustomTime{Time: time.Now().UTC()}
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+		return s.linkAlbumImages(ctx, tx, record.AlbumId, imageIds, now)
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add images to frozen album slug %s: %v", slug, err)
+	}
+
+	if err := s.smart.DeleteSmartAlbum(record.AlbumId); err != nil {
+		return nil, fmt.Errorf("failed to remove rules of frozen album slug %s: %v", slug, err)
+	}
+
+	log.Info(fmt.Sprintf("froze smart album slug %s with %d images", slug, len(imageIds)))
+
+	return &api.SmartAlbumFreeze{
+		AlbumSlug:  slug,
+		ImageCount: len(imageIds),
+	}, nil
+}
+
+// findSmartAlbum is a helper which retrieves the smart album record of an album by its slug,
+// returning an error if the album is not a smart album.
+func (s *albumService) findSmartAlbum(slug string) (*SmartAlbumRecord, error) {
+
+	// vadidate the slug is well formed
+	// redundant check, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("invalid album slug: %s", slug)
+	}
+
+	slugIndex, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+	}
+
+	record, err := s.smart.FindSmartAlbum(slugIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve smart album record for album slug %s: %v", slug, err)
+	}
+
+	if record == nil {
+		exists, err := s.db.AlbumExists(slugIndex)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check if album %s exists: %v", slug, err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("album %s was not found", slug)
+		}
+		return nil, fmt.Errorf("album %s is not a smart album", slug)
+	}
+
+	return record, nil
+}
+
+// findSmartAlbumImages is a helper which evaluates a smart album's rules, returning the album-image records
+// of the matching images the user has permission to view, optionally narrowed to an inclusive year-month range.
+// Note: like a regular album, curators get a single row with empty image fields if no images match.
+func (s *albumService) findSmartAlbumImages(
+	record SmartAlbumRecord,
+	psMap map[string]exo.PermissionRecord,
+	from, to string,
+) ([]api.AlbumImageRecord, error) {
+
+	rules, f, err := s.decryptSmartAlbumFilter(record)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.smart.FindSmartAlbumImagesData(record.AlbumId, narrowSmartAlbumFilter(f, from, to), psMap)
+	if err != nil {
+		return nil, err
+	}
+
+	if !rules.Untitled || len(records) == 0 {
+		return records, nil
+	}
+
+	// titles are encrypted, so the untitled rule is applied after decryption
+	var (
+		wg       sync.WaitGroup
+		errCh    = make(chan error, len(records))
+		untitled = make([]bool, len(records))
+	)
+
+	for i, r := range records {
+		if r.ImageId == "" {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, ciphertext string) {
+			defer wg.Done()
+
+			title, err := s.cryptor.DecryptImageField(ciphertext)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decrypt title of image '%s': %v", records[i].ImageId, err)
+				return
+			}
+			untitled[i] = api.IsUntitledImageTitle(title)
+		}(i, r.ImageTitle)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, fmt.Errorf("failed to decrypt smart album image titles: %v", errors.Join(errs...))
+	}
+
+	matched := make([]api.AlbumImageRecord, 0, len(records))
+	for i, r := range records {
+		if untitled[i] {
+			matched = append(matched, r)
+		}
+	}
+
+	// curators still get the album itself if every image is titled
+	if _, ok := psMap[util.PermissionCurator]; ok && len(matched) == 0 {
+		r := records[0]
+		matched = append(matched, api.AlbumImageRecord{
+			AlbumId:          r.AlbumId,
+			AlbumTitle:       r.AlbumTitle,
+			AlbumDescription: r.AlbumDescription,
+			AlbumSlug:        r.AlbumSlug,
+			AlbumCreatedAt:   r.AlbumCreatedAt,
+			AlbumUpdatedAt:   r.AlbumUpdatedAt,
+			AlbumIsArchived:  r.AlbumIsArchived,
+			AlbumCoverId:     r.AlbumCoverId,
+			AlbumParentId:    r.AlbumParentId,
+			AlbumVersion:     r.AlbumVersion,
+		})
+	}
+
+	return matched, nil
+}
+
+// decryptSmartAlbumFilter is a helper which decrypts a smart album's rules and builds the filter for the database query.
+// Note: the untitled rule is not part of the filter: titles are encrypted.
+func (s *albumService) decryptSmartAlbumFilter(record SmartAlbumRecord) (*api.SmartAlbumRules, SmartAlbumFilter, error) {
+
+	rules, err := s.cryptor.DecryptSmartAlbumRules(record.Rules)
+	if err != nil {
+		return nil, SmartAlbumFilter{}, fmt.Errorf("failed to decrypt smart album rules: %v", err)
+	}
+
+	f, err := s.buildSmartAlbumFilter(*rules, false)
+	if err != nil {
+		return nil, SmartAlbumFilter{}, err
+	}
+
+	return rules, f, nil
+}
+
+// narrowSmartAlbumFilter is a helper which narrows the date range of a smart album's rules to the requested
+// inclusive year-month range, if provided.
+func narrowSmartAlbumFilter(f SmartAlbumFilter, from, to string) SmartAlbumFilter {
+
+	if from != "" && from > f.From {
+		f.From = from
+	}
+	if to != "" && (f.To == "" || to < f.To) {
+		f.To = to
+	}
+
+	return f
+}
+
+// buildSmartAlbumFilter is a helper which resolves the slugs referenced by a smart album's rules to
+// their blind indexes for the database query.  If checkExists is true, it also checks every referenced
+// album and permission exists.
+func (s *albumService) buildSmartAlbumFilter(rules api.SmartAlbumRules, checkExists bool) (SmartAlbumFilter, error) {
+
+	f := SmartAlbumFilter{
+		From:      rules.From,
+		To:        rules.To,
+		FileTypes: rules.FileTypes,
+		Published: rules.Published,
+		Archived:  rules.Archived,
+		HasGps:    rules.HasGps,
+	}
+
+	indexes := func(kind string, slugs []string, exists func(string) (bool, error)) ([]string, error) {
+		idxs := make([]string, 0, len(slugs))
+		for _, slug := range slugs {
+			idx, err := s.indexer.ObtainBlindIndex(slug)
+			if err != nil {
+				return nil, fmt.Errorf("failed to obtain blind index for %s slug '%s': %v", kind, slug, err)
+			}
+
+			if checkExists {
+				ok, err := exists(idx)
+				if err != nil {
+					return nil, fmt.Errorf("failed to check if %s slug %s exists: %v", kind, slug, err)
+				}
+				if !ok {
+					return nil, fmt.Errorf("%s slug '%s' does not exist", kind, slug)
+				}
+			}
+
+			idxs = append(idxs, idx)
+		}
+		return idxs, nil
+	}
+
+	var err error
+	if f.PermissionIndexes, err = indexes("permission", rules.PermissionSlugs, s.smart.PermissionExists); err != nil {
+		return SmartAlbumFilter{}, err
+	}
+	if f.InAlbumIndexes, err = indexes("album", rules.InAlbumSlugs, s.db.AlbumExists); err != nil {
+		return SmartAlbumFilter{}, err
+	}
+	if f.NotInAlbumIndexes, err = indexes("album", rules.NotInAlbumSlugs, s.db.AlbumExists); err != nil {
+		return SmartAlbumFilter{}, err
+	}
+
+	return f, nil
+}
+
+// evaluateSmartAlbums is a helper which evaluates the rules of the smart albums concurrently, returning the
+// album-image records of the images the user has permission to view in each, by album id.
+// If only is not nil, only the smart albums with those ids are evaluated.
+// Note: every evaluated smart album is in the map, even if the user may view none of its images.
+func (s *albumService) evaluateSmartAlbums(
+	psMap map[string]exo.PermissionRecord,
+	only map[string]bool,
+) (map[string][]api.AlbumImageRecord, error) {
+
+	smart, err := s.smart.FindSmartAlbums()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve smart albums from database: %v", err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errCh   = make(chan error, len(smart))
+		results = make(map[string][]api.AlbumImageRecord, len(smart))
+	)
+
+	for _, sa := range smart {
+		if only != nil && !only[sa.AlbumId] {
+			continue
+		}
+
+		wg.Add(1)
+		go func(sa SmartAlbumRecord) {
+			defer wg.Done()
+
+			records, err := s.findSmartAlbumImages(sa, psMap, "", "")
+			if err != nil {
+				errCh <- fmt.Errorf("failed to evaluate smart album '%s': %v", sa.AlbumId, err)
+				return
+			}
+
+			// drop the empty image row curators get for a smart album matching no images
+			matched := make([]api.AlbumImageRecord, 0, len(records))
+			for _, r := range records {
+				if r.ImageId != "" {
+					matched = append(matched, r)
+				}
+			}
+
+			mu.Lock()
+			results[sa.AlbumId] = matched
+			mu.Unlock()
+		}(sa)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, errors.Join(errs...)
+	}
+
+	return results, nil
+}
+
+// withVisibleSmartAlbums is a helper which replaces the smart albums in the allowed album records with
+// the smart albums whose rules match at least one image the user has permission to view.
+// Note: a smart album's own album-image xrefs, if any, do not make it visible.
+func (s *albumService) withVisibleSmartAlbums(
+	albums []api.AlbumRecord,
+	psMap map[string]exo.PermissionRecord,
+) ([]api.AlbumRecord, error) {
+
+	smart, err := s.evaluateSmartAlbums(psMap, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate smart albums: %v", err)
+	}
+
+	if len(smart) == 0 {
+		return albums, nil
+	}
+
+	albums = slices.DeleteFunc(albums, func(a api.AlbumRecord) bool {
+		_, ok := smart[a.Id]
+		return ok
+	})
+
+	// the album fields are encrypted, like the allowed album records, and decrypted by the caller
+	for _, records := range smart {
+		if len(records) == 0 {
+			continue
+		}
+
+		r := records[0]
+		albums = append(albums, api.AlbumRecord{
+			Id:           r.AlbumId,
+			Title:        r.AlbumTitle,
+			Description:  r.AlbumDescription,
+			Slug:         r.AlbumSlug,
+			CreatedAt:    r.AlbumCreatedAt,
+			UpdatedAt:    r.AlbumUpdatedAt,
+			IsArchived:   r.AlbumIsArchived,
+			CoverImageId: r.AlbumCoverId,
+			ParentId:     r.AlbumParentId,
+			Version:      r.AlbumVersion,
+		})
+	}
+
+	return albums, nil
+}
+
+// smartAlbumCover is a helper which picks the cover of a smart album from its matching images:
+// the selected cover if the user may view it, otherwise the most recently uploaded image.
+func smartAlbumCover(records []api.AlbumImageRecord) (api.AlbumImageRecord, bool) {
+
+	if len(records) == 0 {
+		return api.AlbumImageRecord{}, false
+	}
+
+	cover := records[0]
+	for _, r := range records {
+		if r.AlbumCoverId != "" && r.ImageId == r.AlbumCoverId {
+			return r, true
+		}
+		if r.ImageCreatedAt > cover.ImageCreatedAt {
+			cover = r
+		}
+	}
+
+	return cover, true
+}
@@ -49,7 +49,13 @@ func (s *stagedAdapter) FindUnpublishedImages() ([]api.ImageRecord, error) {
 			i.created_at,
 			i.updated_at,
 			i.is_archived,
-			i.is_published
+			i.is_published,
+			i.blur_hash,
+			i.dominant_color,
+			i.image_date_key,
+			i.image_date_precision,
+			i.image_date_end_year,
+			i.version
 		FROM
 			image i
 		WHERE i.is_published = FALSE`
@@ -173,10 +173,14 @@ func (s *stagedImageService) GetStagedImages(ctx context.Context) (*api.Album, e
 				Height:      ir.Height,
 				Size:        ir.Size,
 				ImageDate:   ir.ImageDate,
+				Date:        api.BuildPartialDate(ir.ImageDate, ir.ImageDatePrecision, ir.ImageDateEndYear),
 				CreatedAt:   ir.CreatedAt.Format(time.RFC3339),
 				UpdatedAt:   ir.UpdatedAt.Format(time.RFC3339),
 				IsArchived:  ir.IsArchived,
 				IsPublished: ir.IsPublished,
+
+				BlurHash:      ir.BlurHash,
+				DominantColor: ir.DominantColor,
 			}
 
 			var (
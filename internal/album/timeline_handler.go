@@ -0,0 +1,335 @@
+package album
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/jwt"
+	"github.com/tdeslauriers/pixie/internal/permission"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// scopes required to interact with the timeline handler(s) endpoints
+var readTimelineAllowed = []string{"r:pixie:*", "r:pixie:images:*"}
+
+// TimelineHandler is an interface that defines methods for handling chronological browsing requests.
+type TimelineHandler interface {
+
+	// HandleTimeline handles requests to browse the timeline of images.
+	HandleTimeline(w http.ResponseWriter, r *http.Request)
+
+	// HandleMemories handles requests for the "on this day" memories feed.
+	HandleMemories(w http.ResponseWriter, r *http.Request)
+}
+
+// NewTimelineHandler creates a new TimelineHandler instance and returns a pointer to the concrete implementation.
+// The timezone is the default used to determine "today" for the memories feed.
+func NewTimelineHandler(s Service, p permission.Service, s2s jwt.Verifier, iam jwt.Verifier, tz *time.Location) TimelineHandler {
+
+	return &timelineHandler{
+		svc:   s,
+		perms: p,
+		s2s:   s2s,
+		iam:   iam,
+		tz:    tz,
+
+		logger: slog.Default().
+			With(slog.String(util.ComponentKey, util.ComponentTimelineHandler)).
+			With(slog.String(util.PackageKey, util.PackagePicture)),
+	}
+}
+
+var _ TimelineHandler = (*timelineHandler)(nil)
+
+// timelineHandler is the concrete implementation of the TimelineHandler interface.
+type timelineHandler struct {
+	svc   Service
+	perms permission.Service
+	s2s   jwt.Verifier
+	iam   jwt.Verifier
+	tz    *time.Location // default timezone for determining "today"
+
+	logger *slog.Logger
+}
+
+// HandleTimeline is the concrete implementation of the interface method which handles requests
+// to browse the timeline of images.
+func (h *timelineHandler) HandleTimeline(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGetTimeline(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleGetTimeline handles the retrieval of a page of the timeline of images a user has permission to view.
+func (h *timelineHandler) handleGetTimeline(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readTimelineAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readTimelineAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// parse the timeline query parameters
+	q, err := parseTimelineQuery(r)
+	if err != nil {
+		log.Error("failed to parse timeline query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the query
+	if err := q.Validate(); err != nil {
+		log.Error("invalid timeline query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get the user's permissions
+	ps, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to retrieve permissions for user", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	timeline, err := h.svc.GetTimeline(ctx, ps, *q)
+	if err != nil {
+		// the query, including its cursor, was validated above, so any error is the service's
+		log.Error("failed to retrieve timeline", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve timeline",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	log.Info(fmt.Sprintf("successfully retrieved %d timeline months of %d total images", len(timeline.Months), timeline.Total))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(timeline); err != nil {
+		log.Error("failed to encode timeline to json", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to encode timeline to json",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// HandleMemories is the concrete implementation of the interface method which handles requests
+// for the "on this day" memories feed.
+func (h *timelineHandler) HandleMemories(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGetMemories(w, r)
+		return
+	default:
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleGetMemories handles the retrieval of the images a user has permission to view which were
+// taken on this day, or the requested date, in earlier years.
+func (h *timelineHandler) handleGetMemories(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate service token
+	s2sToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readTimelineAllowed, s2sToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	iamToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readTimelineAllowed, iamToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// parse the memories query parameters
+	params := r.URL.Query()
+	q := api.MemoriesQuery{
+		Date:     params.Get("date"),
+		Timezone: params.Get("tz"),
+	}
+
+	if wd := params.Get("window"); wd != "" {
+		window, err := strconv.Atoi(wd)
+		if err != nil {
+			log.Error("failed to parse memories window", "err", err.Error())
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusBadRequest,
+				Message:    "window must be an integer number of days",
+			}
+			e.SendJsonErr(w)
+			return
+		}
+		q.Window = window
+	}
+
+	// validate the query
+	if err := q.Validate(); err != nil {
+		log.Error("invalid memories query", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// determine the day: the requested date, or today in the requested or default timezone
+	tz := h.tz
+	if q.Timezone != "" {
+		tz, _ = time.LoadLocation(q.Timezone) // validated above
+	}
+
+	day := time.Now().In(tz)
+	if q.Date != "" {
+		day, _ = time.ParseInLocation(time.DateOnly, q.Date, tz) // validated above
+	}
+
+	// get the user's permissions
+	ps, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to retrieve permissions for user", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	memories, err := h.svc.GetMemories(ctx, ps, day, q.Window)
+	if err != nil {
+		log.Error("failed to retrieve memories", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to retrieve memories",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	log.Info(fmt.Sprintf("successfully retrieved memories from %d earlier years for %s", len(memories.Years), memories.Date))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(memories); err != nil {
+		log.Error("failed to encode memories to json", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to encode memories to json",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// parseTimelineQuery is a helper which parses the timeline query parameters from the request.
+func parseTimelineQuery(r *http.Request) (*api.TimelineQuery, error) {
+
+	params := r.URL.Query()
+
+	q := &api.TimelineQuery{
+		Cursor:    params.Get("cursor"),
+		Direction: params.Get("direction"),
+		At:        params.Get("at"),
+		From:      params.Get("from"),
+		To:        params.Get("to"),
+	}
+
+	if l := params.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = limit
+	}
+
+	return q, nil
+}
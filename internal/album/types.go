@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/tdeslauriers/carapace/pkg/data"
 	"github.com/tdeslauriers/carapace/pkg/permissions"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
@@ -24,6 +25,8 @@ func MapAlbumRecordsToApi(albums []api.AlbumRecord) ([]api.Album, error) {
 			CreatedAt:   album.CreatedAt,
 			UpdatedAt:   album.UpdatedAt,
 			IsArchived:  album.IsArchived,
+			ParentId:    album.ParentId,
+			Version:     album.Version,
 		}
 		apiAlbums[i] = apiAlbum
 	}
@@ -52,7 +55,10 @@ func BuildAlbumsQuery(ps map[string]permissions.PermissionRecord) (string, error
 			a.slug_index,
 			a.created_at,
 			a.updated_at,
-			a.is_archived
+			a.is_archived,
+			a.cover_image_uuid,
+			a.parent_uuid,
+			a.version
 		FROM album a
 			LEFT OUTER JOIN album_image ai ON a.uuid = ai.album_uuid
 			LEFT OUTER JOIN image i ON ai.image_uuid = i.uuid
@@ -78,7 +84,7 @@ func BuildAlbumsQuery(ps map[string]permissions.PermissionRecord) (string, error
 	// check if permissions include 'Gallery Curator'
 	if _, ok := ps["CURATOR"]; !ok {
 		// if not, add a condition to filter out albums that are archived
-		qb.WriteString(" AND a.is_archived = FALSE")
+		qb.WriteString(archivedAlbumsFilter)
 		qb.WriteString(" AND i.is_archived = FALSE")
 		qb.WriteString(" AND i.is_published = TRUE")
 	}
@@ -86,8 +92,9 @@ func BuildAlbumsQuery(ps map[string]permissions.PermissionRecord) (string, error
 	return qb.String(), nil
 }
 
-const AlbumImageQueryBase string = `
-	SELECT DISTINCT
+// albumImageColumns are the album-image columns selected by the album-image queries,
+// in the same order as the fields of api.AlbumImageRecord.
+const albumImageColumns string = `
 		a.uuid AS album_uuid,
 		a.title AS album_title,
 		a.description AS album_description,
@@ -109,12 +116,40 @@ const AlbumImageQueryBase string = `
 		COALESCE(i.created_at, '') AS image_created_at,
 		COALESCE(i.updated_at, '') AS image_updated_at,
 		COALESCE(i.is_archived, FALSE) AS image_is_archived,
-		COALESCE(i.is_published, FALSE) AS image_is_published
+		COALESCE(i.is_published, FALSE) AS image_is_published,
+		COALESCE(i.blur_hash, '') AS blur_hash,
+		COALESCE(i.dominant_color, '') AS dominant_color,
+		COALESCE(i.image_date_key, '') AS image_date_key,
+		COALESCE(i.image_date_precision, '') AS image_date_precision,
+		COALESCE(i.image_date_end_year, 0) AS image_date_end_year,
+		a.cover_image_uuid AS album_cover_image_uuid,
+		COALESCE(ai.position, 0) AS image_position,
+		a.parent_uuid AS album_parent_uuid,
+		a.version AS album_version`
+
+// albumImageJoins are the joins from album to its images and their permissions used by the album-image queries.
+const albumImageJoins string = `
 	FROM album a
 		LEFT OUTER JOIN album_image ai ON a.uuid = ai.album_uuid
 		LEFT OUTER JOIN image i ON ai.image_uuid = i.uuid
 		LEFT OUTER JOIN image_permission ip ON i.uuid = ip.image_uuid`
 
+// archivedAlbumsFilter filters out albums which are archived, or are sub-albums of an archived album,
+// at any depth, so that archiving a parent album hides all of its children.
+// Note: UNION rather than UNION ALL so the recursion terminates even if the parent links form a cycle.
+const archivedAlbumsFilter string = `
+		AND a.uuid NOT IN (
+			WITH RECURSIVE archived AS (
+				SELECT uuid FROM album WHERE is_archived = TRUE
+				UNION
+				SELECT c.uuid FROM album c INNER JOIN archived p ON c.parent_uuid = p.uuid
+			)
+			SELECT uuid FROM archived
+		)`
+
+const AlbumImageQueryBase string = `
+	SELECT DISTINCT` + albumImageColumns + albumImageJoins
+
 // BuildAllAlbumsImagesQuery is a helper function which builds a query to
 // retrieve album-image records based on the user's permissions.
 // It uses the provided permissions map to create the query params.
@@ -149,17 +184,308 @@ func BuildAllAlbumsImagesQuery(ps map[string]permissions.PermissionRecord) (stri
 	// check if permissions include 'Gallery Curator'
 	if _, ok := ps["CURATOR"]; !ok {
 		// if not, add a condition to filter out albums that are archived
-		qb.WriteString(" AND a.is_archived = FALSE")
+		qb.WriteString(archivedAlbumsFilter)
+		qb.WriteString(" AND i.is_archived = FALSE")
+		qb.WriteString(" AND i.is_published = TRUE")
+	}
+
+	return qb.String(), nil
+}
+
+// BuildAlbumCoversQuery is a helper function which builds a query to retrieve a single cover
+// album-image record for each of the albums in the list of album uuids: the album's selected cover image
+// if the user has permission to view it, otherwise the most recently added image the user has permission to view.
+// Note: because the ranking is over the images the user may view, a restricted cover is never returned.
+func BuildAlbumCoversQuery(ps map[string]permissions.PermissionRecord, albumCount int) (string, error) {
+
+	// check for empty permissions map
+	if len(ps) == 0 {
+		return "", fmt.Errorf("permissions map cannot be empty for album covers query builder")
+	}
+
+	if albumCount < 1 {
+		return "", fmt.Errorf("at least one album is required for album covers query builder")
+	}
+
+	// rank each album's images so only the first can be selected
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT
+		covers.album_uuid,
+		covers.album_title,
+		covers.album_description,
+		covers.album_slug,
+		covers.album_created_at,
+		covers.album_updated_at,
+		covers.album_is_archived,
+		covers.image_uuid,
+		covers.image_title,
+		covers.image_description,
+		covers.file_name,
+		covers.file_type,
+		covers.image_object_key,
+		covers.image_slug,
+		covers.width,
+		covers.height,
+		covers.size,
+		covers.image_date,
+		covers.image_created_at,
+		covers.image_updated_at,
+		covers.image_is_archived,
+		covers.image_is_published,
+		covers.blur_hash,
+		covers.dominant_color,
+		covers.image_date_key,
+		covers.image_date_precision,
+		covers.image_date_end_year,
+		covers.album_cover_image_uuid,
+		covers.image_position,
+		covers.album_parent_uuid,
+		covers.album_version
+	FROM (
+		SELECT` + albumImageColumns + `,
+		ROW_NUMBER() OVER (
+			PARTITION BY a.uuid
+			ORDER BY (i.uuid = a.cover_image_uuid) DESC, i.created_at DESC, i.uuid
+		) AS cover_rank` + albumImageJoins)
+
+	// add where clause to filter by album uuids as variables/params
+	qb.WriteString(`
+		WHERE a.uuid IN (`)
+	for i := 0; i < albumCount; i++ {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+	}
+	qb.WriteString(")")
+
+	// Note: curator should see everything, so we don't filter by permissions if present
+	if _, ok := ps["CURATOR"]; !ok {
+		qb.WriteString(` AND ip.permission_uuid IN (`)
+		i := 0
+		for range ps {
+			if i > 0 {
+				qb.WriteString(", ")
+			}
+			qb.WriteString("?")
+			i++
+		}
+		qb.WriteString(")")
+
+		// if not curator, filter out archived and unpublished images
+		qb.WriteString(archivedAlbumsFilter)
 		qb.WriteString(" AND i.is_archived = FALSE")
 		qb.WriteString(" AND i.is_published = TRUE")
 	}
 
+	qb.WriteString(`
+	) covers
+	WHERE covers.cover_rank = 1`)
+
+	return qb.String(), nil
+}
+
+// timelineImageColumns are the image columns selected by the timeline query,
+// in the same order as the fields of api.ImageRecord.
+const timelineImageColumns string = `
+		i.uuid,
+		i.title,
+		i.description,
+		i.file_name,
+		i.file_type,
+		i.object_key,
+		i.slug,
+		i.slug_index,
+		i.width,
+		i.height,
+		i.size,
+		i.image_date,
+		i.created_at,
+		i.updated_at,
+		i.is_archived,
+		i.is_published,
+		i.blur_hash,
+		i.dominant_color,
+		i.image_date_key,
+		i.image_date_precision,
+		i.image_date_end_year,
+		i.version`
+
+// writeTimelineFilters is a helper function which writes the where clause shared by the timeline queries:
+// only images with an image date, that the user has permission to view, within the optional date key range.
+// Params, in order: the permission uuids (if not curator), from (if provided), to (if provided).
+// Note: the permission check is an EXISTS rather than a join so images are not repeated per permission,
+// which keeps the ordering and limit of the timeline query correct without DISTINCT.
+func writeTimelineFilters(qb *strings.Builder, ps map[string]permissions.PermissionRecord, from, to string) {
+
+	qb.WriteString(`
+	FROM image i
+	WHERE i.image_date_key <> ''`)
+
+	writeImageVisibilityFilters(qb, ps)
+	writeImageDateKeyRange(qb, from, to)
+}
+
+// writeImageVisibilityFilters is a helper function which writes the conditions that the image, aliased i,
+// is visible to the user: unless curator, who sees everything, it has at least one of the user's permissions,
+// and is published and not archived.  Params are the permission uuids (if not curator).
+// Note: shared by the album image and timeline queries so their permission filtering cannot drift apart.
+func writeImageVisibilityFilters(qb *strings.Builder, ps map[string]permissions.PermissionRecord) {
+
+	if _, ok := ps["CURATOR"]; ok {
+		return
+	}
+
+	writeImagePermissionExists(qb, ps)
+	qb.WriteString(" AND i.is_archived = FALSE")
+	qb.WriteString(" AND i.is_published = TRUE")
+}
+
+// writeImageDateKeyRange is a helper function which writes the conditions that the image, aliased i,
+// is within the inclusive range of the sortable image date key.  Params, in order: from and to, each only if set.
+func writeImageDateKeyRange(qb *strings.Builder, from, to string) {
+
+	if from != "" {
+		qb.WriteString(" AND i.image_date_key >= ?")
+	}
+	if to != "" {
+		qb.WriteString(" AND i.image_date_key <= ?")
+	}
+}
+
+// writeImagePermissionExists is a helper function which writes a condition that the image, aliased i,
+// has at least one of the user's permissions.  Params are the permission uuids.
+func writeImagePermissionExists(qb *strings.Builder, ps map[string]permissions.PermissionRecord) {
+
+	qb.WriteString(`
+		AND EXISTS (
+			SELECT 1
+			FROM image_permission ip
+			WHERE ip.image_uuid = i.uuid
+				AND ip.permission_uuid IN (`)
+	i := 0
+	for range ps {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+		i++
+	}
+	qb.WriteString("))")
+}
+
+// BuildMemoriesQuery is a helper function which builds a query to retrieve the candidate image records
+// for the memories feed: published, non-archived images the user has permission to view, taken in one of
+// the months (01-12) of any year up to and including the latest date key.
+// The day of the month is encrypted, so candidates must be filtered to the exact dates after decryption.
+// Params, in order: the months, the latest date key, and the permission uuids (if not curator).
+// Note: unlike the timeline, curators do not see archived or unpublished images in their memories.
+func BuildMemoriesQuery(ps map[string]permissions.PermissionRecord, monthCount int) (string, error) {
+
+	// check for empty permissions map
+	if len(ps) == 0 {
+		return "", fmt.Errorf("permissions map cannot be empty for memories query builder")
+	}
+
+	if monthCount < 1 {
+		return "", fmt.Errorf("at least one month is required for memories query builder")
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT` + timelineImageColumns + `
+	FROM image i
+	WHERE i.image_date_key <> ''
+		AND SUBSTRING(i.image_date_key, 6, 2) IN (`)
+	for i := 0; i < monthCount; i++ {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+	}
+	qb.WriteString(`)
+		AND i.image_date_key <= ?
+		AND i.image_date_precision IN ('', 'exact', 'day')
+		AND i.is_archived = FALSE
+		AND i.is_published = TRUE`)
+
+	if _, ok := ps["CURATOR"]; !ok {
+		writeImagePermissionExists(&qb, ps)
+	}
+
+	return qb.String(), nil
+}
+
+// timelineSortKeys are the image columns the timeline orders by, in order,
+// ending with the uuid tiebreak so the order is total for keyset paging.
+var timelineSortKeys = []string{"i.image_date_key", "i.created_at", "i.uuid"}
+
+// BuildTimelineQuery is a helper function which builds a keyset query to retrieve a page of the timeline:
+// image records the user has permission to view, ordered newest first by image date key, then upload date.
+// Params, in order: those of writeTimelineFilters, at (if provided), the cursor's date key, created at,
+// and uuid (if provided), and the limit.
+// If newer is true, the page after the cursor towards newer images is returned, oldest first.
+func BuildTimelineQuery(
+	ps map[string]permissions.PermissionRecord,
+	from, to, at string,
+	hasCursor, newer bool,
+) (string, error) {
+
+	// check for empty permissions map
+	if len(ps) == 0 {
+		return "", fmt.Errorf("permissions map cannot be empty for timeline query builder")
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT` + timelineImageColumns)
+
+	writeTimelineFilters(&qb, ps, from, to)
+
+	// jump to the newest image in or before the month
+	if at != "" {
+		qb.WriteString(" AND i.image_date_key <= ?")
+	}
+
+	writeKeyset(&qb, timelineSortKeys, !newer, hasCursor)
+
+	qb.WriteString(`
+	LIMIT ?`)
+
+	return qb.String(), nil
+}
+
+// BuildTimelineCountsQuery is a helper function which builds a query to count the images the user
+// has permission to view in each month of the timeline, newest first, eg, for a timeline scrubber.
+// Params are those of writeTimelineFilters.
+func BuildTimelineCountsQuery(ps map[string]permissions.PermissionRecord, from, to string) (string, error) {
+
+	// check for empty permissions map
+	if len(ps) == 0 {
+		return "", fmt.Errorf("permissions map cannot be empty for timeline counts query builder")
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT
+		i.image_date_key,
+		COUNT(*) AS image_count`)
+
+	writeTimelineFilters(&qb, ps, from, to)
+
+	qb.WriteString(`
+	GROUP BY i.image_date_key
+	ORDER BY i.image_date_key DESC`)
+
 	return qb.String(), nil
 }
 
 // BuildAlbumImagesQuery is a helper function which builds a query to
 // retrieve image records for a specific album based on the user's permissions.
-func BuildAlbumImagesQuery(ps map[string]permissions.PermissionRecord) (string, error) {
+// If from and/or to are provided, images are filtered to that inclusive range of the sortable image date key.
+// Records are ordered by the image date key, then upload date.
+func BuildAlbumImagesQuery(ps map[string]permissions.PermissionRecord, from, to string) (string, error) {
 
 	// check for empty permissions map
 	if len(ps) == 0 {
@@ -175,8 +501,46 @@ func BuildAlbumImagesQuery(ps map[string]permissions.PermissionRecord) (string,
 	qb.WriteString(`
 		WHERE a.slug_index = ?`)
 
+	// if not curator, filter out archived albums, and images the user cannot view
 	if _, ok := ps["CURATOR"]; !ok {
-		qb.WriteString(` AND ip.permission_uuid IN (`)
+		qb.WriteString(archivedAlbumsFilter)
+	}
+	writeImageVisibilityFilters(&qb, ps)
+
+	// filter by the sortable image date key range, if provided
+	writeImageDateKeyRange(&qb, from, to)
+
+	// manually ordered images first, then by image date
+	// Note: DISTINCT requires ordering by the selected column aliases
+	qb.WriteString(`
+	ORDER BY image_position = 0, image_position, image_date_key, image_created_at, image_uuid`)
+
+	return qb.String(), nil
+}
+
+// BuildAlbumImageCountsQuery is a helper function which builds a query to count the images the user has
+// permission to view directly in each album.  Albums with no such images are not returned.
+// Params, in order: the permission uuids (if not curator).
+func BuildAlbumImageCountsQuery(ps map[string]permissions.PermissionRecord) (string, error) {
+
+	// check for empty permissions map
+	if len(ps) == 0 {
+		return "", fmt.Errorf("permissions map cannot be empty for album image counts query builder")
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT
+		ai.album_uuid,
+		COUNT(DISTINCT i.uuid) AS image_count
+	FROM album_image ai
+		INNER JOIN image i ON ai.image_uuid = i.uuid`)
+
+	// Note: curator should see everything, so we don't filter by permissions if present
+	if _, ok := ps["CURATOR"]; !ok {
+		qb.WriteString(`
+		INNER JOIN image_permission ip ON i.uuid = ip.image_uuid
+	WHERE ip.permission_uuid IN (`)
 		i := 0
 		for range ps {
 			if i > 0 {
@@ -186,16 +550,323 @@ func BuildAlbumImagesQuery(ps map[string]permissions.PermissionRecord) (string,
 			i++
 		}
 		qb.WriteString(")")
-	}
 
-	// check if permissions include 'Gallery Curator'
-	if _, ok := ps["CURATOR"]; !ok {
-		// if not, add a condition to filter out archived images
-		qb.WriteString(" AND a.is_archived = FALSE")
+		// if not curator, only count published, non-archived images
 		qb.WriteString(" AND i.is_archived = FALSE")
 		qb.WriteString(" AND i.is_published = TRUE")
 	}
 
+	qb.WriteString(`
+	GROUP BY ai.album_uuid`)
+
+	return qb.String(), nil
+}
+
+// SmartAlbumFilter is a model which represents a smart album's rules resolved for the database query:
+// the slugs of the permissions and albums the rules reference are replaced by their blind indexes.
+// Note: the untitled rule is not included since image titles are encrypted, so it is applied after decryption.
+type SmartAlbumFilter struct {
+	From              string
+	To                string
+	PermissionIndexes []string
+	FileTypes         []string
+	Published         *bool
+	Archived          *bool
+	InAlbumIndexes    []string
+	NotInAlbumIndexes []string
+	HasGps            *bool
+}
+
+// BuildSmartAlbumImagesQuery is a helper function which builds a query to retrieve the album-image records
+// of a smart album: every image matching the album's rules, which the user has permission to view.
+// The rules are part of the image join so curators still get the album row if no images match, like an empty album.
+// Params, in order: those of writeSmartAlbumRules, the album uuid, and the permission uuids (if not curator).
+// Records are ordered by the image date key, then upload date.
+func BuildSmartAlbumImagesQuery(ps map[string]permissions.PermissionRecord, f SmartAlbumFilter) (string, error) {
+
+	// check for empty permissions map
+	if len(ps) == 0 {
+		return "", fmt.Errorf("permissions map cannot be empty for smart album images query builder")
+	}
+
+	// Note: smart albums do not have album-image xrefs, the join only keeps the selected columns the same
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT` + albumImageColumns + `
+	FROM album a
+		LEFT OUTER JOIN image i ON TRUE`)
+
+	writeSmartAlbumRules(&qb, f)
+
+	qb.WriteString(`
+		LEFT OUTER JOIN album_image ai ON a.uuid = ai.album_uuid AND i.uuid = ai.image_uuid
+	WHERE a.uuid = ?`)
+
+	// if not curator, filter out archived albums, and images the user cannot view
+	if _, ok := ps["CURATOR"]; !ok {
+		qb.WriteString(archivedAlbumsFilter)
+	}
+	writeImageVisibilityFilters(&qb, ps)
+
+	qb.WriteString(`
+	ORDER BY i.image_date_key, i.created_at, i.uuid`)
+
 	return qb.String(), nil
 }
 
+// writeSmartAlbumRules is a helper function which writes the conditions an image, aliased i, must meet to be in
+// a smart album.  Params, in order: from, to, the file types, published, archived, has gps, the permission
+// blind indexes, the included album blind indexes, and the excluded album blind indexes, each only if set.
+func writeSmartAlbumRules(qb *strings.Builder, f SmartAlbumFilter) {
+
+	// images without an image date cannot be in a date range
+	if f.From != "" || f.To != "" {
+		qb.WriteString(" AND i.image_date_key <> ''")
+	}
+	if f.From != "" {
+		qb.WriteString(" AND i.image_date_key >= ?")
+	}
+	if f.To != "" {
+		qb.WriteString(" AND i.image_date_key <= ?")
+	}
+
+	if len(f.FileTypes) > 0 {
+		qb.WriteString(" AND i.file_type IN (")
+		writePlaceholders(qb, len(f.FileTypes))
+		qb.WriteString(")")
+	}
+
+	if f.Published != nil {
+		qb.WriteString(" AND i.is_published = ?")
+	}
+	if f.Archived != nil {
+		qb.WriteString(" AND i.is_archived = ?")
+	}
+	if f.HasGps != nil {
+		qb.WriteString(" AND i.has_gps = ?")
+	}
+
+	if len(f.PermissionIndexes) > 0 {
+		qb.WriteString(`
+			AND EXISTS (
+				SELECT 1
+				FROM image_permission rip
+					INNER JOIN permission rp ON rip.permission_uuid = rp.uuid
+				WHERE rip.image_uuid = i.uuid
+					AND rp.slug_index IN (`)
+		writePlaceholders(qb, len(f.PermissionIndexes))
+		qb.WriteString("))")
+	}
+
+	if len(f.InAlbumIndexes) > 0 {
+		qb.WriteString(`
+			AND EXISTS (
+				SELECT 1
+				FROM album_image rai
+					INNER JOIN album ra ON rai.album_uuid = ra.uuid
+				WHERE rai.image_uuid = i.uuid
+					AND ra.slug_index IN (`)
+		writePlaceholders(qb, len(f.InAlbumIndexes))
+		qb.WriteString("))")
+	}
+
+	if len(f.NotInAlbumIndexes) > 0 {
+		qb.WriteString(`
+			AND NOT EXISTS (
+				SELECT 1
+				FROM album_image rai
+					INNER JOIN album ra ON rai.album_uuid = ra.uuid
+				WHERE rai.image_uuid = i.uuid
+					AND ra.slug_index IN (`)
+		writePlaceholders(qb, len(f.NotInAlbumIndexes))
+		qb.WriteString("))")
+	}
+}
+
+// albumImagesSortKeys are the selected column aliases of the album-image queries each database sort
+// orders by, in order, ending with the uuid tiebreak so the order is total for keyset paging.
+// Note: titles are encrypted, so the title sort cannot be ordered in the database.
+var albumImagesSortKeys = map[string][]string{
+	api.SortImageDate:  {"q.image_date_key", "q.image_created_at", "q.image_uuid"},
+	api.SortUploadDate: {"q.image_created_at", "q.image_uuid"},
+	// manually ordered images first, then by image date, same as the album order
+	api.SortPosition: {"q.image_position = 0", "q.image_position", "q.image_date_key", "q.image_created_at", "q.image_uuid"},
+}
+
+// BuildAlbumImagesPageQuery is a helper function which wraps an album-image query, ie, from BuildAlbumImagesQuery
+// or BuildSmartAlbumImagesQuery, in a keyset query to retrieve a page of its images in the sort order.
+// Params, in order: those of the wrapped query, the cursor's sort keys (if provided), see AlbumImagesCursorArgs,
+// and the limit.
+func BuildAlbumImagesPageQuery(base, sort string, desc, hasCursor bool) (string, error) {
+
+	keys, ok := albumImagesSortKeys[sort]
+	if !ok {
+		return "", fmt.Errorf("sort '%s' cannot be ordered in the database", sort)
+	}
+
+	// curators get a row with empty image fields for an album with no images
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT * FROM (` + base + `
+	) q
+	WHERE q.image_uuid <> ''`)
+
+	writeKeyset(&qb, keys, desc, hasCursor)
+
+	qb.WriteString(`
+	LIMIT ?`)
+
+	return qb.String(), nil
+}
+
+// AlbumImagesCursorArgs is a helper function which builds the params for the cursor's sort keys
+// in a query built by BuildAlbumImagesPageQuery, in the order they appear in the query.
+func AlbumImagesCursorArgs(sort string, c api.PageCursor) []interface{} {
+	switch sort {
+	case api.SortImageDate:
+		return []interface{}{c.DateKey, c.CreatedAt, c.Id}
+	case api.SortUploadDate:
+		return []interface{}{c.CreatedAt, c.Id}
+	default:
+		return []interface{}{c.Position == 0, c.Position, c.DateKey, c.CreatedAt, c.Id}
+	}
+}
+
+// BuildAlbumImagesCountQuery is a helper function which wraps an album-image query to count its images.
+// Params are those of the wrapped query.
+func BuildAlbumImagesCountQuery(base string) string {
+	return `
+	SELECT COUNT(DISTINCT q.image_uuid) AS record_count FROM (` + base + `
+	) q
+	WHERE q.image_uuid <> ''`
+}
+
+// BuildAlbumHeadQuery is a helper function which wraps an album-image query to retrieve a single one of its
+// records for the album's fields: the album's selected cover image, if it is one of the images.
+// Params are those of the wrapped query.
+func BuildAlbumHeadQuery(base string) string {
+	return `
+	SELECT * FROM (` + base + `
+	) q
+	ORDER BY q.image_uuid = q.album_cover_image_uuid DESC
+	LIMIT 1`
+}
+
+// BuildAlbumsPageQuery is a helper function which wraps the query from BuildAlbumsQuery in a keyset query
+// to retrieve a page of the albums the user has permission to view by upload date.
+// Smart albums are left out unless curator: their visibility depends on their rules, not their image xrefs.
+// Params, in order: those of BuildAlbumsQuery, the cursor's created at and uuid (if provided), and the limit.
+func BuildAlbumsPageQuery(ps map[string]permissions.PermissionRecord, desc, hasCursor bool) (string, error) {
+
+	base, err := BuildAlbumsQuery(ps)
+	if err != nil {
+		return "", err
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT * FROM (` + base + `
+	) q
+	WHERE TRUE`)
+
+	if _, ok := ps["CURATOR"]; !ok {
+		qb.WriteString(" AND q.uuid NOT IN (SELECT album_uuid FROM smart_album)")
+	}
+
+	writeKeyset(&qb, []string{"q.created_at", "q.uuid"}, desc, hasCursor)
+
+	qb.WriteString(`
+	LIMIT ?`)
+
+	return qb.String(), nil
+}
+
+// BuildAlbumsCountQuery is a helper function which wraps the query from BuildAlbumsQuery to count the albums
+// the user has permission to view, leaving out smart albums unless curator, like BuildAlbumsPageQuery.
+// Params are those of BuildAlbumsQuery.
+func BuildAlbumsCountQuery(ps map[string]permissions.PermissionRecord) (string, error) {
+
+	base, err := BuildAlbumsQuery(ps)
+	if err != nil {
+		return "", err
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+	SELECT COUNT(*) AS record_count FROM (` + base + `
+	) q`)
+
+	if _, ok := ps["CURATOR"]; !ok {
+		qb.WriteString(`
+	WHERE q.uuid NOT IN (SELECT album_uuid FROM smart_album)`)
+	}
+
+	return qb.String(), nil
+}
+
+// writeKeyset is a helper function which writes the keyset condition, if there is a cursor, and the order by
+// clause of a keyset query over the sort keys, ascending, or descending if desc is true.
+// Params: one for each of the sort keys (if there is a cursor).
+// Note: the condition is appended to an existing where clause.
+func writeKeyset(qb *strings.Builder, keys []string, desc, hasCursor bool) {
+
+	op, dir := ">", " ASC"
+	if desc {
+		op, dir = "<", " DESC"
+	}
+
+	if hasCursor {
+		qb.WriteString(" AND (" + strings.Join(keys, ", ") + ") " + op + " (")
+		writePlaceholders(qb, len(keys))
+		qb.WriteString(")")
+	}
+
+	qb.WriteString(`
+	ORDER BY `)
+	for i, k := range keys {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString(k + dir)
+	}
+}
+
+// writePlaceholders is a helper function which writes a comma separated list of n query params.
+func writePlaceholders(qb *strings.Builder, n int) {
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+	}
+}
+
+// AlbumImageCount is a model which represents the number of images the user has permission to view in an album.
+type AlbumImageCount struct {
+	AlbumId string `db:"album_uuid"`
+	Count   int    `db:"image_count"`
+}
+
+// RecordCount is a model which represents the number of records matching a count query.
+type RecordCount struct {
+	Count int `db:"record_count"`
+}
+
+// SmartAlbumRecord is a model which represents a smart_album record in the database.
+// Note: the rules are encrypted json.
+type SmartAlbumRecord struct {
+	AlbumId   string          `db:"album_uuid"`
+	Rules     string          `db:"rules"`
+	CreatedAt data.CustomTime `db:"created_at"`
+	UpdatedAt data.CustomTime `db:"updated_at"`
+}
+
+// AlbumImagePosition is a model which represents the manual order of an image within an album,
+// ie, a subset of the album_image xref record and the image's slug index to look it up by.
+type AlbumImagePosition struct {
+	XrefId         int    `db:"id"`
+	ImageId        string `db:"image_uuid"`
+	ImageSlugIndex string `db:"slug_index"`
+	Position       int    `db:"position"` // 0 if not ordered
+}
+
@@ -0,0 +1,177 @@
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tdeslauriers/pixie/internal/transaction"
+)
+
+// Repository is the interface for reading and writing whole tables for backups and restores.
+// All operations take part in a unit of work, so a backup reads every table from the same snapshot
+// and a restore is only committed once every table and object has been verified.
+// Note: rows are read and written by the table's own columns rather than through the per-table record types
+// and repositories: the record types only map the columns their queries select, eg, the image record has no
+// upload session, so a backup through them would silently drop those columns.
+type Repository interface {
+
+	// ReadTable reads every row of the table, in primary key order, calling fn with each row's values
+	// in the order of the table's columns, which are passed to start before the first row.
+	// Note: values are returned as stored, ie, encrypted fields remain encrypted.
+	ReadTable(ctx context.Context, tx transaction.Tx, table string, start func(columns []string) error, fn func(values []any) error) error
+
+	// InsertRow inserts a row of values, in the order of the columns, into the table.
+	InsertRow(ctx context.Context, tx transaction.Tx, table string, columns []string, values []any) error
+
+	// CountRows counts the rows in the table.
+	CountRows(ctx context.Context, tx transaction.Tx, table string) (int64, error)
+
+	// TableColumns returns the names of the table's columns, in the order of the table's columns.
+	TableColumns(ctx context.Context, tx transaction.Tx, table string) ([]string, error)
+}
+
+// NewRepository creates a new Repository instance, returning a pointer to the concrete implementation.
+func NewRepository() Repository {
+	return &repository{}
+}
+
+var _ Repository = (*repository)(nil)
+
+// repository is the concrete implementation of the Repository interface.
+type repository struct{}
+
+// ReadTable is the concrete implementation of the interface method which reads every row of the table.
+func (r *repository) ReadTable(
+	ctx context.Context,
+	tx transaction.Tx,
+	table string,
+	start func(columns []string) error,
+	fn func(values []any) error,
+) error {
+
+	if !isBackupTable(table) {
+		return fmt.Errorf("table '%s' is not a backup table", table)
+	}
+
+	// every table's primary key is its first column
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s ORDER BY 1", table))
+	if err != nil {
+		return fmt.Errorf("failed to query table '%s': %v", table, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to get columns of table '%s': %v", table, err)
+	}
+
+	if err := start(columns); err != nil {
+		return err
+	}
+
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("failed to scan row of table '%s': %v", table, err)
+		}
+
+		// the driver returns text columns as bytes, and reuses them between rows
+		row := make([]any, len(values))
+		for i, v := range values {
+			switch val := v.(type) {
+			case []byte:
+				row[i] = string(val)
+			case time.Time:
+				row[i] = val.UTC().Format("2006-01-02 15:04:05")
+			default:
+				row[i] = val
+			}
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows of table '%s': %v", table, err)
+	}
+
+	return nil
+}
+
+// InsertRow is the concrete implementation of the interface method which inserts a row into the table.
+func (r *repository) InsertRow(ctx context.Context, tx transaction.Tx, table string, columns []string, values []any) error {
+
+	if !isBackupTable(table) {
+		return fmt.Errorf("table '%s' is not a backup table", table)
+	}
+
+	if len(columns) == 0 || len(columns) != len(values) {
+		return fmt.Errorf("row of table '%s' has %d values for %d columns", table, len(values), len(columns))
+	}
+
+	// column names come from the archive, so they are quoted as identifiers
+	quoted := make([]string, 0, len(columns))
+	for _, c := range columns {
+		quoted = append(quoted, "`"+strings.ReplaceAll(c, "`", "``")+"`")
+	}
+
+	qry := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table,
+		strings.Join(quoted, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", "))
+
+	return transaction.UpdateRecord(ctx, tx, qry, values...)
+}
+
+// CountRows is the concrete implementation of the interface method which counts the rows in the table.
+func (r *repository) CountRows(ctx context.Context, tx transaction.Tx, table string) (int64, error) {
+
+	if !isBackupTable(table) {
+		return 0, fmt.Errorf("table '%s' is not a backup table", table)
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT COUNT(*) FROM %s", table))
+	if err != nil {
+		return 0, fmt.Errorf("failed to count rows of table '%s': %v", table, err)
+	}
+	defer rows.Close()
+
+	var count int64
+	if rows.Next() {
+		if err := rows.Scan(&count); err != nil {
+			return 0, fmt.Errorf("failed to scan row count of table '%s': %v", table, err)
+		}
+	}
+
+	return count, rows.Err()
+}
+
+// TableColumns is the concrete implementation of the interface method which returns the names of the table's columns.
+func (r *repository) TableColumns(ctx context.Context, tx transaction.Tx, table string) ([]string, error) {
+
+	if !isBackupTable(table) {
+		return nil, fmt.Errorf("table '%s' is not a backup table", table)
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s LIMIT 0", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query columns of table '%s': %v", table, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns of table '%s': %v", table, err)
+	}
+
+	return columns, nil
+}
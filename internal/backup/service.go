@@ -0,0 +1,571 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log/slog"
+	"mime"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/pixie/internal/multipart"
+	"github.com/tdeslauriers/pixie/internal/transaction"
+	"github.com/tdeslauriers/pixie/internal/util"
+)
+
+// Service is the interface for backing up the database and object storage bucket to a single archive,
+// and restoring them from it for disaster recovery.
+type Service interface {
+
+	// Backup writes a tar archive of every table, read from a single consistent snapshot, and every object
+	// in the bucket to w, followed by a manifest of the checksum of each entry.
+	// Note: objects are read after the snapshot, so objects uploaded during the backup may be included
+	// without their image records, but every object of the backed up records is included.
+	Backup(ctx context.Context, w io.Writer) (*Manifest, error)
+
+	// Restore rehydrates an empty database and bucket from a backup archive read from r.  The rows are only
+	// committed once every entry has been verified against the manifest, after which the restored row counts,
+	// and the restored objects' checksums, are verified against it again.
+	// If the restore fails before committing, the objects already restored are removed.
+	Restore(ctx context.Context, r io.Reader) (*Manifest, error)
+}
+
+// NewService creates a new backup Service instance, returning a pointer to the concrete implementation.
+func NewService(db *sql.DB, bucket string, obj storage.ObjectStorage, mp multipart.Store) Service {
+	return &service{
+		uow:       transaction.NewUnitOfWork(db),
+		db:        NewRepository(),
+		bucket:    bucket,
+		store:     obj,
+		multipart: mp,
+
+		logger: slog.Default().
+			With(slog.String(util.PackageKey, util.PackageBackup)).
+			With(slog.String(util.ComponentKey, util.ComponentBackup)),
+	}
+}
+
+var _ Service = (*service)(nil)
+
+// service is the concrete implementation of the Service interface.
+type service struct {
+	uow       transaction.UnitOfWork
+	db        Repository
+	bucket    string
+	store     storage.ObjectStorage
+	multipart multipart.Store // streams restored objects rather than buffering them, unlike PutObject
+
+	logger *slog.Logger
+}
+
+// Backup is the concrete implementation of the interface method which writes a backup archive to w.
+func (s *service) Backup(ctx context.Context, w io.Writer) (*Manifest, error) {
+
+	tw := tar.NewWriter(w)
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC().Format(time.RFC3339),
+		Bucket:        s.bucket,
+	}
+
+	// every table is read in one transaction so the tables are consistent with each other
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+		for _, table := range Tables {
+			tm, err := s.backupTable(ctx, tx, tw, table)
+			if err != nil {
+				return err
+			}
+			manifest.Tables = append(manifest.Tables, *tm)
+
+			s.logger.Info(fmt.Sprintf("backed up %d rows of table '%s'", tm.Rows, table))
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.store.ListObjects(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects in bucket '%s': %v", s.bucket, err)
+	}
+	slices.Sort(keys)
+
+	for i, key := range keys {
+		om, err := s.backupObject(ctx, tw, key)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Objects = append(manifest.Objects, *om)
+
+		if (i+1)%100 == 0 {
+			s.logger.Info(fmt.Sprintf("backed up %d of %d objects", i+1, len(keys)))
+		}
+	}
+	s.logger.Info(fmt.Sprintf("backed up %d objects from bucket '%s'", len(keys), s.bucket))
+
+	// the manifest is written last, once every entry's checksum is known
+	encoded, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode backup manifest: %v", err)
+	}
+
+	if err := writeEntry(tw, manifestName, encoded); err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(encoded)
+	if err := writeEntry(tw, manifestChecksum, []byte(fmt.Sprintf("%s  %s\n", hex.EncodeToString(sum[:]), manifestName))); err != nil {
+		return nil, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to finish backup archive: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// backupTable is a helper which writes a table to the archive as json lines: the column names, then a line per row.
+// Note: the tar header needs the entry's size up front, so the table is encoded in memory before it is written.
+func (s *service) backupTable(ctx context.Context, tx transaction.Tx, tw *tar.Writer, table string) (*TableManifest, error) {
+
+	var (
+		buf bytes.Buffer
+		enc = json.NewEncoder(&buf)
+		tm  = &TableManifest{Name: table}
+	)
+
+	if err := s.db.ReadTable(ctx, tx, table,
+		func(columns []string) error {
+			tm.Columns = columns
+			return enc.Encode(columns)
+		},
+		func(values []any) error {
+			tm.Rows++
+			return enc.Encode(values)
+		},
+	); err != nil {
+		return nil, fmt.Errorf("failed to back up table '%s': %v", table, err)
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	tm.Size = int64(buf.Len())
+	tm.Sha256 = hex.EncodeToString(sum[:])
+
+	if err := writeEntry(tw, tablesDir+table+tableExt, buf.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return tm, nil
+}
+
+// backupObject is a helper which streams an object from the bucket into the archive.
+func (s *service) backupObject(ctx context.Context, tw *tar.Writer, key string) (*ObjectManifest, error) {
+
+	om := &ObjectManifest{Key: key}
+	if err := s.store.WithObject(ctx, key, func(r storage.ReadSeekCloser) error {
+
+		// the tar header needs the size up front
+		size, err := r.Seek(0, io.SeekEnd)
+		if err != nil {
+			return fmt.Errorf("failed to get size: %v", err)
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return fmt.Errorf("failed to rewind: %v", err)
+		}
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     objectsDir + key,
+			Mode:     0644,
+			Size:     size,
+			ModTime:  time.Now().UTC(),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return err
+		}
+
+		h := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(tw, h), r); err != nil {
+			return err
+		}
+
+		om.Size = size
+		om.Sha256 = hex.EncodeToString(h.Sum(nil))
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to back up object '%s': %v", key, err)
+	}
+
+	return om, nil
+}
+
+// Restore is the concrete implementation of the interface method which restores a backup archive read from r.
+func (s *service) Restore(ctx context.Context, r io.Reader) (*Manifest, error) {
+
+	// never restore over existing data: the archive is the whole gallery, not a merge
+	if err := s.checkEmpty(ctx); err != nil {
+		return nil, err
+	}
+
+	var (
+		manifest *Manifest
+		restored []string // object keys, to remove if the restore fails
+	)
+
+	err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+
+		var (
+			tables  = make(map[string]TableManifest)
+			objects = make(map[string]ObjectManifest)
+			encoded []byte
+			sumFile []byte
+		)
+
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read backup archive: %v", err)
+			}
+
+			switch {
+			case strings.HasPrefix(hdr.Name, tablesDir):
+				table := strings.TrimSuffix(strings.TrimPrefix(hdr.Name, tablesDir), tableExt)
+				tm, err := s.restoreTable(ctx, tx, table, tr)
+				if err != nil {
+					return err
+				}
+				tables[table] = *tm
+
+				s.logger.Info(fmt.Sprintf("restored %d rows of table '%s'", tm.Rows, table))
+
+			case strings.HasPrefix(hdr.Name, objectsDir):
+				key := strings.TrimPrefix(hdr.Name, objectsDir)
+				om, err := s.restoreObject(ctx, key, tr, hdr.Size)
+				if err != nil {
+					return err
+				}
+				restored = append(restored, key)
+				objects[key] = *om
+
+				if len(objects)%100 == 0 {
+					s.logger.Info(fmt.Sprintf("restored %d objects", len(objects)))
+				}
+
+			case hdr.Name == manifestName:
+				if encoded, err = io.ReadAll(tr); err != nil {
+					return fmt.Errorf("failed to read backup manifest: %v", err)
+				}
+
+			case hdr.Name == manifestChecksum:
+				if sumFile, err = io.ReadAll(tr); err != nil {
+					return fmt.Errorf("failed to read backup manifest checksum: %v", err)
+				}
+
+			default:
+				return fmt.Errorf("backup archive entry '%s' is not valid", hdr.Name)
+			}
+		}
+
+		m, err := parseManifest(encoded, sumFile)
+		if err != nil {
+			return err
+		}
+		manifest = m
+
+		// nothing is committed unless every entry matches the manifest, and the manifest lists every entry
+		return verifyArchive(manifest, tables, objects)
+	})
+	if err != nil {
+		if len(restored) > 0 {
+			if derr := s.store.DeleteObjects(ctx, restored); derr != nil {
+				return nil, errors.Join(err, fmt.Errorf("failed to remove %d restored objects: %v", len(restored), derr))
+			}
+		}
+		return nil, err
+	}
+
+	s.logger.Info(fmt.Sprintf("restored %d tables and %d objects", len(manifest.Tables), len(manifest.Objects)))
+
+	if err := s.verifyRestore(ctx, manifest); err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// checkEmpty is a helper which checks that none of the backup tables have rows and that the bucket has no objects.
+func (s *service) checkEmpty(ctx context.Context) error {
+
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+		for _, table := range Tables {
+			count, err := s.db.CountRows(ctx, tx, table)
+			if err != nil {
+				return err
+			}
+			if count > 0 {
+				return fmt.Errorf("database is not empty: table '%s' has %d rows", table, count)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	keys, err := s.store.ListObjects(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list objects in bucket '%s': %v", s.bucket, err)
+	}
+	if len(keys) > 0 {
+		return fmt.Errorf("bucket '%s' is not empty: it has %d objects", s.bucket, len(keys))
+	}
+
+	return nil
+}
+
+// restoreTable is a helper which inserts the rows of a table's json lines entry, checksumming the entry as it is read.
+func (s *service) restoreTable(ctx context.Context, tx transaction.Tx, table string, r io.Reader) (*TableManifest, error) {
+
+	if !isBackupTable(table) {
+		return nil, fmt.Errorf("backup table '%s' is not valid", table)
+	}
+
+	h := sha256.New()
+	counter := &countingWriter{h: h}
+	dec := json.NewDecoder(io.TeeReader(r, counter))
+	dec.UseNumber() // keep large integers, eg, sizes, exact
+
+	tm := &TableManifest{Name: table}
+	if err := dec.Decode(&tm.Columns); err != nil {
+		return nil, fmt.Errorf("failed to read columns of table '%s': %v", table, err)
+	}
+
+	// the rows are inserted by column name, so the columns must be the same, but may be in a different order,
+	// eg, a migrated database has its added columns in the order they were added
+	live, err := s.db.TableColumns(ctx, tx, table)
+	if err != nil {
+		return nil, err
+	}
+
+	if !sameColumns(tm.Columns, live) {
+		return nil, fmt.Errorf("backup table '%s' columns %v do not match the database's columns %v: the schema has changed since the backup",
+			table, tm.Columns, live)
+	}
+
+	for {
+		var values []any
+		if err := dec.Decode(&values); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read row %d of table '%s': %v", tm.Rows+1, table, err)
+		}
+
+		if err := s.db.InsertRow(ctx, tx, table, tm.Columns, values); err != nil {
+			return nil, fmt.Errorf("failed to restore row %d of table '%s': %v", tm.Rows+1, table, err)
+		}
+		tm.Rows++
+	}
+
+	// the decoder reads ahead, so drain the entry to checksum all of it
+	if _, err := io.Copy(counter, r); err != nil {
+		return nil, fmt.Errorf("failed to read table '%s': %v", table, err)
+	}
+
+	tm.Size = counter.n
+	tm.Sha256 = hex.EncodeToString(h.Sum(nil))
+
+	return tm, nil
+}
+
+// restoreObject is a helper which streams an object's entry into the bucket, checksumming it as it is read.
+func (s *service) restoreObject(ctx context.Context, key string, r io.Reader, size int64) (*ObjectManifest, error) {
+
+	if key == "" || strings.HasSuffix(key, "/") {
+		return nil, fmt.Errorf("backup object key '%s' is not valid", key)
+	}
+
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := sha256.New()
+	if err := s.multipart.Put(ctx, key, contentType, io.TeeReader(r, h), size); err != nil {
+		return nil, fmt.Errorf("failed to restore object '%s': %v", key, err)
+	}
+
+	return &ObjectManifest{
+		Key:    key,
+		Size:   size,
+		Sha256: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// verifyRestore is a helper which verifies the restored database row counts and bucket objects against the manifest,
+// reading each object back from the bucket to check its checksum.
+func (s *service) verifyRestore(ctx context.Context, manifest *Manifest) error {
+
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+		for _, tm := range manifest.Tables {
+			count, err := s.db.CountRows(ctx, tx, tm.Name)
+			if err != nil {
+				return err
+			}
+			if count != tm.Rows {
+				return fmt.Errorf("restored table '%s' has %d rows: the backup has %d", tm.Name, count, tm.Rows)
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	keys, err := s.store.ListObjects(ctx, "")
+	if err != nil {
+		return fmt.Errorf("failed to list restored objects in bucket '%s': %v", s.bucket, err)
+	}
+	if len(keys) != len(manifest.Objects) {
+		return fmt.Errorf("restored bucket '%s' has %d objects: the backup has %d", s.bucket, len(keys), len(manifest.Objects))
+	}
+
+	for _, om := range manifest.Objects {
+		if err := s.store.WithObject(ctx, om.Key, func(r storage.ReadSeekCloser) error {
+			h := sha256.New()
+			if _, err := io.Copy(h, r); err != nil {
+				return err
+			}
+			if sum := hex.EncodeToString(h.Sum(nil)); sum != om.Sha256 {
+				return fmt.Errorf("checksum %s does not match the backup's %s", sum, om.Sha256)
+			}
+			return nil
+		}); err != nil {
+			return fmt.Errorf("failed to verify restored object '%s': %v", om.Key, err)
+		}
+	}
+
+	s.logger.Info("verified restored row counts and object checksums against the backup manifest")
+
+	return nil
+}
+
+// parseManifest is a helper which checks the manifest against its checksum and decodes it.
+func parseManifest(encoded, sumFile []byte) (*Manifest, error) {
+
+	if encoded == nil || sumFile == nil {
+		return nil, fmt.Errorf("backup archive is not valid: it is incomplete or missing its manifest")
+	}
+
+	want, _, _ := strings.Cut(strings.TrimSpace(string(sumFile)), " ")
+	sum := sha256.Sum256(encoded)
+	if got := hex.EncodeToString(sum[:]); got != want {
+		return nil, fmt.Errorf("backup manifest checksum %s does not match %s", got, want)
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(encoded, &m); err != nil {
+		return nil, fmt.Errorf("failed to decode backup manifest: %v", err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &m, nil
+}
+
+// verifyArchive is a helper which checks the tables and objects read from the archive are exactly those in the
+// manifest, with the same sizes, row counts, and checksums.
+func verifyArchive(m *Manifest, tables map[string]TableManifest, objects map[string]ObjectManifest) error {
+
+	if len(tables) != len(m.Tables) {
+		return fmt.Errorf("backup archive has %d tables: the manifest lists %d", len(tables), len(m.Tables))
+	}
+	for _, want := range m.Tables {
+		got, ok := tables[want.Name]
+		if !ok {
+			return fmt.Errorf("backup archive is missing table '%s'", want.Name)
+		}
+		if got.Rows != want.Rows || got.Size != want.Size || got.Sha256 != want.Sha256 {
+			return fmt.Errorf("backup table '%s' does not match the manifest: %d rows, checksum %s, expected %d rows, checksum %s",
+				want.Name, got.Rows, got.Sha256, want.Rows, want.Sha256)
+		}
+	}
+
+	if len(objects) != len(m.Objects) {
+		return fmt.Errorf("backup archive has %d objects: the manifest lists %d", len(objects), len(m.Objects))
+	}
+	for _, want := range m.Objects {
+		got, ok := objects[want.Key]
+		if !ok {
+			return fmt.Errorf("backup archive is missing object '%s'", want.Key)
+		}
+		if got.Size != want.Size || got.Sha256 != want.Sha256 {
+			return fmt.Errorf("backup object '%s' does not match the manifest: checksum %s, expected %s", want.Key, got.Sha256, want.Sha256)
+		}
+	}
+
+	return nil
+}
+
+// sameColumns is a helper which checks two lists of column names have the same columns, in any order.
+func sameColumns(a, b []string) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+
+	return slices.Equal(a, b)
+}
+
+// writeEntry is a helper which writes a file entry to the archive.
+func writeEntry(tw *tar.Writer, name string, data []byte) error {
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  time.Now().UTC(),
+		Typeflag: tar.TypeReg,
+	}); err != nil {
+		return fmt.Errorf("failed to write backup archive entry '%s': %v", name, err)
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to write backup archive entry '%s': %v", name, err)
+	}
+
+	return nil
+}
+
+// countingWriter is a helper which hashes and counts the bytes written to it.
+type countingWriter struct {
+	h hash.Hash
+	n int64
+}
+
+// Write implements io.Writer.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.h.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,91 @@
+package backup
+
+import "fmt"
+
+// FormatVersion is the version of the backup archive format.  It is incremented whenever the layout
+// of the archive or its manifest changes, so an archive is never restored by a tool which would misread it.
+const FormatVersion int = 1
+
+// paths of the entries in a backup archive
+const (
+	tablesDir        = "tables/"              // one json lines file per table, eg, "tables/image.jsonl"
+	objectsDir       = "objects/"             // every object in the bucket, by its object key
+	manifestName     = "manifest.json"        // written last, once every entry's checksum is known
+	manifestChecksum = "manifest.json.sha256" // sha256sum format checksum of the manifest
+	tableExt         = ".jsonl"
+)
+
+// Tables are the database tables included in a backup, in the order they are restored:
+// tables are restored before the tables with foreign keys referencing them.
+var Tables = []string{
+	"image",
+	"album",
+	"permission",
+	"patron",
+	"upload_session",
+	"smart_album",
+	"album_image",
+	"image_permission",
+	"album_permission",
+	"patron_permission",
+	"servicetoken",
+}
+
+// Manifest is a model which represents the manifest of a backup archive: the tables and objects it contains,
+// with their checksums, so that a restore can verify every entry.
+type Manifest struct {
+	FormatVersion int              `json:"format_version"`
+	CreatedAt     string           `json:"created_at"`
+	Bucket        string           `json:"bucket"`
+	Tables        []TableManifest  `json:"tables"`
+	Objects       []ObjectManifest `json:"objects"`
+}
+
+// TableManifest is a model which represents a database table in a backup archive.
+// Note: encrypted fields are backed up, and restored, still encrypted.
+type TableManifest struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+	Rows    int64    `json:"rows"`
+	Size    int64    `json:"size"`   // size of the table's json lines file in bytes
+	Sha256  string   `json:"sha256"` // hex encoded checksum of the table's json lines file
+}
+
+// ObjectManifest is a model which represents an object storage object in a backup archive.
+type ObjectManifest struct {
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"` // hex encoded checksum of the object's contents
+}
+
+// Validate checks the manifest can be restored by this version of the tool.
+func (m *Manifest) Validate() error {
+
+	if m.FormatVersion != FormatVersion {
+		return fmt.Errorf("backup format version %d is not supported: must be %d", m.FormatVersion, FormatVersion)
+	}
+
+	seen := make(map[string]struct{}, len(m.Tables))
+	for _, t := range m.Tables {
+		if !isBackupTable(t.Name) {
+			return fmt.Errorf("backup table '%s' is not valid", t.Name)
+		}
+		if _, ok := seen[t.Name]; ok {
+			return fmt.Errorf("backup table '%s' is listed more than once", t.Name)
+		}
+		seen[t.Name] = struct{}{}
+	}
+
+	return nil
+}
+
+// isBackupTable is a helper which checks the table is one of the backup tables.
+// Note: table names cannot be query parameters, so only these names may be interpolated into queries.
+func isBackupTable(table string) bool {
+	for _, t := range Tables {
+		if t == table {
+			return true
+		}
+	}
+	return false
+}
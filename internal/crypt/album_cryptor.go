@@ -1,6 +1,7 @@
 package crypt
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -25,6 +26,13 @@ type AlbumCryptor interface {
 
 	// DecryptAlbum decrypts sensitive fields in the Album struct.
 	DecryptAlbum(a *api.Album) error
+
+	// EncryptSmartAlbumRules encrypts a smart album's rules for storage.
+	// Note: the rules reference other albums and permissions by slug, so they are encrypted like the slugs are.
+	EncryptSmartAlbumRules(r api.SmartAlbumRules) (string, error)
+
+	// DecryptSmartAlbumRules decrypts a smart album's stored rules.
+	DecryptSmartAlbumRules(ciphertext string) (*api.SmartAlbumRules, error)
 }
 
 // NewAlbumCryptor creates a new AlbumCryptor instance, returning a pointer to the concrete implementation.
@@ -224,6 +232,42 @@ func (ac *albumCryptor) decryptAlbumFields(
 	return nil
 }
 
+// EncryptSmartAlbumRules encrypts a smart album's rules for storage.
+func (ac *albumCryptor) EncryptSmartAlbumRules(r api.SmartAlbumRules) (string, error) {
+
+	plaintext, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal smart album rules: %v", err)
+	}
+
+	encrypted, err := ac.cryptor.EncryptServiceData(plaintext)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt smart album rules: %v", err)
+	}
+
+	return encrypted, nil
+}
+
+// DecryptSmartAlbumRules decrypts a smart album's stored rules.
+func (ac *albumCryptor) DecryptSmartAlbumRules(ciphertext string) (*api.SmartAlbumRules, error) {
+
+	if ciphertext == "" {
+		return nil, fmt.Errorf("failed to decrypt smart album rules because they are empty")
+	}
+
+	plaintext, err := ac.cryptor.DecryptServiceData(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt smart album rules: %v", err)
+	}
+
+	var r api.SmartAlbumRules
+	if err := json.Unmarshal(plaintext, &r); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal smart album rules: %v", err)
+	}
+
+	return &r, nil
+}
+
 // decrypt is a helper method that decrypts a field in the album record.
 func (ac *albumCryptor) decrypt(field, ciphertext string, fieldCh chan string, errCh chan error, wg *sync.WaitGroup) {
 
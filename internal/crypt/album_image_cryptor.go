@@ -59,6 +59,8 @@ func (aic *albumImageCryptor) DecryptAlbumImage(r *api.AlbumImageRecord) error {
 		&r.ImageDate,
 		&r.ObjectKey,
 		&r.ImageSlug,
+		&r.BlurHash,
+		&r.DominantColor,
 	)
 }
 
@@ -73,7 +75,9 @@ func (aic *albumImageCryptor) decryptAlbumImageFields(
 	fileName,
 	imageDate,
 	objectKey,
-	imageSlug *string,
+	imageSlug,
+	blurHash,
+	dominantColor *string,
 ) error {
 
 	var (
@@ -89,8 +93,10 @@ func (aic *albumImageCryptor) decryptAlbumImageFields(
 		imageDateCh  = make(chan string, 1)
 		objectKeyCh  = make(chan string, 1)
 		imageSlugCh  = make(chan string, 1)
+		blurHashCh   = make(chan string, 1)
+		colorCh      = make(chan string, 1)
 
-		errChan = make(chan error, 9) // buffer size equal to number of fields to avoid goroutine leaks
+		errChan = make(chan error, 11) // buffer size equal to number of fields to avoid goroutine leaks
 	)
 
 	wg.Add(3)
@@ -110,6 +116,17 @@ func (aic *albumImageCryptor) decryptAlbumImageFields(
 		go aic.decrypt(*imageDate, "image date", imageDateCh, errChan, &wg)
 	}
 
+	// placeholders will be empty until the pipeline has processed the image
+	if *blurHash != "" {
+		wg.Add(1)
+		go aic.decrypt(*blurHash, "blur hash", blurHashCh, errChan, &wg)
+	}
+
+	if *dominantColor != "" {
+		wg.Add(1)
+		go aic.decrypt(*dominantColor, "dominant color", colorCh, errChan, &wg)
+	}
+
 	wg.Wait()
 	close(albumTitleCh)
 	close(albumDescCh)
@@ -120,6 +137,8 @@ func (aic *albumImageCryptor) decryptAlbumImageFields(
 	close(imageDateCh)
 	close(objectKeyCh)
 	close(imageSlugCh)
+	close(blurHashCh)
+	close(colorCh)
 	close(errChan)
 
 	// check for errors during decryption
@@ -188,6 +207,18 @@ func (aic *albumImageCryptor) decryptAlbumImageFields(
 		}
 	}
 
+	if blurHash != nil {
+		if bh, ok := <-blurHashCh; ok {
+			*blurHash = bh
+		}
+	}
+
+	if dominantColor != nil {
+		if color, ok := <-colorCh; ok {
+			*dominantColor = color
+		}
+	}
+
 	return nil
 }
 
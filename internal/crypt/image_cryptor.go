@@ -25,6 +25,10 @@ type ImageCryptor interface {
 
 	// DecryptImageRecord decrypts sensitive fields in the ImageRecord struct.
 	DecryptImageRecord(image *api.ImageRecord) error
+
+	// DecryptImageField decrypts a single sensitive image field, eg, the title or image date needed to sort
+	// a listing, without decrypting every field of every record.  Returns an empty string if the field is empty.
+	DecryptImageField(ciphertext string) (string, error)
 }
 
 // NewCryptor creates a new ImageCryptor instance, returning a pointer to the concrete implementation.
@@ -64,6 +68,8 @@ func (ic *imageCryptor) EncryptImageRecord(image *api.ImageRecord) error {
 		&image.ImageDate,
 		&image.ObjectKey,
 		&image.Slug,
+		&image.BlurHash,
+		&image.DominantColor,
 	); err != nil {
 		return fmt.Errorf("failed to encrypt image record '%s': %v", image.Id, err)
 	}
@@ -86,6 +92,8 @@ func (ic *imageCryptor) EncryptImageData(image *api.ImageData) error {
 		&image.ImageDate,
 		&image.ObjectKey,
 		&image.Slug,
+		&image.BlurHash,
+		&image.DominantColor,
 	); err != nil {
 		return fmt.Errorf("failed to encrypt image data '%s': %v", image.Id, err)
 	}
@@ -101,6 +109,8 @@ func (ic *imageCryptor) encryptImageFieldData(
 	imageDatePtr *string,
 	objectKeyPtr *string,
 	slugPtr *string,
+	blurHashPtr *string,
+	dominantColorPtr *string,
 ) error {
 
 	// encrypt the sensitive fields in the updated image record
@@ -113,8 +123,10 @@ func (ic *imageCryptor) encryptImageFieldData(
 		imgDateCh = make(chan string, 1) // image date is encrypted to prevent leakage of sensitive information due to low numbers of images in certain years.
 		objKeyCh  = make(chan string, 1) // object key may have changed if the image was unpublished or if the upload pipeline failed.
 		slugCh    = make(chan string, 1)
+		blurCh    = make(chan string, 1) // placeholders are only present once the pipeline has processed the image
+		colorCh   = make(chan string, 1)
 
-		errCh = make(chan error, 6) // to capture any errors from encryption
+		errCh = make(chan error, 8) // to capture any errors from encryption
 	)
 
 	wg.Add(5)
@@ -130,6 +142,17 @@ func (ic *imageCryptor) encryptImageFieldData(
 		go ic.encrypt(*imageDatePtr, "image date", imgDateCh, errCh, &wg)
 	}
 
+	// placeholders will not exist until the image has been processed by the pipeline
+	if blurHashPtr != nil && *blurHashPtr != "" {
+		wg.Add(1)
+		go ic.encrypt(*blurHashPtr, "image blur hash", blurCh, errCh, &wg)
+	}
+
+	if dominantColorPtr != nil && *dominantColorPtr != "" {
+		wg.Add(1)
+		go ic.encrypt(*dominantColorPtr, "image dominant color", colorCh, errCh, &wg)
+	}
+
 	// wait for all goroutines to finish
 	wg.Wait()
 	close(titleCh)
@@ -138,6 +161,8 @@ func (ic *imageCryptor) encryptImageFieldData(
 	close(imgDateCh) // need to close this channel even if it was not used
 	close(objKeyCh)  // need to close this channel even if it was not used
 	close(slugCh)
+	close(blurCh)  // need to close this channel even if it was not used
+	close(colorCh) // need to close this channel even if it was not used
 	close(errCh)
 
 	// check for any errors during encryption
@@ -186,6 +211,18 @@ func (ic *imageCryptor) encryptImageFieldData(
 		}
 	}
 
+	if blurHashPtr != nil {
+		if blur, ok := <-blurCh; ok {
+			*blurHashPtr = blur
+		}
+	}
+
+	if dominantColorPtr != nil {
+		if color, ok := <-colorCh; ok {
+			*dominantColorPtr = color
+		}
+	}
+
 	return nil
 }
 
@@ -225,6 +262,8 @@ func (ic *imageCryptor) DecryptImageData(image *api.ImageData) error {
 		&image.ObjectKey,
 		&image.Slug,
 		&image.ImageDate,
+		&image.BlurHash,
+		&image.DominantColor,
 	); err != nil {
 		return fmt.Errorf("failed to decrypt image data '%s': %v", image.Id, err)
 	}
@@ -246,6 +285,8 @@ func (ic *imageCryptor) DecryptImageRecord(image *api.ImageRecord) error {
 		&image.ObjectKey,
 		&image.Slug,
 		&image.ImageDate,
+		&image.BlurHash,
+		&image.DominantColor,
 	); err != nil {
 		return fmt.Errorf("failed to decrypt image record '%s': %v", image.Id, err)
 	}
@@ -253,6 +294,23 @@ func (ic *imageCryptor) DecryptImageRecord(image *api.ImageRecord) error {
 	return nil
 }
 
+// DecryptImageField decrypts a single sensitive image field, eg, the title or image date needed to sort
+// a listing, without decrypting every field of every record.  Returns an empty string if the field is empty.
+func (ic *imageCryptor) DecryptImageField(ciphertext string) (string, error) {
+
+	// optional fields, eg, image date, may be empty
+	if ciphertext == "" {
+		return "", nil
+	}
+
+	plaintext, err := ic.cryptor.DecryptServiceData(ciphertext)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt image field: %v", err)
+	}
+
+	return string(plaintext), nil
+}
+
 // decryptImageFields decrypts the sensitive fields of an image record.
 func (ic *imageCryptor) decryptImageFields(
 	titlePtr *string,
@@ -261,6 +319,8 @@ func (ic *imageCryptor) decryptImageFields(
 	objectKeyPtr *string,
 	slugPtr *string,
 	imageDatePtr *string,
+	blurHashPtr *string,
+	dominantColorPtr *string,
 ) error {
 
 	var (
@@ -272,8 +332,10 @@ func (ic *imageCryptor) decryptImageFields(
 		okCh    = make(chan string, 1) // object key is encrypted because it is made of the slug
 		slugCh  = make(chan string, 1)
 		IdCh    = make(chan string, 1) // image date is encrypted
+		blurCh  = make(chan string, 1) // blur hash is encrypted
+		colorCh = make(chan string, 1) // dominant color is encrypted
 
-		errCh = make(chan error, 6) // to capture any errors from decryption
+		errCh = make(chan error, 8) // to capture any errors from decryption
 	)
 
 	wg.Add(5)
@@ -289,6 +351,17 @@ func (ic *imageCryptor) decryptImageFields(
 		go ic.decrypt(*imageDatePtr, "image date", IdCh, errCh, &wg)
 	}
 
+	// placeholders will not exist until the image has been processed by the pipeline
+	if blurHashPtr != nil && *blurHashPtr != "" {
+		wg.Add(1)
+		go ic.decrypt(*blurHashPtr, "image blur hash", blurCh, errCh, &wg)
+	}
+
+	if dominantColorPtr != nil && *dominantColorPtr != "" {
+		wg.Add(1)
+		go ic.decrypt(*dominantColorPtr, "image dominant color", colorCh, errCh, &wg)
+	}
+
 	// wait for all goroutines to finish
 	wg.Wait()
 	close(titleCh)
@@ -297,6 +370,8 @@ func (ic *imageCryptor) decryptImageFields(
 	close(okCh)
 	close(slugCh)
 	close(IdCh)
+	close(blurCh)
+	close(colorCh)
 	close(errCh)
 
 	// check for any errors during decryption
@@ -345,6 +420,18 @@ func (ic *imageCryptor) decryptImageFields(
 		}
 	}
 
+	if blurHashPtr != nil {
+		if blur, ok := <-blurCh; ok {
+			*blurHashPtr = blur
+		}
+	}
+
+	if dominantColorPtr != nil {
+		if color, ok := <-colorCh; ok {
+			*dominantColorPtr = color
+		}
+	}
+
 	return nil
 }
 
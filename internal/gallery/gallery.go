@@ -10,6 +10,8 @@ import (
 
 	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/tdeslauriers/carapace/pkg/config"
@@ -24,12 +26,15 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/storage"
 	"github.com/tdeslauriers/pixie/internal/album"
 	"github.com/tdeslauriers/pixie/internal/crypt"
+	"github.com/tdeslauriers/pixie/internal/multipart"
 	"github.com/tdeslauriers/pixie/internal/notification"
 	"github.com/tdeslauriers/pixie/internal/patron"
 	"github.com/tdeslauriers/pixie/internal/permission"
 	"github.com/tdeslauriers/pixie/internal/picture"
 	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
 )
 
 // Gallery is the interface for engine that runs this service
@@ -95,6 +100,13 @@ func New(config *config.Config) (Gallery, error) {
 		return nil, fmt.Errorf("failed to create object storage service: %v", err)
 	}
 
+	// multipart uploads for files too large for a single presigned put,
+	// and short-lived signed urls for downloading untouched originals as attachments
+	multipartStore, err := multipart.New(objStorageConfig, minioTlsConfig, util.MultipartPartUrlExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload object storage service: %v", err)
+	}
+
 	// db client
 	dbClientPki := &connect.Pki{
 		CertFile: *config.Certs.DbClientCert,
@@ -181,9 +193,41 @@ func New(config *config.Config) (Gallery, error) {
 	exoPermissionService := exo.NewService(db, indexer, cryptor, permission.AllowedServices)
 	patronPermissionService := permission.NewPatronPermissionService(db, indexer, cryptor)
 	imagePermissionService := permission.NewImagePermissionService(db, indexer, cryptor)
-	permissionService := permission.NewService(exoPermissionService, patronPermissionService, imagePermissionService)
+	albumPermissionService := permission.NewAlbumPermissionService(db, indexer, cryptor)
+	permissionService := permission.NewService(exoPermissionService, patronPermissionService, imagePermissionService, albumPermissionService)
+
+	// timezone used to determine "today" for the memories feed
+	memoriesTz := time.UTC
+	if tz := os.Getenv(util.MemoriesTimezoneEnv); tz != "" {
+		loc, err := time.LoadLocation(tz)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load memories timezone '%s' from %s: %v", tz, util.MemoriesTimezoneEnv, err)
+		}
+		memoriesTz = loc
+	}
+
+	// largest image file by file type, eg, to allow high resolution tiff scans
+	sizeLimits, err := api.ParseImageSizeLimits(os.Getenv(util.ImageSizeLimitsEnv))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse image size limits from %s: %v", util.ImageSizeLimitsEnv, err)
+	}
+
+	// widest resolution served to view-only users: it must be one that renditions are stored at or below
+	viewOnlyMaxWidth := util.DefaultViewOnlyMaxWidth
+	if w := os.Getenv(util.ViewOnlyMaxWidthEnv); w != "" {
+		width, err := strconv.Atoi(w)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse view-only max width '%s' from %s: %v", w, util.ViewOnlyMaxWidthEnv, err)
+		}
+		least, most := util.ResolutionWidthsImages[0], util.ResolutionWidthsImages[len(util.ResolutionWidthsImages)-1]
+		if width < least || width > most {
+			return nil, fmt.Errorf("view-only max width %d from %s must be between %d and %d", width, util.ViewOnlyMaxWidthEnv, least, most)
+		}
+		viewOnlyMaxWidth = width
+	}
 
 	// create reprocess and deletion queue
+	directUploadQueue := make(chan pipeline.UploadCmd, 100)
 	reprocessQueue := make(chan pipeline.ReprocessCmd, 100)
 	deletionQueue := make(chan pipeline.DeletionCmd, 100)
 
@@ -199,15 +243,18 @@ func New(config *config.Config) (Gallery, error) {
 		iamVerifier:      jwt.NewVerifier(config.ServiceName, iamPublicKey),
 		identity:         connect.NewS2sCaller(config.UserAuth.Url, util.ServiceIdentity, s2sClient, retry),
 		patVerifier:      pat.NewVerifier(util.ServiceS2s, s2s, tokenProvider),
-		pictures:         picture.NewService(db, indexer, cryptor, objStore, reprocessQueue, deletionQueue),
-		albums:           album.NewService(db, indexer, cryptor, objStore),
+		pictures:         picture.NewService(db, indexer, cryptor, objStore, multipartStore, sizeLimits, viewOnlyMaxWidth, directUploadQueue, reprocessQueue, deletionQueue),
+		albums:           album.NewService(db, indexer, cryptor, objStore, viewOnlyMaxWidth, deletionQueue),
 		staged:           album.NewStagedImageService(db, indexer, cryptor, objStore),
 		patrons:          patron.NewService(patronRepository, indexer, cryptor, permissionService),
 		permissions:      permissionService,
+		unitOfWork:       transaction.NewUnitOfWork(db),
+		memoriesTz:       memoriesTz,
 
-		uploadQueue:    make(chan storage.WebhookPutObject, 100),
-		reprocessQueue: reprocessQueue,
-		deletionQueue:  deletionQueue,
+		uploadQueue:       make(chan storage.WebhookPutObject, 100),
+		directUploadQueue: directUploadQueue,
+		reprocessQueue:    reprocessQueue,
+		deletionQueue:     deletionQueue,
 
 		logger: slog.Default().
 			With(slog.String(util.ServiceKey, util.ServiceGallery)).
@@ -236,11 +283,14 @@ type gallery struct {
 	staged           album.StagedImageService
 	patrons          patron.Service
 	permissions      permission.Service
+	unitOfWork       transaction.UnitOfWork
+	memoriesTz       *time.Location
 
-	uploadQueue    chan storage.WebhookPutObject
-	reprocessQueue chan pipeline.ReprocessCmd
-	deletionQueue  chan pipeline.DeletionCmd
-	wg             sync.WaitGroup
+	uploadQueue       chan storage.WebhookPutObject
+	directUploadQueue chan pipeline.UploadCmd
+	reprocessQueue    chan pipeline.ReprocessCmd
+	deletionQueue     chan pipeline.DeletionCmd
+	wg                sync.WaitGroup
 
 	logger *slog.Logger
 }
@@ -259,6 +309,7 @@ func (g *gallery) Run(ctx context.Context) error {
 	// image processing pipeline queue
 	imgPipeline := pipeline.NewImagePipeline(
 		g.uploadQueue,
+		g.directUploadQueue,
 		g.reprocessQueue,
 		g.deletionQueue,
 		&g.wg,
@@ -267,11 +318,19 @@ func (g *gallery) Run(ctx context.Context) error {
 		crypt.NewCryptor(g.cryptor),
 		g.objectStorage)
 
-	g.wg.Add(3)
+	g.wg.Add(7)
 	go imgPipeline.UploadQueue(ctx)
 	go imgPipeline.ReprocessQueue(ctx)
 	go imgPipeline.DeletionQueue(ctx)
 
+	// one-off backfills of derived data for images processed before they existed
+	go imgPipeline.BackfillPlaceholders(ctx)
+	go imgPipeline.BackfillDisplayOriginals(ctx)
+	go imgPipeline.BackfillDateKeys(ctx)
+
+	// abort abandoned multipart uploads so their parts do not accumulate in object storage
+	go g.cleanupMultipartUploads(ctx)
+
 	// register handlers
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", diagnostics.HealthCheckHandler)
@@ -285,16 +344,41 @@ func (g *gallery) Run(ctx context.Context) error {
 		g.iamVerifier,
 	)
 	mux.HandleFunc("/albums/{slug...}", albs.HandleAlbums)
+	mux.HandleFunc("/albums/{slug}/order", albs.HandleOrder)
+	mux.HandleFunc("/albums/{slug}/parent", albs.HandleParent)
+	mux.HandleFunc("/albums/{slug}/permissions", albs.HandlePermissions)
+	mux.HandleFunc("/albums/{slug}/rules", albs.HandleSmartRules)
+	mux.HandleFunc("/albums/{slug}/freeze", albs.HandleFreeze)
+	mux.HandleFunc("/albums/{slug}/export.zip", albs.HandleExport)
+
+	// timeline handler: chronological browsing across all permitted images
+	timeline := album.NewTimelineHandler(
+		g.albums,
+		g.permissions,
+		g.s2sVerifier,
+		g.iamVerifier,
+		g.memoriesTz,
+	)
+	mux.HandleFunc("/timeline", timeline.HandleTimeline)
+	mux.HandleFunc("/memories", timeline.HandleMemories)
 
 	// image handlers
 	pics := picture.NewHandler(
 		g.pictures,
 		g.albums,
 		g.permissions,
+		g.unitOfWork,
 		g.s2sVerifier,
 		g.iamVerifier,
 	)
 	mux.HandleFunc("/images/{slug...}", pics.HandleImage)
+	mux.HandleFunc("/images/{slug}/download", pics.HandleDownload)
+	mux.HandleFunc("/images/{slug}/renditions/{width}", pics.HandleRendition)
+	mux.HandleFunc("/images/{slug}/resize", pics.HandleResize)
+	mux.HandleFunc("/images/bulk", pics.HandleBulk) // more specific than /images/{slug...}, so takes precedence
+	mux.HandleFunc("/upload-sessions/{slug...}", pics.HandleUploadSessions)
+	mux.HandleFunc("/images/{slug}/multipart/{action...}", pics.HandleMultipart)
+	mux.HandleFunc("/images/upload", pics.HandleUpload)
 
 	// notification handler
 	notify := notification.NewHandler(
@@ -338,8 +422,35 @@ func (g *gallery) Run(ctx context.Context) error {
 	// close the queues first to handle graceful shutdown.
 	g.wg.Wait()
 	close(g.uploadQueue)
+	close(g.directUploadQueue)
 	close(g.reprocessQueue)
 	close(g.deletionQueue)
 
 	return nil
 }
+
+// cleanupMultipartUploads periodically aborts multipart uploads which have been in progress longer than
+// the multipart upload expiry, eg, because the client gave up, until the context is cancelled.
+func (g *gallery) cleanupMultipartUploads(ctx context.Context) {
+
+	defer g.wg.Done()
+
+	ticker := time.NewTicker(util.MultipartCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		aborted, err := g.pictures.AbortAbandonedMultipartUploads(ctx, util.MultipartUploadExpiry)
+		if err != nil && ctx.Err() == nil {
+			g.logger.Error("failed to clean up abandoned multipart uploads", "err", err.Error())
+		}
+		if aborted > 0 {
+			g.logger.Info(fmt.Sprintf("aborted %d abandoned multipart uploads", aborted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package multipart
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// listPageSize is the number of uploads or parts requested per page when listing them.
+const listPageSize int = 1000
+
+// Store is the interface for S3-style multipart uploads to object storage, which the carapace
+// object storage service does not expose: initiate an upload, presign each part, then complete or abort it.
+type Store interface {
+
+	// Initiate starts a multipart upload to the object key, returning the upload id.
+	Initiate(ctx context.Context, objectKey, contentType string) (string, error)
+
+	// PresignPart generates a presigned PUT url for uploading a part of the multipart upload.
+	PresignPart(ctx context.Context, objectKey, uploadId string, partNumber int) (*url.URL, error)
+
+	// ListParts lists the parts of the multipart upload which have been uploaded, ordered by part number.
+	ListParts(ctx context.Context, objectKey, uploadId string) ([]Part, error)
+
+	// Complete assembles the uploaded parts into the object.
+	// Object storage sends its object created notification once this returns.
+	Complete(ctx context.Context, objectKey, uploadId string, parts []Part) error
+
+	// Abort abandons the multipart upload, discarding any parts which have been uploaded.
+	Abort(ctx context.Context, objectKey, uploadId string) error
+
+	// ListUploads lists the multipart uploads in progress for object keys beginning with the prefix.
+	ListUploads(ctx context.Context, prefix string) ([]Upload, error)
+
+	// Put streams the reader into the object, uploading it in parts if it is large, so that only one part
+	// is held in memory at a time.  The size must be the number of bytes the reader returns.
+	Put(ctx context.Context, objectKey, contentType string, r io.Reader, size int64) error
+
+	// PresignDownload generates a presigned GET url for the object which expires after the given duration.
+	// Object storage responds with the content disposition, eg, an attachment filename, so browsers save the
+	// file under that name, which the carapace object storage service's signed urls cannot do.
+	PresignDownload(ctx context.Context, objectKey, contentDisposition string, expiry time.Duration) (*url.URL, error)
+}
+
+// Part is a model representing an uploaded part of a multipart upload.
+type Part struct {
+	PartNumber int
+	ETag       string
+	Size       int64
+}
+
+// Upload is a model representing a multipart upload in progress.
+type Upload struct {
+	ObjectKey string
+	UploadId  string
+	Initiated time.Time
+}
+
+// New creates a new multipart upload Store for the object storage bucket, returning a pointer to the concrete implementation.
+// The presigned part urls expire after the given duration.
+func New(config storage.Config, tlsConfig *tls.Config, expiry time.Duration) (Store, error) {
+
+	transport, err := minio.DefaultTransport(true)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload client transport: %v", err)
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	core, err := minio.NewCore(config.Url, &minio.Options{
+		Creds:     credentials.NewStaticV4(config.AccessKey, config.SecretKey, ""),
+		Secure:    true,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create multipart upload client: %v", err)
+	}
+
+	return &store{
+		core:   core,
+		bucket: config.Bucket,
+		expiry: expiry,
+	}, nil
+}
+
+var _ Store = (*store)(nil)
+
+// store is the concrete implementation of the Store interface.
+type store struct {
+	core   *minio.Core
+	bucket string
+	expiry time.Duration
+}
+
+// Initiate is the concrete implementation of the interface method which starts a multipart upload to the object key.
+func (s *store) Initiate(ctx context.Context, objectKey, contentType string) (string, error) {
+
+	uploadId, err := s.core.NewMultipartUpload(ctx, s.bucket, objectKey, minio.PutObjectOptions{ContentType: contentType})
+	if err != nil {
+		return "", fmt.Errorf("failed to initiate multipart upload for object '%s': %v", objectKey, err)
+	}
+
+	return uploadId, nil
+}
+
+// PresignPart is the concrete implementation of the interface method which generates a presigned PUT url
+// for uploading a part of the multipart upload.
+func (s *store) PresignPart(ctx context.Context, objectKey, uploadId string, partNumber int) (*url.URL, error) {
+
+	params := url.Values{}
+	params.Set("partNumber", strconv.Itoa(partNumber))
+	params.Set("uploadId", uploadId)
+
+	u, err := s.core.Presign(ctx, http.MethodPut, s.bucket, objectKey, s.expiry, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign part %d of multipart upload for object '%s': %v", partNumber, objectKey, err)
+	}
+
+	return u, nil
+}
+
+// ListParts is the concrete implementation of the interface method which lists the parts of the multipart upload
+// which have been uploaded.
+func (s *store) ListParts(ctx context.Context, objectKey, uploadId string) ([]Part, error) {
+
+	var parts []Part
+	marker := 0
+	for {
+		result, err := s.core.ListObjectParts(ctx, s.bucket, objectKey, uploadId, marker, listPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts of multipart upload for object '%s': %v", objectKey, err)
+		}
+
+		for _, p := range result.ObjectParts {
+			parts = append(parts, Part{
+				PartNumber: p.PartNumber,
+				ETag:       p.ETag,
+				Size:       p.Size,
+			})
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		marker = result.NextPartNumberMarker
+	}
+
+	sort.Slice(parts, func(i, j int) bool { return parts[i].PartNumber < parts[j].PartNumber })
+
+	return parts, nil
+}
+
+// Complete is the concrete implementation of the interface method which assembles the uploaded parts into the object.
+func (s *store) Complete(ctx context.Context, objectKey, uploadId string, parts []Part) error {
+
+	// the S3 api requires the parts in ascending order
+	complete := make([]minio.CompletePart, 0, len(parts))
+	for _, p := range parts {
+		complete = append(complete, minio.CompletePart{PartNumber: p.PartNumber, ETag: p.ETag})
+	}
+	sort.Slice(complete, func(i, j int) bool { return complete[i].PartNumber < complete[j].PartNumber })
+
+	if _, err := s.core.CompleteMultipartUpload(ctx, s.bucket, objectKey, uploadId, complete, minio.PutObjectOptions{}); err != nil {
+		return fmt.Errorf("failed to complete multipart upload for object '%s': %v", objectKey, err)
+	}
+
+	return nil
+}
+
+// Abort is the concrete implementation of the interface method which abandons the multipart upload.
+func (s *store) Abort(ctx context.Context, objectKey, uploadId string) error {
+
+	if err := s.core.AbortMultipartUpload(ctx, s.bucket, objectKey, uploadId); err != nil {
+		return fmt.Errorf("failed to abort multipart upload for object '%s': %v", objectKey, err)
+	}
+
+	return nil
+}
+
+// ListUploads is the concrete implementation of the interface method which lists the multipart uploads in progress
+// for object keys beginning with the prefix.
+func (s *store) ListUploads(ctx context.Context, prefix string) ([]Upload, error) {
+
+	var uploads []Upload
+	keyMarker, uploadIdMarker := "", ""
+	for {
+		result, err := s.core.ListMultipartUploads(ctx, s.bucket, prefix, keyMarker, uploadIdMarker, "", listPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list multipart uploads with prefix '%s': %v", prefix, err)
+		}
+
+		for _, u := range result.Uploads {
+			uploads = append(uploads, Upload{
+				ObjectKey: u.Key,
+				UploadId:  u.UploadID,
+				Initiated: u.Initiated,
+			})
+		}
+
+		if !result.IsTruncated {
+			break
+		}
+		keyMarker, uploadIdMarker = result.NextKeyMarker, result.NextUploadIDMarker
+	}
+
+	return uploads, nil
+}
+
+// Put is the concrete implementation of the interface method which streams the reader into the object.
+func (s *store) Put(ctx context.Context, objectKey, contentType string, r io.Reader, size int64) error {
+
+	opts := minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    api.MultipartPartSize,
+	}
+
+	// Core's PutObject uploads a single part: the client's streams in parts
+	if _, err := s.core.Client.PutObject(ctx, s.bucket, objectKey, r, size, opts); err != nil {
+		return fmt.Errorf("failed to stream object '%s' to object storage: %v", objectKey, err)
+	}
+
+	return nil
+}
+
+// PresignDownload is the concrete implementation of the interface method which generates a presigned GET url
+// for the object with a response content disposition.
+func (s *store) PresignDownload(ctx context.Context, objectKey, contentDisposition string, expiry time.Duration) (*url.URL, error) {
+
+	params := url.Values{}
+	if contentDisposition != "" {
+		params.Set("response-content-disposition", contentDisposition)
+	}
+
+	u, err := s.core.Presign(ctx, http.MethodGet, s.bucket, objectKey, expiry, params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to presign download of object '%s': %v", objectKey, err)
+	}
+
+	return u, nil
+}
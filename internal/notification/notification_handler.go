@@ -19,6 +19,14 @@ import (
 // required scopes for upload notification
 var requiredScopes = []string{"w:pixie:*", "w:pixie:images:notify:upload:*"}
 
+// uploadEvents are the object storage events which mean an image file has finished uploading:
+// a single presigned PUT, or the completion of a multipart upload.
+// Note: the bucket notification must be configured to send both.
+var uploadEvents = map[string]struct{}{
+	"s3:ObjectCreated:Put":                     {},
+	"s3:ObjectCreated:CompleteMultipartUpload": {},
+}
+
 // Handler handles external service notification-related operations.
 type Handler interface {
 
@@ -127,6 +135,14 @@ func (h *handler) HandleImageUploadNotification(w http.ResponseWriter, r *http.R
 		return
 	}
 
+	// other events, eg, copies, are acknowledged so object storage does not retry them, but not processed
+	if _, ok := uploadEvents[webhook.MinioEventName]; !ok {
+		log.Warn(fmt.Sprintf("ignoring %s notification for object %s: not an upload", webhook.MinioEventName, webhook.MinioKey))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
 	log.Info(fmt.Sprintf("received image upload notification for object %s in bucket %s", webhook.MinioKey, webhook.Records[0].S3.Bucket.Name))
 
 	// send webhook to processing queue
@@ -0,0 +1,418 @@
+package permission
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/data"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/transaction"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// AlbumPermissionService is an interface for managing album_permission xref records in the database,
+// ie, permissions granted at the album level, and passing them on to the album's images.
+type AlbumPermissionService interface {
+
+	// GetAlbumPermissions retrieves the permissions granted at the album level, and whether
+	// images added to the album inherit them.
+	GetAlbumPermissions(albumId string) ([]exo.PermissionRecord, bool, error)
+
+	// GetInheritedPermissionSlugs retrieves the slugs of the permissions an image inherits when it is added
+	// to the albums with the given slugs.  Albums which do not pass their permissions on are ignored.
+	GetInheritedPermissionSlugs(albumSlugs []string) ([]string, error)
+
+	// UpdateAlbumPermissions replaces the permissions granted at the album level and, if the command asks,
+	// applies the added and removed permissions to the album's existing images.
+	// If the command is a preview, the changes are reported but not made.  Otherwise, the album and image
+	// changes are made in a single transaction, so they are all made or none are.
+	// Note: only the id, slug, and title of the images are used.
+	UpdateAlbumPermissions(ctx context.Context, albumId string, images []api.ImageData, cmd api.AlbumPermissionsCmd) (*api.AlbumPermissionsChange, error)
+}
+
+// imagePermissionBatchSize is the most images a permission is added to, or removed from, in a single statement
+// when an album's permission change is applied to its images, keeping statements well within placeholder limits.
+const imagePermissionBatchSize = 500
+
+// NewAlbumPermissionService creates a new AlbumPermissionService instance, returning a pointer to the concrete implementation.
+func NewAlbumPermissionService(sql *sql.DB, i data.Indexer, c data.Cryptor) AlbumPermissionService {
+	return &albumPermissionService{
+		sql:     NewRepository(sql),
+		uow:     transaction.NewUnitOfWork(sql),
+		indexer: i,
+		cryptor: exo.NewPermissionCryptor(c),
+
+		logger: slog.Default().
+			With(slog.String(util.PackageKey, util.PackagePermissions)).
+			With(slog.String(util.ComponentKey, util.ComponentAlbumPermissions)),
+	}
+}
+
+var _ AlbumPermissionService = (*albumPermissionService)(nil)
+
+// albumPermissionService is the concrete implementation of the AlbumPermissionService interface.
+type albumPermissionService struct {
+	sql     Repository
+	uow     transaction.UnitOfWork
+	indexer data.Indexer
+	cryptor exo.PermissionCryptor
+
+	logger *slog.Logger
+}
+
+// GetAlbumPermissions is the concrete implementation of the interface method which
+// retrieves the permissions granted at the album level, and whether images added to the album inherit them.
+func (s *albumPermissionService) GetAlbumPermissions(albumId string) ([]exo.PermissionRecord, bool, error) {
+
+	// validate the album id
+	if err := validate.ValidateUuid(albumId); err != nil {
+		return nil, false, fmt.Errorf("album Id must be a valid UUID")
+	}
+
+	var (
+		wg    sync.WaitGroup
+		errCh = make(chan error, 2)
+
+		records []exo.PermissionRecord
+		inherit bool
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		found, err := s.sql.FindAlbumPermissions(albumId)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to retrieve album '%s' permissions from database: %v", albumId, err)
+			return
+		}
+		records = found
+	}()
+
+	go func() {
+		defer wg.Done()
+		ok, err := s.sql.AlbumInheritsPermissions(albumId)
+		if err != nil {
+			errCh <- fmt.Errorf("failed to check if album '%s' passes its permissions on: %v", albumId, err)
+			return
+		}
+		inherit = ok
+	}()
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, false, errors.Join(errs...)
+	}
+
+	if err := s.decryptPermissions(records); err != nil {
+		return nil, false, err
+	}
+
+	return records, inherit, nil
+}
+
+// GetInheritedPermissionSlugs is the concrete implementation of the interface method which
+// retrieves the slugs of the permissions an image inherits when it is added to the albums with the given slugs.
+// Albums which do not pass their permissions on are ignored.
+func (s *albumPermissionService) GetInheritedPermissionSlugs(albumSlugs []string) ([]string, error) {
+
+	if len(albumSlugs) == 0 {
+		return []string{}, nil
+	}
+
+	// album slugs are encrypted: look them up by blind index
+	indexes := make([]string, 0, len(albumSlugs))
+	for _, slug := range albumSlugs {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return nil, fmt.Errorf("album slug '%s' must be a valid UUID", slug)
+		}
+
+		index, err := s.indexer.ObtainBlindIndex(slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain blind index for album slug '%s': %v", slug, err)
+		}
+		indexes = append(indexes, index)
+	}
+
+	records, err := s.sql.FindInheritedAlbumPermissions(indexes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve inherited album permissions from database: %v", err)
+	}
+
+	if err := s.decryptPermissions(records); err != nil {
+		return nil, err
+	}
+
+	slugs := make([]string, 0, len(records))
+	for _, p := range records {
+		slugs = append(slugs, p.Slug)
+	}
+
+	return slugs, nil
+}
+
+// UpdateAlbumPermissions is the concrete implementation of the interface method which
+// replaces the permissions granted at the album level and, if the command asks, applies
+// the added and removed permissions to the album's existing images.
+// If the command is a preview, the changes are reported but not made.
+func (s *albumPermissionService) UpdateAlbumPermissions(
+	ctx context.Context,
+	albumId string,
+	images []api.ImageData,
+	cmd api.AlbumPermissionsCmd,
+) (*api.AlbumPermissionsChange, error) {
+
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = s.logger.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for UpdateAlbumPermissions")
+	}
+
+	// validate the album id and command
+	if err := validate.ValidateUuid(albumId); err != nil {
+		return nil, fmt.Errorf("album Id must be a valid UUID")
+	}
+
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+
+	// retrieve all permissions to check the cmd permission slugs are real permissions
+	all, err := s.sql.FindAllPermissions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve all permissions: %v", err)
+	}
+
+	if err := s.decryptPermissions(all); err != nil {
+		return nil, err
+	}
+
+	bySlug := make(map[string]exo.PermissionRecord, len(all))
+	for _, p := range all {
+		bySlug[p.Slug] = p
+	}
+
+	// NOTE: keyed by permission uuid to compare with the album's current permissions
+	requested := make(map[string]exo.PermissionRecord, len(cmd.PermissionSlugs))
+	for _, slug := range cmd.PermissionSlugs {
+		p, ok := bySlug[slug]
+		if !ok {
+			return nil, fmt.Errorf("permission slug '%s' does not exist", slug)
+		}
+		requested[p.Id] = p
+	}
+
+	// get the album's current permissions
+	current, inherit, err := s.GetAlbumPermissions(albumId)
+	if err != nil {
+		return nil, err
+	}
+
+	currentById := make(map[string]exo.PermissionRecord, len(current))
+	for _, p := range current {
+		currentById[p.Id] = p
+	}
+
+	// determine which permissions need to be added and which need to be removed
+	var toAdd, toRemove []exo.PermissionRecord
+	for id, p := range requested {
+		if _, ok := currentById[id]; !ok {
+			toAdd = append(toAdd, p)
+		}
+	}
+
+	for id, p := range currentById {
+		if _, ok := requested[id]; !ok {
+			toRemove = append(toRemove, p)
+		}
+	}
+
+	// sort by name so previews are stable between requests
+	sortByName := func(ps []exo.PermissionRecord) {
+		sort.Slice(ps, func(i, j int) bool { return ps[i].Name < ps[j].Name })
+	}
+	sortByName(toAdd)
+	sortByName(toRemove)
+
+	added, _ := MapPermissionRecordsToApi(toAdd)      // cannot fail
+	removed, _ := MapPermissionRecordsToApi(toRemove) // cannot fail
+
+	change := &api.AlbumPermissionsChange{
+		InheritPermissions: cmd.InheritPermissions,
+		Added:              added,
+		Removed:            removed,
+		Images:             []api.ImagePermissionsChange{},
+	}
+
+	// determine which existing images gain or lose permissions if the change is applied to them
+	if cmd.ApplyToImages && len(images) > 0 && (len(toAdd) > 0 || len(toRemove) > 0) {
+
+		xrefs, err := s.sql.FindAlbumImagePermissionXrefs(albumId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve permissions of album '%s' images: %v", albumId, err)
+		}
+
+		has := make(map[string]map[string]struct{}, len(images))
+		for _, x := range xrefs {
+			if has[x.ImageId] == nil {
+				has[x.ImageId] = make(map[string]struct{})
+			}
+			has[x.ImageId][x.PermissionId] = struct{}{}
+		}
+
+		for _, img := range images {
+			ic := api.ImagePermissionsChange{
+				Id:    img.Id,
+				Slug:  img.Slug,
+				Title: img.Title,
+			}
+
+			for _, p := range toAdd {
+				if _, ok := has[img.Id][p.Id]; !ok {
+					ic.Added = append(ic.Added, p.Slug)
+				}
+			}
+
+			for _, p := range toRemove {
+				if _, ok := has[img.Id][p.Id]; ok {
+					ic.Removed = append(ic.Removed, p.Slug)
+				}
+			}
+
+			if len(ic.Added) > 0 || len(ic.Removed) > 0 {
+				change.Images = append(change.Images, ic)
+			}
+		}
+	}
+
+	if cmd.Preview {
+		log.Info(fmt.Sprintf("previewed permissions change for album '%s': %d to add, %d to remove, %d images affected",
+			albumId, len(toAdd), len(toRemove), len(change.Images)))
+		return change, nil
+	}
+
+	// group the image changes by permission so each permission is applied to its images in one statement
+	addImages := make(map[string][]string, len(toAdd))
+	removeImages := make(map[string][]string, len(toRemove))
+	for _, ic := range change.Images {
+		for _, slug := range ic.Added {
+			addImages[bySlug[slug].Id] = append(addImages[bySlug[slug].Id], ic.Id)
+		}
+		for _, slug := range ic.Removed {
+			removeImages[bySlug[slug].Id] = append(removeImages[bySlug[slug].Id], ic.Id)
+		}
+	}
+
+	// the album and image changes are made together: an album must never be left half applied
+	// note: statements within a transaction run one at a time on its connection, so they are not fanned out
+	now := data.CustomTime{Time: time.Now().UTC()}
+	if err := s.uow.Run(ctx, func(tx transaction.Tx) error {
+
+		// add new album permission associations
+		for _, p := range toAdd {
+			xref := AlbumPermissionXref{
+				Id:           0, // auto-incremented by the database
+				AlbumId:      albumId,
+				PermissionId: p.Id,
+				CreatedAt:    now,
+			}
+
+			if err := s.sql.InsertAlbumPermissionXrefTx(ctx, tx, xref); err != nil {
+				return fmt.Errorf("failed to add permission '%s' to album '%s': %v", p.Id, albumId, err)
+			}
+		}
+
+		// remove old album permission associations
+		for _, p := range toRemove {
+			if err := s.sql.DeleteAlbumPermissionXrefTx(ctx, tx, albumId, p.Id); err != nil {
+				return fmt.Errorf("failed to remove permission '%s' from album '%s': %v", p.Id, albumId, err)
+			}
+		}
+
+		// update whether images added to the album later inherit its permissions
+		if inherit != cmd.InheritPermissions {
+			if err := s.sql.UpdateAlbumInheritsPermissionsTx(ctx, tx, albumId, cmd.InheritPermissions); err != nil {
+				return fmt.Errorf("failed to update whether album '%s' passes its permissions on: %v", albumId, err)
+			}
+		}
+
+		// apply the change to the album's existing images, in batches to bound the size of each statement
+		for _, p := range toAdd {
+			for batch := range slices.Chunk(addImages[p.Id], imagePermissionBatchSize) {
+				if err := s.sql.InsertImagePermissionXrefsTx(ctx, tx, p.Id, batch, now); err != nil {
+					return fmt.Errorf("failed to add permission '%s' to %d images of album '%s': %v", p.Id, len(batch), albumId, err)
+				}
+			}
+		}
+
+		for _, p := range toRemove {
+			for batch := range slices.Chunk(removeImages[p.Id], imagePermissionBatchSize) {
+				if err := s.sql.DeleteImagePermissionXrefsTx(ctx, tx, p.Id, batch); err != nil {
+					return fmt.Errorf("failed to remove permission '%s' from %d images of album '%s': %v", p.Id, len(batch), albumId, err)
+				}
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to update album permission xref records: %v", err)
+	}
+
+	change.Applied = true
+
+	log.Info(fmt.Sprintf("updated permissions for album '%s': %d added, %d removed, %d images updated",
+		albumId, len(toAdd), len(toRemove), len(change.Images)))
+
+	return change, nil
+}
+
+// decryptPermissions is a helper which concurrently decrypts permission records in place.
+func (s *albumPermissionService) decryptPermissions(records []exo.PermissionRecord) error {
+
+	var (
+		wg    sync.WaitGroup
+		errCh = make(chan error, len(records))
+	)
+
+	for i, record := range records {
+		wg.Add(1)
+		go func(i int, record exo.PermissionRecord) {
+			defer wg.Done()
+			decrypted, err := s.cryptor.DecryptPermission(record)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decrypt permission '%s': %v", record.Id, err)
+				return
+			}
+			records[i] = *decrypted
+		}(i, record)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return fmt.Errorf("failed to decrypt permission records: %v", errors.Join(errs...))
+	}
+
+	return nil
+}
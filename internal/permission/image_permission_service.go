@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/data"
 	exo "github.com/tdeslauriers/carapace/pkg/permissions"
 	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
 )
 
@@ -28,12 +30,33 @@ type ImagePermissionService interface {
 	// UpdateImagePermissions updates the permissions associated with an image.
 	// It adds new permissions and removes old ones as necessary.
 	// It takes the image ID and a slice of permission slugs to be associated with the image.
+	// The changes are written within the unit of work's transaction.
 	// Returns an error if any.
-	UpdateImagePermissions(ctx context.Context, imageId string, permissionSlugs []string) error
+	UpdateImagePermissions(ctx context.Context, tx transaction.Tx, imageId string, permissionSlugs []string) error
 
 	// DeleteImagePermissions deletes all xrefs associated with an image, effectively removing all permissions from the image.
 	// It takes the image ID as input and returns an error if any.
 	DeleteImagePermissions(imageId string) error
+
+	// GetImageDownloadPermissions retrieves the permissions associated with an image whose holders
+	// may download the original image file.  Returns an empty slice if the image is view-only for all permissions.
+	GetImageDownloadPermissions(imageId string) ([]exo.PermissionRecord, error)
+
+	// UpdateImageDownloadPermissions sets which of the image's permissions may download the original image file.
+	// All other permissions associated with the image are view-only.
+	// Note: permissions must already be associated with the image, ie, call UpdateImagePermissions first,
+	// within the same unit of work.
+	UpdateImageDownloadPermissions(ctx context.Context, tx transaction.Tx, imageId string, permissionSlugs []string) error
+
+	// PatchImagePermissions grants and revokes the given permissions on an image, leaving its other permissions as they are.
+	// The changes are written within the unit of work's transaction.
+	PatchImagePermissions(ctx context.Context, tx transaction.Tx, imageId string, add, remove []string) error
+
+	// PatchImageDownloadPermissions allows and disallows downloading the original image file for the given permissions,
+	// leaving the others as they are.
+	// Note: permissions must already be associated with the image, ie, call PatchImagePermissions first,
+	// within the same unit of work.
+	PatchImageDownloadPermissions(ctx context.Context, tx transaction.Tx, imageId string, add, remove []string) error
 }
 
 // NewImagePermissionService creates a new ImagePermissionService instance, returning a pointer to the concrete implementation.
@@ -77,6 +100,25 @@ func (s *imagePermissionService) GetImagePermissions(imageId string) (map[string
 		return nil, nil, fmt.Errorf("failed to retrieve image '%s' permissions from database: %w", imageId, err)
 	}
 
+	return s.mapImagePermissions(imageId, records)
+}
+
+// getImagePermissionsTx is a helper which retrieves the permissions associated with an image
+// within a unit of work, so changes made earlier in the unit of work are included.
+func (s *imagePermissionService) getImagePermissionsTx(ctx context.Context, tx transaction.Tx, imageId string) (map[string]exo.PermissionRecord, []exo.PermissionRecord, error) {
+
+	records, err := s.sql.FindImagePermissionsTx(ctx, tx, imageId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve image '%s' permissions from database: %w", imageId, err)
+	}
+
+	return s.mapImagePermissions(imageId, records)
+}
+
+// mapImagePermissions is a helper which decrypts an image's permission records,
+// returning them as a map keyed by the permission field, and as a slice.
+func (s *imagePermissionService) mapImagePermissions(imageId string, records []exo.PermissionRecord) (map[string]exo.PermissionRecord, []exo.PermissionRecord, error) {
+
 	if len(records) == 0 {
 		return nil, nil, fmt.Errorf("no permissions found for image '%s'", imageId)
 	}
@@ -129,8 +171,9 @@ func (s *imagePermissionService) GetImagePermissions(imageId string) (map[string
 // updates the permissions associated with an image.
 // It adds new permissions and removes old ones as necessary.
 // It takes the image ID and a slice of permission slugs to be associated with the image.
+// The changes are written within the unit of work's transaction.
 // Returns an error if any.
-func (s *imagePermissionService) UpdateImagePermissions(ctx context.Context, imageId string, permissionSlugs []string) error {
+func (s *imagePermissionService) UpdateImagePermissions(ctx context.Context, tx transaction.Tx, imageId string, permissionSlugs []string) error {
 
 	log := s.logger
 	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
@@ -215,7 +258,7 @@ func (s *imagePermissionService) UpdateImagePermissions(ctx context.Context, ima
 	}
 
 	// get the current permissions associated with the image
-	currentPsMap, _, err := s.GetImagePermissions(imageId)
+	currentPsMap, _, err := s.getImagePermissionsTx(ctx, tx, imageId)
 	if err != nil {
 
 		if strings.Contains(err.Error(), "no permissions found for image") {
@@ -251,63 +294,33 @@ func (s *imagePermissionService) UpdateImagePermissions(ctx context.Context, ima
 
 	if len(toAdd) > 0 || len(toRemove) > 0 {
 		log.Info(fmt.Sprintf("updating permissions for image '%s': %d to add, %d to remove", imageId, len(toAdd), len(toRemove)))
-		// perform the additions and removals in parallel
-		var (
-			xrefWg    sync.WaitGroup
-			xrefErrCh = make(chan error, len(toAdd)+len(toRemove))
-		)
 
-		// add new permissions associations
+		// statements within a transaction run one at a time on its connection, so they are not fanned out
 		for _, p := range toAdd {
-			xrefWg.Add(1)
-			go func(permissionId string) {
-				defer xrefWg.Done()
-
-				// add the xref record
-				xref := ImagePermissionXref{
-					Id:           0, // auto-incremented by the database
-					ImageId:      imageId,
-					PermissionId: permissionId,
-					CreatedAt:    data.CustomTime{Time: time.Now().UTC()},
-				}
-
-				if err := s.sql.InsertImagePermissionXref(xref); err != nil {
-					xrefErrCh <- fmt.Errorf("failed to add permission '%s' to image '%s': %v", p.Slug, imageId, err)
-					return
-				}
-
-				log.Info(fmt.Sprintf("added permission '%s' to image '%s'", permissionId, imageId))
-			}(p.Id)
-		}
 
-		// remove old permissions associations
-		for _, p := range toRemove {
-			xrefWg.Add(1)
-			go func(permissionId string) {
-				defer xrefWg.Done()
-
-				// remove the xref record
-				if err := s.sql.DeleteImagePermissionXref(imageId, permissionId); err != nil {
-					xrefErrCh <- fmt.Errorf("failed to remove permission '%s' from image '%s': %v", p.Id, imageId, err)
-					return
-				}
-
-				log.Info(fmt.Sprintf("removed permission '%s' from image '%s'", permissionId, imageId))
-			}(p.Id)
+			// add the xref record
+			xref := ImagePermissionXref{
+				Id:           0, // auto-incremented by the database
+				ImageId:      imageId,
+				PermissionId: p.Id,
+				CreatedAt:    data.CustomTime{Time: time.Now().UTC()},
+			}
+
+			if err := s.sql.InsertImagePermissionXrefTx(ctx, tx, xref); err != nil {
+				return fmt.Errorf("failed to add permission '%s' to image '%s': %v", p.Slug, imageId, err)
+			}
+
+			log.Info(fmt.Sprintf("added permission '%s' to image '%s'", p.Id, imageId))
 		}
 
-		xrefWg.Wait()
-		close(xrefErrCh)
+		for _, p := range toRemove {
 
-		// check for errors during xref updates
-		if len(xrefErrCh) > 0 {
-			var errs []error
-			for e := range xrefErrCh {
-				errs = append(errs, e)
-			}
-			if len(errs) > 0 {
-				return fmt.Errorf("failed to update image permission xref records: %v", errors.Join(errs...))
+			// remove the xref record
+			if err := s.sql.DeleteImagePermissionXrefTx(ctx, tx, imageId, p.Id); err != nil {
+				return fmt.Errorf("failed to remove permission '%s' from image '%s': %v", p.Id, imageId, err)
 			}
+
+			log.Info(fmt.Sprintf("removed permission '%s' from image '%s'", p.Id, imageId))
 		}
 
 	} else {
@@ -334,3 +347,211 @@ func (s *imagePermissionService) DeleteImagePermissions(imageId string) error {
 
 	return nil
 }
+
+// GetImageDownloadPermissions is the concrete implementation of the interface method which
+// retrieves the permissions associated with an image whose holders may download the original image file.
+// Returns an empty slice if the image is view-only for all permissions.
+func (s *imagePermissionService) GetImageDownloadPermissions(imageId string) ([]exo.PermissionRecord, error) {
+
+	// validate the image id
+	if err := validate.ValidateUuid(imageId); err != nil {
+		return nil, fmt.Errorf("image Id must be a valid UUID")
+	}
+
+	records, err := s.sql.FindImageDownloadPermissions(imageId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve image '%s' download permissions from database: %v", imageId, err)
+	}
+
+	if len(records) == 0 {
+		return []exo.PermissionRecord{}, nil
+	}
+
+	var (
+		wg    sync.WaitGroup
+		errCh = make(chan error, len(records))
+	)
+
+	for i, record := range records {
+		wg.Add(1)
+		go func(i int, record exo.PermissionRecord) {
+			defer wg.Done()
+			decrypted, err := s.cryptor.DecryptPermission(record)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to decrypt permission '%s': %v", record.Id, err)
+				return
+			}
+			records[i] = *decrypted
+		}(i, record)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	// check for errors during decryption
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		return nil, fmt.Errorf("failed to decrypt download permission records: %v", errors.Join(errs...))
+	}
+
+	return records, nil
+}
+
+// UpdateImageDownloadPermissions is the concrete implementation of the interface method which
+// sets which of the image's permissions may download the original image file.
+// All other permissions associated with the image are view-only.
+// Note: permissions must already be associated with the image, ie, call UpdateImagePermissions first,
+// within the same unit of work.
+func (s *imagePermissionService) UpdateImageDownloadPermissions(ctx context.Context, tx transaction.Tx, imageId string, permissionSlugs []string) error {
+
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = s.logger.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for UpdateImageDownloadPermissions")
+	}
+
+	// validate the image id
+	if err := validate.ValidateUuid(imageId); err != nil {
+		return fmt.Errorf("image Id must be a valid UUID")
+	}
+
+	// validate the permission slugs
+	for _, slug := range permissionSlugs {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return fmt.Errorf("permission slug '%s' is not valid", slug)
+		}
+	}
+
+	// get the permissions associated with the image, including any added earlier in the unit of work
+	_, current, err := s.getImagePermissionsTx(ctx, tx, imageId)
+	if err != nil {
+		if !strings.Contains(err.Error(), "no permissions found for image") {
+			return fmt.Errorf("failed to get current permissions for image '%s': %v", imageId, err)
+		}
+	}
+
+	// map the image's permissions by slug to look up the download permission slugs
+	bySlug := make(map[string]exo.PermissionRecord, len(current))
+	for _, p := range current {
+		bySlug[p.Slug] = p
+	}
+
+	updated := make(map[string]struct{}, len(permissionSlugs))
+	for _, slug := range permissionSlugs {
+		p, ok := bySlug[slug]
+		if !ok {
+			return fmt.Errorf("download permission slug '%s' is not valid: it is not associated with image '%s'", slug, imageId)
+		}
+		updated[p.Id] = struct{}{}
+	}
+
+	// get the current download permissions to determine if an update is necessary
+	// Note: only the uuids are compared, so there is no need to decrypt them
+	existing, err := s.sql.FindImageDownloadPermissionsTx(ctx, tx, imageId)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve image '%s' download permissions from database: %v", imageId, err)
+	}
+
+	changed := len(existing) != len(updated)
+	if !changed {
+		for _, p := range existing {
+			if _, ok := updated[p.Id]; !ok {
+				changed = true
+				break
+			}
+		}
+	}
+
+	if !changed {
+		log.Info(fmt.Sprintf("no changes to download permissions for image '%s'", imageId))
+		return nil
+	}
+
+	ids := make([]string, 0, len(updated))
+	for id := range updated {
+		ids = append(ids, id)
+	}
+
+	if err := s.sql.UpdateImagePermissionDownloads(ctx, tx, imageId, ids); err != nil {
+		return fmt.Errorf("failed to update download permissions for image '%s': %v", imageId, err)
+	}
+
+	log.Info(fmt.Sprintf("updated download permissions for image '%s': %d downloadable of %d", imageId, len(ids), len(current)))
+
+	return nil
+}
+
+// PatchImagePermissions is the concrete implementation of the interface method which
+// grants and revokes the given permissions on an image, leaving its other permissions as they are.
+func (s *imagePermissionService) PatchImagePermissions(ctx context.Context, tx transaction.Tx, imageId string, add, remove []string) error {
+
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	// get the permissions associated with the image, including any added earlier in the unit of work
+	_, current, err := s.getImagePermissionsTx(ctx, tx, imageId)
+	if err != nil && !strings.Contains(err.Error(), "no permissions found for image") {
+		return fmt.Errorf("failed to get current permissions for image '%s': %v", imageId, err)
+	}
+
+	currentSlugs := make([]string, 0, len(current))
+	for _, p := range current {
+		currentSlugs = append(currentSlugs, p.Slug)
+	}
+
+	return s.UpdateImagePermissions(ctx, tx, imageId, patchSlugs(currentSlugs, add, remove))
+}
+
+// PatchImageDownloadPermissions is the concrete implementation of the interface method which
+// allows and disallows downloading the original image file for the given permissions, leaving the others as they are.
+func (s *imagePermissionService) PatchImageDownloadPermissions(ctx context.Context, tx transaction.Tx, imageId string, add, remove []string) error {
+
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	// get the permissions associated with the image, including any added earlier in the unit of work
+	_, current, err := s.getImagePermissionsTx(ctx, tx, imageId)
+	if err != nil && !strings.Contains(err.Error(), "no permissions found for image") {
+		return fmt.Errorf("failed to get current permissions for image '%s': %v", imageId, err)
+	}
+
+	// the download permissions are not decrypted, so their slugs are looked up from the image's permissions
+	slugsById := make(map[string]string, len(current))
+	for _, p := range current {
+		slugsById[p.Id] = p.Slug
+	}
+
+	downloads, err := s.sql.FindImageDownloadPermissionsTx(ctx, tx, imageId)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve image '%s' download permissions from database: %v", imageId, err)
+	}
+
+	downloadSlugs := make([]string, 0, len(downloads))
+	for _, p := range downloads {
+		if slug, ok := slugsById[p.Id]; ok {
+			downloadSlugs = append(downloadSlugs, slug)
+		}
+	}
+
+	return s.UpdateImageDownloadPermissions(ctx, tx, imageId, patchSlugs(downloadSlugs, add, remove))
+}
+
+// patchSlugs is a helper which returns the current slugs with the added slugs appended, if not already present,
+// and the removed slugs dropped.
+func patchSlugs(current, add, remove []string) []string {
+
+	patched := make([]string, 0, len(current)+len(add))
+	for _, slug := range slices.Concat(current, add) {
+		if !slices.Contains(remove, slug) && !slices.Contains(patched, slug) {
+			patched = append(patched, slug)
+		}
+	}
+
+	return patched
+}
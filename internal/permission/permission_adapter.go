@@ -1,10 +1,61 @@
 package permission
 
 import (
+	"context"
 	"database/sql"
+	"strings"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
 	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/pixie/internal/transaction"
+)
+
+// image permission queries shared by the repository methods run on their own and within a unit of work
+const (
+	findImagePermissionsQry = `
+		SELECT 
+			p.uuid,
+			p.service_name,
+			p.permission,
+			p.name,
+			p.description,
+			p.created_at,
+			p.active,
+			p.slug,
+			p.slug_index
+		FROM permission p
+			LEFT OUTER JOIN image_permission ip ON p.uuid = ip.permission_uuid
+		WHERE ip.image_uuid = ?
+			AND p.active = TRUE`
+
+	findImageDownloadPermissionsQry = `
+		SELECT 
+			p.uuid,
+			p.service_name,
+			p.permission,
+			p.name,
+			p.description,
+			p.created_at,
+			p.active,
+			p.slug,
+			p.slug_index
+		FROM permission p
+			LEFT OUTER JOIN image_permission ip ON p.uuid = ip.permission_uuid
+		WHERE ip.image_uuid = ?
+			AND ip.can_download = TRUE
+			AND p.active = TRUE`
+
+	insertImagePermissionXrefQry = `
+		INSERT INTO image_permission (
+			id, 
+			image_uuid, 
+			permission_uuid, 
+			created_at) 
+		VALUES (?, ?, ?, ?)`
+
+	deleteImagePermissionXrefQry = `
+		DELETE FROM image_permission 
+		WHERE image_uuid = ? AND permission_uuid = ?`
 )
 
 // Repository is an interface for data operations related to permissions.
@@ -19,6 +70,10 @@ type Repository interface {
 	// FindImagePermissions retrieves the active permission records associated with an image by its UUID.
 	FindImagePermissions(imageId string) ([]exo.PermissionRecord, error)
 
+	// FindImageDownloadPermissions retrieves the active permission records associated with an image by its UUID
+	// whose holders may download the original image file.
+	FindImageDownloadPermissions(imageId string) ([]exo.PermissionRecord, error)
+
 	// InsertPatronPermissionXref inserts a patron permission cross-reference record into the database.
 	InsertPatronPermissionXref(xref PatronPermissionXrefRecord) error
 
@@ -33,6 +88,55 @@ type Repository interface {
 
 	// DeleteImagePermissionXrefs deletes all image permission cross-reference records associated with an image from the database.
 	DeleteImagePermissionXrefs(imageId string) error
+
+	// FindImagePermissionsTx retrieves the active permission records associated with an image by its UUID
+	// within a unit of work, seeing its uncommitted changes.
+	FindImagePermissionsTx(ctx context.Context, tx transaction.Tx, imageId string) ([]exo.PermissionRecord, error)
+
+	// FindImageDownloadPermissionsTx retrieves the active permission records associated with an image by its UUID
+	// whose holders may download the original image file within a unit of work, seeing its uncommitted changes.
+	FindImageDownloadPermissionsTx(ctx context.Context, tx transaction.Tx, imageId string) ([]exo.PermissionRecord, error)
+
+	// InsertImagePermissionXrefTx inserts an image permission cross-reference record within a unit of work.
+	InsertImagePermissionXrefTx(ctx context.Context, tx transaction.Tx, xref ImagePermissionXref) error
+
+	// DeleteImagePermissionXrefTx deletes an image permission cross-reference record within a unit of work.
+	DeleteImagePermissionXrefTx(ctx context.Context, tx transaction.Tx, imageId, permissionId string) error
+
+	// UpdateImagePermissionDownloads sets the can_download flag on an image's permission xref records
+	// within a unit of work: true for the provided permission uuids, false for all others.
+	UpdateImagePermissionDownloads(ctx context.Context, tx transaction.Tx, imageId string, permissionIds []string) error
+
+	// FindAlbumPermissions retrieves the active permission records granted at the album level by the album's UUID.
+	FindAlbumPermissions(albumId string) ([]exo.PermissionRecord, error)
+
+	// AlbumInheritsPermissions checks if images added to the album inherit its permissions by the album's UUID.
+	AlbumInheritsPermissions(albumId string) (bool, error)
+
+	// FindInheritedAlbumPermissions retrieves the active permission records an image inherits when it is added to
+	// the albums with the given slug indexes.  Albums which do not pass their permissions on are ignored.
+	FindInheritedAlbumPermissions(albumSlugIndexes []string) ([]exo.PermissionRecord, error)
+
+	// FindAlbumImagePermissionXrefs retrieves the image permission cross-reference records of all images in an album.
+	FindAlbumImagePermissionXrefs(albumId string) ([]ImagePermissionXref, error)
+
+	// InsertAlbumPermissionXrefTx inserts an album permission cross-reference record within a unit of work.
+	InsertAlbumPermissionXrefTx(ctx context.Context, tx transaction.Tx, xref AlbumPermissionXref) error
+
+	// DeleteAlbumPermissionXrefTx deletes an album permission cross-reference record within a unit of work.
+	DeleteAlbumPermissionXrefTx(ctx context.Context, tx transaction.Tx, albumId, permissionId string) error
+
+	// UpdateAlbumInheritsPermissionsTx sets whether images added to the album inherit its permissions
+	// within a unit of work.
+	UpdateAlbumInheritsPermissionsTx(ctx context.Context, tx transaction.Tx, albumId string, inherit bool) error
+
+	// InsertImagePermissionXrefsTx grants the permission to each of the images in a single statement within
+	// a unit of work, skipping any images which already have it.
+	InsertImagePermissionXrefsTx(ctx context.Context, tx transaction.Tx, permissionId string, imageIds []string, createdAt data.CustomTime) error
+
+	// DeleteImagePermissionXrefsTx removes the permission from each of the images in a single statement
+	// within a unit of work.
+	DeleteImagePermissionXrefsTx(ctx context.Context, tx transaction.Tx, permissionId string, imageIds []string) error
 }
 
 // NewRepository creates a new Repository instance, returning a pointer to the concrete implementation.
@@ -95,23 +199,14 @@ func (r *repository) FindPatronPermissions(userIndex string) ([]exo.PermissionRe
 // FindImagePermissions retrieves the active permission records associated with an image by its UUID.
 func (r *repository) FindImagePermissions(imageId string) ([]exo.PermissionRecord, error) {
 
-	qry := `
-		SELECT 
-			p.uuid,
-			p.service_name,
-			p.permission,
-			p.name,
-			p.description,
-			p.created_at,
-			p.active,
-			p.slug,
-			p.slug_index
-		FROM permission p
-			LEFT OUTER JOIN image_permission ip ON p.uuid = ip.permission_uuid
-		WHERE ip.image_uuid = ?
-			AND p.active = TRUE`
+	return data.SelectRecords[exo.PermissionRecord](r.sql, findImagePermissionsQry, imageId)
+}
 
-	return data.SelectRecords[exo.PermissionRecord](r.sql, qry, imageId)
+// FindImageDownloadPermissions retrieves the active permission records associated with an image by its UUID
+// whose holders may download the original image file.
+func (r *repository) FindImageDownloadPermissions(imageId string) ([]exo.PermissionRecord, error) {
+
+	return data.SelectRecords[exo.PermissionRecord](r.sql, findImageDownloadPermissionsQry, imageId)
 }
 
 // InsertPatronPermissionXref inserts a patron permission cross-reference record into the database.
@@ -131,15 +226,7 @@ func (r *repository) InsertPatronPermissionXref(xref PatronPermissionXrefRecord)
 // InsertImagePermissionXref inserts an image permission cross-reference record into the database.
 func (r *repository) InsertImagePermissionXref(xref ImagePermissionXref) error {
 
-	qry := `
-		INSERT INTO image_permission (
-			id, 
-			image_uuid, 
-			permission_uuid, 
-			created_at) 
-		VALUES (?, ?, ?, ?)`
-
-	return data.InsertRecord(r.sql, qry, xref)
+	return data.InsertRecord(r.sql, insertImagePermissionXrefQry, xref)
 }
 
 // DeletePatronPermissionXref deletes a patron permission cross-reference record from the database.
@@ -155,11 +242,7 @@ func (r *repository) DeletePatronPermissionXref(patronId, permissionId string) e
 // DeleteImagePermissionXref deletes an image permission cross-reference record from the database.
 func (r *repository) DeleteImagePermissionXref(imageId, permissionId string) error {
 
-	qry := `
-		DELETE FROM image_permission 
-		WHERE image_uuid = ? AND permission_uuid = ?`
-
-	return data.DeleteRecord(r.sql, qry, imageId, permissionId)
+	return data.DeleteRecord(r.sql, deleteImagePermissionXrefQry, imageId, permissionId)
 }
 
 // DeleteImagePermissionXrefs deletes all image permission cross-reference records associated with an image from the database.
@@ -171,3 +254,262 @@ func (r *repository) DeleteImagePermissionXrefs(imageId string) error {
 
 	return data.DeleteRecord(r.sql, qry, imageId)
 }
+
+// UpdateImagePermissionDownloads sets the can_download flag on an image's permission xref records
+// within a unit of work: true for the provided permission uuids, false for all others.
+func (r *repository) UpdateImagePermissionDownloads(ctx context.Context, tx transaction.Tx, imageId string, permissionIds []string) error {
+
+	// no download permissions -> all of the image's permissions are view-only
+	if len(permissionIds) == 0 {
+		qry := `
+			UPDATE image_permission 
+			SET can_download = FALSE
+			WHERE image_uuid = ?`
+
+		return transaction.UpdateRecord(ctx, tx, qry, imageId)
+	}
+
+	// build the (?, ?, ?) list for the IN clause
+	var qb strings.Builder
+	qb.WriteString(`
+		UPDATE image_permission 
+		SET can_download = permission_uuid IN (`)
+	for i := range permissionIds {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+	}
+	qb.WriteString(`)
+		WHERE image_uuid = ?`)
+
+	args := make([]interface{}, 0, len(permissionIds)+1)
+	for _, id := range permissionIds {
+		args = append(args, id)
+	}
+	args = append(args, imageId) // where clause
+
+	return transaction.UpdateRecord(ctx, tx, qb.String(), args...)
+}
+
+// FindImagePermissionsTx retrieves the active permission records associated with an image by its UUID
+// within a unit of work, seeing its uncommitted changes.
+func (r *repository) FindImagePermissionsTx(ctx context.Context, tx transaction.Tx, imageId string) ([]exo.PermissionRecord, error) {
+
+	return transaction.SelectRecords[exo.PermissionRecord](ctx, tx, findImagePermissionsQry, imageId)
+}
+
+// FindImageDownloadPermissionsTx retrieves the active permission records associated with an image by its UUID
+// whose holders may download the original image file within a unit of work, seeing its uncommitted changes.
+func (r *repository) FindImageDownloadPermissionsTx(ctx context.Context, tx transaction.Tx, imageId string) ([]exo.PermissionRecord, error) {
+
+	return transaction.SelectRecords[exo.PermissionRecord](ctx, tx, findImageDownloadPermissionsQry, imageId)
+}
+
+// InsertImagePermissionXrefTx inserts an image permission cross-reference record within a unit of work.
+func (r *repository) InsertImagePermissionXrefTx(ctx context.Context, tx transaction.Tx, xref ImagePermissionXref) error {
+
+	return transaction.InsertRecord(ctx, tx, insertImagePermissionXrefQry, xref)
+}
+
+// DeleteImagePermissionXrefTx deletes an image permission cross-reference record within a unit of work.
+func (r *repository) DeleteImagePermissionXrefTx(ctx context.Context, tx transaction.Tx, imageId, permissionId string) error {
+
+	return transaction.DeleteRecord(ctx, tx, deleteImagePermissionXrefQry, imageId, permissionId)
+}
+
+// FindAlbumPermissions retrieves the active permission records granted at the album level by the album's UUID.
+func (r *repository) FindAlbumPermissions(albumId string) ([]exo.PermissionRecord, error) {
+
+	qry := `
+		SELECT 
+			p.uuid,
+			p.service_name,
+			p.permission,
+			p.name,
+			p.description,
+			p.created_at,
+			p.active,
+			p.slug,
+			p.slug_index
+		FROM permission p
+			INNER JOIN album_permission ap ON p.uuid = ap.permission_uuid
+		WHERE ap.album_uuid = ?
+			AND p.active = TRUE`
+
+	return data.SelectRecords[exo.PermissionRecord](r.sql, qry, albumId)
+}
+
+// AlbumInheritsPermissions checks if images added to the album inherit its permissions by the album's UUID.
+func (r *repository) AlbumInheritsPermissions(albumId string) (bool, error) {
+
+	qry := `
+		SELECT EXISTS (
+			SELECT 1
+			FROM album
+			WHERE uuid = ?
+				AND inherit_permissions = TRUE
+		)`
+
+	return data.SelectExists(r.sql, qry, albumId)
+}
+
+// FindInheritedAlbumPermissions retrieves the active permission records an image inherits when it is added to
+// the albums with the given slug indexes.  Albums which do not pass their permissions on are ignored.
+func (r *repository) FindInheritedAlbumPermissions(albumSlugIndexes []string) ([]exo.PermissionRecord, error) {
+
+	if len(albumSlugIndexes) == 0 {
+		return nil, nil
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+		SELECT DISTINCT
+			p.uuid,
+			p.service_name,
+			p.permission,
+			p.name,
+			p.description,
+			p.created_at,
+			p.active,
+			p.slug,
+			p.slug_index
+		FROM permission p
+			INNER JOIN album_permission ap ON p.uuid = ap.permission_uuid
+			INNER JOIN album a ON ap.album_uuid = a.uuid
+		WHERE a.inherit_permissions = TRUE
+			AND p.active = TRUE
+			AND a.slug_index IN (`)
+	for i := range albumSlugIndexes {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+	}
+	qb.WriteString(")")
+
+	args := make([]interface{}, 0, len(albumSlugIndexes))
+	for _, index := range albumSlugIndexes {
+		args = append(args, index)
+	}
+
+	return data.SelectRecords[exo.PermissionRecord](r.sql, qb.String(), args...)
+}
+
+// FindAlbumImagePermissionXrefs retrieves the image permission cross-reference records of all images in an album.
+func (r *repository) FindAlbumImagePermissionXrefs(albumId string) ([]ImagePermissionXref, error) {
+
+	qry := `
+		SELECT 
+			ip.id,
+			ip.image_uuid,
+			ip.permission_uuid,
+			ip.created_at
+		FROM image_permission ip
+			INNER JOIN album_image ai ON ip.image_uuid = ai.image_uuid
+		WHERE ai.album_uuid = ?`
+
+	return data.SelectRecords[ImagePermissionXref](r.sql, qry, albumId)
+}
+
+// InsertAlbumPermissionXrefTx inserts an album permission cross-reference record within a unit of work.
+func (r *repository) InsertAlbumPermissionXrefTx(ctx context.Context, tx transaction.Tx, xref AlbumPermissionXref) error {
+
+	qry := `
+		INSERT INTO album_permission (
+			id, 
+			album_uuid, 
+			permission_uuid, 
+			created_at) 
+		VALUES (?, ?, ?, ?)`
+
+	return transaction.InsertRecord(ctx, tx, qry, xref)
+}
+
+// DeleteAlbumPermissionXrefTx deletes an album permission cross-reference record within a unit of work.
+func (r *repository) DeleteAlbumPermissionXrefTx(ctx context.Context, tx transaction.Tx, albumId, permissionId string) error {
+
+	qry := `
+		DELETE FROM album_permission 
+		WHERE album_uuid = ? AND permission_uuid = ?`
+
+	return transaction.DeleteRecord(ctx, tx, qry, albumId, permissionId)
+}
+
+// UpdateAlbumInheritsPermissionsTx sets whether images added to the album inherit its permissions
+// within a unit of work.
+func (r *repository) UpdateAlbumInheritsPermissionsTx(ctx context.Context, tx transaction.Tx, albumId string, inherit bool) error {
+
+	qry := `
+		UPDATE album 
+		SET inherit_permissions = ?
+		WHERE uuid = ?`
+
+	return transaction.UpdateRecord(ctx, tx, qry, inherit, albumId)
+}
+
+// InsertImagePermissionXrefsTx grants the permission to each of the images in a single statement within
+// a unit of work, skipping any images which already have it.
+func (r *repository) InsertImagePermissionXrefsTx(
+	ctx context.Context,
+	tx transaction.Tx,
+	permissionId string,
+	imageIds []string,
+	createdAt data.CustomTime,
+) error {
+
+	if len(imageIds) == 0 {
+		return nil
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+		INSERT INTO image_permission (
+			image_uuid,
+			permission_uuid,
+			created_at
+		) VALUES `)
+
+	args := make([]interface{}, 0, len(imageIds)*3)
+	for i, id := range imageIds {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("(?, ?, ?)")
+		args = append(args, id, permissionId, createdAt)
+	}
+
+	// the pair is unique, so images which already have the permission keep it as it is, including can_download
+	qb.WriteString(`
+		ON DUPLICATE KEY UPDATE id = id`)
+
+	return transaction.UpdateRecord(ctx, tx, qb.String(), args...)
+}
+
+// DeleteImagePermissionXrefsTx removes the permission from each of the images in a single statement
+// within a unit of work.
+func (r *repository) DeleteImagePermissionXrefsTx(ctx context.Context, tx transaction.Tx, permissionId string, imageIds []string) error {
+
+	if len(imageIds) == 0 {
+		return nil
+	}
+
+	var qb strings.Builder
+	qb.WriteString(`
+		DELETE FROM image_permission
+		WHERE permission_uuid = ?
+			AND image_uuid IN (`)
+
+	args := make([]interface{}, 0, len(imageIds)+1)
+	args = append(args, permissionId)
+	for i, id := range imageIds {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString("?")
+		args = append(args, id)
+	}
+	qb.WriteString(")")
+
+	return transaction.DeleteRecord(ctx, tx, qb.String(), args...)
+}
@@ -16,6 +16,7 @@ type Service interface {
 	permissions.Service
 	PatronPermissionService
 	ImagePermissionService
+	AlbumPermissionService
 }
 
 // NewService creates a new service instance, returning a pointer to the concrete implementation.
@@ -23,12 +24,14 @@ func NewService(
 	ps permissions.Service,
 	pps PatronPermissionService,
 	ips ImagePermissionService,
+	aps AlbumPermissionService,
 ) Service {
 
 	return &service{
 		Service:                 ps,
 		PatronPermissionService: pps,
 		ImagePermissionService:  ips,
+		AlbumPermissionService:  aps,
 	}
 }
 
@@ -40,6 +43,7 @@ type service struct {
 	permissions.Service
 	PatronPermissionService
 	ImagePermissionService
+	AlbumPermissionService
 }
 
 // PatronPermissionXrefRecord is a model which represents a patron permission cross-reference record in the database.
@@ -80,3 +84,11 @@ type ImagePermissionXref struct {
 	PermissionId string          `db:"permission_uuid" json:"permission_id"` // UUID of the permission
 	CreatedAt    data.CustomTime `db:"created_at" json:"created_at"`         // Timestamp when the xref was created
 }
+
+// AlbumPermissionXref is a model which represents an album_permission xref record in the database.
+type AlbumPermissionXref struct {
+	Id           int             `db:"id" json:"id"`                         // Unique identifier for the xref record
+	AlbumId      string          `db:"album_uuid" json:"album_id"`           // UUID of the album
+	PermissionId string          `db:"permission_uuid" json:"permission_id"` // UUID of the permission
+	CreatedAt    data.CustomTime `db:"created_at" json:"created_at"`         // Timestamp when the xref was created
+}
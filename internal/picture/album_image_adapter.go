@@ -1,9 +1,11 @@
 package picture
 
 import (
+	"context"
 	"database/sql"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
 
@@ -19,11 +21,11 @@ type AlbumImageRepository interface {
 	// InsertImagePermissionXref inserts an image permission cross-reference record into the database.
 	InsertImagePermissionXref(xref ImagePermissionXref) error
 
-	// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database.
-	InsertAlbumImageXref(xref api.AlbumImageXref) error
+	// InsertAlbumImageXref inserts a xref record into the ablum_image table within a unit of work.
+	InsertAlbumImageXref(ctx context.Context, tx transaction.Tx, xref api.AlbumImageXref) error
 
-	// DeleteAlbumImageXref deletes an album-image xref record from the database.
-	DeleteAlbumImageXref(albumId, imageId string) error
+	// DeleteAlbumImageXref deletes an album-image xref record within a unit of work.
+	DeleteAlbumImageXref(ctx context.Context, tx transaction.Tx, albumId, imageId string) error
 
 	// DeleteAllAlbumImageXrefs deletes all album-image xref records associated with an image uuid, effectively removing all albums from the image.
 	DeleteAllAlbumImageXrefs(imageId string) error
@@ -55,7 +57,10 @@ func (r *albumImageRepository) FindAllAlbums() ([]api.AlbumRecord, error) {
 			slug_index, 
 			created_at, 
 			updated_at, 
-			is_archived 
+			is_archived,
+			cover_image_uuid,
+			parent_uuid,
+			version
 		FROM album`
 
 	return data.SelectRecords[api.AlbumRecord](r.sql, qry)
@@ -74,7 +79,10 @@ func (r *albumImageRepository) FindAlbumByImgId(imageId string) ([]api.AlbumReco
 			a.slug_index,
 			a.created_at,
 			a.updated_at,
-			a.is_archived
+			a.is_archived,
+			a.cover_image_uuid,
+			a.parent_uuid,
+			a.version
 		FROM album a
 			LEFT OUTER JOIN album_image ai ON a.uuid = ai.album_uuid
 		WHERE ai.image_uuid = ?`
@@ -82,7 +90,8 @@ func (r *albumImageRepository) FindAlbumByImgId(imageId string) ([]api.AlbumReco
 	return data.SelectRecords[api.AlbumRecord](r.sql, qry, imageId)
 }
 
-// InsertImagePermissionXref inserts an image permission cross-reference record into the database.
+// InsertImagePermissionXref inserts an image permission cross-reference record into the database,
+// skipping it if the image already has the permission, eg, inherited from an album it was added to.
 func (r *albumImageRepository) InsertImagePermissionXref(xref ImagePermissionXref) error {
 
 	qry := `
@@ -91,13 +100,14 @@ func (r *albumImageRepository) InsertImagePermissionXref(xref ImagePermissionXre
 			image_uuid, 
 			permission_uuid, 
 			created_at) 
-		VALUES (?, ?, ?, ?)`
+		VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE id = id`
 
 	return data.InsertRecord(r.sql, qry, xref)
 }
 
-// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database.
-func (a *albumImageRepository) InsertAlbumImageXref(xref api.AlbumImageXref) error {
+// InsertAlbumImageXref inserts a xref record into the ablum_image table within a unit of work.
+func (a *albumImageRepository) InsertAlbumImageXref(ctx context.Context, tx transaction.Tx, xref api.AlbumImageXref) error {
 
 	qry := `
 		INSERT INTO album_image (
@@ -107,17 +117,17 @@ func (a *albumImageRepository) InsertAlbumImageXref(xref api.AlbumImageXref) err
 			created_at
 		) VALUES (?, ?, ?, ?)`
 
-	return data.InsertRecord(a.sql, qry, xref)
+	return transaction.InsertRecord(ctx, tx, qry, xref)
 }
 
-// DeleteAlbumImageXref deletes an album-image xref record from the database.
-func (a *albumImageRepository) DeleteAlbumImageXref(albumId, imageId string) error {
+// DeleteAlbumImageXref deletes an album-image xref record within a unit of work.
+func (a *albumImageRepository) DeleteAlbumImageXref(ctx context.Context, tx transaction.Tx, albumId, imageId string) error {
 
 	qry := `
 		DELETE FROM album_image 
 		WHERE album_uuid = ? AND image_uuid = ?`
 
-	return data.DeleteRecord(a.sql, qry, albumId, imageId)
+	return transaction.DeleteRecord(ctx, tx, qry, albumId, imageId)
 }
 
 // DeleteAllAlbumImageXrefs deletes all album-image xref records associated with an image uuid, effectively removing all albums from the image.
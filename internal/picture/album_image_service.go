@@ -14,6 +14,7 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/data"
 	"github.com/tdeslauriers/carapace/pkg/validate"
 	"github.com/tdeslauriers/pixie/internal/crypt"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
@@ -32,8 +33,13 @@ type AlbumImageService interface {
 
 	// UpdateAlbumImages updates the albums associated with an image.
 	// It adds new associations and removes old ones.
+	// The changes are written within the unit of work's transaction.
 	// Returns an error if any operation fails.
-	UpdateAlbumImages(ctx context.Context, imageId string, albumSlugs []string) error
+	UpdateAlbumImages(ctx context.Context, tx transaction.Tx, imageId string, albumSlugs []string) error
+
+	// PatchAlbumImages adds an image to, and removes it from, the given albums, leaving its other albums as they are.
+	// The changes are written within the unit of work's transaction.
+	PatchAlbumImages(ctx context.Context, tx transaction.Tx, imageId string, add, remove []string) error
 
 	// DeleteAlbumImages deletes all album-image xref records associated with an image uuid,
 	// effectively removing all albums from the image.
@@ -159,8 +165,9 @@ func (s *albumImageService) InsertImagePermissionXref(imageId, permissionId stri
 
 // UpdateAlbumImages updates the albums associated with an image.
 // It adds new associations and removes old ones.
+// The changes are written within the unit of work's transaction.
 // Returns an error if any operation fails.
-func (s *albumImageService) UpdateAlbumImages(ctx context.Context, imageId string, albumSlugs []string) error {
+func (s *albumImageService) UpdateAlbumImages(ctx context.Context, tx transaction.Tx, imageId string, albumSlugs []string) error {
 
 	// create function scoped logger
 	// add telemetry fields from context if exists
@@ -189,46 +196,10 @@ func (s *albumImageService) UpdateAlbumImages(ctx context.Context, imageId strin
 		}
 	}
 
-	// get all albums from db to validate the cmd album slugs exist
-	allAlbums, err := s.sql.FindAllAlbums()
+	// get all albums to validate the cmd album slugs exist
+	allAlbumsMap, err := s.findAllAlbumsBySlug()
 	if err != nil {
-		return fmt.Errorf("failed to retrieve all albums for validation: %v", err)
-	}
-
-	// decrypt and build a map of all albums
-	var (
-		wg    sync.WaitGroup
-		errCh = make(chan error, len(allAlbums))
-	)
-	allAlbumsMap := make(map[string]api.AlbumRecord, len(allAlbums))
-	mu := &sync.Mutex{}
-
-	for i := range allAlbums {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			if err := s.cryptor.DecryptAlbumRecord(&allAlbums[i]); err != nil {
-				errCh <- fmt.Errorf("failed to decrypt album record: %v", err)
-				return
-			}
-			mu.Lock()
-			allAlbumsMap[allAlbums[i].Slug] = allAlbums[i]
-			mu.Unlock()
-		}(i)
-	}
-
-	wg.Wait()
-	close(errCh)
-
-	// log errors if any
-	if len(errCh) > 0 {
-		var errs []error
-		for e := range errCh {
-			errs = append(errs, e)
-		}
-		if len(errs) > 0 {
-			return fmt.Errorf("failed to decrypt some album records: %v", errors.Join(errs...))
-		}
+		return err
 	}
 
 	// build a map of the new album slugs for easy lookup
@@ -274,68 +245,163 @@ func (s *albumImageService) UpdateAlbumImages(ctx context.Context, imageId strin
 	if len(toAdd) > 0 || len(toRemove) > 0 {
 		log.Info(fmt.Sprintf("updating albums for image '%s': %d to add, %d to remove", imageId, len(toAdd), len(toRemove)))
 
-		// perform the additions and removals in parallel
-		var (
-			xrefWg    sync.WaitGroup
-			xrefErrCh = make(chan error, len(toAdd)+len(toRemove))
-		)
-
+		// statements within a transaction run one at a time on its connection, so they are not fanned out
 		for _, a := range toAdd {
-			xrefWg.Add(1)
-			go func(albumId string) {
-				defer xrefWg.Done()
-
-				xref := api.AlbumImageXref{
-					Id:        0, // auto-incremented by the database
-					AlbumId:   albumId,
-					ImageId:   imageId,
-					CreatedAt: data.CustomTime{Time: time.Now().UTC()},
-				}
-				if err := s.sql.InsertAlbumImageXref(xref); err != nil {
-					xrefErrCh <- fmt.Errorf("failed to add album '%s' to image '%s': %v", albumId, imageId, err)
-					return
-				}
-
-				log.Info(fmt.Sprintf("added album '%s' to image '%s'", albumId, imageId))
-			}(a.Id)
+
+			xref := api.AlbumImageXref{
+				Id:        0, // auto-incremented by the database
+				AlbumId:   a.Id,
+				ImageId:   imageId,
+				CreatedAt: data.CustomTime{Time: time.Now().UTC()},
+			}
+			if err := s.sql.InsertAlbumImageXref(ctx, tx, xref); err != nil {
+				return fmt.Errorf("failed to add album '%s' to image '%s': %v", a.Id, imageId, err)
+			}
+
+			log.Info(fmt.Sprintf("added album '%s' to image '%s'", a.Id, imageId))
 		}
 
 		for _, a := range toRemove {
-			xrefWg.Add(1)
-			go func(albumId string) {
-				defer xrefWg.Done()
 
-				if err := s.sql.DeleteAlbumImageXref(albumId, imageId); err != nil {
-					xrefErrCh <- fmt.Errorf("failed to remove album '%s' from image '%s': %v", albumId, imageId, err)
-					return
-				}
+			if err := s.sql.DeleteAlbumImageXref(ctx, tx, a.Id, imageId); err != nil {
+				return fmt.Errorf("failed to remove album '%s' from image '%s': %v", a.Id, imageId, err)
+			}
+
+			log.Info(fmt.Sprintf("removed album '%s' from image '%s'", a.Id, imageId))
+		}
+
+		log.Info(fmt.Sprintf("successfully updated albums for image '%s'", imageId))
+	} else {
+		log.Info(fmt.Sprintf("no album changes detected for image '%s', nothing to update", imageId))
+	}
+
+	return nil
+}
+
+// PatchAlbumImages adds an image to, and removes it from, the given albums, leaving its other albums as they are.
+// Adding an image to an album it is already in, or removing it from one it is not in, is a no-op.
+// The changes are written within the unit of work's transaction.
+func (s *albumImageService) PatchAlbumImages(ctx context.Context, tx transaction.Tx, imageId string, add, remove []string) error {
+
+	// create function scoped logger
+	// add telemetry fields from context if exists
+	log := s.logger
+	if tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry); ok && tel != nil {
+		log = log.With(tel.TelemetryFields()...)
+	} else {
+		log.Warn("no telemetry found in context for PatchAlbumImages")
+	}
+
+	// validate the image id
+	if err := validate.ValidateUuid(imageId); err != nil {
+		return fmt.Errorf("image Id must be a valid UUID")
+	}
+
+	if len(add) == 0 && len(remove) == 0 {
+		return nil
+	}
+
+	// get the current albums associated with the image
+	current, _, err := s.GetImageAlbums(imageId)
+	if err != nil && !strings.Contains(err.Error(), "no albums found for image") {
+		return fmt.Errorf("failed to get current albums for image '%s': %v", imageId, err)
+	}
+
+	if len(add) > 0 {
 
-				log.Info(fmt.Sprintf("removed album '%s' from image '%s'", albumId, imageId))
-			}(a.Id)
+		// get all albums to validate the added album slugs exist
+		allAlbumsMap, err := s.findAllAlbumsBySlug()
+		if err != nil {
+			return err
 		}
 
-		xrefWg.Wait()
-		close(xrefErrCh)
+		for _, slug := range add {
+			a, exists := allAlbumsMap[slug]
+			if !exists {
+				return fmt.Errorf("album with slug '%s' does not exist", slug)
+			}
+
+			if _, ok := current[slug]; ok {
+				continue
+			}
 
-		// check for errors during xref updates
-		if len(xrefErrCh) > 0 {
-			var errs []error
-			for e := range xrefErrCh {
-				errs = append(errs, e)
+			xref := api.AlbumImageXref{
+				Id:        0, // auto-incremented by the database
+				AlbumId:   a.Id,
+				ImageId:   imageId,
+				CreatedAt: data.CustomTime{Time: time.Now().UTC()},
 			}
-			if len(errs) > 0 {
-				return fmt.Errorf("failed to update some album_image xref records: %v", errors.Join(errs...))
+			if err := s.sql.InsertAlbumImageXref(ctx, tx, xref); err != nil {
+				return fmt.Errorf("failed to add album '%s' to image '%s': %v", a.Id, imageId, err)
 			}
+
+			log.Info(fmt.Sprintf("added album '%s' to image '%s'", a.Id, imageId))
 		}
+	}
 
-		log.Info(fmt.Sprintf("successfully updated albums for image '%s'", imageId))
-	} else {
-		log.Info(fmt.Sprintf("no album changes detected for image '%s', nothing to update", imageId))
+	for _, slug := range remove {
+		a, ok := current[slug]
+		if !ok {
+			continue
+		}
+
+		if err := s.sql.DeleteAlbumImageXref(ctx, tx, a.Id, imageId); err != nil {
+			return fmt.Errorf("failed to remove album '%s' from image '%s': %v", a.Id, imageId, err)
+		}
+
+		log.Info(fmt.Sprintf("removed album '%s' from image '%s'", a.Id, imageId))
 	}
 
 	return nil
 }
 
+// findAllAlbumsBySlug is a helper which retrieves and decrypts all albums, returning them mapped by slug.
+func (s *albumImageService) findAllAlbumsBySlug() (map[string]api.AlbumRecord, error) {
+
+	allAlbums, err := s.sql.FindAllAlbums()
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve all albums for validation: %v", err)
+	}
+
+	// decrypt and build a map of all albums
+	var (
+		wg    sync.WaitGroup
+		errCh = make(chan error, len(allAlbums))
+	)
+	allAlbumsMap := make(map[string]api.AlbumRecord, len(allAlbums))
+	mu := &sync.Mutex{}
+
+	for i := range allAlbums {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := s.cryptor.DecryptAlbumRecord(&allAlbums[i]); err != nil {
+				errCh <- fmt.Errorf("failed to decrypt album record: %v", err)
+				return
+			}
+			mu.Lock()
+			allAlbumsMap[allAlbums[i].Slug] = allAlbums[i]
+			mu.Unlock()
+		}(i)
+	}
+
+	wg.Wait()
+	close(errCh)
+
+	// log errors if any
+	if len(errCh) > 0 {
+		var errs []error
+		for e := range errCh {
+			errs = append(errs, e)
+		}
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to decrypt some album records: %v", errors.Join(errs...))
+		}
+	}
+
+	return allAlbumsMap, nil
+}
+
 // DeleteAlbumImages deletes all album-image xref records associated with an image uuid,
 // effectively removing all albums from the image.
 func (s *albumImageService) DeleteAlbumImages(imageId string) error {
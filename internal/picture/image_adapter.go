@@ -1,10 +1,13 @@
 package picture
 
 import (
+	"context"
 	"database/sql"
+	"strings"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
 	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
@@ -35,13 +38,25 @@ type Repository interface {
 		userPs map[string]exo.PermissionRecord,
 	) (*api.ImageRecord, error)
 
-	// InsertImage inserts a new image metadata record into the database.
+	// ImageDownloadable checks if at least one of the user's permissions is marked as
+	// allowed to download the untouched original for the image with the given slug index.
+	ImageDownloadable(
+		slugIndex string,
+		userPs map[string]exo.PermissionRecord,
+	) (bool, error)
+
+	// InsertImage inserts a new image metadata record into the database, along with the upload session
+	// it was created in, if any.
 	// Note: fields must be encrypted prior to calling this function.
-	InsertImage(record api.ImageRecord) error
+	InsertImage(record api.ImageRecord, uploadSessionId string) error
 
-	// UpdateImage updates an existing image metadata record in the database.
+	// UpdateImage updates an existing image metadata record within a unit of work.
 	// Note: fields must be encrypted prior to calling this function.
-	UpdateImage(record api.ImageRecord) error
+	UpdateImage(ctx context.Context, tx transaction.Tx, record api.ImageRecord) error
+
+	// IncrementImageVersion increments an image's version within a unit of work, if it is still at the given version.
+	// Returns false if the version is stale, ie, no record was updated.
+	IncrementImageVersion(ctx context.Context, tx transaction.Tx, imageId string, version int) (bool, error)
 
 	// DeleteImage deletes an image metadata record from the database.
 	DeleteImage(slugIndex string) error
@@ -159,9 +174,50 @@ func (r *repository) FindImageByPermissions(
 	return &record, nil
 }
 
-// InsertImage inserts a new image metadata record into the database.
+// ImageDownloadable checks if at least one of the user's permissions is marked as
+// allowed to download the untouched original for the image with the given slug index.
+func (r *repository) ImageDownloadable(
+	slugIndex string,
+	userPs map[string]exo.PermissionRecord,
+) (bool, error) {
+
+	// no permissions means nothing could be marked downloadable
+	if len(userPs) == 0 {
+		return false, nil
+	}
+
+	// makes a (?, ?, ?) list for the IN clause the length of the user's permissions
+	var qb strings.Builder
+	qb.WriteString(`
+		SELECT EXISTS (
+			SELECT 1
+			FROM image i
+				LEFT OUTER JOIN image_permission ip ON i.uuid = ip.image_uuid
+			WHERE i.slug_index = ?
+				AND ip.can_download = TRUE
+				AND ip.permission_uuid IN (`)
+	for i := 0; i < len(userPs); i++ {
+		if i > 0 {
+			qb.WriteString(", ")
+		}
+		qb.WriteString(`?`)
+	}
+	qb.WriteString("))")
+
+	// create the []args ...interface{} slice
+	args := make([]interface{}, 0, len(userPs)+1)
+	args = append(args, slugIndex)
+	for _, p := range userPs {
+		args = append(args, p.Id)
+	}
+
+	return data.SelectExists(r.sql, qb.String(), args...)
+}
+
+// InsertImage inserts a new image metadata record into the database, along with the upload session
+// it was created in, if any.
 // Note: fields must be encrypted prior to calling this function.
-func (r *repository) InsertImage(record api.ImageRecord) error {
+func (r *repository) InsertImage(record api.ImageRecord, uploadSessionId string) error {
 
 	qry := `
 		INSERT INTO image (
@@ -180,15 +236,47 @@ func (r *repository) InsertImage(record api.ImageRecord) error {
 			created_at,
 			updated_at,
 			is_archived,
-			is_published
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	return data.InsertRecord(r.sql, qry, record)
+			is_published,
+			blur_hash,
+			dominant_color,
+			image_date_key,
+			image_date_precision,
+			image_date_end_year,
+			version,
+			upload_session_uuid
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	// the upload session is not a field of the image record, so the fields are passed explicitly
+	return data.UpdateRecord(r.sql, qry,
+		record.Id,
+		record.Title,
+		record.Description,
+		record.FileName,
+		record.FileType,
+		record.ObjectKey,
+		record.Slug,
+		record.SlugIndex,
+		record.Width,
+		record.Height,
+		record.Size,
+		record.ImageDate,
+		record.CreatedAt,
+		record.UpdatedAt,
+		record.IsArchived,
+		record.IsPublished,
+		record.BlurHash,
+		record.DominantColor,
+		record.ImageDateKey,
+		record.ImageDatePrecision,
+		record.ImageDateEndYear,
+		record.Version,
+		uploadSessionId,
+	)
 }
 
-// UpdateImage updates an existing image metadata record in the database.
+// UpdateImage updates an existing image metadata record within a unit of work.
 // Note: fields must be encrypted prior to calling this function.
-func (r *repository) UpdateImage(record api.ImageRecord) error {
+func (r *repository) UpdateImage(ctx context.Context, tx transaction.Tx, record api.ImageRecord) error {
 
 	qry := `
 		UPDATE image SET
@@ -196,25 +284,53 @@ func (r *repository) UpdateImage(record api.ImageRecord) error {
 			description = ?,
 			object_key = ?,
 			image_date = ?,
+			image_date_key = ?,
 			updated_at = ?,
 			is_archived = ?,
 			is_published = ?
 		WHERE slug_index = ?`
 
-	return data.UpdateRecord(
-		r.sql,
+	return transaction.UpdateRecord(
+		ctx,
+		tx,
 		qry,
-		record.Title,       // update
-		record.Description, // update
-		record.ObjectKey,   // update
-		record.ImageDate,   // update
-		record.UpdatedAt,   // update
-		record.IsArchived,  // update
-		record.IsPublished, // update
-		record.SlugIndex,   // where clause
+		record.Title,        // update
+		record.Description,  // update
+		record.ObjectKey,    // update
+		record.ImageDate,    // update
+		record.ImageDateKey, // update
+		record.UpdatedAt,    // update
+		record.IsArchived,   // update
+		record.IsPublished,  // update
+		record.SlugIndex,    // where clause
 	)
 }
 
+// IncrementImageVersion increments an image's version within a unit of work, if it is still at the given version.
+// Returns false if the version is stale, ie, no record was updated.
+// Note: this also locks the image row until the unit of work commits, so concurrent edits are serialized.
+func (r *repository) IncrementImageVersion(ctx context.Context, tx transaction.Tx, imageId string, version int) (bool, error) {
+
+	qry := `
+		UPDATE image SET
+			version = version + 1
+		WHERE uuid = ?
+			AND version = ?`
+
+	// transaction.UpdateRecord does not return the result, which is needed to detect a stale version
+	result, err := tx.ExecContext(ctx, qry, imageId, version)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
 // DeleteImage deletes an image metadata record from the database.
 func (r *repository) DeleteImage(slugIndex string) error {
 
@@ -0,0 +1,195 @@
+package picture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	exo "github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// bulkImageBatchSize is the number of images a bulk operation changes concurrently:
+// each image is its own unit of work, so this bounds the database connections a single request holds.
+const bulkImageBatchSize int = 8
+
+// HandleBulk is the concrete implementation of the interface method which applies a set of operations,
+// eg, publish and add to an album, to each of a list of images.  Each image is changed as its own
+// unit of work, so one image failing does not undo the others: the response reports a result per image.
+func (h *imageHandler) HandleBulk(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	if r.Method != http.MethodPost {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(writeImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(writeImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get the bulk command from the request body
+	var cmd api.BulkImageCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode request body", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode request body",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the bulk command
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate bulk image command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get user permissions: once for the whole request rather than once per image
+	usrPsMap, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to get user permissions", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to get user permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// apply the operations to the images in batches, preserving the order of the command's slugs
+	results := make([]api.BulkImageResult, len(cmd.ImageSlugs))
+	for start := 0; start < len(cmd.ImageSlugs); start += bulkImageBatchSize {
+
+		// stop starting new batches if the client has gone away: the remaining images are reported as not changed
+		if ctx.Err() != nil {
+			log.Warn(fmt.Sprintf("bulk image operation cancelled after %d of %d images", start, len(cmd.ImageSlugs)))
+			for i := start; i < len(cmd.ImageSlugs); i++ {
+				results[i] = api.BulkImageResult{
+					Slug:       cmd.ImageSlugs[i],
+					StatusCode: http.StatusServiceUnavailable,
+					Message:    "bulk operation was cancelled before the image was changed",
+				}
+			}
+			break
+		}
+
+		end := min(start+bulkImageBatchSize, len(cmd.ImageSlugs))
+
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = h.applyBulkImageOperations(ctx, log, cmd.ImageSlugs[i], &cmd.Operations, usrPsMap)
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	resp := api.BulkImageResponse{Results: results}
+	for _, result := range results {
+		if result.StatusCode == http.StatusNoContent {
+			resp.Succeeded++
+		} else {
+			resp.Failed++
+		}
+	}
+
+	// audit log
+	log.Info("successfully processed bulk image operation",
+		"image_count", len(cmd.ImageSlugs),
+		"succeeded", resp.Succeeded,
+		"failed", resp.Failed,
+		"added_albums", cmd.Operations.AddAlbumSlugs,
+		"removed_albums", cmd.Operations.RemoveAlbumSlugs,
+		"added_permissions", cmd.Operations.AddPermissionSlugs,
+		"removed_permissions", cmd.Operations.RemovePermissionSlugs,
+	)
+
+	// the request itself succeeded even if some, or all, images failed: see the results
+	connect.SendJsonSuccess(w, http.StatusOK, resp)
+}
+
+// applyBulkImageOperations is a helper which applies a bulk command's operations to a single image,
+// the same as a patch of that image, and reports the outcome.  It does not return an error:
+// failures are reported in the result so the other images' operations can go ahead.
+func (h *imageHandler) applyBulkImageOperations(
+	ctx context.Context,
+	log *slog.Logger,
+	slug string,
+	ops *api.BulkImageOperations,
+	usrPsMap map[string]exo.PermissionRecord,
+) api.BulkImageResult {
+
+	log = log.With("image_slug", slug)
+
+	existing, err := h.svc.GetImageData(ctx, slug, usrPsMap)
+	if err != nil {
+		log.Error("failed to get existing image data for bulk operation", "err", err.Error())
+		return api.BulkImageResult{Slug: slug, StatusCode: imageServiceErrorStatus(err), Message: err.Error()}
+	}
+	log = log.With("image_id", existing.Id)
+
+	// each image gets its own copy of the patch:
+	// the permissions inherited from added albums differ image to image
+	patch := ops.Patch()
+	patch.AddPermissionSlugs = slices.Clone(patch.AddPermissionSlugs)
+
+	updated, err := mergeImagePatch(existing, &patch, ops.ShiftImageDate)
+	if err != nil {
+		log.Error("failed to merge bulk operations into image record", "err", err.Error())
+		return api.BulkImageResult{Slug: slug, StatusCode: http.StatusUnprocessableEntity, Message: err.Error()}
+	}
+
+	changes, err := h.saveImagePatch(ctx, log, existing, updated, &patch)
+	if err != nil {
+		log.Error("failed to apply bulk operations to image, no changes were saved", "err", err.Error())
+		return api.BulkImageResult{Slug: slug, StatusCode: imageServiceErrorStatus(err), Message: err.Error()}
+	}
+
+	if len(changes) > 0 {
+		log.With(changes...).Info("successfully updated image record in bulk operation")
+	}
+
+	return api.BulkImageResult{Slug: slug, StatusCode: http.StatusNoContent, Version: existing.Version + 1}
+}
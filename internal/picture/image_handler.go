@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -15,8 +17,11 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/data"
 	"github.com/tdeslauriers/carapace/pkg/jwt"
 	"github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/validate"
 	"github.com/tdeslauriers/pixie/internal/album"
 	"github.com/tdeslauriers/pixie/internal/permission"
+	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
@@ -33,6 +38,33 @@ type ImageHandler interface {
 
 	// HandleImage handles the image processing request
 	HandleImage(w http.ResponseWriter, r *http.Request)
+
+	// HandleDownload handles the request for a short-lived signed URL to download the original image file
+	HandleDownload(w http.ResponseWriter, r *http.Request)
+
+	// HandleRendition handles the request to stream a rendition of an image through pixie
+	// rather than redirecting the client to object storage.
+	HandleRendition(w http.ResponseWriter, r *http.Request)
+
+	// HandleResize handles the request to stream an on-demand rendition of an image for sizes
+	// outside the stored resolution ladder, eg, email digests and open graph cards.
+	HandleResize(w http.ResponseWriter, r *http.Request)
+
+	// HandleBulk handles the request to apply a set of operations, eg, publish, to each of a list of images,
+	// reporting the outcome for each image.
+	HandleBulk(w http.ResponseWriter, r *http.Request)
+
+	// HandleUploadSessions handles the request to create a placeholder for each of a batch of files in one upload session,
+	// and the request for the session's progress through the upload pipeline.
+	HandleUploadSessions(w http.ResponseWriter, r *http.Request)
+
+	// HandleMultipart handles the multipart upload of a placeholder image too large for a single presigned PUT:
+	// resuming, presigning parts, completing, or aborting it.
+	HandleMultipart(w http.ResponseWriter, r *http.Request)
+
+	// HandleUpload handles the request to upload an image's metadata and file together as multipart/form-data,
+	// streaming the file into object storage through pixie rather than to a presigned url.
+	HandleUpload(w http.ResponseWriter, r *http.Request)
 }
 
 // NewHandler creates a new image handler instance, returning a pointer to the concrete implementation.
@@ -40,6 +72,7 @@ func NewHandler(
 	s Service,
 	a album.Service,
 	p permission.Service,
+	u transaction.UnitOfWork,
 	s2s jwt.Verifier,
 	iam jwt.Verifier,
 ) ImageHandler {
@@ -47,6 +80,7 @@ func NewHandler(
 		svc:    s,
 		albums: a,
 		perms:  p,
+		uow:    u,
 		s2s:    s2s,
 		iam:    iam,
 
@@ -64,6 +98,7 @@ type imageHandler struct {
 	svc    Service
 	albums album.Service
 	perms  permission.Service
+	uow    transaction.UnitOfWork
 	s2s    jwt.Verifier
 	iam    jwt.Verifier
 
@@ -80,6 +115,9 @@ func (h *imageHandler) HandleImage(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPut:
 		h.handleUpdateImageRecord(w, r)
 		return
+	case http.MethodPatch:
+		h.handlePatchImageRecord(w, r)
+		return
 	case http.MethodPost: // image upload
 		h.handleAddImageRecord(w, r)
 		return
@@ -102,6 +140,319 @@ func (h *imageHandler) HandleImage(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// HandleDownload is the concrete implementation of the HandleDownload method.
+// It returns a short-lived signed URL to download the untouched original image file
+// if the user's permissions allow downloading, not just viewing, the image.
+func (h *imageHandler) HandleDownload(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	if r.Method != http.MethodGet {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("image slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get user permissions
+	usrPsMap, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to get user permissions", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to get user permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get the signed download url
+	download, err := h.svc.GetImageDownload(ctx, slug, usrPsMap)
+	if err != nil {
+		log.Error("failed to get image download", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	log.Info(fmt.Sprintf("issued download url for original image '%s'", slug))
+
+	connect.SendJsonSuccess(w, http.StatusOK, download)
+}
+
+// HandleRendition is the concrete implementation of the HandleRendition method.
+// It streams a stored rendition after checking the same permissions as getting the image data.
+func (h *imageHandler) HandleRendition(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("image slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get width from request path
+	width, err := strconv.Atoi(r.PathValue("width"))
+	if err != nil || width <= 0 {
+		log.Error(fmt.Sprintf("failed to get valid rendition width from path '%s'", r.PathValue("width")))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("rendition width '%s' is not well-formed", r.PathValue("width")),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get user permissions
+	usrPsMap, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to get user permissions", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to get user permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// once streaming starts, the status and headers have been written and errors can only be logged
+	streaming := false
+	if err := h.svc.StreamImageRendition(ctx, slug, width, usrPsMap, func(rendition *ImageRendition) error {
+		streaming = true
+		serveRendition(w, r, rendition)
+		return nil
+	}); err != nil {
+		if streaming {
+			log.Error(fmt.Sprintf("failed while streaming rendition width %d of image '%s'", width, slug), "err", err.Error())
+			return
+		}
+
+		log.Error(fmt.Sprintf("failed to stream rendition width %d of image '%s'", width, slug), "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+}
+
+// HandleResize is the concrete implementation of the HandleResize method.
+// It streams an on-demand rendition for the w, h, fit, and q query params, which are snapped to the allowlisted
+// parameters, after checking the same permissions as getting the image data.
+func (h *imageHandler) HandleResize(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(readImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(readImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	// note: the slug is not the last path segment, so it is pulled from the path pattern
+	slug := r.PathValue("slug")
+	if err := validate.ValidateUuid(slug); err != nil {
+		log.Error(fmt.Sprintf("failed to get valid slug: %v", err))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("image slug '%s' is not well-formed", slug),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get the rendition parameters from the query string: all are optional, but one of w or h is required
+	params := make(map[string]int, 3)
+	for _, name := range []string{"w", "h", "q"} {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			continue
+		}
+
+		v, err := strconv.Atoi(raw)
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to parse rendition query param '%s'", name), "err", err.Error())
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusBadRequest,
+				Message:    fmt.Sprintf("rendition query param '%s' is not well-formed", name),
+			}
+			e.SendJsonErr(w)
+			return
+		}
+		params[name] = v
+	}
+
+	// snap the requested parameters to the allowlists
+	spec, err := pipeline.NewRenditionSpec(params["w"], params["h"], r.URL.Query().Get("fit"), params["q"])
+	if err != nil {
+		log.Error("failed to build rendition spec", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get user permissions
+	usrPsMap, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to get user permissions", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to get user permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// once streaming starts, the status and headers have been written and errors can only be logged
+	streaming := false
+	if err := h.svc.StreamResizedImage(ctx, slug, spec, usrPsMap, func(rendition *ImageRendition) error {
+		streaming = true
+		serveRendition(w, r, rendition)
+		return nil
+	}); err != nil {
+		if streaming {
+			log.Error(fmt.Sprintf("failed while streaming rendition '%s' of image '%s'", spec.DerivedKey(slug), slug), "err", err.Error())
+			return
+		}
+
+		log.Error(fmt.Sprintf("failed to stream rendition '%s' of image '%s'", spec.DerivedKey(slug), slug), "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+}
+
+// serveRendition is a helper which writes the caching headers for a rendition and streams it to the client.
+// Range, if-range, and if-none-match requests are handled by http.ServeContent using the strong ETag.
+// Requests which include the ETag as the "v" query param address the content, so may be cached indefinitely.
+func serveRendition(w http.ResponseWriter, r *http.Request, rendition *ImageRendition) {
+
+	// permission-checked content must never be stored by shared caches
+	cacheControl := "private, no-cache"
+	if v := r.URL.Query().Get("v"); v != "" && v == strings.Trim(rendition.ETag, `"`) {
+		cacheControl = fmt.Sprintf("private, max-age=%d, immutable", int(util.RenditionCacheMaxAge.Seconds()))
+	}
+
+	w.Header().Set("ETag", rendition.ETag)
+	w.Header().Set("Cache-Control", cacheControl)
+	w.Header().Set("Content-Type", rendition.ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	http.ServeContent(w, r, "", time.Time{}, rendition.Body)
+}
+
 // getImageData handles the GET request for image processing.
 func (h *imageHandler) getImageData(w http.ResponseWriter, r *http.Request) {
 
@@ -178,6 +529,7 @@ func (h *imageHandler) getImageData(w http.ResponseWriter, r *http.Request) {
 
 		albumsCh := make(chan albumsResult, 1)
 		permissionsCh := make(chan permissionsResult, 1)
+		downloadsCh := make(chan permissionsResult, 1)
 
 		// gather albums concurrently
 		go func() {
@@ -191,9 +543,16 @@ func (h *imageHandler) getImageData(w http.ResponseWriter, r *http.Request) {
 			permissionsCh <- permissionsResult{records: permissionRecords, err: err}
 		}()
 
-		// wait for both results
+		// gather download permissions concurrently
+		go func() {
+			downloadRecords, err := h.perms.GetImageDownloadPermissions(imageData.Id)
+			downloadsCh <- permissionsResult{records: downloadRecords, err: err}
+		}()
+
+		// wait for all results
 		albumsRes := <-albumsCh
 		permissionsRes := <-permissionsCh
+		downloadsRes := <-downloadsCh
 
 		// handle albums result
 		if albumsRes.err != nil {
@@ -240,8 +599,23 @@ func (h *imageHandler) getImageData(w http.ResponseWriter, r *http.Request) {
 			}
 			imageData.Permissions = permissions
 		}
+
+		// handle download permissions result
+		// note: log only.  Still need to return the image data to the frontend
+		if downloadsRes.err != nil {
+			log.Error(fmt.Sprintf("/images/slug handler failed to get download permissions for image '%s'", imageData.Slug),
+				"err", downloadsRes.err.Error())
+		} else {
+			slugs := make([]string, 0, len(downloadsRes.records))
+			for _, p := range downloadsRes.records {
+				slugs = append(slugs, p.Slug)
+			}
+			imageData.DownloadPermissionSlugs = slugs
+		}
 	}
 
+	// the version is returned as the ETag so it can be sent back as If-Match when editing the image
+	w.Header().Set("ETag", util.VersionETag(imageData.Version))
 	connect.SendJsonSuccess(w, http.StatusOK, imageData)
 }
 
@@ -287,6 +661,28 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// edits must be based on the current version of the image, sent back as If-Match
+	if r.Header.Get("If-Match") == "" {
+		log.Error(fmt.Sprintf("missing If-Match header for update of image slug %s", slug))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusPreconditionRequired,
+			Message:    "If-Match header with the image's ETag is required",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	version, err := util.ParseIfMatch(r)
+	if err != nil {
+		log.Error("failed to parse If-Match header", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
 	// get update data from the request body
 	var cmd api.UpdateMetadataCmd
 	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
@@ -330,6 +726,13 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// the edit was based on a stale read of the image
+	if existing.Version != version {
+		log.Error(fmt.Sprintf("If-Match version %d does not match image slug %s version %d", version, slug, existing.Version))
+		h.respondImagePreconditionFailed(existing, w)
+		return
+	}
+
 	// validate the slugs all match:
 	// no risk since the slug will not be overwritten, but good practice to validate
 	// and a good check for tampering with the request overall
@@ -355,10 +758,19 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 
 	// build image date from the update cmd date fields
 	// image data is required, so if any of the fields are empty, validation ^^ will have returned an error
-	imageDate := time.Date(cmd.ImageDateYear, time.Month(cmd.ImageDateMonth), cmd.ImageDateDay, 0, 0, 0, 0, time.UTC)
+	imageDate := cmd.PartialDate()
+
+	// an unchanged day keeps the exact date and time read from the image's exif metadata
+	if existing.Date != nil && existing.Date.Precision == api.DatePrecisionExact &&
+		imageDate.Precision == api.DatePrecisionDay &&
+		existing.Date.Year == imageDate.Year &&
+		existing.Date.Month == imageDate.Month &&
+		existing.Date.Day == imageDate.Day {
+		imageDate = existing.Date
+	}
 
 	// update the objectKey (note it may not change, but we still need the value for the update cmd)
-	objectKey := fmt.Sprintf("%d/%s", imageDate.Year(), existing.FileName)
+	objectKey := fmt.Sprintf("%d/%s", imageDate.Year, existing.FileName)
 
 	// build image record that are allowed to be updated
 	// Note: more fields can be added here as needed
@@ -371,18 +783,21 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 		ObjectKey:   objectKey,         // object key may change if err on upload pipeline => unpublished image
 		Slug:        existing.Slug,     // slug should not change
 		Size:        existing.Size,
-		ImageDate:   imageDate.Format(time.RFC3339), // format the image date as RFC3339
+		ImageDate:   imageDate.ImageDate(), // the start of the date's range, as RFC3339
+		// how precisely the image date is known
+		ImageDatePrecision: imageDate.Precision,
+		ImageDateEndYear:   imageDate.EndYear,
 		// created_at will not change, and will not be included in the updated fields:  leave default value.
 		UpdatedAt:   data.CustomTime{Time: time.Now().UTC()},
 		IsArchived:  cmd.IsArchived,
 		IsPublished: cmd.IsPublished,
 	}
 
-	// TODO: add concurrency here if needed
-	// Note these check if necessary before firing, need to go before the image update check
-	// update albums associated with the image
-	if err := h.svc.UpdateAlbumImages(ctx, existing.Id, cmd.AlbumSlugs); err != nil {
-		log.Error("/images/slug handler failed to update image albums",
+	// images added to an album inherit the album's permissions, if the album passes them on
+	// must go before the albums update so the newly added albums can be determined
+	inherited, err := h.getInheritedPermissionSlugs(existing.Id, cmd.AlbumSlugs)
+	if err != nil {
+		log.Error("/images/slug handler failed to get permissions inherited from added albums",
 			"err", err.Error(),
 			"image_slug", existing.Slug,
 			"image_id", existing.Id,
@@ -391,25 +806,86 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// update permissions associated with the image
-	if err := h.perms.UpdateImagePermissions(ctx, existing.Id, cmd.PermissionSlugs); err != nil {
-		log.Error("/images/slug handler failed to update image permissions",
+	for _, slug := range inherited {
+		if !slices.Contains(cmd.PermissionSlugs, slug) {
+			cmd.PermissionSlugs = append(cmd.PermissionSlugs, slug)
+			log.Info(fmt.Sprintf("image '%s' inherits permission '%s' from an added album", existing.Slug, slug))
+		}
+	}
+
+	// is update to image data necessary?
+	recordChanged := existing.Title != updated.Title ||
+		existing.Description != updated.Description ||
+		imageDateChanged(existing, updated) ||
+		existing.ObjectKey != updated.ObjectKey ||
+		existing.IsArchived != updated.IsArchived ||
+		existing.IsPublished != updated.IsPublished
+
+	// the image record, album xrefs, and permission xrefs are updated as a unit of work:
+	// either all of the changes are committed, or none of them are.
+	// Note: the reprocess command, if any, is only sent once the unit of work commits.
+	err = h.uow.Run(ctx, func(tx transaction.Tx) error {
+
+		// claim the next version first: if another edit committed since the image was read, nothing is saved.
+		// Every edit increments the version, even one which only changes albums or permissions.
+		if err := h.svc.IncrementImageVersion(ctx, tx, existing.Id, version); err != nil {
+			return fmt.Errorf("failed to update image version: %w", err)
+		}
+
+		// update albums associated with the image
+		if err := h.svc.UpdateAlbumImages(ctx, tx, existing.Id, cmd.AlbumSlugs); err != nil {
+			return fmt.Errorf("failed to update image albums: %w", err)
+		}
+
+		// update permissions associated with the image
+		if err := h.perms.UpdateImagePermissions(ctx, tx, existing.Id, cmd.PermissionSlugs); err != nil {
+			return fmt.Errorf("failed to update image permissions: %w", err)
+		}
+
+		// update which of the image's permissions may download the original
+		// must go after the permissions update since download permissions must be associated with the image
+		if err := h.perms.UpdateImageDownloadPermissions(ctx, tx, existing.Id, cmd.DownloadPermissionSlugs); err != nil {
+			return fmt.Errorf("failed to update image download permissions: %w", err)
+		}
+
+		if !recordChanged {
+			return nil
+		}
+
+		// handles updating the database and the object store if necessary
+		if err := h.svc.UpdateImageData(ctx, tx, existing, updated); err != nil {
+			return fmt.Errorf("failed to update image data: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.Error("/images/slug handler failed to update image, no changes were saved",
 			"err", err.Error(),
 			"image_slug", existing.Slug,
 			"image_id", existing.Id,
 		)
+
+		// the image was changed between reading and updating it: respond with the current image
+		if strings.Contains(err.Error(), "is stale") {
+			current, err := h.svc.GetImageData(ctx, slug, usrPsMap)
+			if err != nil {
+				log.Error("failed to get current image data", "err", err.Error())
+				h.svc.HandleImageServiceError(ctx, err, w)
+				return
+			}
+			h.respondImagePreconditionFailed(current, w)
+			return
+		}
+
 		h.svc.HandleImageServiceError(ctx, err, w)
 		return
 	}
 
-	// is update to image data necessary?
-	if existing.Title == updated.Title &&
-		existing.Description == updated.Description &&
-		existing.ImageDate == updated.ImageDate &&
-		existing.ObjectKey == updated.ObjectKey &&
-		existing.IsArchived == updated.IsArchived &&
-		existing.IsPublished == updated.IsPublished {
+	// the new version's ETag for the next edit
+	w.Header().Set("ETag", util.VersionETag(version+1))
 
+	if !recordChanged {
 		log.Warn("no changes detected in image record update, skipping database update",
 			"image_slug", existing.Slug,
 			"image_id", existing.Id,
@@ -419,18 +895,20 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// handles updating the database and the object store if necessary
-	if err := h.svc.UpdateImageData(ctx, existing, updated); err != nil {
-		log.Error("failed to update image data",
-			"err", err.Error(),
-			"image_slug", existing.Slug,
-			"image_id", existing.Id,
-		)
-		h.svc.HandleImageServiceError(ctx, err, w)
-		return
+	// audit log
+	changes := imageRecordChanges(existing, updated)
+
+	if len(changes) > 0 {
+		log = log.With(changes...)
+		log.Info("successfully updated image record", "image_slug", existing.Slug, "image_id", existing.Id)
 	}
 
-	// audit log
+	w.WriteHeader(http.StatusNoContent) // 204 No Content
+}
+
+// imageRecordChanges is a helper which builds the audit log attributes for the fields changed by an image record update.
+func imageRecordChanges(existing *api.ImageData, updated *api.ImageRecord) []any {
+
 	var changes []any
 
 	if existing.Title != updated.Title {
@@ -445,10 +923,24 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 			slog.String("new_description", updated.Description))
 	}
 
-	if existing.ImageDate != updated.ImageDate {
+	if imageDateChanged(existing, updated) {
 		changes = append(changes,
 			slog.String("previous_image_date", existing.ImageDate),
 			slog.String("new_image_date", updated.ImageDate))
+
+		var previous string
+		if existing.Date != nil {
+			previous = existing.Date.String()
+		}
+		var next string
+		if d := api.BuildPartialDate(updated.ImageDate, updated.ImageDatePrecision, updated.ImageDateEndYear); d != nil {
+			next = d.String()
+		}
+		if previous != next {
+			changes = append(changes,
+				slog.String("previous_image_date_display", previous),
+				slog.String("new_image_date_display", next))
+		}
 	}
 
 	if existing.ObjectKey != updated.ObjectKey {
@@ -469,12 +961,15 @@ func (h *imageHandler) handleUpdateImageRecord(w http.ResponseWriter, r *http.Re
 			slog.Bool("new_is_published", updated.IsPublished))
 	}
 
-	if len(changes) > 0 {
-		log = log.With(changes...)
-		log.Info("successfully updated image record", "image_slug", existing.Slug, "image_id", existing.Id)
-	}
+	return changes
+}
 
-	w.WriteHeader(http.StatusNoContent) // 204 No Content
+// respondImagePreconditionFailed is a helper which responds 412 with the current image and its ETag,
+// so the client can reapply its edit to the current version.
+func (h *imageHandler) respondImagePreconditionFailed(current *api.ImageData, w http.ResponseWriter) {
+
+	w.Header().Set("ETag", util.VersionETag(current.Version))
+	connect.SendJsonSuccess(w, http.StatusPreconditionFailed, current)
 }
 
 // handleAddImageRecord handles the POST request for adding an image record.
@@ -535,7 +1030,7 @@ func (h *imageHandler) handleAddImageRecord(w http.ResponseWriter, r *http.Reque
 
 	// build placeholder image record waiting for the image file to be processed on
 	// generate a pre-signed PUT URL to return for browser to submit the file to object storage
-	placeholder, err := h.svc.BuildPlaceholder(ctx, cmd)
+	placeholder, err := h.svc.BuildPlaceholder(ctx, cmd, "")
 	if err != nil {
 		log.Error("failed to build placeholder image record", "err", err.Error())
 		h.svc.HandleImageServiceError(ctx, err, w)
@@ -556,7 +1051,8 @@ func (h *imageHandler) handleAddImageRecord(w http.ResponseWriter, r *http.Reque
 
 				go func(albId, imgId string) {
 					// add the image to the album by updating xref table
-					if err := h.albums.InsertAlbumImageXref(albId, imgId); err != nil {
+					// the request context is done once the response is sent, so it is detached from its cancellation
+					if err := h.albums.InsertAlbumImageXref(context.WithoutCancel(ctx), albId, imgId); err != nil {
 						log.Error(fmt.Sprintf("failed to add image to album '%s'", albId), "err", err.Error())
 						return
 					}
@@ -607,6 +1103,29 @@ func (h *imageHandler) handleAddImageRecord(w http.ResponseWriter, r *http.Reque
 	connect.SendJsonSuccess(w, http.StatusOK, placeholder)
 }
 
+// getInheritedPermissionSlugs is a helper function that retrieves the slugs of the permissions the image inherits
+// from the albums in the album slugs it is not already in, ie, the albums it is being added to.
+func (h *imageHandler) getInheritedPermissionSlugs(imageId string, albumSlugs []string) ([]string, error) {
+
+	current, _, err := h.svc.GetImageAlbums(imageId)
+	if err != nil && !strings.Contains(err.Error(), "no albums found for image") {
+		return nil, fmt.Errorf("failed to get current albums for image '%s': %v", imageId, err)
+	}
+
+	added := make([]string, 0, len(albumSlugs))
+	for _, slug := range albumSlugs {
+		if _, ok := current[slug]; !ok {
+			added = append(added, slug)
+		}
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	return h.perms.GetInheritedPermissionSlugs(added)
+}
+
 // getValidAlbumIds is a helper function that retrieves the valid album IDs from the provided album command data.
 func (h *imageHandler) getValidAlbumIds(ctx context.Context, username string, albumsCmd []api.Album) ([]string, error) {
 
@@ -757,3 +1276,19 @@ func (h *imageHandler) handleDeleteImageRecord(w http.ResponseWriter, r *http.Re
 	)
 	w.WriteHeader(http.StatusNoContent) // 204 No Content
 }
+
+// imageDateChanged is a helper which checks if an image record update changes the image date,
+// including how precisely it is known, eg, 1974 to the 1970s keeps the same stored start date.
+func imageDateChanged(existing *api.ImageData, updated *api.ImageRecord) bool {
+
+	if existing.ImageDate != updated.ImageDate {
+		return true
+	}
+
+	d := api.BuildPartialDate(updated.ImageDate, updated.ImageDatePrecision, updated.ImageDateEndYear)
+	if existing.Date == nil || d == nil {
+		return existing.Date != nil || d != nil
+	}
+
+	return existing.Date.Precision != d.Precision || existing.Date.EndYear != d.EndYear
+}
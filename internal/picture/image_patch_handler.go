@@ -0,0 +1,341 @@
+package picture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/pixie/internal/transaction"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// handlePatchImageRecord applies a JSON merge-patch to the image metadata, changing only the fields present,
+// and adding the image to or removing it from the listed albums and permissions.
+func (h *imageHandler) handlePatchImageRecord(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(writeImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(writeImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get slug from request path
+	slug, err := connect.GetValidSlug(r)
+	if err != nil {
+		log.Error("failed to get valid slug from request path", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// the body must be a merge-patch: plain json is accepted since a merge-patch is a json object
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != api.MergePatchContentType && mediaType != "application/json") {
+		log.Error(fmt.Sprintf("unsupported content type '%s' for image patch", r.Header.Get("Content-Type")))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnsupportedMediaType,
+			Message:    fmt.Sprintf("content type must be %s", api.MergePatchContentType),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// a patch only changes the fields it lists, so it cannot overwrite a concurrent edit of another field:
+	// If-Match is optional, but if sent, the patch is only applied to that version
+	var ifMatch int
+	if r.Header.Get("If-Match") != "" {
+		ifMatch, err = util.ParseIfMatch(r)
+		if err != nil {
+			log.Error("failed to parse If-Match header", "err", err.Error())
+			e := connect.ErrorHttp{
+				StatusCode: http.StatusBadRequest,
+				Message:    err.Error(),
+			}
+			e.SendJsonErr(w)
+			return
+		}
+	}
+
+	// get the patch from the request body
+	var cmd api.PatchMetadataCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode image patch", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the fields present in the patch
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate image patch", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// get user permissions
+	usrPsMap, _, err := h.perms.GetPatronPermissions(ctx, authedUser.Claims.Subject)
+	if err != nil {
+		log.Error("failed to get user permissions", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to get user permissions",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// check if the image exists -> get from database
+	existing, err := h.svc.GetImageData(ctx, slug, usrPsMap)
+	if err != nil {
+		log.Error("failed to get existing image data", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	// the patch was based on a stale read of the image
+	if ifMatch != 0 && existing.Version != ifMatch {
+		log.Error(fmt.Sprintf("If-Match version %d does not match image slug %s version %d", ifMatch, slug, existing.Version))
+		h.respondImagePreconditionFailed(existing, w)
+		return
+	}
+
+	// merge the patch into the existing image record
+	updated, err := mergeImagePatch(existing, &cmd, nil)
+	if err != nil {
+		log.Error(fmt.Sprintf("failed to merge patch into image slug %s", slug), "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// the image record, album xrefs, and permission xrefs are patched as a unit of work,
+	// the same as a full update.
+	changes, err := h.saveImagePatch(ctx, log, existing, updated, &cmd)
+	if err != nil {
+		log.Error("/images/slug handler failed to patch image, no changes were saved",
+			"err", err.Error(),
+			"image_slug", existing.Slug,
+			"image_id", existing.Id,
+		)
+
+		// the image was changed between reading and patching it: respond with the current image
+		if strings.Contains(err.Error(), "is stale") {
+			current, err := h.svc.GetImageData(ctx, slug, usrPsMap)
+			if err != nil {
+				log.Error("failed to get current image data", "err", err.Error())
+				h.svc.HandleImageServiceError(ctx, err, w)
+				return
+			}
+			h.respondImagePreconditionFailed(current, w)
+			return
+		}
+
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	// audit log
+	log = log.With(changes...)
+	log.Info("successfully patched image record",
+		"image_slug", existing.Slug,
+		"image_id", existing.Id,
+		"added_albums", cmd.AddAlbumSlugs,
+		"removed_albums", cmd.RemoveAlbumSlugs,
+		"added_permissions", cmd.AddPermissionSlugs,
+		"removed_permissions", cmd.RemovePermissionSlugs,
+	)
+
+	// the new version's ETag for the next edit
+	w.Header().Set("ETag", util.VersionETag(existing.Version+1))
+	w.WriteHeader(http.StatusNoContent) // 204 No Content
+}
+
+// mergeImagePatch is a helper which merges a patch into the existing image record, returning the updated record.
+// If shift is not nil, the image date is shifted by it rather than merged from the patch's date fields.
+// Returns an error if the patched record would not be valid, eg, both archived and published.
+func mergeImagePatch(existing *api.ImageData, cmd *api.PatchMetadataCmd, shift *api.ImageDateShift) (*api.ImageRecord, error) {
+
+	// Note: more fields can be added here as needed
+	updated := &api.ImageRecord{
+		Id:          existing.Id,          // id should not change
+		Title:       existing.Title,       // unless patched
+		Description: existing.Description, // unless patched
+		FileName:    existing.FileName,    // file name should not change
+		FileType:    existing.FileType,    // file type should not change
+		ObjectKey:   existing.ObjectKey,   // unless the image date is patched
+		Slug:        existing.Slug,        // slug should not change
+		Size:        existing.Size,
+		ImageDate:   existing.ImageDate, // unless patched
+		// created_at will not change, and will not be included in the updated fields:  leave default value.
+		UpdatedAt:   data.CustomTime{Time: time.Now().UTC()},
+		IsArchived:  existing.IsArchived,  // unless patched
+		IsPublished: existing.IsPublished, // unless patched
+	}
+
+	// precision and end year are unchanged unless the image date is patched
+	if existing.Date != nil {
+		updated.ImageDatePrecision = existing.Date.Precision
+		updated.ImageDateEndYear = existing.Date.EndYear
+	}
+
+	if cmd.Title != nil {
+		updated.Title = strings.TrimSpace(*cmd.Title)
+	}
+
+	if cmd.Description != nil {
+		updated.Description = strings.TrimSpace(*cmd.Description)
+	}
+
+	if shift != nil || cmd.HasImageDate() {
+
+		var (
+			merged *api.PartialDate
+			err    error
+		)
+		if shift != nil {
+			merged, err = shift.Apply(existing.Date)
+		} else {
+			merged, err = cmd.MergeImageDate(existing.Date)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		updated.ImageDate = merged.ImageDate()
+		updated.ImageDatePrecision = merged.Precision
+		updated.ImageDateEndYear = merged.EndYear
+
+		// the object key may change if the year does, same as a full update
+		updated.ObjectKey = fmt.Sprintf("%d/%s", merged.Year, existing.FileName)
+	}
+
+	if cmd.IsArchived != nil {
+		updated.IsArchived = *cmd.IsArchived
+	}
+
+	if cmd.IsPublished != nil {
+		updated.IsPublished = *cmd.IsPublished
+	}
+
+	// eg, publishing an archived image without un-archiving it
+	if updated.IsArchived && updated.IsPublished {
+		return nil, fmt.Errorf("image cannot be both archived and published at the same time")
+	}
+
+	return updated, nil
+}
+
+// saveImagePatch is a helper which saves the patched image record, and adds the image to or removes it from
+// the patch's albums and permissions, as a unit of work.  Permissions inherited from added albums are added
+// to the patch's permission slugs, unless the patch removes them.
+// Returns the audit log attributes of the record's changed fields.
+func (h *imageHandler) saveImagePatch(
+	ctx context.Context,
+	log *slog.Logger,
+	existing *api.ImageData,
+	updated *api.ImageRecord,
+	cmd *api.PatchMetadataCmd,
+) ([]any, error) {
+
+	// images added to an album inherit the album's permissions, if the album passes them on,
+	// unless the patch explicitly revokes them
+	inherited, err := h.getInheritedPermissionSlugs(existing.Id, cmd.AddAlbumSlugs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permissions inherited from added albums: %w", err)
+	}
+
+	for _, slug := range inherited {
+		if slices.Contains(cmd.RemovePermissionSlugs, slug) {
+			log.Warn(fmt.Sprintf("image '%s' does not inherit permission '%s' from an added album: the patch removes it", existing.Slug, slug))
+			continue
+		}
+		if !slices.Contains(cmd.AddPermissionSlugs, slug) {
+			cmd.AddPermissionSlugs = append(cmd.AddPermissionSlugs, slug)
+			log.Info(fmt.Sprintf("image '%s' inherits permission '%s' from an added album", existing.Slug, slug))
+		}
+	}
+
+	// is update to image data necessary?
+	changes := imageRecordChanges(existing, updated)
+
+	err = h.uow.Run(ctx, func(tx transaction.Tx) error {
+
+		// claim the next version first: if another edit committed since the image was read, nothing is saved
+		if err := h.svc.IncrementImageVersion(ctx, tx, existing.Id, existing.Version); err != nil {
+			return fmt.Errorf("failed to update image version: %w", err)
+		}
+
+		if err := h.svc.PatchAlbumImages(ctx, tx, existing.Id, cmd.AddAlbumSlugs, cmd.RemoveAlbumSlugs); err != nil {
+			return fmt.Errorf("failed to patch image albums: %w", err)
+		}
+
+		if err := h.perms.PatchImagePermissions(ctx, tx, existing.Id, cmd.AddPermissionSlugs, cmd.RemovePermissionSlugs); err != nil {
+			return fmt.Errorf("failed to patch image permissions: %w", err)
+		}
+
+		// must go after the permissions patch since download permissions must be associated with the image
+		if err := h.perms.PatchImageDownloadPermissions(ctx, tx, existing.Id, cmd.AddDownloadPermissionSlugs, cmd.RemoveDownloadPermissionSlugs); err != nil {
+			return fmt.Errorf("failed to patch image download permissions: %w", err)
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+
+		// handles updating the database and the object store if necessary
+		if err := h.svc.UpdateImageData(ctx, tx, existing, updated); err != nil {
+			return fmt.Errorf("failed to update image data: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,112 @@
+package picture
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+func TestMergeImagePatch(t *testing.T) {
+
+	existing := func() *api.ImageData {
+		return &api.ImageData{
+			Id:          "11111111-1111-1111-1111-111111111111",
+			Title:       "Old Title",
+			FileName:    "22222222-2222-2222-2222-222222222222.jpg",
+			ObjectKey:   "2020/22222222-2222-2222-2222-222222222222.jpg",
+			ImageDate:   "2020-01-15T00:00:00Z",
+			Date:        api.BuildPartialDate("2020-01-15T00:00:00Z", api.DatePrecisionDay, 0),
+			IsPublished: true,
+		}
+	}
+
+	title := "  New Title "
+	archived := true
+	year, precision := 1974, api.DatePrecisionYear
+
+	tests := []struct {
+		name      string
+		existing  *api.ImageData
+		cmd       api.PatchMetadataCmd
+		shift     *api.ImageDateShift
+		wantDate  string
+		wantKey   string
+		wantTitle string
+		wantErr   string
+	}{
+		{
+			name:      "shift only, with an empty patch",
+			existing:  existing(),
+			shift:     &api.ImageDateShift{Months: -1},
+			wantDate:  "2019-12-15T00:00:00Z",
+			wantKey:   "2019/22222222-2222-2222-2222-222222222222.jpg",
+			wantTitle: "Old Title",
+		},
+		{
+			name:      "shift with other fields",
+			existing:  existing(),
+			cmd:       api.PatchMetadataCmd{Title: &title},
+			shift:     &api.ImageDateShift{Years: 1},
+			wantDate:  "2021-01-15T00:00:00Z",
+			wantKey:   "2021/22222222-2222-2222-2222-222222222222.jpg",
+			wantTitle: "New Title",
+		},
+		{
+			name:      "set date",
+			existing:  existing(),
+			cmd:       api.PatchMetadataCmd{ImageDateYear: &year, ImageDatePrecision: &precision},
+			wantDate:  "1974-01-01T00:00:00Z",
+			wantKey:   "1974/22222222-2222-2222-2222-222222222222.jpg",
+			wantTitle: "Old Title",
+		},
+		{
+			name:      "no date change",
+			existing:  existing(),
+			cmd:       api.PatchMetadataCmd{Title: &title},
+			wantDate:  "2020-01-15T00:00:00Z",
+			wantKey:   "2020/22222222-2222-2222-2222-222222222222.jpg",
+			wantTitle: "New Title",
+		},
+		{
+			name: "shift image with no date",
+			existing: func() *api.ImageData {
+				d := existing()
+				d.ImageDate, d.Date = "", nil
+				return d
+			}(),
+			shift:   &api.ImageDateShift{Years: 1},
+			wantErr: "no image date",
+		},
+		{
+			name:     "archive a published image",
+			existing: existing(),
+			cmd:      api.PatchMetadataCmd{IsArchived: &archived},
+			wantErr:  "both archived and published",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := mergeImagePatch(tc.existing, &tc.cmd, tc.shift)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("mergeImagePatch() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeImagePatch() error = %v", err)
+			}
+			if got.ImageDate != tc.wantDate {
+				t.Errorf("ImageDate = %s, want %s", got.ImageDate, tc.wantDate)
+			}
+			if got.ObjectKey != tc.wantKey {
+				t.Errorf("ObjectKey = %s, want %s", got.ObjectKey, tc.wantKey)
+			}
+			if got.Title != tc.wantTitle {
+				t.Errorf("Title = %q, want %q", got.Title, tc.wantTitle)
+			}
+		})
+	}
+}
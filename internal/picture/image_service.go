@@ -1,10 +1,17 @@
 package picture
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"database/sql"
 	"fmt"
+	"image"
+	"io"
 	"log/slog"
+	"mime"
+	"net/http"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -16,7 +23,9 @@ import (
 	"github.com/tdeslauriers/carapace/pkg/storage"
 	"github.com/tdeslauriers/carapace/pkg/validate"
 	"github.com/tdeslauriers/pixie/internal/crypt"
+	"github.com/tdeslauriers/pixie/internal/multipart"
 	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/internal/util"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
@@ -30,14 +39,51 @@ type ImageService interface {
 	// fetches signed URL for the image.
 	GetImageData(ctx context.Context, slug string, userPs map[string]exo.PermissionRecord) (*api.ImageData, error)
 
-	// UpdateImageData updates an existing image record in the database.
-	UpdateImageData(ctx context.Context, existing *api.ImageData, updated *api.ImageRecord) error
+	// GetImageDownload issues a short-lived signed URL to download the untouched original image file
+	// if the user's permissions allow downloading, along with an attachment filename built from the title and date.
+	GetImageDownload(ctx context.Context, slug string, userPs map[string]exo.PermissionRecord) (*api.ImageDownload, error)
+
+	// StreamImageRendition checks the user's permissions for the image and the requested rendition width,
+	// then opens the rendition from object storage and passes it to fn to stream to the client.
+	StreamImageRendition(
+		ctx context.Context,
+		slug string,
+		width int,
+		userPs map[string]exo.PermissionRecord,
+		fn func(rendition *ImageRendition) error,
+	) error
+
+	// StreamResizedImage checks the user's permissions for the image, then streams the on-demand rendition
+	// for the spec to fn, generating it from the nearest larger stored rendition and caching it in
+	// object storage if it does not exist yet.
+	StreamResizedImage(
+		ctx context.Context,
+		slug string,
+		spec pipeline.RenditionSpec,
+		userPs map[string]exo.PermissionRecord,
+		fn func(rendition *ImageRendition) error,
+	) error
+
+	// UpdateImageData updates an existing image record within the unit of work's transaction.
+	// If the image must be reprocessed, the command is only sent once the unit of work commits.
+	UpdateImageData(ctx context.Context, tx transaction.Tx, existing *api.ImageData, updated *api.ImageRecord) error
+
+	// IncrementImageVersion increments an image's version within the unit of work's transaction.
+	// Returns an error if the version is stale, ie, the image was changed since it was read.
+	IncrementImageVersion(ctx context.Context, tx transaction.Tx, imageId string, version int) error
 
 	// BuildPlaceholder builds the metadata for a placeholder image record.
 	// eg, the id, slug, title, and description provided by the user.
-	// Once meta data persisted, a presigned put url is generated and returned.
+	// Once meta data persisted, a presigned put url is generated and returned, or, if the file is
+	// too large for a single put, a multipart upload is initiated and returned.
 	// The image processing pipeline will build the rest of the record upon ingestion of the image file.
-	BuildPlaceholder(ctx context.Context, cmd api.AddMetaDataCmd) (*api.Placeholder, error)
+	// The upload session id is recorded on the placeholder, and is empty if it was not created in an upload session.
+	BuildPlaceholder(ctx context.Context, cmd api.AddMetaDataCmd, uploadSessionId string) (*api.Placeholder, error)
+
+	// UploadImage builds the placeholder image record, the same as BuildPlaceholder, then streams the image
+	// file into object storage itself rather than returning a presigned put url, and hands it to the upload pipeline.
+	// The file must be the type and exact size the command declares.  If the file cannot be uploaded, the placeholder is removed.
+	UploadImage(ctx context.Context, cmd api.AddMetaDataCmd, file io.Reader) (*api.Placeholder, error)
 
 	// DeleteImage deletes an image record from the database and removes the associated image file from object storage.
 	DeleteImage(ctx context.Context, imageData *api.ImageData) error
@@ -48,17 +94,25 @@ func NewImageService(
 	sql *sql.DB,
 	i data.Indexer, c data.Cryptor,
 	obj storage.ObjectStorage,
+	mp multipart.Store,
+	limits map[string]int64,
+	viewOnlyMaxWidth int,
+	uq chan pipeline.UploadCmd,
 	rq chan pipeline.ReprocessCmd,
 	dq chan pipeline.DeletionCmd,
 ) ImageService {
 
 	return &imageService{
-		db:        NewRepository(sql),
-		indexer:   i,
-		cryptor:   crypt.NewCryptor(c),
-		store:     obj,
-		reprocess: rq,
-		delete:    dq,
+		db:         NewRepository(sql),
+		indexer:    i,
+		cryptor:    crypt.NewCryptor(c),
+		store:      obj,
+		multipart:  mp,
+		sizeLimits: limits,
+		viewOnly:   viewOnlyMaxWidth,
+		uploads:    uq,
+		reprocess:  rq,
+		delete:     dq,
 
 		logger: slog.Default().
 			With(slog.String(util.PackageKey, util.PackagePicture)).
@@ -70,12 +124,16 @@ var _ ImageService = (*imageService)(nil)
 
 // imageService is the concrete implementation of the ImageService interface.
 type imageService struct {
-	db        Repository
-	indexer   data.Indexer
-	cryptor   crypt.Cryptor // image data specific wrapper around data.Cryptor
-	store     storage.ObjectStorage
-	reprocess chan pipeline.ReprocessCmd
-	delete    chan pipeline.DeletionCmd
+	db         Repository
+	indexer    data.Indexer
+	cryptor    crypt.Cryptor // image data specific wrapper around data.Cryptor
+	store      storage.ObjectStorage
+	multipart  multipart.Store         // uploads of files too large for a single presigned put, and download urls
+	sizeLimits map[string]int64        // largest file, in bytes, by file type
+	viewOnly   int                     // widest resolution, in pixels, served to users who may not download the image
+	uploads    chan pipeline.UploadCmd // files uploaded through pixie rather than to a presigned url
+	reprocess  chan pipeline.ReprocessCmd
+	delete     chan pipeline.DeletionCmd
 
 	logger *slog.Logger
 }
@@ -91,61 +149,16 @@ func (s *imageService) GetImageData(ctx context.Context, slug string, userPs map
 	}
 	log := s.logger.With(tel.TelemetryFields()...)
 
-	// validate the slug
-	// redundant check, but good practice
-	if err := validate.ValidateUuid(slug); err != nil {
-		return nil, fmt.Errorf("image slug '%s' is not well-formed", slug)
-	}
-
-	// get blind index for the slug
-	index, err := s.indexer.ObtainBlindIndex(slug)
+	// get the image record the user has permission to view
+	record, err := s.findPermittedImage(slug, userPs)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate blind index for image slug '%s': %v", slug, err)
+		return nil, err
 	}
 
-	// get image from database based on user's permissions
-	record, err := s.db.FindImageByPermissions(index, userPs)
+	// determine if the user may download the original or is view-only
+	downloadable, err := s.isDownloadable(record.SlugIndex, userPs)
 	if err != nil {
-		// all of the following presume the user is not a curator/admin.
-		if err == sql.ErrNoRows {
-
-			// check if the image exists at all
-			if exists, err := s.db.ImageExists(index); err != nil {
-				return nil, fmt.Errorf("failed to check if image exists for slug '%s': %v", slug, err)
-			} else if !exists {
-				return nil, fmt.Errorf("image '%s' was not found", slug)
-			}
-
-			// check if the image exists but the user has not permissions
-			if exists, err := s.db.ImageExistsNoPermissions(index, userPs); err != nil {
-				return nil, fmt.Errorf("failed to check if image exists for slug '%s': %v", slug, err)
-			} else if exists {
-				return nil, fmt.Errorf("user does not have permission to view image '%s'", slug)
-			}
-
-			// check if the image is archived
-			if exists, err := s.db.ImageExistsArchived(index); err != nil {
-				return nil, fmt.Errorf("failed to check if image is archived for slug '%s': %v", slug, err)
-			} else if exists {
-				return nil, fmt.Errorf("image '%s' is archived", slug)
-			}
-
-			// check if the image is published
-			if exists, err := s.db.ImageExistsUnpublished(index); err != nil {
-				return nil, fmt.Errorf("failed to check if image is published for slug '%s': %v", slug, err)
-			} else if exists {
-				return nil, fmt.Errorf("image '%s' is not published", slug)
-			}
-
-			// unknown error
-			return nil, fmt.Errorf("image '%s' was not found for unknown/unaccounted for reason", slug)
-		}
-		return nil, fmt.Errorf("failed to get image data for slug '%s': %v", slug, err)
-	}
-
-	// decrypt the sensitive fields in the image record
-	if err := s.cryptor.DecryptImageRecord(record); err != nil {
-		return nil, fmt.Errorf("failed to decrypt image record for slug '%s': %v", slug, err)
+		return nil, err
 	}
 
 	// Generate a signed URL for the image from object storage service
@@ -169,11 +182,28 @@ func (s *imageService) GetImageData(ctx context.Context, slug string, userPs map
 	)
 
 	// get the highest resolution signed URL
-	wg.Add(1)
-	go s.getObjectUrl(ctx, record.ObjectKey, record.Width, urlsCh, errCh, &wg)
+	// the untouched original still contains exif/gps metadata, so only curators and users
+	// with the download original permission get it: everyone else gets the sanitized display original.
+	originalKey := fmt.Sprintf("%s/%s_display%s", dir, slug, ext)
+	if _, ok := userPs[util.PermissionCurator]; ok {
+		originalKey = record.ObjectKey
+	} else if _, ok := userPs[util.PermissionDownloadOriginal]; ok {
+		originalKey = record.ObjectKey
+	}
+
+	// view-only users are capped at the view-only max width so full resolution
+	// files cannot be scraped from the signed urls
+	if downloadable || record.Width <= s.viewOnly {
+		wg.Add(1)
+		go s.getObjectUrl(ctx, originalKey, record.Width, urlsCh, errCh, &wg)
+	}
 
 	// get signed URLs for each resolution width
 	for _, width := range util.ResolutionWidthsImages {
+
+		if !downloadable && width > s.viewOnly {
+			continue
+		}
 		// build the object key for the resized image
 		resizedKey := fmt.Sprintf("%s/%s_w%d%s", dir, slug, width, ext)
 
@@ -182,25 +212,28 @@ func (s *imageService) GetImageData(ctx context.Context, slug string, userPs map
 	}
 
 	// get the signed URL for the blur placeholder image
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
+	// only needed for legacy images: the inline blur hash replaces it once the pipeline has computed it
+	if record.BlurHash == "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
 
-		blurKey := fmt.Sprintf("%s/%s_blur%s", dir, slug, ext)
+			blurKey := fmt.Sprintf("%s/%s_blur%s", dir, slug, ext)
 
-		url, err := s.store.GetSignedUrl(ctx, blurKey)
-		if err != nil {
-			errCh <- fmt.Errorf("failed to get signed URL for blur object key '%s': %v", blurKey, err)
-			return
-		}
+			url, err := s.store.GetSignedUrl(ctx, blurKey)
+			if err != nil {
+				errCh <- fmt.Errorf("failed to get signed URL for blur object key '%s': %v", blurKey, err)
+				return
+			}
 
-		if url == nil || url.String() == "" {
-			errCh <- fmt.Errorf("signed URL for blur object key '%s' is empty", blurKey)
-			return
-		}
+			if url == nil || url.String() == "" {
+				errCh <- fmt.Errorf("signed URL for blur object key '%s' is empty", blurKey)
+				return
+			}
 
-		blurCh <- url.String()
-	}()
+			blurCh <- url.String()
+		}()
+	}
 
 	// wait for all goroutines to finish
 	wg.Wait()
@@ -231,7 +264,7 @@ func (s *imageService) GetImageData(ctx context.Context, slug string, userPs map
 
 	// note: log only.  Still need to return the metadata to the frontend
 	blur, ok := <-blurCh
-	if !ok {
+	if !ok && record.BlurHash == "" {
 		log.Error("failed to get blur url")
 	}
 
@@ -248,22 +281,435 @@ func (s *imageService) GetImageData(ctx context.Context, slug string, userPs map
 		Height:      record.Height,
 		Size:        record.Size,
 		ImageDate:   record.ImageDate, // possibly empty, which is fine.
+		Date:        api.BuildPartialDate(record.ImageDate, record.ImageDatePrecision, record.ImageDateEndYear),
 		CreatedAt:   record.CreatedAt.String(),
 		UpdatedAt:   record.UpdatedAt.String(),
 		IsArchived:  record.IsArchived,
 		IsPublished: record.IsPublished,
+		Version:     record.Version,
+
+		Downloadable: downloadable,
 
 		ImageTargets: signedURLs,
 		BlurUrl:      blur,
+
+		BlurHash:      record.BlurHash,
+		DominantColor: record.DominantColor,
 	}
 
 	return image, nil
 }
 
+// GetImageDownload is the concrete implementation of the interface method which issues a short-lived
+// signed URL to download the untouched original image file if the user's permissions allow downloading,
+// which object storage serves as an attachment named from the decrypted title and date.
+func (s *imageService) GetImageDownload(ctx context.Context, slug string, userPs map[string]exo.PermissionRecord) (*api.ImageDownload, error) {
+
+	// get the image record the user has permission to view
+	record, err := s.findPermittedImage(slug, userPs)
+	if err != nil {
+		return nil, err
+	}
+
+	// check the user may download the original, not just view it
+	downloadable, err := s.isDownloadable(record.SlugIndex, userPs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !downloadable {
+		return nil, fmt.Errorf("user does not have permission to download image '%s'", slug)
+	}
+
+	if record.ObjectKey == "" {
+		return nil, fmt.Errorf("object key for image '%s' is empty", slug)
+	}
+
+	// get the extension from the object key since the original file name may not match the stored file
+	_, _, ext, _, err := pipeline.ParseObjectKey(record.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse object key for image '%s': %v", slug, err)
+	}
+
+	fileName := util.DownloadFileName(record.Title, record.ImageDate, record.Slug, ext)
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+
+	// the disposition is signed into the url so object storage serves the original as a named attachment
+	expiresAt := time.Now().UTC().Add(util.DownloadUrlExpiry)
+	url, err := s.multipart.PresignDownload(ctx, record.ObjectKey, disposition, util.DownloadUrlExpiry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get signed download URL for image '%s': %v", slug, err)
+	}
+
+	if url == nil || url.String() == "" {
+		return nil, fmt.Errorf("signed download URL for image '%s' is empty", slug)
+	}
+
+	return &api.ImageDownload{
+		SignedUrl:          url.String(),
+		FileName:           fileName,
+		ContentDisposition: disposition,
+		ExpiresAt:          expiresAt.Format(time.RFC3339),
+	}, nil
+}
+
+// StreamImageRendition is the concrete implementation of the interface method which checks the user's
+// permissions for the image and the requested rendition width, then opens the rendition from object
+// storage and passes it to fn to stream to the client.
+func (s *imageService) StreamImageRendition(
+	ctx context.Context,
+	slug string,
+	width int,
+	userPs map[string]exo.PermissionRecord,
+	fn func(rendition *ImageRendition) error,
+) error {
+
+	if fn == nil {
+		return fmt.Errorf("rendition stream function is required")
+	}
+
+	// only stored renditions from the resolution ladder may be streamed
+	if !slices.Contains(util.ResolutionWidthsImages, width) {
+		return fmt.Errorf("rendition width %d is not valid: must be one of %v", width, util.ResolutionWidthsImages)
+	}
+
+	// get the image record the user has permission to view
+	record, err := s.findPermittedImage(slug, userPs)
+	if err != nil {
+		return err
+	}
+
+	// view-only users are capped at the view-only max width
+	if width > s.viewOnly {
+		downloadable, err := s.isDownloadable(record.SlugIndex, userPs)
+		if err != nil {
+			return err
+		}
+
+		if !downloadable {
+			return fmt.Errorf("user does not have permission to view rendition width %d of image '%s'", width, slug)
+		}
+	}
+
+	if record.ObjectKey == "" {
+		return fmt.Errorf("object key for image '%s' is empty", slug)
+	}
+
+	dir, _, ext, _, err := pipeline.ParseObjectKey(record.ObjectKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse object key for image '%s': %v", slug, err)
+	}
+
+	// renditions are always re-encoded as jpeg by the pipeline, regardless of the extension
+	key := fmt.Sprintf("%s/%s_w%d%s", dir, record.Slug, width, ext)
+
+	return s.store.WithObject(ctx, key, func(r storage.ReadSeekCloser) error {
+		return fn(&ImageRendition{
+			ObjectKey:   key,
+			ETag:        RenditionETag(key, pipeline.JpegQuality),
+			ContentType: "image/jpeg",
+			Body:        r,
+		})
+	})
+}
+
+// StreamResizedImage is the concrete implementation of the interface method which checks the user's permissions
+// for the image, then streams the on-demand rendition for the spec to fn, generating it from the nearest larger
+// stored rendition and caching it in object storage if it does not exist yet.
+// Note: the spec must come from pipeline.NewRenditionSpec so it is snapped to the allowlisted parameters.
+func (s *imageService) StreamResizedImage(
+	ctx context.Context,
+	slug string,
+	spec pipeline.RenditionSpec,
+	userPs map[string]exo.PermissionRecord,
+	fn func(rendition *ImageRendition) error,
+) error {
+
+	tel, ok := ctx.Value(telemetry.TelemetryKey).(*telemetry.Telemetry)
+	if !ok {
+		s.logger.Error("failed to get telemetry for stream resized image method")
+	}
+	log := s.logger.With(tel.TelemetryFields()...)
+
+	if fn == nil {
+		return fmt.Errorf("rendition stream function is required")
+	}
+
+	// get the image record the user has permission to view
+	record, err := s.findPermittedImage(slug, userPs)
+	if err != nil {
+		return err
+	}
+
+	// images which have not been through the pipeline yet do not have stored renditions to build from
+	if record.Width <= 0 || record.Height <= 0 {
+		return fmt.Errorf("image '%s' has not been processed yet: renditions not found", slug)
+	}
+
+	// cap the width at the largest stored rendition, since renditions are never upscaled,
+	// and at the view-only max width unless the user may download the image
+	needed := spec.SourceWidth(record.Width, record.Height)
+	maxWidth := pipeline.NearestStoredWidth(needed)
+	if needed > s.viewOnly {
+		downloadable, err := s.isDownloadable(record.SlugIndex, userPs)
+		if err != nil {
+			return err
+		}
+
+		if !downloadable {
+			maxWidth = min(maxWidth, s.viewOnly)
+		}
+	}
+
+	if needed > maxWidth {
+		spec.MaxWidth = maxWidth
+	}
+
+	key := spec.DerivedKey(record.Slug)
+	etag := RenditionETag(key, spec.Quality)
+
+	// stream the cached rendition if it has already been generated
+	found, err := s.store.ListObjects(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to check for cached rendition '%s': %v", key, err)
+	}
+
+	if slices.Contains(found, key) {
+		return s.store.WithObject(ctx, key, func(r storage.ReadSeekCloser) error {
+			return fn(&ImageRendition{
+				ObjectKey:   key,
+				ETag:        etag,
+				ContentType: "image/jpeg",
+				Body:        r,
+			})
+		})
+	}
+
+	// generate from the nearest larger stored rendition rather than the (much larger) original
+	dir, _, ext, _, err := pipeline.ParseObjectKey(record.ObjectKey)
+	if err != nil {
+		return fmt.Errorf("failed to parse object key for image '%s': %v", slug, err)
+	}
+
+	sourceKey := fmt.Sprintf("%s/%s_w%d%s",
+		dir, record.Slug, pipeline.NearestStoredWidth(spec.SourceWidth(record.Width, record.Height)), ext)
+
+	var encoded []byte
+	if err := s.store.WithObject(ctx, sourceKey, func(r storage.ReadSeekCloser) error {
+
+		src, _, err := image.Decode(r)
+		if err != nil {
+			return fmt.Errorf("failed to image-format-decode (jpeg/png) object %s: %v", sourceKey, err)
+		}
+
+		encoded, err = pipeline.BuildRendition(src, spec)
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to build rendition '%s' from '%s': %v", key, sourceKey, err)
+	}
+
+	// cache the rendition for subsequent requests
+	// note: log only.  The rendition can still be served from memory
+	if err := s.store.PutObject(ctx, key, encoded, "image/jpeg"); err != nil {
+		log.Error(fmt.Sprintf("failed to cache rendition '%s'", key), "err", err.Error())
+	} else {
+		log.Info(fmt.Sprintf("successfully built and cached rendition '%s' from '%s'", key, sourceKey))
+	}
+
+	return fn(&ImageRendition{
+		ObjectKey:   key,
+		ETag:        etag,
+		ContentType: "image/jpeg",
+		Body:        nopCloser{bytes.NewReader(encoded)},
+	})
+}
+
+// findPermittedImage is a helper which retrieves and decrypts the image record for the slug if the
+// user has permission to view it.  If the record is not returned, it determines why so that
+// the error can be mapped to the correct http status.
+func (s *imageService) findPermittedImage(slug string, userPs map[string]exo.PermissionRecord) (*api.ImageRecord, error) {
+
+	// validate the slug
+	// redundant check, but good practice
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("image slug '%s' is not well-formed", slug)
+	}
+
+	// get blind index for the slug
+	index, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate blind index for image slug '%s': %v", slug, err)
+	}
+
+	// get image from database based on user's permissions
+	record, err := s.db.FindImageByPermissions(index, userPs)
+	if err != nil {
+		// all of the following presume the user is not a curator/admin.
+		if err == sql.ErrNoRows {
+
+			// check if the image exists at all
+			if exists, err := s.db.ImageExists(index); err != nil {
+				return nil, fmt.Errorf("failed to check if image exists for slug '%s': %v", slug, err)
+			} else if !exists {
+				return nil, fmt.Errorf("image '%s' was not found", slug)
+			}
+
+			// check if the image exists but the user has not permissions
+			if exists, err := s.db.ImageExistsNoPermissions(index, userPs); err != nil {
+				return nil, fmt.Errorf("failed to check if image exists for slug '%s': %v", slug, err)
+			} else if exists {
+				return nil, fmt.Errorf("user does not have permission to view image '%s'", slug)
+			}
+
+			// check if the image is archived
+			if exists, err := s.db.ImageExistsArchived(index); err != nil {
+				return nil, fmt.Errorf("failed to check if image is archived for slug '%s': %v", slug, err)
+			} else if exists {
+				return nil, fmt.Errorf("image '%s' is archived", slug)
+			}
+
+			// check if the image is published
+			if exists, err := s.db.ImageExistsUnpublished(index); err != nil {
+				return nil, fmt.Errorf("failed to check if image is published for slug '%s': %v", slug, err)
+			} else if exists {
+				return nil, fmt.Errorf("image '%s' is not published", slug)
+			}
+
+			// unknown error
+			return nil, fmt.Errorf("image '%s' was not found for unknown/unaccounted for reason", slug)
+		}
+		return nil, fmt.Errorf("failed to get image data for slug '%s': %v", slug, err)
+	}
+
+	// decrypt the sensitive fields in the image record
+	if err := s.cryptor.DecryptImageRecord(record); err != nil {
+		return nil, fmt.Errorf("failed to decrypt image record for slug '%s': %v", slug, err)
+	}
+
+	return record, nil
+}
+
+// isDownloadable is a helper which determines if the user may download the untouched original image:
+// curators and holders of the download original permission always may, otherwise at least one of the
+// user's permissions must be marked downloadable for the image.
+func (s *imageService) isDownloadable(slugIndex string, userPs map[string]exo.PermissionRecord) (bool, error) {
+
+	if _, ok := userPs[util.PermissionCurator]; ok {
+		return true, nil
+	}
+
+	if _, ok := userPs[util.PermissionDownloadOriginal]; ok {
+		return true, nil
+	}
+
+	downloadable, err := s.db.ImageDownloadable(slugIndex, userPs)
+	if err != nil {
+		return false, fmt.Errorf("failed to check if image is downloadable: %v", err)
+	}
+
+	return downloadable, nil
+}
+
 // BuildPlaceholder is the concrete implementation of the interface method which
 // builds the metadata for a placeholder image from an add image cmd.
 // The image processing pipeline will build the rest of the record upon ingestion of the image file.
-func (s *imageService) BuildPlaceholder(ctx context.Context, cmd api.AddMetaDataCmd) (*api.Placeholder, error) {
+func (s *imageService) BuildPlaceholder(ctx context.Context, cmd api.AddMetaDataCmd, uploadSessionId string) (*api.Placeholder, error) {
+
+	record, err := s.insertPlaceholder(cmd, uploadSessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	// files too large for a single put are uploaded in parts, so an interrupted upload can be resumed
+	placeholder := buildPlaceholderModel(record)
+	if record.Size > api.ImageMaxSize {
+		uploadId, err := s.multipart.Initiate(ctx, record.ObjectKey, record.FileType)
+		if err != nil {
+			return nil, fmt.Errorf("failed to initiate multipart upload for image object key '%s': %v", record.ObjectKey, err)
+		}
+
+		// part urls are requested as the parts are uploaded, since they expire
+		placeholder.Multipart = &api.MultipartUpload{
+			UploadId:  uploadId,
+			PartSize:  api.MultipartPartSize,
+			PartCount: api.MultipartPartCount(record.Size),
+		}
+	} else {
+		// generate a presigned put URL for the image file in object storage
+		putUrl, err := s.store.GetPreSignedPutUrl(ctx, record.ObjectKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get presigned put URL for image object key '%s': %v", record.ObjectKey, err)
+		}
+		placeholder.SignedUrl = putUrl.String() // the pre-signed PUT URL for the browser to upload the image file into object storage
+	}
+
+	return placeholder, nil
+}
+
+// UploadImage is the concrete implementation of the interface method which builds the placeholder image record,
+// streams the image file into object storage, and hands it to the upload pipeline.
+func (s *imageService) UploadImage(ctx context.Context, cmd api.AddMetaDataCmd, file io.Reader) (*api.Placeholder, error) {
+
+	// check the file is the type it claims to be before creating anything for it
+	body := bufio.NewReaderSize(file, sniffLength)
+	head, err := body.Peek(sniffLength)
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read image file: %v", err)
+	}
+	if !matchesFileType(head, strings.TrimSpace(cmd.FileType)) {
+		return nil, fmt.Errorf("image file is not a valid %s file", strings.TrimSpace(cmd.FileType))
+	}
+
+	record, err := s.insertPlaceholder(cmd, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// the declared size is enforced while streaming: it was checked against the limits, and object storage needs it up front
+	upload := &sizedReader{r: body, size: record.Size}
+	if err := s.multipart.Put(ctx, record.ObjectKey, record.FileType, upload, record.Size); err != nil {
+
+		// the file will never arrive: remove the placeholder rather than leave it waiting
+		if err := s.db.DeleteImage(record.SlugIndex); err != nil {
+			s.logger.Error("failed to remove placeholder image record after failed upload",
+				"image_id", record.Id,
+				"err", err.Error())
+		}
+
+		if upload.err != nil {
+			return nil, upload.err
+		}
+		return nil, fmt.Errorf("failed to upload image file to object storage: %v", err)
+	}
+
+	// the file must end at the declared size, or only part of it was uploaded
+	if _, err := body.Peek(1); err != io.EOF {
+		if err := s.store.DeleteObject(ctx, record.ObjectKey); err != nil {
+			s.logger.Error("failed to remove partial image file after failed upload",
+				"image_id", record.Id,
+				"err", err.Error())
+		}
+		if err := s.db.DeleteImage(record.SlugIndex); err != nil {
+			s.logger.Error("failed to remove placeholder image record after failed upload",
+				"image_id", record.Id,
+				"err", err.Error())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read image file: %v", err)
+		}
+		return nil, fmt.Errorf("image file is too large: it is larger than the declared size of %d bytes", record.Size)
+	}
+
+	// hand off to the upload pipeline the same as a notification of an upload to a presigned url
+	s.uploads <- pipeline.UploadCmd{ObjectKey: record.ObjectKey}
+
+	return buildPlaceholderModel(record), nil
+}
+
+// insertPlaceholder is a helper which validates the add image command, checking the configured size limit
+// for its file type, and inserts the placeholder image record for it, returning the plaintext record.
+// The upload session is recorded in the same insert, so a session's placeholder is never left without it.
+func (s *imageService) insertPlaceholder(cmd api.AddMetaDataCmd, uploadSessionId string) (*api.ImageRecord, error) {
 
 	// validate the created metadata
 	// should be a redundant check, but good practice
@@ -271,6 +717,11 @@ func (s *imageService) BuildPlaceholder(ctx context.Context, cmd api.AddMetaData
 		return nil, fmt.Errorf("failed to validate image record: %v", err)
 	}
 
+	// check the configured limit for the file type, eg, tiffs may be much larger than jpegs
+	if limit := s.sizeLimits[strings.TrimSpace(cmd.FileType)]; cmd.Size > limit {
+		return nil, fmt.Errorf("image file is too large: %s files must be less than or equal to %d bytes", strings.TrimSpace(cmd.FileType), limit)
+	}
+
 	// notification from the object storage service
 	id, err := uuid.NewRandom()
 	if err != nil {
@@ -315,6 +766,7 @@ func (s *imageService) BuildPlaceholder(ctx context.Context, cmd api.AddMetaData
 		UpdatedAt:   data.CustomTime{Time: now}, // updated at is the same as created at for a new record
 		IsArchived:  false,                      // default to not archived
 		IsPublished: false,                      // default to not published --> image prcessing pipeline will publish the image when processing is complete
+		Version:     1,                          // first version, incremented on every edit
 	}
 
 	// get the blind index for the slug
@@ -335,18 +787,18 @@ func (s *imageService) BuildPlaceholder(ctx context.Context, cmd api.AddMetaData
 	}
 
 	// insert record into the database
-	if err := s.db.InsertImage(copy); err != nil {
+	if err := s.db.InsertImage(copy, uploadSessionId); err != nil {
 		return nil, fmt.Errorf("failed to insert image record into database: %v", err)
 	}
 
-	// generate a presigned put URL for the image file in object storage
-	putUrl, err := s.store.GetPreSignedPutUrl(ctx, record.ObjectKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get presigned put URL for image object key '%s': %v", record.ObjectKey, err)
-	}
+	return &record, nil
+}
 
-	// build image meta data struct to return
-	data := &api.Placeholder{
+// buildPlaceholderModel is a helper which builds the placeholder model returned to the client from
+// the plaintext placeholder image record, without the means to upload its file.
+func buildPlaceholderModel(record *api.ImageRecord) *api.Placeholder {
+
+	return &api.Placeholder{
 		Id:          record.Id,
 		Title:       record.Title,
 		Description: record.Description,
@@ -359,17 +811,30 @@ func (s *imageService) BuildPlaceholder(ctx context.Context, cmd api.AddMetaData
 		UpdatedAt:   record.UpdatedAt.Format(time.RFC3339), // format the time as RFC3339
 		IsArchived:  record.IsArchived,
 		IsPublished: record.IsPublished,
-		SignedUrl:   putUrl.String(), // the pre-signed PUT URL for the browser to upload the image file into object storage
 	}
+}
+
+// IncrementImageVersion is the concrete implementation of the interface method which
+// increments an image's version within the unit of work's transaction.
+func (s *imageService) IncrementImageVersion(ctx context.Context, tx transaction.Tx, imageId string, version int) error {
 
-	return data, nil
+	ok, err := s.db.IncrementImageVersion(ctx, tx, imageId, version)
+	if err != nil {
+		return fmt.Errorf("failed to increment version of image '%s': %v", imageId, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("image '%s' version %d is stale: it was changed by someone else", imageId, version)
+	}
+
+	return nil
 }
 
 // UpdateImageData is the concrete implementation of the interface method which
 // updates an existing image record in the database.
 // NOTE: the only reason existing is passed is so that we check if the ojbectstore needs to be updated
 // due to a new ojbect key being generated.
-func (s *imageService) UpdateImageData(ctx context.Context, existing *api.ImageData, updated *api.ImageRecord) error {
+func (s *imageService) UpdateImageData(ctx context.Context, tx transaction.Tx, existing *api.ImageData, updated *api.ImageRecord) error {
 
 	// create function scoped logger
 	// add telemetry fields from context if exists
@@ -391,6 +856,7 @@ func (s *imageService) UpdateImageData(ctx context.Context, existing *api.ImageD
 	if existing.Title == updated.Title &&
 		existing.Description == updated.Description &&
 		existing.ImageDate == updated.ImageDate &&
+		!imageDateChanged(existing, updated) &&
 		existing.ObjectKey == updated.ObjectKey &&
 		existing.IsArchived == updated.IsArchived &&
 		existing.IsPublished == updated.IsPublished {
@@ -409,6 +875,14 @@ func (s *imageService) UpdateImageData(ctx context.Context, existing *api.ImageD
 	// set the slug index for the updated image record
 	updated.SlugIndex = index
 
+	// keep the sortable date key in step with the image date and its precision
+	date := api.BuildPartialDate(updated.ImageDate, updated.ImageDatePrecision, updated.ImageDateEndYear)
+	if date != nil {
+		updated.ImageDateKey = date.DateKey()
+	} else {
+		updated.ImageDateKey = api.BuildImageDateKey(updated.ImageDate)
+	}
+
 	// need to encrypt a copy of the updated image record
 	encrypted := *updated
 
@@ -418,7 +892,7 @@ func (s *imageService) UpdateImageData(ctx context.Context, existing *api.ImageD
 	}
 
 	// update the image record in the database
-	if err := s.db.UpdateImage(encrypted); err != nil {
+	if err := s.db.UpdateImage(ctx, tx, encrypted); err != nil {
 		return fmt.Errorf("failed to update image record id '%s' in database: %v", existing.Id, err)
 	}
 
@@ -440,10 +914,22 @@ func (s *imageService) UpdateImageData(ctx context.Context, existing *api.ImageD
 			UpdatedObjKey: updated.ObjectKey,
 			MoveRequired:  true,
 			RetryCount:    1, // first attempt
+
+			// staged images may never have made it far enough in the pipeline to compute placeholders
+			PlaceholdersRequired: existing.BlurHash == "",
 		}
 
-		// send to reprocessing queue
-		s.reprocess <- cmd
+		// images dated to a decade or circa range belong in no year album
+		if date != nil {
+			_, ok := date.YearAlbum()
+			cmd.NoYearAlbum = !ok
+		}
+
+		// send to reprocessing queue once the update commits:
+		// the pipeline must not move the file for an update which is rolled back
+		tx.AfterCommit(func() {
+			s.reprocess <- cmd
+		})
 	}
 
 	return nil
@@ -507,3 +993,57 @@ func (s *imageService) DeleteImage(ctx context.Context, imageData *api.ImageData
 	return nil
 
 }
+
+// sniffLength is the number of bytes read from the start of an image file to check its content matches its file type.
+const sniffLength int = 512
+
+// matchesFileType is a helper which checks the start of an image file is consistent with its declared file type.
+// Note: this guards against mislabeled or non-image files, the pipeline's decoding is the definitive check.
+func matchesFileType(head []byte, fileType string) bool {
+
+	switch fileType {
+	case "image/tiff":
+		// the content sniffing algorithm does not recognize tiffs: little or big endian byte order marks
+		return bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*"))
+	case "image/svg+xml":
+		// svgs are xml text, which may open with a declaration, comments, or a doctype
+		return bytes.Contains(bytes.ToLower(head), []byte("<svg"))
+	default:
+		return http.DetectContentType(head) == fileType
+	}
+}
+
+// sizedReader is a helper which streams at most size bytes of an image file, failing the upload if the file
+// is shorter than the size declared, and checked against the limits, when its placeholder was created.
+// Note: object storage stops reading at the declared size, so a longer file is caught once the upload returns.
+type sizedReader struct {
+	r    *bufio.Reader
+	size int64
+	read int64
+	err  error // why the upload was failed, if the file was not the declared size
+}
+
+// Read implements io.Reader, returning an error if the file ends before the declared size.
+func (sr *sizedReader) Read(p []byte) (int, error) {
+
+	if sr.err != nil {
+		return 0, sr.err
+	}
+
+	if sr.read >= sr.size {
+		return 0, io.EOF
+	}
+
+	if remaining := sr.size - sr.read; int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+
+	n, err := sr.r.Read(p)
+	sr.read += int64(n)
+	if err == io.EOF && sr.read < sr.size {
+		sr.err = fmt.Errorf("image file must be the declared size of %d bytes, but it is only %d bytes", sr.size, sr.read)
+		return n, sr.err
+	}
+
+	return n, err
+}
@@ -57,36 +57,42 @@ func (ise *imageServiceErr) HandleImageServiceError(ctx context.Context, err err
 		return
 	}
 
+	e := connect.ErrorHttp{
+		StatusCode: imageServiceErrorStatus(err),
+		Message:    err.Error(),
+	}
+	e.SendJsonErr(w)
+}
+
+// imageServiceErrorStatus is a helper which maps an image service error to its http status code,
+// eg, for the per-image results of a bulk operation, which cannot each be written as a response.
+func imageServiceErrorStatus(err error) int {
+
 	switch {
 	case strings.Contains(err.Error(), "no access") ||
 		strings.Contains(err.Error(), "not have permission") ||
 		strings.Contains(err.Error(), "not have correct permission") ||
 		strings.Contains(err.Error(), "published"):
-		e := connect.ErrorHttp{
-			StatusCode: http.StatusForbidden,
-			Message:    err.Error(),
-		}
-		e.SendJsonErr(w)
-		return
+		return http.StatusForbidden
 
 	case strings.Contains(err.Error(), "not found") ||
 		strings.Contains(err.Error(), "does not exist") ||
 		strings.Contains(err.Error(), "no albums found") ||
 		strings.Contains(err.Error(), "no permissions found"):
-		e := connect.ErrorHttp{
-			StatusCode: http.StatusNotFound,
-			Message:    err.Error(),
-		}
-		e.SendJsonErr(w)
-		return
+		return http.StatusNotFound
 
 	case strings.Contains(err.Error(), "is archived"):
-		e := connect.ErrorHttp{
-			StatusCode: http.StatusGone,
-			Message:    err.Error(),
-		}
-		e.SendJsonErr(w)
-		return
+		return http.StatusGone
+
+	case strings.Contains(err.Error(), "is stale"):
+		return http.StatusPreconditionFailed
+
+	case strings.Contains(err.Error(), "already been uploaded") ||
+		strings.Contains(err.Error(), "has not been uploaded"):
+		return http.StatusConflict
+
+	case strings.Contains(err.Error(), "too large"):
+		return http.StatusRequestEntityTooLarge
 
 	case strings.Contains(err.Error(), "not well-formed") ||
 		strings.Contains(err.Error(), "invalid") ||
@@ -94,19 +100,9 @@ func (ise *imageServiceErr) HandleImageServiceError(ctx context.Context, err err
 		strings.Contains(err.Error(), "not a valid") ||
 		strings.Contains(err.Error(), "must be") ||
 		strings.Contains(err.Error(), "required"):
-		e := connect.ErrorHttp{
-			StatusCode: http.StatusUnprocessableEntity,
-			Message:    err.Error(),
-		}
-		e.SendJsonErr(w)
-		return
+		return http.StatusUnprocessableEntity
 
 	default:
-		e := connect.ErrorHttp{
-			StatusCode: http.StatusInternalServerError,
-			Message:    err.Error(),
-		}
-		e.SendJsonErr(w)
-		return
+		return http.StatusInternalServerError
 	}
 }
@@ -0,0 +1,31 @@
+package picture
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestImageServiceErrorStatus(t *testing.T) {
+
+	tests := []struct {
+		err  string
+		want int
+	}{
+		// a version mismatch against If-Match is a failed precondition, not a conflict
+		{"image 'abc' version 3 is stale: it was changed by someone else", http.StatusPreconditionFailed},
+		{"image 'abc' not found", http.StatusNotFound},
+		{"image 'abc' is archived", http.StatusGone},
+		{"user does not have permission to edit image 'abc'", http.StatusForbidden},
+		{"title must be alphanumeric and spaces", http.StatusUnprocessableEntity},
+		{"failed to update image record", http.StatusInternalServerError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.err, func(t *testing.T) {
+			if got := imageServiceErrorStatus(errors.New(tc.err)); got != tc.want {
+				t.Errorf("imageServiceErrorStatus(%q) = %d, want %d", tc.err, got, tc.want)
+			}
+		})
+	}
+}
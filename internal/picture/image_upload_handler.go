@@ -0,0 +1,159 @@
+package picture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+const (
+	uploadMetadataPart = "metadata" // form field holding the AddMetaDataCmd json, which must come before the file
+	uploadFilePart     = "file"     // form field holding the image file
+
+	uploadFormOverhead = 1024 * 1024 // allowance for the metadata and multipart boundaries on top of the largest image file, 1 MB
+)
+
+// HandleUpload is the concrete implementation of the interface method which handles the POST request
+// to upload an image's metadata and file together as multipart/form-data, for clients which cannot
+// upload to a presigned url.  The file is streamed into object storage rather than buffered, then
+// handed to the upload pipeline the same as a file uploaded to a presigned url.
+func (h *imageHandler) HandleUpload(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	if r.Method != http.MethodPost {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(writeImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(writeImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// read the form part by part: ParseMultipartForm would buffer the file to memory or disk
+	r.Body = http.MaxBytesReader(w, r.Body, api.ImageUploadMaxSize+uploadFormOverhead)
+	form, err := r.MultipartReader()
+	if err != nil {
+		log.Error("failed to read multipart form", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "request body must be multipart/form-data",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// the metadata comes first so the file can be checked against it as it streams
+	part, err := form.NextPart()
+	if err != nil || part.FormName() != uploadMetadataPart {
+		log.Error("multipart form does not begin with the image metadata")
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("multipart form must begin with the '%s' field, followed by the '%s' field", uploadMetadataPart, uploadFilePart),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	var cmd api.AddMetaDataCmd
+	if err := json.NewDecoder(part).Decode(&cmd); err != nil {
+		log.Error("failed to decode image metadata", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode image metadata",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the incoming data
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate create image metadata command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// resolve the albums and permissions before uploading, so a bad slug does not leave an orphaned image
+	albumIds, permissionIds, err := h.resolveUploadSessionXrefs(ctx, authedUser.Claims.Subject, api.UploadSessionCmd{
+		Images: []api.AddMetaDataCmd{cmd},
+	})
+	if err != nil {
+		log.Error("failed to resolve image albums and permissions", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	part, err = form.NextPart()
+	if err != nil || part.FormName() != uploadFilePart {
+		log.Error("multipart form does not include the image file after the metadata")
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    fmt.Sprintf("multipart form must include the '%s' field after the '%s' field", uploadFilePart, uploadMetadataPart),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+	defer part.Close()
+
+	placeholder, err := h.svc.UploadImage(ctx, cmd, part)
+	if err != nil {
+		log.Error("failed to upload image file", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	for _, albumId := range albumIds[0] {
+		if err := h.albums.InsertAlbumImageXref(ctx, albumId, placeholder.Id); err != nil {
+			log.Error(fmt.Sprintf("failed to add image '%s' to album '%s'", placeholder.Id, albumId), "err", err.Error())
+		}
+	}
+
+	for _, permissionId := range permissionIds[0] {
+		if err := h.svc.InsertImagePermissionXref(placeholder.Id, permissionId); err != nil {
+			log.Error(fmt.Sprintf("failed to add image '%s' to permission '%s'", placeholder.Id, permissionId), "err", err.Error())
+		}
+	}
+
+	log.Info("successfully uploaded image file",
+		"image_slug", placeholder.Slug,
+		"image_id", placeholder.Id,
+		"size", placeholder.Size,
+	)
+
+	connect.SendJsonSuccess(w, http.StatusOK, placeholder)
+}
@@ -0,0 +1,54 @@
+package picture
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// MultipartRepository is an interface for data operations related to multipart uploads of placeholder images.
+type MultipartRepository interface {
+
+	// FindMultipartImage retrieves the fields of an image needed to manage its multipart upload by its slug index.
+	// Note: the object key field is encrypted.
+	FindMultipartImage(slugIndex string) (*api.MultipartImageRecord, error)
+}
+
+// NewMultipartRepository creates a new MultipartRepository instance, returning a pointer to the concrete implementation.
+func NewMultipartRepository(db *sql.DB) MultipartRepository {
+	return &multipartRepository{
+		sql: db,
+	}
+}
+
+var _ MultipartRepository = (*multipartRepository)(nil)
+
+// multipartRepository is the concrete implementation of the MultipartRepository interface.
+type multipartRepository struct {
+	sql *sql.DB
+}
+
+// FindMultipartImage retrieves the fields of an image needed to manage its multipart upload by its slug index.
+func (r *multipartRepository) FindMultipartImage(slugIndex string) (*api.MultipartImageRecord, error) {
+
+	qry := `
+		SELECT
+			uuid,
+			file_type,
+			object_key,
+			size
+		FROM image
+		WHERE slug_index = ?`
+
+	record, err := data.SelectOneRecord[api.MultipartImageRecord](r.sql, qry, slugIndex)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("image not found")
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
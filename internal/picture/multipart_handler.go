@@ -0,0 +1,184 @@
+package picture
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// HandleMultipart is the concrete implementation of the interface method which handles the multipart upload
+// of a placeholder image too large for a single presigned PUT:
+//   - GET /images/{slug}/multipart: the upload in progress and the parts already uploaded, to resume it
+//   - POST /images/{slug}/multipart: restart the upload if it was aborted or abandoned
+//   - POST /images/{slug}/multipart/parts: presigned PUT urls for parts
+//   - POST /images/{slug}/multipart/complete: assemble the parts, which triggers the upload pipeline
+//   - DELETE /images/{slug}/multipart: abort the upload, discarding the parts
+func (h *imageHandler) HandleMultipart(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(writeImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(writeImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	slug := r.PathValue("slug")
+	log = log.With("image_slug", slug)
+
+	switch action := r.PathValue("action"); {
+	case r.Method == http.MethodGet && action == "":
+		h.handleGetMultipartUpload(ctx, log, w, slug)
+	case r.Method == http.MethodPost && action == "":
+		h.handleRestartMultipartUpload(ctx, log, w, slug)
+	case r.Method == http.MethodPost && action == "parts":
+		h.handlePresignMultipartParts(ctx, log, w, r, slug)
+	case r.Method == http.MethodPost && action == "complete":
+		h.handleCompleteMultipartUpload(ctx, log, w, r, slug)
+	case r.Method == http.MethodDelete && action == "":
+		h.handleAbortMultipartUpload(ctx, log, w, slug)
+	default:
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+	}
+}
+
+// handleGetMultipartUpload responds with the image's multipart upload in progress and the parts already uploaded.
+func (h *imageHandler) handleGetMultipartUpload(ctx context.Context, log *slog.Logger, w http.ResponseWriter, slug string) {
+
+	upload, err := h.svc.GetMultipartUpload(ctx, slug)
+	if err != nil {
+		log.Error("failed to get multipart upload", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	connect.SendJsonSuccess(w, http.StatusOK, upload)
+}
+
+// handleRestartMultipartUpload starts a new multipart upload for the image if none is in progress.
+func (h *imageHandler) handleRestartMultipartUpload(ctx context.Context, log *slog.Logger, w http.ResponseWriter, slug string) {
+
+	upload, err := h.svc.RestartMultipartUpload(ctx, slug)
+	if err != nil {
+		log.Error("failed to restart multipart upload", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	log.Info("multipart upload in progress", "upload_id", upload.UploadId, "uploaded_parts", len(upload.Parts))
+
+	connect.SendJsonSuccess(w, http.StatusOK, upload)
+}
+
+// handlePresignMultipartParts responds with presigned PUT urls for the requested parts of the image's multipart upload.
+func (h *imageHandler) handlePresignMultipartParts(ctx context.Context, log *slog.Logger, w http.ResponseWriter, r *http.Request, slug string) {
+
+	var cmd api.MultipartPartsCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode request body", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode request body",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate multipart parts command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	urls, err := h.svc.PresignMultipartParts(ctx, slug, cmd.PartNumbers)
+	if err != nil {
+		log.Error("failed to presign multipart upload parts", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	connect.SendJsonSuccess(w, http.StatusOK, urls)
+}
+
+// handleCompleteMultipartUpload assembles the uploaded parts of the image's multipart upload into the image file.
+func (h *imageHandler) handleCompleteMultipartUpload(ctx context.Context, log *slog.Logger, w http.ResponseWriter, r *http.Request, slug string) {
+
+	var cmd api.CompleteMultipartCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode request body", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode request body",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate complete multipart upload command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	if err := h.svc.CompleteMultipartUpload(ctx, slug, cmd.Parts); err != nil {
+		log.Error("failed to complete multipart upload", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	log.Info("successfully completed multipart upload", "part_count", len(cmd.Parts))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleAbortMultipartUpload abandons the image's multipart upload, discarding any parts which have been uploaded.
+func (h *imageHandler) handleAbortMultipartUpload(ctx context.Context, log *slog.Logger, w http.ResponseWriter, slug string) {
+
+	if err := h.svc.AbortMultipartUpload(ctx, slug); err != nil {
+		log.Error("failed to abort multipart upload", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	log.Info("successfully aborted multipart upload")
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,416 @@
+package picture
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/crypt"
+	"github.com/tdeslauriers/pixie/internal/multipart"
+	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// MultipartService is the interface for managing the multipart uploads of placeholder images too large
+// for a single presigned PUT.  The placeholder's multipart upload is initiated when the placeholder is created.
+type MultipartService interface {
+
+	// GetMultipartUpload retrieves the image's multipart upload in progress, including the parts already uploaded,
+	// eg, so a client can resume an interrupted upload.
+	GetMultipartUpload(ctx context.Context, slug string) (*api.MultipartUpload, error)
+
+	// RestartMultipartUpload initiates a new multipart upload for the image if none is in progress, eg,
+	// because it was aborted or abandoned, returning the upload in progress.
+	RestartMultipartUpload(ctx context.Context, slug string) (*api.MultipartUpload, error)
+
+	// PresignMultipartParts generates presigned PUT urls for uploading parts of the image's multipart upload.
+	PresignMultipartParts(ctx context.Context, slug string, partNumbers []int) ([]api.MultipartPartUrl, error)
+
+	// CompleteMultipartUpload assembles the uploaded parts of the image's multipart upload into the image file,
+	// which triggers the upload pipeline the same as a single presigned PUT.
+	CompleteMultipartUpload(ctx context.Context, slug string, parts []api.MultipartPart) error
+
+	// AbortMultipartUpload abandons the image's multipart upload, discarding any parts which have been uploaded.
+	// The placeholder is not deleted: a new multipart upload may be started for it.
+	AbortMultipartUpload(ctx context.Context, slug string) error
+
+	// AbortAbandonedMultipartUploads aborts multipart uploads initiated longer ago than the expiry,
+	// so the parts of abandoned uploads do not accumulate in object storage.  Returns the number aborted.
+	AbortAbandonedMultipartUploads(ctx context.Context, expiry time.Duration) (int, error)
+}
+
+// NewMultipartService creates a new MultipartService instance, returning a pointer to the concrete implementation.
+func NewMultipartService(
+	sql *sql.DB,
+	i data.Indexer,
+	c data.Cryptor,
+	obj storage.ObjectStorage,
+	mp multipart.Store,
+) MultipartService {
+	return &multipartService{
+		db:        NewMultipartRepository(sql),
+		indexer:   i,
+		cryptor:   crypt.NewCryptor(c),
+		store:     obj,
+		multipart: mp,
+
+		logger: slog.Default().
+			With(slog.String(util.PackageKey, util.PackagePicture)).
+			With(slog.String(util.ComponentKey, util.ComponentMultipart)),
+	}
+}
+
+var _ MultipartService = (*multipartService)(nil)
+
+// multipartService is the concrete implementation of the MultipartService interface.
+type multipartService struct {
+	db        MultipartRepository
+	indexer   data.Indexer
+	cryptor   crypt.Cryptor
+	store     storage.ObjectStorage
+	multipart multipart.Store
+
+	logger *slog.Logger
+}
+
+// multipartImage is a model representing a placeholder image waiting for its file to be uploaded in parts.
+type multipartImage struct {
+	Slug      string
+	FileType  string
+	ObjectKey string // decrypted
+	Size      int64  // declared size of the file in bytes
+	PartCount int
+}
+
+// GetMultipartUpload is the concrete implementation of the interface method which retrieves
+// the image's multipart upload in progress, including the parts already uploaded.
+func (s *multipartService) GetMultipartUpload(ctx context.Context, slug string) (*api.MultipartUpload, error) {
+
+	img, err := s.findMultipartImage(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	upload, err := s.findUpload(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.buildMultipartUpload(ctx, img, upload.UploadId)
+}
+
+// RestartMultipartUpload is the concrete implementation of the interface method which initiates
+// a new multipart upload for the image if none is in progress.
+func (s *multipartService) RestartMultipartUpload(ctx context.Context, slug string) (*api.MultipartUpload, error) {
+
+	img, err := s.findMultipartImage(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	// resume the upload in progress rather than discarding its parts
+	if upload, err := s.findUpload(ctx, img); err == nil {
+		return s.buildMultipartUpload(ctx, img, upload.UploadId)
+	}
+
+	// the file may have been uploaded, but not processed yet
+	keys, err := s.store.ListObjects(ctx, img.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check if image '%s' has already been uploaded: %v", slug, err)
+	}
+	if slices.Contains(keys, img.ObjectKey) {
+		return nil, fmt.Errorf("image '%s' has already been uploaded", slug)
+	}
+
+	uploadId, err := s.multipart.Initiate(ctx, img.ObjectKey, img.FileType)
+	if err != nil {
+		return nil, err
+	}
+
+	return &api.MultipartUpload{
+		UploadId:  uploadId,
+		PartSize:  api.MultipartPartSize,
+		PartCount: img.PartCount,
+	}, nil
+}
+
+// PresignMultipartParts is the concrete implementation of the interface method which generates
+// presigned PUT urls for uploading parts of the image's multipart upload.
+func (s *multipartService) PresignMultipartParts(ctx context.Context, slug string, partNumbers []int) ([]api.MultipartPartUrl, error) {
+
+	img, err := s.findMultipartImage(slug)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range partNumbers {
+		if n < 1 || n > img.PartCount {
+			return nil, fmt.Errorf("part number must be between 1 and %d, got %d", img.PartCount, n)
+		}
+	}
+
+	upload, err := s.findUpload(ctx, img)
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]api.MultipartPartUrl, 0, len(partNumbers))
+	for _, n := range partNumbers {
+		u, err := s.multipart.PresignPart(ctx, img.ObjectKey, upload.UploadId, n)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, api.MultipartPartUrl{PartNumber: n, SignedUrl: u.String()})
+	}
+
+	return urls, nil
+}
+
+// CompleteMultipartUpload is the concrete implementation of the interface method which assembles
+// the uploaded parts of the image's multipart upload into the image file.
+func (s *multipartService) CompleteMultipartUpload(ctx context.Context, slug string, parts []api.MultipartPart) error {
+
+	img, err := s.findMultipartImage(slug)
+	if err != nil {
+		return err
+	}
+
+	// every part of the file must be listed, otherwise object storage would assemble a truncated file
+	if len(parts) != img.PartCount {
+		return fmt.Errorf("all %d parts are required to complete the upload, got %d", img.PartCount, len(parts))
+	}
+
+	upload, err := s.findUpload(ctx, img)
+	if err != nil {
+		return err
+	}
+
+	uploaded, err := s.multipart.ListParts(ctx, img.ObjectKey, upload.UploadId)
+	if err != nil {
+		return err
+	}
+
+	etags := make(map[int]string, len(uploaded))
+	sizes := make(map[int]int64, len(uploaded))
+	for _, p := range uploaded {
+		etags[p.PartNumber] = strings.Trim(p.ETag, `"`)
+		sizes[p.PartNumber] = p.Size
+	}
+
+	var total int64
+	complete := make([]multipart.Part, 0, len(parts))
+	for _, p := range parts {
+		if p.PartNumber > img.PartCount {
+			return fmt.Errorf("part number must be between 1 and %d, got %d", img.PartCount, p.PartNumber)
+		}
+
+		etag, ok := etags[p.PartNumber]
+		if !ok {
+			return fmt.Errorf("part %d has not been uploaded", p.PartNumber)
+		}
+		if etag != strings.Trim(p.ETag, `"`) {
+			return fmt.Errorf("part %d etag does not match the part uploaded: it must be uploaded again", p.PartNumber)
+		}
+
+		complete = append(complete, multipart.Part{PartNumber: p.PartNumber, ETag: p.ETag})
+		total += sizes[p.PartNumber]
+
+		// the declared size is only checked against the file type's limit when the upload is initiated,
+		// so the parts actually uploaded must add up to it, otherwise any size of file could be uploaded
+		if p.PartNumber < img.PartCount && sizes[p.PartNumber] != api.MultipartPartSize {
+			return s.abortInvalidUpload(ctx, img, upload,
+				fmt.Errorf("part %d is %d bytes: every part except the last must be %d bytes", p.PartNumber, sizes[p.PartNumber], api.MultipartPartSize))
+		}
+	}
+
+	if total != img.Size {
+		return s.abortInvalidUpload(ctx, img, upload,
+			fmt.Errorf("uploaded parts total %d bytes: they must be the declared size of %d bytes", total, img.Size))
+	}
+
+	if err := s.multipart.Complete(ctx, img.ObjectKey, upload.UploadId, complete); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AbortMultipartUpload is the concrete implementation of the interface method which abandons
+// the image's multipart upload, discarding any parts which have been uploaded.
+func (s *multipartService) AbortMultipartUpload(ctx context.Context, slug string) error {
+
+	img, err := s.findMultipartImage(slug)
+	if err != nil {
+		return err
+	}
+
+	upload, err := s.findUpload(ctx, img)
+	if err != nil {
+		return err
+	}
+
+	if err := s.multipart.Abort(ctx, img.ObjectKey, upload.UploadId); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// AbortAbandonedMultipartUploads is the concrete implementation of the interface method which aborts
+// multipart uploads initiated longer ago than the expiry.
+func (s *multipartService) AbortAbandonedMultipartUploads(ctx context.Context, expiry time.Duration) (int, error) {
+
+	uploads, err := s.multipart.ListUploads(ctx, "uploads/")
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := time.Now().UTC().Add(-expiry)
+	aborted := 0
+	for _, u := range uploads {
+
+		// bail on shutdown
+		if ctx.Err() != nil {
+			return aborted, ctx.Err()
+		}
+
+		if u.Initiated.After(cutoff) {
+			continue
+		}
+
+		// one failure should not stop the others being cleaned up: it will be retried next time
+		if err := s.multipart.Abort(ctx, u.ObjectKey, u.UploadId); err != nil {
+			s.logger.Error("failed to abort abandoned multipart upload",
+				"image_object_key", u.ObjectKey,
+				"err", err.Error())
+			continue
+		}
+		aborted++
+	}
+
+	return aborted, nil
+}
+
+// abortInvalidUpload is a helper which aborts a multipart upload whose parts cannot be completed,
+// so they are not left in object storage, returning the reason it is invalid.
+func (s *multipartService) abortInvalidUpload(ctx context.Context, img *multipartImage, upload *multipart.Upload, invalid error) error {
+
+	if err := s.multipart.Abort(ctx, img.ObjectKey, upload.UploadId); err != nil {
+		return fmt.Errorf("%v: %v", invalid, err)
+	}
+
+	s.logger.Warn("aborted invalid multipart upload",
+		"image_slug", img.Slug,
+		"err", invalid.Error())
+
+	return fmt.Errorf("%v: the upload has been aborted", invalid)
+}
+
+// findMultipartImage is a helper which retrieves the placeholder image for the slug, checking its file
+// has not been uploaded yet and is large enough to be uploaded in parts.
+func (s *multipartService) findMultipartImage(slug string) (*multipartImage, error) {
+
+	if err := validate.ValidateUuid(slug); err != nil {
+		return nil, fmt.Errorf("invalid image slug: %s", slug)
+	}
+
+	index, err := s.indexer.ObtainBlindIndex(slug)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate blind index for image slug '%s': %v", slug, err)
+	}
+
+	record, err := s.db.FindMultipartImage(index)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, fmt.Errorf("image '%s' not found", slug)
+		}
+		return nil, fmt.Errorf("failed to retrieve image '%s' from database: %v", slug, err)
+	}
+
+	objectKey, err := s.cryptor.DecryptImageField(record.ObjectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt object key for image '%s': %v", record.Id, err)
+	}
+
+	// the pipeline moves the file out of 'uploads' once it has processed it
+	dir, _, _, _, err := pipeline.ParseObjectKey(objectKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse object key for image '%s': %v", record.Id, err)
+	}
+	if dir != "uploads" {
+		return nil, fmt.Errorf("image '%s' has already been uploaded", slug)
+	}
+
+	if record.Size <= api.ImageMaxSize {
+		return nil, fmt.Errorf("image '%s' must be uploaded with its presigned PUT url, it is not large enough to upload in parts", slug)
+	}
+
+	return &multipartImage{
+		Slug:      slug,
+		FileType:  record.FileType,
+		ObjectKey: objectKey,
+		Size:      record.Size,
+		PartCount: api.MultipartPartCount(record.Size),
+	}, nil
+}
+
+// findUpload is a helper which finds the multipart upload in progress for the image's file.
+// If there is more than one, eg, the client restarted without aborting, the most recent is used.
+func (s *multipartService) findUpload(ctx context.Context, img *multipartImage) (*multipart.Upload, error) {
+
+	uploads, err := s.multipart.ListUploads(ctx, img.ObjectKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *multipart.Upload
+	for i := range uploads {
+		// the prefix could match a longer key
+		if uploads[i].ObjectKey != img.ObjectKey {
+			continue
+		}
+		if latest == nil || uploads[i].Initiated.After(latest.Initiated) {
+			latest = &uploads[i]
+		}
+	}
+
+	if latest == nil {
+		return nil, fmt.Errorf("multipart upload for image '%s' not found", img.Slug)
+	}
+
+	return latest, nil
+}
+
+// buildMultipartUpload is a helper which builds the multipart upload model, including the parts already uploaded.
+func (s *multipartService) buildMultipartUpload(ctx context.Context, img *multipartImage, uploadId string) (*api.MultipartUpload, error) {
+
+	parts, err := s.multipart.ListParts(ctx, img.ObjectKey, uploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	upload := &api.MultipartUpload{
+		UploadId:  uploadId,
+		PartSize:  api.MultipartPartSize,
+		PartCount: img.PartCount,
+		Parts:     make([]api.MultipartPart, 0, len(parts)),
+	}
+
+	for _, p := range parts {
+		upload.Parts = append(upload.Parts, api.MultipartPart{
+			PartNumber: p.PartNumber,
+			ETag:       p.ETag,
+			Size:       p.Size,
+		})
+	}
+
+	return upload, nil
+}
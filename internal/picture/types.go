@@ -1,12 +1,17 @@
 package picture
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"fmt"
 	"strings"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
 	"github.com/tdeslauriers/carapace/pkg/permissions"
 	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/pixie/internal/multipart"
 	"github.com/tdeslauriers/pixie/internal/pipeline"
 	"github.com/tdeslauriers/pixie/internal/util"
 )
@@ -16,6 +21,8 @@ type Service interface {
 	AlbumImageService
 	ImageService
 	ImageServiceErr
+	UploadSessionService
+	MultipartService
 }
 
 func NewService(
@@ -23,13 +30,20 @@ func NewService(
 	i data.Indexer,
 	c data.Cryptor,
 	obj storage.ObjectStorage,
+	mp multipart.Store,
+	limits map[string]int64,
+	viewOnlyMaxWidth int,
+	uq chan pipeline.UploadCmd,
 	rq chan pipeline.ReprocessCmd,
 	dq chan pipeline.DeletionCmd) Service {
 	return &service{
 
 		AlbumImageService: NewAlbumImageService(sql, i, c),
-		ImageService:      NewImageService(sql, i, c, obj, rq, dq),
+		ImageService:      NewImageService(sql, i, c, obj, mp, limits, viewOnlyMaxWidth, uq, rq, dq),
 		ImageServiceErr:   NewImageServiceErr(),
+
+		UploadSessionService: NewUploadSessionService(sql, c, obj),
+		MultipartService:     NewMultipartService(sql, i, c, obj, mp),
 	}
 }
 
@@ -40,6 +54,8 @@ type service struct {
 	AlbumImageService
 	ImageService
 	ImageServiceErr
+	UploadSessionService
+	MultipartService
 }
 
 type ImagePermissionXref struct {
@@ -72,7 +88,13 @@ func BuildGetImageQuery(userPs map[string]permissions.PermissionRecord) string {
 			i.created_at,
 			i.updated_at,
 			i.is_archived,
-			i.is_published 
+			i.is_published,
+			i.blur_hash,
+			i.dominant_color,
+			i.image_date_key,
+			i.image_date_precision,
+			i.image_date_end_year,
+			i.version
 		FROM image i
 			LEFT OUTER JOIN image_permission ip ON i.uuid = ip.image_uuid
 			LEFT OUTER JOIN permission p ON ip.permission_uuid = p.uuid
@@ -142,3 +164,29 @@ func BuildImagePermissionsQry(userPs map[string]permissions.PermissionRecord) st
 
 	return qb.String()
 }
+
+// ImageRendition is a model representing a rendition of an image being streamed through pixie
+// rather than handed out as a signed object storage url.
+type ImageRendition struct {
+	ObjectKey   string                 // object storage key of the rendition, eg, "2025/slug_w640.jpg"
+	ETag        string                 // strong, quoted entity tag for the rendition bytes
+	ContentType string                 // MIME type of the rendition, eg, "image/jpeg"
+	Body        storage.ReadSeekCloser // rendition bytes: only valid for the duration of the stream callback
+}
+
+// nopCloser wraps an in-memory reader so it satisfies storage.ReadSeekCloser,
+// eg, for streaming a rendition which was just generated.
+type nopCloser struct {
+	*bytes.Reader
+}
+
+// Close is a no-op: there is nothing to release for an in-memory reader.
+func (nopCloser) Close() error { return nil }
+
+// RenditionETag builds a strong entity tag for a stored rendition.  Rendition keys embed the
+// image slug, which is unique per upload, and renditions are deterministically re-encoded from
+// an immutable original, so the key and the encoder settings address the content.
+func RenditionETag(objectKey string, quality int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|q%d", objectKey, quality)))
+	return fmt.Sprintf(`"%s"`, hex.EncodeToString(sum[:16]))
+}
@@ -0,0 +1,103 @@
+package picture
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// UploadSessionRepository is an interface for data operations related to upload sessions,
+// ie, batches of image placeholders created together.
+type UploadSessionRepository interface {
+
+	// FindUploadSession retrieves an upload session record by its uuid.
+	FindUploadSession(sessionId string) (*api.UploadSessionRecord, error)
+
+	// FindUploadSessionImages retrieves the images created in an upload session.
+	// Note: the slug and object key fields are encrypted.
+	FindUploadSessionImages(sessionId string) ([]api.UploadSessionImageRecord, error)
+
+	// InsertUploadSession inserts a new upload session record into the database.
+	InsertUploadSession(record api.UploadSessionRecord) error
+
+	// UpdateUploadSessionImageCount sets the number of placeholders created in an upload session.
+	UpdateUploadSessionImageCount(sessionId string, count int) error
+}
+
+// NewUploadSessionRepository creates a new UploadSessionRepository instance, returning a pointer to the concrete implementation.
+func NewUploadSessionRepository(db *sql.DB) UploadSessionRepository {
+	return &uploadSessionRepository{
+		sql: db,
+	}
+}
+
+var _ UploadSessionRepository = (*uploadSessionRepository)(nil)
+
+// uploadSessionRepository is the concrete implementation of the UploadSessionRepository interface.
+type uploadSessionRepository struct {
+	sql *sql.DB
+}
+
+// FindUploadSession retrieves an upload session record by its uuid.
+func (r *uploadSessionRepository) FindUploadSession(sessionId string) (*api.UploadSessionRecord, error) {
+
+	qry := `
+		SELECT
+			uuid,
+			image_count,
+			created_at
+		FROM upload_session
+		WHERE uuid = ?`
+
+	record, err := data.SelectOneRecord[api.UploadSessionRecord](r.sql, qry, sessionId)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("upload session '%s' not found", sessionId)
+		}
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// FindUploadSessionImages retrieves the images created in an upload session.
+func (r *uploadSessionRepository) FindUploadSessionImages(sessionId string) ([]api.UploadSessionImageRecord, error) {
+
+	qry := `
+		SELECT
+			uuid,
+			slug,
+			object_key,
+			is_published
+		FROM image
+		WHERE upload_session_uuid = ?
+		ORDER BY created_at, uuid`
+
+	return data.SelectRecords[api.UploadSessionImageRecord](r.sql, qry, sessionId)
+}
+
+// InsertUploadSession inserts a new upload session record into the database.
+func (r *uploadSessionRepository) InsertUploadSession(record api.UploadSessionRecord) error {
+
+	qry := `
+		INSERT INTO upload_session (
+			uuid,
+			image_count,
+			created_at
+		) VALUES (?, ?, ?)`
+
+	return data.InsertRecord(r.sql, qry, record)
+}
+
+// UpdateUploadSessionImageCount sets the number of placeholders created in an upload session.
+func (r *uploadSessionRepository) UpdateUploadSessionImageCount(sessionId string, count int) error {
+
+	qry := `
+		UPDATE upload_session
+		SET image_count = ?
+		WHERE uuid = ?`
+
+	return data.UpdateRecord(r.sql, qry, count, sessionId)
+}
@@ -0,0 +1,350 @@
+package picture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/connect"
+	"github.com/tdeslauriers/carapace/pkg/connect/telemetry"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// uploadSessionBatchSize is the number of placeholders an upload session creates concurrently.
+const uploadSessionBatchSize int = 8
+
+// HandleUploadSessions is the concrete implementation of the interface method which handles
+// creating an upload session, ie, a placeholder for each of a batch of files, and reporting its progress.
+func (h *imageHandler) HandleUploadSessions(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		h.handleGetUploadSessionProgress(w, r)
+		return
+	case http.MethodPost:
+		h.handleCreateUploadSession(w, r)
+		return
+	default:
+		// Handle unsupported methods
+		// get telemetry from request
+		tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+		log := h.logger.With(tel.TelemetryFields()...)
+
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+}
+
+// handleCreateUploadSession creates a placeholder image record and presigned PUT URL for each image in the
+// upload session command, the same as adding a single image record, and records the session on each image.
+// Albums and permissions are checked once for the whole session, before any placeholders are created.
+func (h *imageHandler) handleCreateUploadSession(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(writeImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(writeImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// a session is always created new: there is no slug to post to
+	if r.PathValue("slug") != "" {
+		log.Error(fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusMethodNotAllowed,
+			Message:    fmt.Sprintf("unsupported method %s for endpoint %s", r.Method, r.URL.Path),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// decode the request body into the UploadSessionCmd struct
+	var cmd api.UploadSessionCmd
+	if err := json.NewDecoder(r.Body).Decode(&cmd); err != nil {
+		log.Error("failed to decode request body", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    "failed to decode request body",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// validate the incoming data
+	if err := cmd.Validate(); err != nil {
+		log.Error("failed to validate create upload session command", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusUnprocessableEntity,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// resolve every album and permission in the session once, rather than once per image,
+	// so a misspelled album fails the session before any placeholders are created
+	albumIds, permissionIds, err := h.resolveUploadSessionXrefs(ctx, authedUser.Claims.Subject, cmd)
+	if err != nil {
+		log.Error("failed to resolve upload session albums and permissions", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	session, err := h.svc.CreateUploadSession()
+	if err != nil {
+		log.Error("failed to create upload session", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+	log = log.With("upload_session_id", session.Id)
+
+	// build the placeholders in batches, preserving the order of the command's images
+	placeholders := make([]*api.Placeholder, len(cmd.Images))
+	failures := make([]error, len(cmd.Images))
+	for start := 0; start < len(cmd.Images); start += uploadSessionBatchSize {
+
+		end := min(start+uploadSessionBatchSize, len(cmd.Images))
+
+		var wg sync.WaitGroup
+		for i := start; i < end; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				placeholders[i], failures[i] = h.buildSessionPlaceholder(ctx, log, session.Id, cmd.Images[i], albumIds[i], permissionIds[i])
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	resp := api.UploadSession{
+		Id:           session.Id,
+		CreatedAt:    session.CreatedAt.Format(time.RFC3339),
+		Placeholders: make([]api.Placeholder, 0, len(cmd.Images)),
+	}
+
+	// a placeholder which could not be added to all of its albums and permissions is still returned with its failure
+	var created int
+	for i, p := range placeholders {
+		if failures[i] != nil {
+			resp.Failures = append(resp.Failures, api.UploadSessionFailure{
+				Index:   i,
+				Title:   cmd.Images[i].Title,
+				Message: failures[i].Error(),
+			})
+		}
+		if p == nil {
+			continue
+		}
+		resp.Placeholders = append(resp.Placeholders, *p)
+		created++
+	}
+
+	if created == 0 {
+		log.Error("failed to build any placeholders for upload session", "image_count", len(cmd.Images))
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusInternalServerError,
+			Message:    "failed to create placeholders for upload session images",
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	// each placeholder recorded the session when it was created, so the session's progress can be reported
+	// without the count: failing the request would leave the placeholders without their upload urls
+	if err := h.svc.CompleteUploadSession(session.Id, created); err != nil {
+		log.Error("failed to record upload session image count", "err", err.Error())
+	}
+
+	log.Info("successfully processed create upload session",
+		"image_count", len(cmd.Images),
+		"placeholder_count", created,
+		"failed_count", len(resp.Failures),
+	)
+
+	connect.SendJsonSuccess(w, http.StatusOK, resp)
+}
+
+// resolveUploadSessionXrefs is a helper which looks up the ids of each session image's albums and permissions:
+// the session's defaults plus the image's own.  Returns an error if any album is not one the user may view,
+// or any permission does not exist.
+func (h *imageHandler) resolveUploadSessionXrefs(ctx context.Context, username string, cmd api.UploadSessionCmd) ([][]string, [][]string, error) {
+
+	albumIds := make([][]string, len(cmd.Images))
+	permissionIds := make([][]string, len(cmd.Images))
+
+	var hasAlbums, hasPermissions bool
+	for _, img := range cmd.Images {
+		hasAlbums = hasAlbums || len(img.Albums) > 0
+		hasPermissions = hasPermissions || len(img.Permissions) > 0
+	}
+	hasAlbums = hasAlbums || len(cmd.Albums) > 0
+	hasPermissions = hasPermissions || len(cmd.Permissions) > 0
+
+	if hasAlbums {
+
+		ps, _, err := h.perms.GetPatronPermissions(ctx, username)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve %s's permissions: %v", username, err)
+		}
+
+		allowed, _, err := h.albums.GetAllowedAlbums(ps)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve %s's allowed albums: %v", username, err)
+		}
+
+		for i, img := range cmd.Images {
+			for _, album := range slices.Concat(cmd.Albums, img.Albums) {
+				record, ok := allowed[album.Slug]
+				if !ok {
+					return nil, nil, fmt.Errorf("album with slug '%s' not found", album.Slug)
+				}
+				if !slices.Contains(albumIds[i], record.Id) {
+					albumIds[i] = append(albumIds[i], record.Id)
+				}
+			}
+		}
+	}
+
+	if hasPermissions {
+
+		psMap, _, err := h.perms.GetAllPermissions()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to retrieve all permissions: %v", err)
+		}
+
+		for i, img := range cmd.Images {
+			for _, p := range slices.Concat(cmd.Permissions, img.Permissions) {
+				perm, ok := psMap[p.Slug]
+				if !ok {
+					return nil, nil, fmt.Errorf("permission slug '%s' not found", p.Slug)
+				}
+				if !slices.Contains(permissionIds[i], perm.Id) {
+					permissionIds[i] = append(permissionIds[i], perm.Id)
+				}
+			}
+		}
+	}
+
+	return albumIds, permissionIds, nil
+}
+
+// buildSessionPlaceholder is a helper which builds the placeholder image record and presigned PUT URL
+// for one image of an upload session, then adds it to its albums and permissions.
+// The placeholder can still be uploaded to if it could not be added to all of its albums and permissions,
+// so in that case both the placeholder and the error are returned, for the session to report the failure.
+func (h *imageHandler) buildSessionPlaceholder(
+	ctx context.Context,
+	log *slog.Logger,
+	sessionId string,
+	cmd api.AddMetaDataCmd,
+	albumIds []string,
+	permissionIds []string,
+) (*api.Placeholder, error) {
+
+	placeholder, err := h.svc.BuildPlaceholder(ctx, cmd, sessionId)
+	if err != nil {
+		log.Error("failed to build placeholder image record", "err", err.Error())
+		return nil, err
+	}
+
+	var errs []error
+	for _, albumId := range albumIds {
+		if err := h.albums.InsertAlbumImageXref(ctx, albumId, placeholder.Id); err != nil {
+			log.Error(fmt.Sprintf("failed to add image '%s' to album '%s'", placeholder.Id, albumId), "err", err.Error())
+			errs = append(errs, fmt.Errorf("failed to add image to album '%s'", albumId))
+		}
+	}
+
+	for _, permissionId := range permissionIds {
+		if err := h.svc.InsertImagePermissionXref(placeholder.Id, permissionId); err != nil {
+			log.Error(fmt.Sprintf("failed to add image '%s' to permission '%s'", placeholder.Id, permissionId), "err", err.Error())
+			errs = append(errs, fmt.Errorf("failed to add image to permission '%s'", permissionId))
+		}
+	}
+
+	return placeholder, errors.Join(errs...)
+}
+
+// handleGetUploadSessionProgress reports how far the images of an upload session have got through the upload pipeline.
+func (h *imageHandler) handleGetUploadSessionProgress(w http.ResponseWriter, r *http.Request) {
+
+	// get telemetry from request
+	tel := telemetry.ObtainHttpTelemetry(r, h.logger)
+	log := h.logger.With(tel.TelemetryFields()...)
+
+	// add telemetry to context for downstream calls + service functions
+	ctx := context.WithValue(r.Context(), telemetry.TelemetryKey, tel)
+
+	// validate s2s token
+	// uploaders check on their sessions, and the unpublished images' slugs are included, so write access is required
+	svcToken := r.Header.Get("Service-Authorization")
+	authedSvc, err := h.s2s.BuildAuthorized(writeImagesAllowed, svcToken)
+	if err != nil {
+		log.Error("failed to validate s2s token", "err", err.Error())
+		connect.RespondAuthFailure(connect.S2s, err, w)
+		return
+	}
+	log = log.With("principal_service", authedSvc.Claims.Subject)
+
+	// validate iam token
+	accessToken := r.Header.Get("Authorization")
+	authedUser, err := h.iam.BuildAuthorized(writeImagesAllowed, accessToken)
+	if err != nil {
+		log.Error("failed to validate iam token", "err", err.Error())
+		connect.RespondAuthFailure(connect.User, err, w)
+		return
+	}
+	log = log.With("principal_user", authedUser.Claims.Subject)
+
+	// get the session id from the request path
+	sessionId, err := connect.GetValidSlug(r)
+	if err != nil {
+		log.Error("failed to get valid upload session id from request path", "err", err.Error())
+		e := connect.ErrorHttp{
+			StatusCode: http.StatusBadRequest,
+			Message:    err.Error(),
+		}
+		e.SendJsonErr(w)
+		return
+	}
+
+	progress, err := h.svc.GetUploadSessionProgress(ctx, sessionId)
+	if err != nil {
+		log.Error("failed to get upload session progress", "err", err.Error())
+		h.svc.HandleImageServiceError(ctx, err, w)
+		return
+	}
+
+	connect.SendJsonSuccess(w, http.StatusOK, progress)
+}
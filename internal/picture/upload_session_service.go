@@ -0,0 +1,180 @@
+package picture
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/carapace/pkg/storage"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+	"github.com/tdeslauriers/pixie/internal/crypt"
+	"github.com/tdeslauriers/pixie/internal/pipeline"
+	"github.com/tdeslauriers/pixie/internal/util"
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// UploadSessionService is the interface for managing upload sessions: batches of image placeholders
+// created together, eg, the files selected in one upload, and reporting their progress through the upload pipeline.
+type UploadSessionService interface {
+
+	// CreateUploadSession creates a new upload session record, returning it.
+	CreateUploadSession() (*api.UploadSessionRecord, error)
+
+	// CompleteUploadSession records the number of placeholder images created in the upload session.
+	// Note: each placeholder records its upload session itself when it is created.
+	CompleteUploadSession(sessionId string, imageCount int) error
+
+	// GetUploadSessionProgress reports how far the images of the upload session have got through the upload pipeline.
+	GetUploadSessionProgress(ctx context.Context, sessionId string) (*api.UploadSessionProgress, error)
+}
+
+// NewUploadSessionService creates a new UploadSessionService instance, returning a pointer to the concrete implementation.
+func NewUploadSessionService(sql *sql.DB, c data.Cryptor, obj storage.ObjectStorage) UploadSessionService {
+	return &uploadSessionService{
+		db:      NewUploadSessionRepository(sql),
+		cryptor: crypt.NewCryptor(c),
+		store:   obj,
+
+		logger: slog.Default().
+			With(slog.String(util.PackageKey, util.PackagePicture)).
+			With(slog.String(util.ComponentKey, util.ComponentUploadSession)),
+	}
+}
+
+var _ UploadSessionService = (*uploadSessionService)(nil)
+
+// uploadSessionService is the concrete implementation of the UploadSessionService interface.
+type uploadSessionService struct {
+	db      UploadSessionRepository
+	cryptor crypt.Cryptor
+	store   storage.ObjectStorage
+
+	logger *slog.Logger
+}
+
+// CreateUploadSession is the concrete implementation of the interface method which creates a new upload session record.
+func (s *uploadSessionService) CreateUploadSession() (*api.UploadSessionRecord, error) {
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate new upload session id: %v", err)
+	}
+
+	record := api.UploadSessionRecord{
+		Id:         id.String(),
+		ImageCount: 0, // set once the placeholders are created
+		CreatedAt:  data.CustomTime{Time: time.Now().UTC()},
+	}
+
+	if err := s.db.InsertUploadSession(record); err != nil {
+		return nil, fmt.Errorf("failed to insert upload session record into database: %v", err)
+	}
+
+	return &record, nil
+}
+
+// CompleteUploadSession is the concrete implementation of the interface method which records
+// the number of placeholder images created in the upload session.
+func (s *uploadSessionService) CompleteUploadSession(sessionId string, imageCount int) error {
+
+	if err := s.db.UpdateUploadSessionImageCount(sessionId, imageCount); err != nil {
+		return fmt.Errorf("failed to update upload session '%s' image count: %v", sessionId, err)
+	}
+
+	return nil
+}
+
+// GetUploadSessionProgress is the concrete implementation of the interface method which reports
+// how far the images of the upload session have got through the upload pipeline.
+// An image's status is determined by the directory of its object key: placeholders are created in 'uploads',
+// and the pipeline moves processed images to 'staging' if they have no exif date, or to the year directory.
+func (s *uploadSessionService) GetUploadSessionProgress(ctx context.Context, sessionId string) (*api.UploadSessionProgress, error) {
+
+	if err := validate.ValidateUuid(sessionId); err != nil {
+		return nil, fmt.Errorf("invalid upload session id: %s", sessionId)
+	}
+
+	session, err := s.db.FindUploadSession(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := s.db.FindUploadSessionImages(sessionId)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve upload session '%s' images from database: %v", sessionId, err)
+	}
+
+	// files waiting in 'uploads' have been uploaded, but not processed yet: list them once rather than per image
+	waiting, err := s.store.ListObjects(ctx, "uploads/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list uploaded files waiting to be processed: %v", err)
+	}
+
+	uploaded := make(map[string]struct{}, len(waiting))
+	for _, key := range waiting {
+		uploaded[key] = struct{}{}
+	}
+
+	progress := &api.UploadSessionProgress{
+		Id:        session.Id,
+		CreatedAt: session.CreatedAt.Format(time.RFC3339),
+		Total:     len(records),
+		Images:    make([]api.UploadSessionImage, 0, len(records)),
+	}
+
+	for _, r := range records {
+
+		slug, err := s.cryptor.DecryptImageField(r.Slug)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt slug for image '%s': %v", r.Id, err)
+		}
+
+		objectKey, err := s.cryptor.DecryptImageField(r.ObjectKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt object key for image '%s': %v", r.Id, err)
+		}
+
+		dir, _, _, _, err := pipeline.ParseObjectKey(objectKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse object key for image '%s': %v", r.Id, err)
+		}
+
+		var status string
+		switch dir {
+		case "uploads":
+			if _, ok := uploaded[objectKey]; ok {
+				status = api.UploadStatusUploaded
+				progress.Uploaded++
+			} else {
+				status = api.UploadStatusPending
+				progress.Pending++
+			}
+		case "staging":
+			status = api.UploadStatusStaged
+			progress.Uploaded++
+			progress.Processed++
+			progress.Staged++
+		default:
+			status = api.UploadStatusProcessed
+			progress.Uploaded++
+			progress.Processed++
+		}
+
+		if r.IsPublished {
+			progress.Published++
+		}
+
+		progress.Images = append(progress.Images, api.UploadSessionImage{
+			Id:          r.Id,
+			Slug:        slug,
+			Status:      status,
+			IsPublished: r.IsPublished,
+		})
+	}
+
+	return progress, nil
+}
@@ -27,6 +27,9 @@ type fakeRow []driver.Value
 type fakeConn struct {
 	queryFn func(query string, args []driver.Value) (columns []string, rows []fakeRow, err error)
 	execFn  func(query string, args []driver.Value) (lastInsertID, rowsAffected int64, err error)
+
+	commits   int
+	rollbacks int
 }
 
 func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
@@ -34,9 +37,16 @@ func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
 }
 func (c *fakeConn) Close() error { return nil }
 func (c *fakeConn) Begin() (driver.Tx, error) {
-	return nil, fmt.Errorf("fakeConn: transactions not supported")
+	return &fakeTx{conn: c}, nil
 }
 
+// fakeTx records whether a transaction committed or rolled back; statements
+// within it are routed through the conn's queryFn and execFn as usual.
+type fakeTx struct{ conn *fakeConn }
+
+func (t *fakeTx) Commit() error   { t.conn.commits++; return nil }
+func (t *fakeTx) Rollback() error { t.conn.rollbacks++; return nil }
+
 type fakeStmt struct {
 	query string
 	conn  *fakeConn
@@ -1,10 +1,13 @@
 package pipeline
 
 import (
+	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/pixie/internal/transaction"
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
 
@@ -20,21 +23,51 @@ type Repository interface {
 	// FindImageAlbums retrieves all albums associated with a given image's uuid.
 	FindImageAlbums(imageId string) ([]api.AlbumRecord, error)
 
+	// FindImagesMissingPlaceholders retrieves all processed image records which
+	// do not yet have a blur hash/dominant color, ie, images uploaded before placeholders existed.
+	FindImagesMissingPlaceholders() ([]api.ImageRecord, error)
+
+	// FindImagesMissingDisplayOriginal retrieves all image records which have been processed by the pipeline,
+	// ie, are no longer waiting on an upload, but are not recorded as having a sanitized display original.
+	FindImagesMissingDisplayOriginal() ([]api.ImageRecord, error)
+
+	// FindImagesMissingDateKey retrieves all image records which have an image date but
+	// no sortable image date key, ie, images processed before the date key existed.
+	FindImagesMissingDateKey() ([]api.ImageRecord, error)
+
 	// InsertAlbum inserts a new album metadata record into the database.
 	InsertAlbum(record api.AlbumRecord) error
 
-	// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database.
-	InsertAlbumImageXref(xref api.AlbumImageXref) error
+	// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database and, if inherit is true,
+	// grants the image the album's permissions if the album passes them on to images added to it, in a single transaction.
+	// An image already linked to the album is left as it is, and does not inherit the album's permissions again.
+	InsertAlbumImageXref(xref api.AlbumImageXref, inherit bool) error
 
 	// UpdateImage updates an existing image metadata record in the database.
 	// Note: fields must be encrypted prior to calling this function.
 	UpdateImage(record api.ImageRecord) error
+
+	// UpdateImagePlaceholders updates only the blur hash and dominant color of an image record.
+	// Note: fields must be encrypted prior to calling this function.
+	UpdateImagePlaceholders(imageId, blurHash, dominantColor string) error
+
+	// UpdateImageDateKey updates only the sortable image date key of an image record.
+	// Note: the date key is not encrypted.
+	UpdateImageDateKey(imageId, dateKey string) error
+
+	// UpdateImageHasGps updates only the flag recording whether an image's exif data contains gps coordinates.
+	// Note: the coordinates themselves are not stored.
+	UpdateImageHasGps(imageId string, hasGps bool) error
+
+	// UpdateImageHasDisplayOriginal records that an image's sanitized display original is in object storage.
+	UpdateImageHasDisplayOriginal(imageId string) error
 }
 
 // NewRepository creates a new Repository instance, returning a pointer to the concrete implementation.
 func NewRepository(db *sql.DB) Repository {
 	return &repository{
 		sql: db,
+		uow: transaction.NewUnitOfWork(db),
 	}
 }
 
@@ -43,6 +76,7 @@ var _ Repository = (*repository)(nil)
 // repository is the concrete implementation of the Repository interface.
 type repository struct {
 	sql *sql.DB
+	uow transaction.UnitOfWork
 }
 
 // FindAllAlbums retrieves all album records from the database.
@@ -57,7 +91,10 @@ func (r *repository) FindAllAlbums() ([]api.AlbumRecord, error) {
 			slug_index,
 			created_at,
 			updated_at,
-			is_archived
+			is_archived,
+			cover_image_uuid,
+			parent_uuid,
+			version
 		FROM album`
 
 	return data.SelectRecords[api.AlbumRecord](r.sql, qry)
@@ -83,7 +120,13 @@ func (r *repository) FindImage(slugIndex string) (*api.ImageRecord, error) {
 			created_at,
 			updated_at,
 			is_archived,
-			is_published 
+			is_published,
+			blur_hash,
+			dominant_color,
+			image_date_key,
+			image_date_precision,
+			image_date_end_year,
+			version
 		FROM image 
 		WHERE slug_index = ?`
 
@@ -110,7 +153,10 @@ func (r *repository) FindImageAlbums(imageId string) ([]api.AlbumRecord, error)
 			a.slug_index,
 			a.created_at,
 			a.updated_at,
-			a.is_archived
+			a.is_archived,
+			a.cover_image_uuid,
+			a.parent_uuid,
+			a.version
 		FROM album a
 			LEFT OUTER JOIN album_image ai ON a.uuid = ai.album_uuid
 		WHERE ai.image_uuid = ?`
@@ -118,6 +164,112 @@ func (r *repository) FindImageAlbums(imageId string) ([]api.AlbumRecord, error)
 	return data.SelectRecords[api.AlbumRecord](r.sql, qry, imageId)
 }
 
+// FindImagesMissingPlaceholders retrieves all processed image records which
+// do not yet have a blur hash/dominant color.
+func (r *repository) FindImagesMissingPlaceholders() ([]api.ImageRecord, error) {
+
+	qry := `
+		SELECT 
+			uuid,
+			title,
+			description,
+			file_name,
+			file_type,
+			object_key,
+			slug,
+			slug_index,
+			width,
+			height,
+			size,
+			image_date,
+			created_at,
+			updated_at,
+			is_archived,
+			is_published,
+			blur_hash,
+			dominant_color,
+			image_date_key,
+			image_date_precision,
+			image_date_end_year,
+			version
+		FROM image 
+		WHERE blur_hash = ''
+			AND width > 0` // width is only set once the pipeline has processed the upload
+
+	return data.SelectRecords[api.ImageRecord](r.sql, qry)
+}
+
+// FindImagesMissingDisplayOriginal retrieves all image records which have been processed by the pipeline,
+// ie, are no longer waiting on an upload, but are not recorded as having a sanitized display original.
+func (r *repository) FindImagesMissingDisplayOriginal() ([]api.ImageRecord, error) {
+
+	qry := `
+		SELECT 
+			uuid,
+			title,
+			description,
+			file_name,
+			file_type,
+			object_key,
+			slug,
+			slug_index,
+			width,
+			height,
+			size,
+			image_date,
+			created_at,
+			updated_at,
+			is_archived,
+			is_published,
+			blur_hash,
+			dominant_color,
+			image_date_key,
+			image_date_precision,
+			image_date_end_year,
+			version
+		FROM image 
+		WHERE width > 0
+			AND has_display_original = FALSE` // width is only set once the pipeline has processed the upload
+
+	return data.SelectRecords[api.ImageRecord](r.sql, qry)
+}
+
+// FindImagesMissingDateKey retrieves all image records which have an image date but
+// no sortable image date key, ie, images processed before the date key existed.
+func (r *repository) FindImagesMissingDateKey() ([]api.ImageRecord, error) {
+
+	qry := `
+		SELECT 
+			uuid,
+			title,
+			description,
+			file_name,
+			file_type,
+			object_key,
+			slug,
+			slug_index,
+			width,
+			height,
+			size,
+			image_date,
+			created_at,
+			updated_at,
+			is_archived,
+			is_published,
+			blur_hash,
+			dominant_color,
+			image_date_key,
+			image_date_precision,
+			image_date_end_year,
+			version
+		FROM image 
+		WHERE image_date_key = ''
+			AND image_date IS NOT NULL
+			AND image_date <> ''`
+
+	return data.SelectRecords[api.ImageRecord](r.sql, qry)
+}
+
 // InsertAlbum inserts a new album metadata record into the database.
 func (r *repository) InsertAlbum(record api.AlbumRecord) error {
 
@@ -130,14 +282,22 @@ func (r *repository) InsertAlbum(record api.AlbumRecord) error {
 			slug_index,
 			created_at,
 			updated_at,
-			is_archived
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+			is_archived,
+			cover_image_uuid,
+			parent_uuid,
+			version
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
 	return data.InsertRecord(r.sql, qry, record)
 }
 
-// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database.
-func (r *repository) InsertAlbumImageXref(xref api.AlbumImageXref) error {
+// InsertAlbumImageXref inserts a xref record into the ablum_image table in the database and, if inherit is true,
+// grants the image the album's permissions if the album passes them on to images added to it.
+// Both are written in a single transaction, so an image is never left linked without the permissions it inherits,
+// which a retry would not grant since it finds the image already linked.
+// Note: an image already linked, eg, on a reprocess or retry, has already inherited the album's permissions:
+// re-granting them would undo any permission a curator has since removed from the image.
+func (r *repository) InsertAlbumImageXref(xref api.AlbumImageXref, inherit bool) error {
 
 	qry := `
 		INSERT INTO album_image (
@@ -147,7 +307,44 @@ func (r *repository) InsertAlbumImageXref(xref api.AlbumImageXref) error {
 			created_at
 		) VALUES (?, ?, ?, ?) ON DUPLICATE KEY UPDATE id = id` // no-op update to avoid duplicate key error
 
-	return data.InsertRecord(r.sql, qry, xref)
+	// permissions the image already has are skipped
+	inheritQry := `
+		INSERT INTO image_permission (
+			image_uuid,
+			permission_uuid,
+			created_at
+		)
+		SELECT ?, ap.permission_uuid, UTC_TIMESTAMP()
+		FROM album_permission ap
+			INNER JOIN album a ON ap.album_uuid = a.uuid
+		WHERE ap.album_uuid = ?
+			AND a.inherit_permissions = TRUE
+		ON DUPLICATE KEY UPDATE id = id` // no-op update to skip permissions the image already has
+
+	ctx := context.Background()
+	return r.uow.Run(ctx, func(tx transaction.Tx) error {
+
+		result, err := tx.ExecContext(ctx, qry, xref.Id, xref.AlbumId, xref.ImageId, xref.CreatedAt)
+		if err != nil {
+			return err
+		}
+
+		// the no-op update affects 0 rows
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 || !inherit {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, inheritQry, xref.ImageId, xref.AlbumId); err != nil {
+			return fmt.Errorf("failed to grant image '%s' the permissions of album '%s': %v", xref.ImageId, xref.AlbumId, err)
+		}
+
+		return nil
+	})
 }
 
 // UpdateImage updates an existing image metadata record in the database.
@@ -160,19 +357,75 @@ func (r *repository) UpdateImage(record api.ImageRecord) error {
 			width = ?,
 			height = ?,
 			image_date = ?,
+			image_date_key = ?,
 			updated_at = ?,
-			is_published = ?
+			is_published = ?,
+			blur_hash = ?,
+			dominant_color = ?,
+			version = version + 1
 		WHERE uuid = ?`
 
+	// the version is incremented so a curator's edit based on the record before processing is rejected as stale
 	return data.UpdateRecord(
 		r.sql,
 		qry,
-		record.ObjectKey,   // to update
-		record.Width,       // to update
-		record.Height,      // to update
-		record.ImageDate,   // to update
-		record.UpdatedAt,   // to update
-		record.IsPublished, // to update
-		record.Id,          // where clause
+		record.ObjectKey,     // to update
+		record.Width,         // to update
+		record.Height,        // to update
+		record.ImageDate,     // to update
+		record.ImageDateKey,  // to update
+		record.UpdatedAt,     // to update
+		record.IsPublished,   // to update
+		record.BlurHash,      // to update
+		record.DominantColor, // to update
+		record.Id,            // where clause
 	)
 }
+
+// UpdateImagePlaceholders updates only the blur hash and dominant color of an image record.
+// Note: fields must be encrypted prior to calling this function.
+func (r *repository) UpdateImagePlaceholders(imageId, blurHash, dominantColor string) error {
+
+	qry := `
+		UPDATE image SET 
+			blur_hash = ?,
+			dominant_color = ?
+		WHERE uuid = ?`
+
+	return data.UpdateRecord(r.sql, qry, blurHash, dominantColor, imageId)
+}
+
+// UpdateImageDateKey updates only the sortable image date key of an image record.
+// Note: the date key is not encrypted.
+func (r *repository) UpdateImageDateKey(imageId, dateKey string) error {
+
+	qry := `
+		UPDATE image SET 
+			image_date_key = ?
+		WHERE uuid = ?`
+
+	return data.UpdateRecord(r.sql, qry, dateKey, imageId)
+}
+
+// UpdateImageHasGps updates only the flag recording whether an image's exif data contains gps coordinates.
+// Note: the coordinates themselves are not stored.
+func (r *repository) UpdateImageHasGps(imageId string, hasGps bool) error {
+
+	qry := `
+		UPDATE image SET 
+			has_gps = ?
+		WHERE uuid = ?`
+
+	return data.UpdateRecord(r.sql, qry, hasGps, imageId)
+}
+
+// UpdateImageHasDisplayOriginal records that an image's sanitized display original is in object storage.
+func (r *repository) UpdateImageHasDisplayOriginal(imageId string) error {
+
+	qry := `
+		UPDATE image SET 
+			has_display_original = TRUE
+		WHERE uuid = ?`
+
+	return data.UpdateRecord(r.sql, qry, imageId)
+}
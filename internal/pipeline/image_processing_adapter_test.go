@@ -13,16 +13,17 @@ import (
 	"github.com/tdeslauriers/pixie/pkg/api"
 )
 
-var albumColumns = []string{"uuid", "title", "description", "slug", "slug_index", "created_at", "updated_at", "is_archived"}
+var albumColumns = []string{"uuid", "title", "description", "slug", "slug_index", "created_at", "updated_at", "is_archived", "cover_image_uuid", "parent_uuid", "version"}
 
 func albumRow(a api.AlbumRecord) fakeRow {
-	return fakeRow{a.Id, a.Title, a.Description, a.Slug, a.SlugIndex, a.CreatedAt.Time, a.UpdatedAt.Time, a.IsArchived}
+	return fakeRow{a.Id, a.Title, a.Description, a.Slug, a.SlugIndex, a.CreatedAt.Time, a.UpdatedAt.Time, a.IsArchived, a.CoverImageId, a.ParentId, int64(a.Version)}
 }
 
 var imageColumns = []string{
 	"uuid", "title", "description", "file_name", "file_type", "object_key",
 	"slug", "slug_index", "width", "height", "size", "image_date",
 	"created_at", "updated_at", "is_archived", "is_published",
+	"blur_hash", "dominant_color", "image_date_key", "image_date_precision", "image_date_end_year", "version",
 }
 
 func imageRow(i api.ImageRecord) fakeRow {
@@ -30,6 +31,7 @@ func imageRow(i api.ImageRecord) fakeRow {
 		i.Id, i.Title, i.Description, i.FileName, i.FileType, i.ObjectKey,
 		i.Slug, i.SlugIndex, int64(i.Width), int64(i.Height), i.Size, i.ImageDate,
 		i.CreatedAt.Time, i.UpdatedAt.Time, i.IsArchived, i.IsPublished,
+		i.BlurHash, i.DominantColor, i.ImageDateKey, i.ImageDatePrecision, int64(i.ImageDateEndYear), int64(i.Version),
 	}
 }
 
@@ -44,28 +46,31 @@ func sampleAlbum() api.AlbumRecord {
 		CreatedAt:   dataCustomTime(now),
 		UpdatedAt:   dataCustomTime(now),
 		IsArchived:  false,
+		Version:     1,
 	}
 }
 
 func sampleImage() api.ImageRecord {
 	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
 	return api.ImageRecord{
-		Id:          "cccccccc-cccc-cccc-cccc-cccccccccccc",
-		Title:       "encrypted-title",
-		Description: "encrypted-description",
-		FileName:    "dddddddd-dddd-dddd-dddd-dddddddddddd.jpg",
-		FileType:    "image/jpeg",
-		ObjectKey:   "2024/dddddddd-dddd-dddd-dddd-dddddddddddd.jpg",
-		Slug:        "dddddddd-dddd-dddd-dddd-dddddddddddd",
-		SlugIndex:   "slugidx",
-		Width:       1920,
-		Height:      1080,
-		Size:        123456,
-		ImageDate:   "2024-03-01T12:00:00Z",
-		CreatedAt:   dataCustomTime(now),
-		UpdatedAt:   dataCustomTime(now),
-		IsArchived:  false,
-		IsPublished: true,
+		Id:           "cccccccc-cccc-cccc-cccc-cccccccccccc",
+		Title:        "encrypted-title",
+		Description:  "encrypted-description",
+		FileName:     "dddddddd-dddd-dddd-dddd-dddddddddddd.jpg",
+		FileType:     "image/jpeg",
+		ObjectKey:    "2024/dddddddd-dddd-dddd-dddd-dddddddddddd.jpg",
+		Slug:         "dddddddd-dddd-dddd-dddd-dddddddddddd",
+		SlugIndex:    "slugidx",
+		Width:        1920,
+		Height:       1080,
+		Size:         123456,
+		ImageDate:    "2024-03-01T12:00:00Z",
+		CreatedAt:    dataCustomTime(now),
+		UpdatedAt:    dataCustomTime(now),
+		IsArchived:   false,
+		IsPublished:  true,
+		ImageDateKey: "2024-03",
+		Version:      2,
 	}
 }
 
@@ -255,8 +260,8 @@ func TestRepository_InsertAlbum(t *testing.T) {
 			name:   "success",
 			record: sampleAlbum(),
 			execFn: func(query string, args []driver.Value) (int64, int64, error) {
-				if len(args) != 8 {
-					t.Fatalf("expected 8 args for album insert, got %d: %v", len(args), args)
+				if len(args) != 11 {
+					t.Fatalf("expected 11 args for album insert, got %d: %v", len(args), args)
 				}
 				if args[0] != sampleAlbum().Id || args[1] != sampleAlbum().Title {
 					t.Fatalf("unexpected args order: %v", args)
@@ -296,27 +301,114 @@ func TestRepository_InsertAlbumImageXref(t *testing.T) {
 		CreatedAt: dataCustomTime(time.Now().UTC()),
 	}
 
+	tests := []struct {
+		name         string
+		inherit      bool
+		linkedRows   int64 // rows affected by the xref insert: 0 if already linked
+		xrefErr      error
+		inheritErr   error
+		wantExecs    int
+		wantErr      bool
+		wantRollback bool
+	}{
+		{
+			name:       "success, inserted and inherits album permissions",
+			inherit:    true,
+			linkedRows: 1,
+			wantExecs:  2,
+		},
+		{
+			name:       "success, inserted without inheriting",
+			inherit:    false,
+			linkedRows: 1,
+			wantExecs:  1,
+		},
+		{
+			name:       "success, already linked: does not inherit permissions again",
+			inherit:    true,
+			linkedRows: 0, // ON DUPLICATE KEY no-op affects 0 rows
+			wantExecs:  1,
+		},
+		{
+			name:         "xref db error rolls back",
+			inherit:      true,
+			xrefErr:      fmt.Errorf("fk constraint violation"),
+			wantExecs:    1,
+			wantErr:      true,
+			wantRollback: true,
+		},
+		{
+			name:         "inherit db error rolls back the xref insert",
+			inherit:      true,
+			linkedRows:   1,
+			inheritErr:   fmt.Errorf("deadlock"),
+			wantExecs:    2,
+			wantErr:      true,
+			wantRollback: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var execs int
+			conn := &fakeConn{execFn: func(query string, args []driver.Value) (int64, int64, error) {
+				execs++
+				if strings.Contains(query, "INSERT INTO album_image") {
+					if len(args) != 4 || args[1] != "album-id" || args[2] != "image-id" {
+						t.Fatalf("unexpected xref args: %v", args)
+					}
+					return 1, tt.linkedRows, tt.xrefErr
+				}
+				if !strings.Contains(query, "inherit_permissions = TRUE") {
+					t.Fatalf("query does not check the album passes its permissions on: %s", query)
+				}
+				if len(args) != 2 || args[0] != "image-id" || args[1] != "album-id" {
+					t.Fatalf("unexpected inherited permissions args: %v", args)
+				}
+				return 0, 2, tt.inheritErr
+			}}
+			repo := NewRepository(newFakeDB(t, conn))
+
+			err := repo.InsertAlbumImageXref(xref, tt.inherit)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InsertAlbumImageXref() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if execs != tt.wantExecs {
+				t.Errorf("InsertAlbumImageXref() statements = %d, want %d", execs, tt.wantExecs)
+			}
+			if (conn.rollbacks == 1) != tt.wantRollback || (conn.commits == 1) == tt.wantRollback {
+				t.Errorf("InsertAlbumImageXref() commits = %d, rollbacks = %d, want rolled back = %v", conn.commits, conn.rollbacks, tt.wantRollback)
+			}
+		})
+	}
+}
+
+func TestRepository_UpdateImageHasGps(t *testing.T) {
+
 	tests := []struct {
 		name    string
 		execFn  func(query string, args []driver.Value) (int64, int64, error)
 		wantErr bool
 	}{
 		{
-			name: "success (including duplicate-key no-op)",
+			name: "success, flag then image id",
 			execFn: func(query string, args []driver.Value) (int64, int64, error) {
-				if len(args) != 4 {
-					t.Fatalf("expected 4 args for xref insert, got %d: %v", len(args), args)
+				if len(args) != 2 {
+					t.Fatalf("expected 2 args for gps flag update, got %d: %v", len(args), args)
 				}
-				if args[1] != "album-id" || args[2] != "image-id" {
+				if args[0] != true || args[1] != "image-id" {
 					t.Fatalf("unexpected args: %v", args)
 				}
-				return 0, 0, nil // ON DUPLICATE KEY no-op affects 0 rows
+				if !strings.Contains(query, "has_gps = ?") {
+					t.Fatalf("query does not update the gps flag: %s", query)
+				}
+				return 0, 1, nil
 			},
 		},
 		{
 			name: "db error propagates",
 			execFn: func(query string, args []driver.Value) (int64, int64, error) {
-				return 0, 0, fmt.Errorf("fk constraint violation")
+				return 0, 0, fmt.Errorf("connection lost")
 			},
 			wantErr: true,
 		},
@@ -327,9 +419,9 @@ func TestRepository_InsertAlbumImageXref(t *testing.T) {
 			db := newFakeDB(t, &fakeConn{execFn: tt.execFn})
 			repo := NewRepository(db)
 
-			err := repo.InsertAlbumImageXref(xref)
+			err := repo.UpdateImageHasGps("image-id", true)
 			if (err != nil) != tt.wantErr {
-				t.Fatalf("InsertAlbumImageXref() error = %v, wantErr %v", err, tt.wantErr)
+				t.Fatalf("UpdateImageHasGps() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
@@ -352,8 +444,9 @@ func TestRepository_UpdateImage(t *testing.T) {
 				// so the value that reaches Exec is the formatted string, not
 				// a time.Time.
 				want := []driver.Value{
-					img.ObjectKey, int64(img.Width), int64(img.Height), img.ImageDate,
-					img.UpdatedAt.Time.UTC().Format("2006-01-02 15:04:05"), img.IsPublished, img.Id,
+					img.ObjectKey, int64(img.Width), int64(img.Height), img.ImageDate, img.ImageDateKey,
+					img.UpdatedAt.Time.UTC().Format("2006-01-02 15:04:05"), img.IsPublished,
+					img.BlurHash, img.DominantColor, img.Id,
 				}
 				if len(args) != len(want) {
 					t.Fatalf("expected %d args, got %d: %v", len(want), len(args), args)
@@ -109,6 +109,7 @@ func (p *imagePipeline) processDeletionCmd(ctx context.Context, cmd DeletionCmd)
 // original image and every derived file (resolutions, tiles, blur) by naming
 // convention -> "<dir>/<slug>.<ext>", "<dir>/<slug>_w1200.<ext>",
 // "<dir>/<slug>_tile_w256.<ext>", "<dir>/<slug>_blur.<ext>", etc.
+// On-demand renditions are cached under "derived/<slug>/", so are matched the same way.
 // Because the slug is a full uuid, the prefix cannot partially match any other
 // image's files.
 func buildDeletionPrefixes(cmd DeletionCmd) ([]string, error) {
@@ -140,6 +141,9 @@ func buildDeletionPrefixes(cmd DeletionCmd) ([]string, error) {
 		dirs[d] = struct{}{}
 	}
 
+	// always sweep the on-demand rendition cache
+	dirs[DerivedDir] = struct{}{}
+
 	// build the prefixes
 	prefixes := make([]string, 0, len(dirs))
 	for dir := range dirs {
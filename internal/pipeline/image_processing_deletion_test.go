@@ -23,34 +23,34 @@ func TestBuildDeletionPrefixes(t *testing.T) {
 			wantErr: true,
 		},
 		{
-			name: "slug only sweeps the default upload/staging directories",
+			name: "slug only sweeps the default upload/staging/derived directories",
 			cmd:  DeletionCmd{Slug: testUUID},
-			want: []string{"uploads/" + testUUID, "staging/" + testUUID},
+			want: []string{"uploads/" + testUUID, "staging/" + testUUID, "derived/" + testUUID},
 		},
 		{
 			name: "parseable object key adds its own directory to the sweep",
 			cmd:  DeletionCmd{ObjectKey: "gallery-bucket/2024/" + testUUID + ".jpg"},
-			want: []string{"2024/" + testUUID, "uploads/" + testUUID, "staging/" + testUUID},
+			want: []string{"2024/" + testUUID, "uploads/" + testUUID, "staging/" + testUUID, "derived/" + testUUID},
 		},
 		{
 			name: "object key directory that duplicates a sweep dir is deduped",
 			cmd:  DeletionCmd{ObjectKey: "gallery-bucket/uploads/" + testUUID + ".jpg"},
-			want: []string{"uploads/" + testUUID, "staging/" + testUUID},
+			want: []string{"uploads/" + testUUID, "staging/" + testUUID, "derived/" + testUUID},
 		},
 		{
 			name: "unparseable object key is not fatal as long as a slug is available",
 			cmd:  DeletionCmd{Slug: testUUID, ObjectKey: "###not-a-valid-key###"},
-			want: []string{"uploads/" + testUUID, "staging/" + testUUID},
+			want: []string{"uploads/" + testUUID, "staging/" + testUUID, "derived/" + testUUID},
 		},
 		{
 			name: "slug is derived from the object key when cmd.Slug is empty",
 			cmd:  DeletionCmd{ObjectKey: "gallery-bucket/2023/" + testUUID2 + ".png"},
-			want: []string{"2023/" + testUUID2, "uploads/" + testUUID2, "staging/" + testUUID2},
+			want: []string{"2023/" + testUUID2, "uploads/" + testUUID2, "staging/" + testUUID2, "derived/" + testUUID2},
 		},
 		{
 			name: "explicit slug wins over one derived from the object key",
 			cmd:  DeletionCmd{Slug: testUUID, ObjectKey: "gallery-bucket/2023/" + testUUID2 + ".png"},
-			want: []string{"2023/" + testUUID, "uploads/" + testUUID, "staging/" + testUUID},
+			want: []string{"2023/" + testUUID, "uploads/" + testUUID, "staging/" + testUUID, "derived/" + testUUID},
 		},
 	}
 
@@ -127,6 +127,7 @@ func TestImagePipeline_ProcessDeletionCmd(t *testing.T) {
 			wantDeleteKeys: []string{
 				"uploads/" + testUUID + ".jpg", "uploads/" + testUUID + "_blur.jpg",
 				"staging/" + testUUID + ".jpg", "staging/" + testUUID + "_blur.jpg",
+				"derived/" + testUUID + ".jpg", "derived/" + testUUID + "_blur.jpg",
 			},
 		},
 		{
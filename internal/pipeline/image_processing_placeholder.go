@@ -0,0 +1,208 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+const (
+	BlurHashComponentsX int = 4 // horizontal components encoded in the blur hash
+	BlurHashComponentsY int = 3 // vertical components encoded in the blur hash
+)
+
+// base83 alphabet used by the BlurHash specification.
+const base83Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+
+// buildPlaceholders is a helper which computes the inline placeholders for an image:
+// a BlurHash string and the dominant color as a hex string, eg, "#a1b2c3".
+// The source image should already be downscaled (see resizeToLongestSide) since
+// both calculations visit every pixel.
+func buildPlaceholders(src image.Image) (string, string, error) {
+
+	if src == nil {
+		return "", "", errors.New("source image is nil")
+	}
+
+	// transparent pixels would otherwise skew both calculations toward black
+	if hasAlphaChannel(src) {
+		src = flattenOnWhite(src)
+	}
+
+	hash, err := encodeBlurHash(src, BlurHashComponentsX, BlurHashComponentsY)
+	if err != nil {
+		return "", "", err
+	}
+
+	return hash, dominantColor(src), nil
+}
+
+// encodeBlurHash encodes the image into a BlurHash string per the specification
+// at https://github.com/woltapp/blurhash.
+func encodeBlurHash(src image.Image, xComponents, yComponents int) (string, error) {
+
+	if xComponents < 1 || xComponents > 9 || yComponents < 1 || yComponents > 9 {
+		return "", fmt.Errorf("blur hash components must be between 1 and 9, got %dx%d", xComponents, yComponents)
+	}
+
+	bounds := src.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if w <= 0 || h <= 0 {
+		return "", errors.New("image has no pixels to encode")
+	}
+
+	// convert to linear rgb once rather than per component
+	linear := make([][3]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			r, g, b, _ := src.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			linear[y*w+x] = [3]float64{
+				srgbToLinear(int(r >> 8)),
+				srgbToLinear(int(g >> 8)),
+				srgbToLinear(int(b >> 8)),
+			}
+		}
+	}
+
+	// calculate the dct factors
+	factors := make([][3]float64, 0, xComponents*yComponents)
+	for j := 0; j < yComponents; j++ {
+		for i := 0; i < xComponents; i++ {
+			normalisation := 2.0
+			if i == 0 && j == 0 {
+				normalisation = 1.0
+			}
+
+			var f [3]float64
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					basis := normalisation *
+						math.Cos(math.Pi*float64(i)*float64(x)/float64(w)) *
+						math.Cos(math.Pi*float64(j)*float64(y)/float64(h))
+					px := linear[y*w+x]
+					f[0] += basis * px[0]
+					f[1] += basis * px[1]
+					f[2] += basis * px[2]
+				}
+			}
+
+			scale := 1.0 / float64(w*h)
+			factors = append(factors, [3]float64{f[0] * scale, f[1] * scale, f[2] * scale})
+		}
+	}
+
+	var sb strings.Builder
+
+	// size flag
+	sb.WriteString(encodeBase83((xComponents-1)+(yComponents-1)*9, 1))
+
+	// quantised maximum ac component value
+	maxValue := 1.0
+	if len(factors) > 1 {
+		actualMax := 0.0
+		for _, f := range factors[1:] {
+			for _, c := range f {
+				actualMax = math.Max(actualMax, math.Abs(c))
+			}
+		}
+
+		quantised := int(math.Max(0, math.Min(82, math.Floor(actualMax*166-0.5))))
+		maxValue = float64(quantised+1) / 166
+		sb.WriteString(encodeBase83(quantised, 1))
+	} else {
+		sb.WriteString(encodeBase83(0, 1))
+	}
+
+	// dc component
+	dc := factors[0]
+	sb.WriteString(encodeBase83(
+		(linearToSrgb(dc[0])<<16)+(linearToSrgb(dc[1])<<8)+linearToSrgb(dc[2]),
+		4,
+	))
+
+	// ac components
+	for _, f := range factors[1:] {
+		quant := func(v float64) int {
+			return int(math.Max(0, math.Min(18, math.Floor(signPow(v/maxValue, 0.5)*9+9.5))))
+		}
+		sb.WriteString(encodeBase83(quant(f[0])*19*19+quant(f[1])*19+quant(f[2]), 2))
+	}
+
+	return sb.String(), nil
+}
+
+// dominantColor returns the most common color in the image as a hex string.
+// Colors are bucketed at 4 bits per channel so near-identical shades are counted together,
+// and the average of the most populated bucket is returned.
+func dominantColor(src image.Image) string {
+
+	type bucket struct {
+		count   int
+		r, g, b int
+	}
+
+	var (
+		buckets [4096]bucket
+		best    int
+	)
+
+	bounds := src.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := src.At(x, y).RGBA()
+			r8, g8, b8 := int(r>>8), int(g>>8), int(b>>8)
+
+			key := (r8>>4)<<8 | (g8>>4)<<4 | (b8 >> 4)
+			buckets[key].count++
+			buckets[key].r += r8
+			buckets[key].g += g8
+			buckets[key].b += b8
+
+			if buckets[key].count > buckets[best].count {
+				best = key
+			}
+		}
+	}
+
+	bk := buckets[best]
+	if bk.count == 0 {
+		return "#000000"
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", bk.r/bk.count, bk.g/bk.count, bk.b/bk.count)
+}
+
+// encodeBase83 encodes a value into a fixed length base83 string.
+func encodeBase83(value, length int) string {
+	out := make([]byte, length)
+	for i := 1; i <= length; i++ {
+		digit := (value / int(math.Pow(83, float64(length-i)))) % 83
+		out[i-1] = base83Chars[digit]
+	}
+	return string(out)
+}
+
+// srgbToLinear converts an 8-bit srgb channel value to linear space.
+func srgbToLinear(value int) float64 {
+	v := float64(value) / 255
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSrgb converts a linear channel value back to an 8-bit srgb value.
+func linearToSrgb(value float64) int {
+	v := math.Max(0, math.Min(1, value))
+	if v <= 0.0031308 {
+		return int(v*12.92*255 + 0.5)
+	}
+	return int((1.055*math.Pow(v, 1/2.4)-0.055)*255 + 0.5)
+}
+
+// signPow raises the absolute value to the exponent, preserving the sign.
+func signPow(value, exp float64) float64 {
+	return math.Copysign(math.Pow(math.Abs(value), exp), value)
+}
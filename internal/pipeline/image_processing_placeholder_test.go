@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestEncodeBlurHash(t *testing.T) {
+
+	solid := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			solid.Set(x, y, color.RGBA{R: 255, G: 0, B: 0, A: 255})
+		}
+	}
+
+	tests := []struct {
+		name    string
+		src     image.Image
+		x, y    int
+		wantLen int
+		wantErr bool
+	}{
+		{
+			name:    "4x3 components",
+			src:     solid,
+			x:       4,
+			y:       3,
+			wantLen: 1 + 1 + 4 + 2*(4*3-1),
+		},
+		{
+			name:    "single dc component",
+			src:     solid,
+			x:       1,
+			y:       1,
+			wantLen: 6,
+		},
+		{
+			name:    "too many components",
+			src:     solid,
+			x:       10,
+			y:       3,
+			wantErr: true,
+		},
+		{
+			name:    "empty image",
+			src:     image.NewRGBA(image.Rect(0, 0, 0, 0)),
+			x:       4,
+			y:       3,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hash, err := encodeBlurHash(tt.src, tt.x, tt.y)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("encodeBlurHash() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(hash) != tt.wantLen {
+				t.Errorf("encodeBlurHash() len = %d, want %d (%q)", len(hash), tt.wantLen, hash)
+			}
+		})
+	}
+
+	// a solid red image has a well-known dc component and no ac energy
+	hash, err := encodeBlurHash(solid, 1, 1)
+	if err != nil {
+		t.Fatalf("encodeBlurHash() unexpected error: %v", err)
+	}
+	if hash != "00TI:j" {
+		t.Errorf("encodeBlurHash() = %q, want %q", hash, "00TI:j")
+	}
+}
+
+func TestDominantColor(t *testing.T) {
+
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			// 70% blue, 30% green
+			if x < 7 {
+				img.Set(x, y, color.RGBA{R: 0, G: 0, B: 200, A: 255})
+			} else {
+				img.Set(x, y, color.RGBA{R: 0, G: 200, B: 0, A: 255})
+			}
+		}
+	}
+
+	if got := dominantColor(img); got != "#0000c8" {
+		t.Errorf("dominantColor() = %q, want %q", got, "#0000c8")
+	}
+}
+
+func TestBuildPlaceholders(t *testing.T) {
+
+	if _, _, err := buildPlaceholders(nil); err == nil {
+		t.Fatal("buildPlaceholders(nil) expected error")
+	}
+
+	// fully transparent image should be flattened onto white
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+
+	hash, col, err := buildPlaceholders(img)
+	if err != nil {
+		t.Fatalf("buildPlaceholders() unexpected error: %v", err)
+	}
+	if hash == "" {
+		t.Error("buildPlaceholders() returned empty blur hash")
+	}
+	if col != "#ffffff" {
+		t.Errorf("buildPlaceholders() color = %q, want %q", col, "#ffffff")
+	}
+}
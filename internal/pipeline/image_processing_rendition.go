@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+	"slices"
+
+	"github.com/tdeslauriers/pixie/internal/util"
+)
+
+const (
+	// DerivedDir is the object storage namespace for on-demand renditions.  Keys are
+	// "derived/<slug>/<spec>.jpg" so they survive moves between year directories and
+	// are swept by the deletion queue using the slug prefix.
+	DerivedDir string = "derived"
+
+	FitContain string = "contain" // scale to fit within the box, preserving the whole image
+	FitCover   string = "cover"   // scale to fill the box, center cropping the overflow
+)
+
+// RenditionSpec is the snapped set of parameters for an on-demand rendition.
+// A zero width or height means that dimension follows the aspect ratio.
+// MaxWidth caps the width the source is scaled to, eg, for view-only users, since a height-only
+// or height-limited spec can otherwise scale to any width.  Zero means not capped.
+type RenditionSpec struct {
+	Width    int
+	Height   int
+	Fit      string
+	Quality  int
+	MaxWidth int
+}
+
+// NewRenditionSpec snaps the requested rendition parameters to the allowlists in util so that
+// arbitrary sizes cannot be requested.  Sizes snap up to the next allowed size (or down to the
+// largest), quality snaps to the nearest allowed quality, and an empty fit defaults to contain.
+func NewRenditionSpec(width, height int, fit string, quality int) (RenditionSpec, error) {
+
+	if width < 0 || height < 0 {
+		return RenditionSpec{}, errors.New("rendition width and height must not be negative")
+	}
+
+	if width == 0 && height == 0 {
+		return RenditionSpec{}, errors.New("rendition width or height is required")
+	}
+
+	switch fit {
+	case "":
+		fit = FitContain
+	case FitContain, FitCover:
+	default:
+		return RenditionSpec{}, fmt.Errorf("rendition fit must be one of '%s' or '%s'", FitContain, FitCover)
+	}
+
+	// cover needs a box to fill: with one dimension it is the same as contain
+	if width == 0 || height == 0 {
+		fit = FitContain
+	}
+
+	if quality < 0 || quality > 100 {
+		return RenditionSpec{}, errors.New("rendition quality must be between 1 and 100")
+	}
+
+	if quality == 0 {
+		quality = JpegQuality
+	}
+
+	return RenditionSpec{
+		Width:   snapUp(width, util.OnDemandSizes),
+		Height:  snapUp(height, util.OnDemandSizes),
+		Fit:     fit,
+		Quality: snapNearest(quality, util.OnDemandQualities),
+	}, nil
+}
+
+// DerivedKey builds the object storage key the rendition is cached under for the image slug.
+// Capped renditions are cached separately so an uncapped request never gets a capped rendition, or vice versa.
+func (s RenditionSpec) DerivedKey(slug string) string {
+	if s.MaxWidth > 0 {
+		return fmt.Sprintf("%s/%s/w%d_h%d_%s_q%d_m%d.jpg", DerivedDir, slug, s.Width, s.Height, s.Fit, s.Quality, s.MaxWidth)
+	}
+	return fmt.Sprintf("%s/%s/w%d_h%d_%s_q%d.jpg", DerivedDir, slug, s.Width, s.Height, s.Fit, s.Quality)
+}
+
+// scale calculates the factor to scale an image of the given dimensions by to satisfy the spec.
+// Images are never upscaled.
+func (s RenditionSpec) scale(width, height int) float64 {
+
+	if width <= 0 || height <= 0 {
+		return 1
+	}
+
+	sw := float64(s.Width) / float64(width)
+	sh := float64(s.Height) / float64(height)
+
+	var scale float64
+	switch {
+	case s.Width == 0:
+		scale = sh
+	case s.Height == 0:
+		scale = sw
+	case s.Fit == FitCover:
+		scale = math.Max(sw, sh)
+	default:
+		scale = math.Min(sw, sh)
+	}
+
+	return math.Min(scale, 1)
+}
+
+// SourceWidth calculates the width the source image must be scaled to, before any cropping,
+// to build the rendition from an original of the given dimensions.  Used to pick the nearest
+// larger stored rendition to generate from.  The width is capped at MaxWidth, if set.
+func (s RenditionSpec) SourceWidth(width, height int) int {
+
+	w := max(1, int(math.Round(float64(width)*s.scale(width, height))))
+	if s.MaxWidth > 0 {
+		w = min(w, s.MaxWidth)
+	}
+
+	return w
+}
+
+// BuildRendition resizes (and for cover, center crops) the source image to the spec
+// and encodes it as a jpeg at the spec's quality.
+func BuildRendition(src image.Image, spec RenditionSpec) ([]byte, error) {
+
+	if src == nil {
+		return nil, errors.New("source image is nil")
+	}
+
+	b := src.Bounds()
+	resized := resizeImageToWidth(src, spec.SourceWidth(b.Dx(), b.Dy()))
+
+	if spec.Fit == FitCover {
+		resized = cropCenter(resized, spec.Width, spec.Height)
+	}
+
+	return encodeToJpeg(resized, spec.Quality)
+}
+
+// cropCenter crops the image to the given dimensions around its center.
+// Dimensions larger than the image are clamped to the image's dimensions.
+func cropCenter(src image.Image, width, height int) image.Image {
+
+	b := src.Bounds()
+	width = min(width, b.Dx())
+	height = min(height, b.Dy())
+
+	if width == b.Dx() && height == b.Dy() {
+		return src
+	}
+
+	offset := image.Pt(b.Min.X+(b.Dx()-width)/2, b.Min.Y+(b.Dy()-height)/2)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), src, offset, draw.Src)
+
+	return dst
+}
+
+// NearestStoredWidth returns the smallest stored resolution width which is at least the needed width,
+// or the largest stored width if none are.
+func NearestStoredWidth(needed int) int {
+
+	widths := slices.Clone(util.ResolutionWidthsImages)
+	slices.Sort(widths)
+
+	for _, w := range widths {
+		if w >= needed {
+			return w
+		}
+	}
+
+	return widths[len(widths)-1]
+}
+
+// snapUp snaps the value up to the next allowed value, or down to the largest allowed value.
+// Zero is returned as is, ie, not requested.
+func snapUp(v int, allowed []int) int {
+
+	if v == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(allowed)
+	slices.Sort(sorted)
+
+	for _, a := range sorted {
+		if a >= v {
+			return a
+		}
+	}
+
+	return sorted[len(sorted)-1]
+}
+
+// snapNearest snaps the value to the closest allowed value, preferring the higher value on a tie.
+func snapNearest(v int, allowed []int) int {
+
+	best := allowed[0]
+	for _, a := range allowed[1:] {
+		d, bd := abs(a-v), abs(best-v)
+		if d < bd || (d == bd && a > best) {
+			best = a
+		}
+	}
+
+	return best
+}
+
+// abs returns the absolute value of an int.
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,248 @@
+package pipeline
+
+import (
+	"bytes"
+	"image"
+	"image/jpeg"
+	"testing"
+)
+
+func TestNewRenditionSpec(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		width   int
+		height  int
+		fit     string
+		quality int
+		want    RenditionSpec
+		wantErr bool
+	}{
+		{
+			name:    "exact allowlisted values",
+			width:   600,
+			height:  315,
+			fit:     FitCover,
+			quality: 75,
+			want:    RenditionSpec{Width: 600, Height: 320, Fit: FitCover, Quality: 75},
+		},
+		{
+			name:  "width only snaps up and defaults",
+			width: 500,
+			want:  RenditionSpec{Width: 600, Height: 0, Fit: FitContain, Quality: 85},
+		},
+		{
+			name:   "oversized snaps down to largest",
+			width:  5000,
+			height: 5000,
+			fit:    FitContain,
+			want:   RenditionSpec{Width: 1600, Height: 1600, Fit: FitContain, Quality: 85},
+		},
+		{
+			name:    "cover with one dimension is contain",
+			height:  100,
+			fit:     FitCover,
+			quality: 68,
+			want:    RenditionSpec{Width: 0, Height: 128, Fit: FitContain, Quality: 75},
+		},
+		{
+			name:    "no dimensions",
+			wantErr: true,
+		},
+		{
+			name:    "negative width",
+			width:   -1,
+			wantErr: true,
+		},
+		{
+			name:    "unknown fit",
+			width:   600,
+			fit:     "stretch",
+			wantErr: true,
+		},
+		{
+			name:    "quality out of range",
+			width:   600,
+			quality: 101,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			got, err := NewRenditionSpec(tt.width, tt.height, tt.fit, tt.quality)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRenditionSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.want {
+				t.Errorf("NewRenditionSpec() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenditionSpec_DerivedKey(t *testing.T) {
+
+	spec := RenditionSpec{Width: 1200, Height: 630, Fit: FitCover, Quality: 85}
+
+	want := "derived/" + testUUID + "/w1200_h630_cover_q85.jpg"
+	if got := spec.DerivedKey(testUUID); got != want {
+		t.Errorf("DerivedKey() = %q, want %q", got, want)
+	}
+}
+
+func TestRenditionSpec_SourceWidth(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		spec   RenditionSpec
+		width  int
+		height int
+		want   int
+	}{
+		{
+			name:   "width only",
+			spec:   RenditionSpec{Width: 800, Fit: FitContain},
+			width:  4000,
+			height: 3000,
+			want:   800,
+		},
+		{
+			name:   "height only follows aspect ratio",
+			spec:   RenditionSpec{Height: 1600, Fit: FitContain},
+			width:  4000,
+			height: 3000,
+			want:   2133,
+		},
+		{
+			name:   "height only capped at max width",
+			spec:   RenditionSpec{Height: 1600, Fit: FitContain, MaxWidth: 1920},
+			width:  4000,
+			height: 3000,
+			want:   1920,
+		},
+		{
+			name:   "height limited cover capped at max width",
+			spec:   RenditionSpec{Width: 1600, Height: 1600, Fit: FitCover, MaxWidth: 3840},
+			width:  24000,
+			height: 2000,
+			want:   3840,
+		},
+		{
+			name:   "height only panorama capped at max width",
+			spec:   RenditionSpec{Height: 1600, Fit: FitContain, MaxWidth: 3840},
+			width:  24000,
+			height: 2000,
+			want:   3840,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.spec.SourceWidth(tt.width, tt.height); got != tt.want {
+				t.Errorf("SourceWidth(%d, %d) = %d, want %d", tt.width, tt.height, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenditionSpec_DerivedKey_Capped(t *testing.T) {
+
+	spec := RenditionSpec{Height: 1600, Fit: FitContain, Quality: 85, MaxWidth: 1920}
+
+	want := "derived/" + testUUID + "/w0_h1600_contain_q85_m1920.jpg"
+	if got := spec.DerivedKey(testUUID); got != want {
+		t.Errorf("DerivedKey() = %q, want %q", got, want)
+	}
+}
+
+func TestNearestStoredWidth(t *testing.T) {
+
+	tests := []struct {
+		needed int
+		want   int
+	}{
+		{1, 384},
+		{384, 384},
+		{385, 640},
+		{1600, 1920},
+		{9000, 3840},
+	}
+
+	for _, tt := range tests {
+		if got := NearestStoredWidth(tt.needed); got != tt.want {
+			t.Errorf("NearestStoredWidth(%d) = %d, want %d", tt.needed, got, tt.want)
+		}
+	}
+}
+
+func TestBuildRendition(t *testing.T) {
+
+	src := image.NewRGBA(image.Rect(0, 0, 800, 400))
+
+	tests := []struct {
+		name       string
+		spec       RenditionSpec
+		wantWidth  int
+		wantHeight int
+	}{
+		{
+			name:       "contain within box",
+			spec:       RenditionSpec{Width: 240, Height: 240, Fit: FitContain, Quality: 75},
+			wantWidth:  240,
+			wantHeight: 120,
+		},
+		{
+			name:       "cover crops to box",
+			spec:       RenditionSpec{Width: 240, Height: 240, Fit: FitCover, Quality: 75},
+			wantWidth:  240,
+			wantHeight: 240,
+		},
+		{
+			name:       "height only",
+			spec:       RenditionSpec{Height: 100, Fit: FitContain, Quality: 75},
+			wantWidth:  200,
+			wantHeight: 100,
+		},
+		{
+			name:       "height only capped at max width",
+			spec:       RenditionSpec{Height: 300, Fit: FitContain, Quality: 75, MaxWidth: 400},
+			wantWidth:  400,
+			wantHeight: 200,
+		},
+		{
+			name:       "never upscales",
+			spec:       RenditionSpec{Width: 1600, Fit: FitContain, Quality: 75},
+			wantWidth:  800,
+			wantHeight: 400,
+		},
+		{
+			name:       "cover larger than source clamps crop",
+			spec:       RenditionSpec{Width: 1200, Height: 630, Fit: FitCover, Quality: 75},
+			wantWidth:  800,
+			wantHeight: 400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			out, err := BuildRendition(src, tt.spec)
+			if err != nil {
+				t.Fatalf("BuildRendition() unexpected error: %v", err)
+			}
+
+			cfg, err := jpeg.DecodeConfig(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("rendition is not a valid jpeg: %v", err)
+			}
+			if cfg.Width != tt.wantWidth || cfg.Height != tt.wantHeight {
+				t.Errorf("rendition dimensions = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.wantWidth, tt.wantHeight)
+			}
+		})
+	}
+}
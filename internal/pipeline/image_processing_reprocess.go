@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"image"
 	"log/slog"
 	"math/rand/v2"
 	"path/filepath"
@@ -94,9 +93,50 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 	}
 
 	// check whether a file move is required
-	// Note: current state: a move is always required but this may change in the future
 	if !cmd.MoveRequired {
-		log.Info("no move required for reprocess command, nothing to do")
+
+		if !cmd.PlaceholdersRequired && !cmd.DisplayRequired {
+			log.Info("no move required for reprocess command, nothing to do")
+			return
+		}
+
+		// placeholders may still need to be (re)built, eg, backfilling older images
+		if cmd.PlaceholdersRequired {
+			if err := p.rebuildPlaceholders(reprocessCtx, cmd.Slug, cmd.CurrentObjKey); err != nil {
+				log.Error("failed to (re)build image placeholders", slog.String("err", err.Error()))
+				p.requeueReprocess(ctx, log, cmd)
+				return
+			}
+
+			log.Info("successfully (re)built image placeholders")
+
+			// do not rebuild placeholders if the display original below needs a retry
+			cmd.PlaceholdersRequired = false
+		}
+
+		// the sanitized display original may need to be (re)built, eg, backfilling older images
+		if cmd.DisplayRequired {
+
+			// deterministic -> retrying an unparseable key cannot succeed, so drop on failure.
+			dir, _, ext, slug, err := ParseObjectKey(cmd.CurrentObjKey)
+			if err != nil {
+				log.Error("failed to parse existing object key, dropping reprocess command",
+					slog.String("current_key", cmd.CurrentObjKey),
+					slog.String("err", err.Error()))
+				return
+			}
+
+			displayKey := fmt.Sprintf("%s/%s_display%s", dir, slug, ext)
+			if err := p.rebuildDisplayOriginal(reprocessCtx, cmd.CurrentObjKey, displayKey, cmd.FileType); err != nil {
+				log.Error("failed to (re)build display original", slog.String("err", err.Error()))
+				p.requeueReprocess(ctx, log, cmd)
+				return
+			}
+
+			log.Info("successfully (re)built display original", slog.String("display_key", displayKey))
+			p.recordDisplayOriginal(log, cmd.Id)
+		}
+
 		return
 	}
 
@@ -164,10 +204,10 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 		)
 	}
 
-	// concurrently move and/or (re)build the derived files: resolutions, tiles, and blur
+	// concurrently move and/or (re)build the derived files: resolutions, tiles, blur, and display original
 	var (
 		wg    sync.WaitGroup
-		errCh = make(chan error, len(util.ResolutionWidthsImages)+len(util.ResolutionWidthsTiles)+1)
+		errCh = make(chan error, len(util.ResolutionWidthsImages)+len(util.ResolutionWidthsTiles)+2)
 	)
 
 	// loop thru resolution widths: move existing and build missing
@@ -198,9 +238,9 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 					if err := p.objStore.WithObject(reprocessCtx, c.UpdatedObjKey, func(r storage.ReadSeekCloser) error {
 
 						// decode the image
-						src, _, err := image.Decode(r)
+						src, err := decodeImage(r)
 						if err != nil {
-							return fmt.Errorf("failed to image-format-decode (jpeg/png) object %s: %v", c.UpdatedObjKey, err)
+							return fmt.Errorf("failed to decode object %s: %v", c.UpdatedObjKey, err)
 						}
 
 						// resize the image to the target width, maintaining aspect ratio,
@@ -264,9 +304,9 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 					if err := p.objStore.WithObject(reprocessCtx, c.UpdatedObjKey, func(r storage.ReadSeekCloser) error {
 
 						// decode the image
-						src, _, err := image.Decode(r)
+						src, err := decodeImage(r)
 						if err != nil {
-							return fmt.Errorf("failed to image-format-decode (jpeg/png) object %s: %v", c.UpdatedObjKey, err)
+							return fmt.Errorf("failed to decode object %s: %v", c.UpdatedObjKey, err)
 						}
 
 						// resize the image to the target width, maintaining aspect ratio,
@@ -327,9 +367,9 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 				if err := p.objStore.WithObject(reprocessCtx, c.UpdatedObjKey, func(r storage.ReadSeekCloser) error {
 
 					// decode the image
-					src, _, err := image.Decode(r)
+					src, err := decodeImage(r)
 					if err != nil {
-						return fmt.Errorf("failed to image-format-decode (jpeg/png) object %s: %v", c.UpdatedObjKey, err)
+						return fmt.Errorf("failed to decode object %s: %v", c.UpdatedObjKey, err)
 					}
 
 					// generate blur/placeholder image
@@ -369,6 +409,51 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 		)
 	}(&cmd, errCh, &wg)
 
+	// move the sanitized display original to the new location or (re)build if missing
+	wg.Add(1)
+	go func(c *ReprocessCmd, ch chan error, wg *sync.WaitGroup) {
+
+		defer wg.Done()
+
+		// derive existing/updated display object key -> based on file naming convention in object storage
+		existingDisplayKey := fmt.Sprintf("%s/%s_display%s", dir, slug, ext)
+		updatedDisplayKey := fmt.Sprintf("%s/%s_display%s", filepath.Dir(c.UpdatedObjKey), slug, ext)
+
+		// the object should already exist, try to move it
+		if err := p.objStore.MoveObject(reprocessCtx, existingDisplayKey, updatedDisplayKey); err != nil {
+
+			// if it does not exist, need to build it, eg, images uploaded before display originals existed
+			if strings.Contains(err.Error(), "does not exist in object storage") {
+
+				log.Warn(
+					"display original not found in object storage, (re)building",
+					slog.String("existing_key", existingDisplayKey),
+				)
+
+				if err := p.rebuildDisplayOriginal(reprocessCtx, c.UpdatedObjKey, updatedDisplayKey, c.FileType); err != nil {
+					ch <- fmt.Errorf("failed to (re)build display original from %s: %v", c.UpdatedObjKey, err)
+					return
+				}
+
+				log.Info(
+					"successfully (re)built display original",
+					slog.String("updated_key", updatedDisplayKey),
+				)
+				return
+			}
+
+			ch <- fmt.Errorf("failed to move display original %s to %s: %v", existingDisplayKey, updatedDisplayKey, err)
+			return
+		}
+
+		// successfully moved existing display original
+		log.Info(
+			"successfully moved display original",
+			slog.String("existing_key", existingDisplayKey),
+			slog.String("updated_key", updatedDisplayKey),
+		)
+	}(&cmd, errCh, &wg)
+
 	// wait for all goroutines to finish
 	wg.Wait()
 	close(errCh)
@@ -389,6 +474,26 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 	}
 
 	log.Info("successfully moved/(re)built all derived images")
+	p.recordDisplayOriginal(log, cmd.Id)
+
+	// (re)build the inline placeholders from the moved original if requested.
+	// the move is idempotent, so a re-queue on failure is safe.
+	if cmd.PlaceholdersRequired {
+		if err := p.rebuildPlaceholders(reprocessCtx, cmd.Slug, cmd.UpdatedObjKey); err != nil {
+			log.Error("failed to (re)build image placeholders", slog.String("err", err.Error()))
+			p.requeueReprocess(ctx, log, cmd)
+			return
+		}
+
+		// placeholders are done: do not rebuild them if the album link below needs a retry
+		cmd.PlaceholdersRequired = false
+	}
+
+	// an image dated to a decade or circa range is filed under its first year, but belongs in no year album
+	if cmd.NoYearAlbum {
+		log.Info("image date spans several years, skipping year album link", slog.String("image_id", cmd.Id))
+		return
+	}
 
 	// ensure image is linked to a year-based album -> if the image is already linked, this is a no-op
 
@@ -424,7 +529,8 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 	}
 
 	// create xref -> database call -> transient -> re-queue on failure.
-	// note: linkToAlbum uses impl of xref insert that is idempotent -> if the xref already exists, it is a no-op.
+	// note: linkToAlbum uses impl of xref insert that is idempotent -> if the xref already exists, it is a no-op,
+	// and the album's permissions are not inherited again.
 	// failures here are likely transient (database connection, etc.) and should be retried.
 	if err := p.linkToAlbum(year, &api.ImageRecord{Id: cmd.Id}); err != nil {
 
@@ -442,6 +548,103 @@ func (p *imagePipeline) processReprocessCmd(ctx context.Context, cmd ReprocessCm
 	log.Info("successfully reprocessed image")
 }
 
+// rebuildPlaceholders is a helper which streams the original image from object storage,
+// computes the blur hash and dominant color, and updates the image record in the database.
+// Only the placeholder fields are written so concurrent metadata edits are not overwritten.
+func (p *imagePipeline) rebuildPlaceholders(ctx context.Context, slug, objKey string) error {
+
+	// get the image record: the placeholders must be encrypted along with the record
+	img, err := p.getImageRecord(slug)
+	if err != nil {
+		return err
+	}
+
+	if err := p.objStore.WithObject(ctx, objKey, func(r storage.ReadSeekCloser) error {
+
+		// orientation matters for the blur hash layout
+		meta, err := ReadExif(r)
+		if err != nil {
+			return fmt.Errorf("failed to read exif data for object %s: %v", objKey, err)
+		}
+
+		src, err := decodeImage(r)
+		if err != nil {
+			return fmt.Errorf("failed to decode object %s: %v", objKey, err)
+		}
+
+		blurHash, color, err := buildPlaceholders(resizeToLongestSide(rotateImage(src, meta.Rotation)))
+		if err != nil {
+			return fmt.Errorf("failed to build placeholders for object %s: %v", objKey, err)
+		}
+
+		img.BlurHash = blurHash
+		img.DominantColor = color
+
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	if err := p.cryptor.EncryptImageRecord(img); err != nil {
+		return fmt.Errorf("failed to encrypt image record for image with id %s: %v", img.Id, err)
+	}
+
+	if err := p.db.UpdateImagePlaceholders(img.Id, img.BlurHash, img.DominantColor); err != nil {
+		return fmt.Errorf("failed to update placeholders in database for image with id %s: %v", img.Id, err)
+	}
+
+	return nil
+}
+
+// recordDisplayOriginal is a helper which records that an image's display original is in object storage.
+// Failure is not fatal: the backfill at startup finds the display original in object storage and records it then.
+func (p *imagePipeline) recordDisplayOriginal(log *slog.Logger, imageId string) {
+	if err := p.db.UpdateImageHasDisplayOriginal(imageId); err != nil {
+		log.Warn("failed to record display original for image",
+			slog.String("image_id", imageId),
+			slog.String("err", err.Error()))
+	}
+}
+
+// rebuildDisplayOriginal is a helper which streams the original image from object storage,
+// and (re)builds the sanitized display original from it, stripped of all metadata
+// except the color profile, and uploads it to the display key.
+func (p *imagePipeline) rebuildDisplayOriginal(ctx context.Context, objKey, displayKey, fileType string) error {
+
+	return p.objStore.WithObject(ctx, objKey, func(r storage.ReadSeekCloser) error {
+
+		// orientation must be applied to the pixels since the exif orientation tag is stripped
+		meta, err := ReadExif(r)
+		if err != nil {
+			return fmt.Errorf("failed to read exif data for object %s: %v", objKey, err)
+		}
+
+		src, err := decodeImage(r)
+		if err != nil {
+			return fmt.Errorf("failed to decode object %s: %v", objKey, err)
+		}
+
+		// not fatal: falls back to the default (sRGB) profile
+		icc, err := readIccProfile(r)
+		if err != nil {
+			p.logger.Warn("failed to read color profile for object",
+				slog.String("object_key", objKey),
+				slog.String("err", err.Error()))
+		}
+
+		encoded, err := buildDisplayOriginal(rotateImage(src, meta.Rotation), icc)
+		if err != nil {
+			return fmt.Errorf("failed to build display original for object %s: %v", objKey, err)
+		}
+
+		if err := p.objStore.PutObject(ctx, displayKey, encoded, fileType); err != nil {
+			return fmt.Errorf("failed to upload display original %s: %v", displayKey, err)
+		}
+
+		return nil
+	})
+}
+
 // requeueReprocess increments a failed command's retry count and re-queues it for
 // another attempt after an exponential backoff delay, dropping it if max retries
 // have been exhausted.
@@ -520,3 +723,193 @@ func reprocessBackoff(attempt int) time.Duration {
 
 	return half + rand.N(half) // crypto rand unnecessary here, just need to de-sync retries, not secure randomness
 }
+
+// BackfillPlaceholders is a concrete implementation of the interface method which finds
+// processed images missing their inline blur hash/dominant color placeholders and submits
+// a reprocess command for each to the reprocess queue.
+// It is intended to be run once at startup: images that fail are picked up on the next run.
+func (p *imagePipeline) BackfillPlaceholders(ctx context.Context) {
+
+	defer p.wg.Done()
+
+	records, err := p.db.FindImagesMissingPlaceholders()
+	if err != nil {
+		p.logger.Error("failed to query images missing placeholders", slog.String("err", err.Error()))
+		return
+	}
+
+	if len(records) == 0 {
+		p.logger.Info("no images missing placeholders, nothing to backfill")
+		return
+	}
+
+	p.logger.Info("backfilling image placeholders", slog.Int("image_count", len(records)))
+
+	for _, r := range records {
+
+		if err := p.cryptor.DecryptImageRecord(&r); err != nil {
+			p.logger.Error("failed to decrypt image record, skipping placeholder backfill",
+				slog.String("image_id", r.Id),
+				slog.String("err", err.Error()))
+			continue
+		}
+
+		cmd := ReprocessCmd{
+			Id:                   r.Id,
+			FileName:             r.FileName,
+			FileType:             r.FileType,
+			Slug:                 r.Slug,
+			CurrentObjKey:        r.ObjectKey,
+			UpdatedObjKey:        r.ObjectKey,
+			MoveRequired:         false,
+			PlaceholdersRequired: true,
+			RetryCount:           1,
+		}
+
+		// bail on shutdown rather than blocking on a full queue
+		select {
+		case <-ctx.Done():
+			return
+		case p.reprocessQueue <- cmd:
+		}
+	}
+}
+
+// BackfillDisplayOriginals is a concrete implementation of the interface method which finds
+// processed images not recorded as having their sanitized display original and submits
+// a reprocess command for each to the reprocess queue.
+// Images whose display original is already in object storage, eg, processed before it was recorded,
+// are recorded rather than reprocessed, so each image is only looked for in object storage until it is recorded.
+// It is intended to be run once at startup: images that fail are picked up on the next run.
+func (p *imagePipeline) BackfillDisplayOriginals(ctx context.Context) {
+
+	defer p.wg.Done()
+
+	records, err := p.db.FindImagesMissingDisplayOriginal()
+	if err != nil {
+		p.logger.Error("failed to query images missing display originals", slog.String("err", err.Error()))
+		return
+	}
+
+	if len(records) == 0 {
+		p.logger.Info("no images missing display originals, nothing to backfill")
+		return
+	}
+
+	queued := 0
+	for _, r := range records {
+
+		if err := p.cryptor.DecryptImageRecord(&r); err != nil {
+			p.logger.Error("failed to decrypt image record, skipping display original backfill",
+				slog.String("image_id", r.Id),
+				slog.String("err", err.Error()))
+			continue
+		}
+
+		dir, _, ext, slug, err := ParseObjectKey(r.ObjectKey)
+		if err != nil {
+			p.logger.Error("failed to parse object key, skipping display original backfill",
+				slog.String("image_id", r.Id),
+				slog.String("err", err.Error()))
+			continue
+		}
+
+		// check if the display original already exists
+		displayKey := fmt.Sprintf("%s/%s_display%s", dir, slug, ext)
+		found, err := p.objStore.ListObjects(ctx, displayKey)
+		if err != nil {
+			p.logger.Error("failed to list display original, skipping backfill",
+				slog.String("display_key", displayKey),
+				slog.String("err", err.Error()))
+			continue
+		}
+
+		if len(found) > 0 {
+			p.recordDisplayOriginal(p.logger, r.Id)
+			continue
+		}
+
+		cmd := ReprocessCmd{
+			Id:              r.Id,
+			FileName:        r.FileName,
+			FileType:        r.FileType,
+			Slug:            r.Slug,
+			CurrentObjKey:   r.ObjectKey,
+			UpdatedObjKey:   r.ObjectKey,
+			MoveRequired:    false,
+			DisplayRequired: true,
+			RetryCount:      1,
+		}
+
+		// bail on shutdown rather than blocking on a full queue
+		select {
+		case <-ctx.Done():
+			return
+		case p.reprocessQueue <- cmd:
+			queued++
+		}
+	}
+
+	p.logger.Info("display original backfill complete", slog.Int("queued_count", queued))
+}
+
+// BackfillDateKeys is a concrete implementation of the interface method which finds images
+// that have an image date but are missing their sortable image date key, and sets it from the
+// decrypted image date.  No object storage access is needed, so it does not use the reprocess queue.
+// It is intended to be run once at startup: images that fail are picked up on the next run.
+func (p *imagePipeline) BackfillDateKeys(ctx context.Context) {
+
+	defer p.wg.Done()
+
+	records, err := p.db.FindImagesMissingDateKey()
+	if err != nil {
+		p.logger.Error("failed to query images missing date keys", slog.String("err", err.Error()))
+		return
+	}
+
+	if len(records) == 0 {
+		p.logger.Info("no images missing date keys, nothing to backfill")
+		return
+	}
+
+	p.logger.Info("backfilling image date keys", slog.Int("image_count", len(records)))
+
+	updated := 0
+	for _, r := range records {
+
+		// bail on shutdown
+		if ctx.Err() != nil {
+			return
+		}
+
+		imageDate, err := p.cryptor.DecryptImageField(r.ImageDate)
+		if err != nil {
+			p.logger.Error("failed to decrypt image date, skipping date key backfill",
+				slog.String("image_id", r.Id),
+				slog.String("err", err.Error()))
+			continue
+		}
+
+		// the date key of an approximate date depends on its precision, eg, "1950-00" for the 1950s
+		var dateKey string
+		if date := api.BuildPartialDate(imageDate, r.ImageDatePrecision, r.ImageDateEndYear); date != nil {
+			dateKey = date.DateKey()
+		}
+		if dateKey == "" {
+			p.logger.Warn("image date is not RFC3339, skipping date key backfill",
+				slog.String("image_id", r.Id))
+			continue
+		}
+
+		if err := p.db.UpdateImageDateKey(r.Id, dateKey); err != nil {
+			p.logger.Error("failed to update image date key",
+				slog.String("image_id", r.Id),
+				slog.String("err", err.Error()))
+			continue
+		}
+
+		updated++
+	}
+
+	p.logger.Info("successfully backfilled image date keys", slog.Int("updated_count", updated))
+}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sync"
 	"testing"
 	"time"
@@ -223,6 +224,14 @@ func TestImagePipeline_ProcessReprocessCmd(t *testing.T) {
 				moveObjectFn: func(ctx context.Context, src, dst string) error { return nil },
 			},
 		},
+		{
+			name: "date spanning several years moves cleanly, no year album work",
+			cmd:  func() ReprocessCmd { c := baseReprocessCmd(); c.NoYearAlbum = true; return c }(),
+			repo: &mockRepository{},
+			objStore: &mockObjectStorage{
+				moveObjectFn: func(ctx context.Context, src, dst string) error { return nil },
+			},
+		},
 		{
 			name: "album linking failure requeues (verified by immediate drop at max-1)",
 			cmd:  func() ReprocessCmd { c := baseReprocessCmd(); c.RetryCount = MaxReprocessRetries - 1; return c }(),
@@ -381,3 +390,170 @@ func TestImagePipeline_ReprocessQueue(t *testing.T) {
 	cancel()
 	wg.Wait()
 }
+
+func TestImagePipeline_BackfillPlaceholders(t *testing.T) {
+
+	re := make(chan ReprocessCmd, 2)
+	repo := &mockRepository{
+		findImagesMissingPlaceholdersFn: func() ([]api.ImageRecord, error) {
+			return []api.ImageRecord{
+				{Id: testUUID, Slug: testUUID, ObjectKey: "2024/" + testUUID + ".jpg"},
+				{Id: testUUID2, Slug: testUUID2, ObjectKey: "staging/" + testUUID2 + ".jpg"},
+			}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	p := &imagePipeline{
+		reprocessQueue: re,
+		wg:             &wg,
+		db:             repo,
+		cryptor:        &mockCryptor{},
+		logger:         newDiscardLogger(),
+	}
+
+	wg.Add(1)
+	p.BackfillPlaceholders(context.Background())
+	wg.Wait()
+	close(re)
+
+	got := make([]ReprocessCmd, 0, 2)
+	for cmd := range re {
+		got = append(got, cmd)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected 2 reprocess commands, got %d", len(got))
+	}
+	for _, cmd := range got {
+		if !cmd.PlaceholdersRequired || cmd.MoveRequired {
+			t.Errorf("expected placeholders-only command, got %+v", cmd)
+		}
+		if cmd.CurrentObjKey != cmd.UpdatedObjKey {
+			t.Errorf("expected object key to be unchanged, got %s -> %s", cmd.CurrentObjKey, cmd.UpdatedObjKey)
+		}
+	}
+}
+
+func TestImagePipeline_BackfillDisplayOriginals(t *testing.T) {
+
+	re := make(chan ReprocessCmd, 2)
+	repo := &mockRepository{
+		findMissingDisplayOriginalFn: func() ([]api.ImageRecord, error) {
+			return []api.ImageRecord{
+				{Id: testUUID, Slug: testUUID, ObjectKey: "2024/" + testUUID + ".jpg"},
+				{Id: testUUID2, Slug: testUUID2, ObjectKey: "staging/" + testUUID2 + ".jpg"},
+			}, nil
+		},
+	}
+
+	// the first image's display original is already in object storage, but not recorded
+	objStore := &mockObjectStorage{
+		listObjectsFn: func(ctx context.Context, prefix string) ([]string, error) {
+			if prefix == "2024/"+testUUID+"_display.jpg" {
+				return []string{prefix}, nil
+			}
+			return nil, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	p := &imagePipeline{
+		reprocessQueue: re,
+		wg:             &wg,
+		db:             repo,
+		cryptor:        &mockCryptor{},
+		objStore:       objStore,
+		logger:         newDiscardLogger(),
+	}
+
+	wg.Add(1)
+	p.BackfillDisplayOriginals(context.Background())
+	wg.Wait()
+	close(re)
+
+	got := make([]ReprocessCmd, 0, 2)
+	for cmd := range re {
+		got = append(got, cmd)
+	}
+
+	if len(got) != 1 || got[0].Id != testUUID2 || !got[0].DisplayRequired || got[0].MoveRequired {
+		t.Fatalf("expected a display-only command for %s, got %+v", testUUID2, got)
+	}
+	if !slices.Equal(repo.updateHasDisplayCalls, []string{testUUID}) {
+		t.Errorf("UpdateImageHasDisplayOriginal calls = %v, want [%s]", repo.updateHasDisplayCalls, testUUID)
+	}
+}
+
+func TestImagePipeline_BackfillDateKeys(t *testing.T) {
+
+	repo := &mockRepository{
+		findImagesMissingDateKeyFn: func() ([]api.ImageRecord, error) {
+			return []api.ImageRecord{
+				{Id: testUUID, ImageDate: "2019-07-04T18:30:00Z"},
+				{Id: testUUID2, ImageDate: "not a date"},
+				{Id: testUUID3, ImageDate: "1950-01-01T00:00:00Z", ImageDatePrecision: api.DatePrecisionDecade},
+			}, nil
+		},
+	}
+
+	var wg sync.WaitGroup
+	p := &imagePipeline{
+		wg:      &wg,
+		db:      repo,
+		cryptor: &mockCryptor{},
+		logger:  newDiscardLogger(),
+	}
+
+	wg.Add(1)
+	p.BackfillDateKeys(context.Background())
+	wg.Wait()
+
+	// unparseable dates are skipped rather than written as an empty key,
+	// and dates without a known month sort at the start of their year
+	want := []string{testUUID + "=2019-07", testUUID3 + "=1950-00"}
+	if !slices.Equal(repo.updateDateKeyCalls, want) {
+		t.Errorf("UpdateImageDateKey calls = %v, want %v", repo.updateDateKeyCalls, want)
+	}
+}
+
+func TestImagePipeline_ProcessReprocessCmd_DisplayRequired(t *testing.T) {
+
+	cmd := baseReprocessCmd()
+	cmd.MoveRequired = false
+	cmd.DisplayRequired = true
+	wantKey := fmt.Sprintf("staging/%s_display.jpg", testUUID2)
+
+	objStore := &mockObjectStorage{
+		withObjectFn: func(ctx context.Context, key string, fn func(r storage.ReadSeekCloser) error) error {
+			if key != cmd.CurrentObjKey {
+				t.Fatalf("WithObject called with unexpected key %q, want the current original %q", key, cmd.CurrentObjKey)
+			}
+			return fn(newFakeReadSeekCloser(noExifJpeg(t, 800, 600)))
+		},
+	}
+
+	var wg sync.WaitGroup
+	repo := &mockRepository{}
+	p := &imagePipeline{
+		reprocessQueue: make(chan ReprocessCmd, 1),
+		wg:             &wg,
+		db:             repo,
+		indexer:        &mockIndexer{},
+		cryptor:        &mockCryptor{},
+		objStore:       objStore,
+		logger:         newDiscardLogger(),
+	}
+
+	p.processReprocessCmd(context.Background(), cmd)
+
+	if len(objStore.moveObjectCalls) != 0 {
+		t.Errorf("MoveObject call count = %d, want 0", len(objStore.moveObjectCalls))
+	}
+	if len(objStore.putObjectCalls) != 1 || objStore.putObjectCalls[0] != wantKey {
+		t.Errorf("PutObject calls = %v, want [%s]", objStore.putObjectCalls, wantKey)
+	}
+	if !slices.Equal(repo.updateHasDisplayCalls, []string{cmd.Id}) {
+		t.Errorf("UpdateImageHasDisplayOriginal calls = %v, want [%s]", repo.updateHasDisplayCalls, cmd.Id)
+	}
+}
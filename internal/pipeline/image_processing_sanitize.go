@@ -0,0 +1,215 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+)
+
+const (
+	// DisplayJpegQuality is the quality used to re-encode the sanitized display original,
+	// higher than JpegQuality since it stands in for the original for patrons.
+	DisplayJpegQuality int = 92
+
+	// iccMaxChunk is the maximum ICC profile payload per jpeg APP2 segment:
+	// 65535 segment length - 2 length bytes - 12 byte "ICC_PROFILE\0" tag - 2 sequence bytes.
+	iccMaxChunk = 65519
+)
+
+// jpeg/png signatures and tags needed to locate embedded color profiles.
+var (
+	iccTag       = []byte("ICC_PROFILE\x00")
+	pngSignature = []byte("\x89PNG\r\n\x1a\n")
+)
+
+// buildDisplayOriginal is a helper which builds the sanitized "display original" served to patrons
+// in place of the untouched original upload.  Re-encoding from decoded pixels drops all
+// exif/xmp/iptc metadata (gps, maker notes, serials, owner names), so only the color profile,
+// if any, is re-embedded. Orientation is preserved by rotating the pixels before encoding.
+func buildDisplayOriginal(src image.Image, iccProfile []byte) ([]byte, error) {
+
+	if src == nil {
+		return nil, errors.New("source image is nil")
+	}
+
+	encoded, err := encodeToJpeg(src, DisplayJpegQuality)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(iccProfile) == 0 {
+		return encoded, nil
+	}
+
+	return embedIccProfile(encoded, iccProfile)
+}
+
+// readIccProfile reads the embedded icc color profile from a jpeg or png stream, if present.
+// Returns nil and no error if the image does not have a color profile.
+// Note: rewinds the reader before returning.
+func readIccProfile(r io.ReadSeeker) ([]byte, error) {
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind reader: %v", err)
+	}
+	defer func() { _, _ = r.Seek(0, io.SeekStart) }()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("failed to read image header: %v", err)
+	}
+
+	switch {
+	case header[0] == 0xFF && header[1] == 0xD8:
+		if _, err := r.Seek(2, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek past jpeg start of image: %v", err)
+		}
+		return readJpegIcc(r)
+	case bytes.Equal(header, pngSignature):
+		return readPngIcc(r)
+	default:
+		return nil, nil // unsupported format for color profiles, nothing to keep
+	}
+}
+
+// readJpegIcc collects the icc profile from a jpeg's APP2 segments, which may be split across
+// several segments with sequence numbers. The reader must be positioned just after the SOI marker.
+func readJpegIcc(r io.Reader) ([]byte, error) {
+
+	chunks := make(map[int][]byte)
+	total := 0
+
+	for {
+		var marker [2]byte
+		if _, err := io.ReadFull(r, marker[:]); err != nil {
+			return nil, fmt.Errorf("failed to read jpeg marker: %v", err)
+		}
+		if marker[0] != 0xFF {
+			return nil, fmt.Errorf("invalid jpeg marker 0x%02x%02x", marker[0], marker[1])
+		}
+
+		// start of scan or end of image: no more metadata segments
+		if marker[1] == 0xDA || marker[1] == 0xD9 {
+			break
+		}
+
+		var length uint16
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, fmt.Errorf("failed to read jpeg segment length: %v", err)
+		}
+		if length < 2 {
+			return nil, fmt.Errorf("invalid jpeg segment length %d", length)
+		}
+
+		payload := make([]byte, length-2)
+		if _, err := io.ReadFull(r, payload); err != nil {
+			return nil, fmt.Errorf("failed to read jpeg segment: %v", err)
+		}
+
+		// APP2 ICC_PROFILE segment: tag, sequence number (1-based), total count, profile chunk
+		if marker[1] == 0xE2 && len(payload) > len(iccTag)+2 && bytes.HasPrefix(payload, iccTag) {
+			seq := int(payload[len(iccTag)])
+			total = int(payload[len(iccTag)+1])
+			chunks[seq] = payload[len(iccTag)+2:]
+		}
+	}
+
+	if len(chunks) == 0 {
+		return nil, nil
+	}
+
+	var profile bytes.Buffer
+	for i := 1; i <= total; i++ {
+		chunk, ok := chunks[i]
+		if !ok {
+			return nil, fmt.Errorf("icc profile chunk %d of %d is missing", i, total)
+		}
+		profile.Write(chunk)
+	}
+
+	return profile.Bytes(), nil
+}
+
+// readPngIcc reads the zlib compressed icc profile from a png's iCCP chunk.
+// The reader must be positioned just after the png signature.
+func readPngIcc(r io.Reader) ([]byte, error) {
+
+	for {
+		var length uint32
+		if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+			return nil, fmt.Errorf("failed to read png chunk length: %v", err)
+		}
+
+		var chunkType [4]byte
+		if _, err := io.ReadFull(r, chunkType[:]); err != nil {
+			return nil, fmt.Errorf("failed to read png chunk type: %v", err)
+		}
+
+		switch string(chunkType[:]) {
+		case "iCCP":
+			payload := make([]byte, length)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return nil, fmt.Errorf("failed to read png iCCP chunk: %v", err)
+			}
+
+			// profile name (null terminated), compression method (1 byte), compressed profile
+			nul := bytes.IndexByte(payload, 0)
+			if nul < 0 || nul+2 > len(payload) {
+				return nil, errors.New("malformed png iCCP chunk")
+			}
+
+			zr, err := zlib.NewReader(bytes.NewReader(payload[nul+2:]))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decompress png icc profile: %v", err)
+			}
+			defer zr.Close()
+
+			return io.ReadAll(zr)
+		case "IDAT", "IEND":
+			// the iCCP chunk must precede image data
+			return nil, nil
+		}
+
+		// skip the chunk data and crc
+		if _, err := io.CopyN(io.Discard, r, int64(length)+4); err != nil {
+			return nil, fmt.Errorf("failed to skip png chunk: %v", err)
+		}
+	}
+}
+
+// embedIccProfile inserts the icc profile as APP2 segments directly after the
+// jpeg SOI marker of the encoded image.
+func embedIccProfile(encoded, profile []byte) ([]byte, error) {
+
+	if len(encoded) < 2 || encoded[0] != 0xFF || encoded[1] != 0xD8 {
+		return nil, errors.New("encoded image is not a jpeg")
+	}
+
+	count := (len(profile) + iccMaxChunk - 1) / iccMaxChunk
+	if count > 255 {
+		return nil, fmt.Errorf("icc profile too large to embed: %d bytes", len(profile))
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(encoded) + len(profile) + count*(len(iccTag)+6))
+	out.Write(encoded[:2])
+
+	for i := 0; i < count; i++ {
+		start := i * iccMaxChunk
+		end := min(start+iccMaxChunk, len(profile))
+
+		out.Write([]byte{0xFF, 0xE2})
+		_ = binary.Write(&out, binary.BigEndian, uint16(2+len(iccTag)+2+end-start))
+		out.Write(iccTag)
+		out.Write([]byte{byte(i + 1), byte(count)})
+		out.Write(profile[start:end])
+	}
+
+	out.Write(encoded[2:])
+
+	return out.Bytes(), nil
+}
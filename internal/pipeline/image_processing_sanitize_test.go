@@ -0,0 +1,121 @@
+package pipeline
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// testProfile builds a fake icc profile of the given size with a repeating pattern,
+// so chunk ordering mistakes show up as mismatches.
+func testProfile(size int) []byte {
+	p := make([]byte, size)
+	for i := range p {
+		p[i] = byte(i % 251)
+	}
+	return p
+}
+
+// pngWithIcc builds a minimal png byte stream containing an iCCP chunk ahead of IDAT.
+func pngWithIcc(t *testing.T, profile []byte) []byte {
+	t.Helper()
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(profile); err != nil {
+		t.Fatalf("failed to compress profile: %v", err)
+	}
+	zw.Close()
+
+	chunk := func(buf *bytes.Buffer, typ string, data []byte) {
+		_ = binary.Write(buf, binary.BigEndian, uint32(len(data)))
+		buf.WriteString(typ)
+		buf.Write(data)
+		_ = binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(append([]byte(typ), data...)))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(pngSignature)
+	chunk(&buf, "IHDR", []byte{0, 0, 0, 1, 0, 0, 0, 1, 8, 2, 0, 0, 0})
+	chunk(&buf, "iCCP", append([]byte("sRGB\x00\x00"), compressed.Bytes()...))
+	chunk(&buf, "IDAT", nil)
+	chunk(&buf, "IEND", nil)
+
+	return buf.Bytes()
+}
+
+func TestBuildDisplayOriginal_IccRoundTrip(t *testing.T) {
+
+	src := image.NewRGBA(image.Rect(0, 0, 16, 16))
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x * 16), G: uint8(y * 16), B: 128, A: 255})
+		}
+	}
+
+	tests := []struct {
+		name    string
+		profile []byte
+	}{
+		{"no profile", nil},
+		{"single segment profile", testProfile(3144)},
+		{"multi segment profile", testProfile(iccMaxChunk*2 + 100)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			out, err := buildDisplayOriginal(src, tt.profile)
+			if err != nil {
+				t.Fatalf("buildDisplayOriginal() unexpected error: %v", err)
+			}
+
+			// must still be a valid jpeg
+			if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+				t.Fatalf("display original is not a valid jpeg: %v", err)
+			}
+
+			// must not carry an exif segment
+			if bytes.Contains(out, []byte("Exif\x00\x00")) {
+				t.Error("display original contains an exif segment")
+			}
+
+			got, err := readIccProfile(bytes.NewReader(out))
+			if err != nil {
+				t.Fatalf("readIccProfile() unexpected error: %v", err)
+			}
+			if !bytes.Equal(got, tt.profile) {
+				t.Errorf("readIccProfile() returned %d bytes, want %d", len(got), len(tt.profile))
+			}
+		})
+	}
+}
+
+func TestReadIccProfile_Png(t *testing.T) {
+
+	profile := testProfile(512)
+
+	got, err := readIccProfile(bytes.NewReader(pngWithIcc(t, profile)))
+	if err != nil {
+		t.Fatalf("readIccProfile() unexpected error: %v", err)
+	}
+	if !bytes.Equal(got, profile) {
+		t.Errorf("readIccProfile() returned %d bytes, want %d", len(got), len(profile))
+	}
+}
+
+func TestReadIccProfile_Unsupported(t *testing.T) {
+
+	got, err := readIccProfile(bytes.NewReader([]byte("GIF89a-not-a-jpeg")))
+	if err != nil {
+		t.Fatalf("readIccProfile() unexpected error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("readIccProfile() = %d bytes, want nil", len(got))
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"image"
+	"log/slog"
 	"path/filepath"
 	"strconv"
 	"sync"
@@ -28,9 +29,16 @@ func (p *imagePipeline) UploadQueue(ctx context.Context) {
 
 	defer p.wg.Done()
 
+	// uploads processed recently, by object key: object storage may send more than one notification
+	// for an upload, and uploads made through pixie are handed to the pipeline directly as well
+	recent := make(map[string]time.Time)
+
 	for {
-		// block until a webhook is received, the channel is closed, or the context is cancelled
-		var webhook storage.WebhookPutObject
+		// block until a webhook or direct upload is received, the channel is closed, or the context is cancelled
+		var (
+			objectKey string
+			process   func()
+		)
 		select {
 		case <-ctx.Done():
 			return
@@ -38,18 +46,53 @@ func (p *imagePipeline) UploadQueue(ctx context.Context) {
 			if !ok {
 				return
 			}
-			webhook = w
+			objectKey = w.MinioKey
+			process = func() { p.processImgUpload(ctx, w) }
+		case cmd, ok := <-p.directUploadQueue:
+			if !ok {
+				return
+			}
+			objectKey = cmd.ObjectKey
+			process = func() { p.processDirectUpload(ctx, cmd) }
+		}
+
+		if isDuplicateUpload(recent, objectKey, time.Now()) {
+			p.logger.Info("skipping upload already processed", "image_object_key", objectKey)
+			continue
 		}
 
-		p.processImgUpload(ctx, webhook)
+		process()
 	}
 }
 
+// isDuplicateUpload is a helper which checks if an upload of the object key was processed within the
+// upload dedupe window, recording the upload if not.  Keys are compared without the bucket name,
+// which notifications include, and keys which cannot be parsed are never duplicates: processing reports them.
+func isDuplicateUpload(recent map[string]time.Time, objectKey string, now time.Time) bool {
+
+	// forget uploads outside the window so the map does not grow
+	for key, at := range recent {
+		if now.Sub(at) > UploadDedupeWindow {
+			delete(recent, key)
+		}
+	}
+
+	dir, file, _, _, err := ParseObjectKey(objectKey)
+	if err != nil {
+		return false
+	}
+
+	key := fmt.Sprintf("%s/%s", dir, file)
+	if _, ok := recent[key]; ok {
+		return true
+	}
+	recent[key] = now
+
+	return false
+}
+
 // processImgUpload processes a single webhook to upload an image:
 func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.WebhookPutObject) {
-	// child context with timeout for processing each image in the pipeline, to prevent hanging
-	itemCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
-	defer cancel()
 
 	// generate telemetry -> in this case just a trace parent for web calls
 	telemetry := &telemetry.Telemetry{
@@ -71,12 +114,39 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 		"image_event_name", webhook.MinioEventName,
 	)
 
+	p.processUploadedObject(ctx, log, webhook.MinioKey)
+}
+
+// processDirectUpload processes an image file uploaded through pixie rather than directly to object storage,
+// the same as an image whose upload notification was received.
+func (p *imagePipeline) processDirectUpload(ctx context.Context, cmd UploadCmd) {
+
+	// generate telemetry -> in this case just a trace parent for web calls
+	telemetry := &telemetry.Telemetry{
+		Traceparent: *telemetry.NewTraceparent(),
+	}
+
+	log := p.logger.With(telemetry.TelemetryFields()...)
+
+	log.Info("processing direct image upload", "image_object_key", cmd.ObjectKey)
+
+	p.processUploadedObject(ctx, log, cmd.ObjectKey)
+}
+
+// processUploadedObject processes an image file which has finished uploading to object storage:
+// reading the exif data, generating the renditions, and moving it to its directory.
+func (p *imagePipeline) processUploadedObject(ctx context.Context, log *slog.Logger, objectKey string) {
+
+	// child context with timeout for processing each image in the pipeline, to prevent hanging
+	itemCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
 	// parse and validate the object key to get the slug
 	// the object key is in the format of "directory/{slug}.extension"
-	dir, file, ext, slug, err := ParseObjectKey(webhook.MinioKey)
+	dir, file, ext, slug, err := ParseObjectKey(objectKey)
 	if err != nil {
 		log.Error(
-			"failed to parse object key from webhook", "image_object_key", webhook.MinioKey, "err", err.Error())
+			"failed to parse uploaded object key", "image_object_key", objectKey, "err", err.Error())
 		return
 	}
 
@@ -92,7 +162,7 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 		// NOTE: some images may not have exif data, so checks for default/zero values are needed
 		meta, err := ReadExif(r)
 		if err != nil {
-			return fmt.Errorf("failed to read exif data for object %s: %v", webhook.MinioKey, err)
+			return fmt.Errorf("failed to read exif data for object %s: %v", objectKey, err)
 		}
 
 		//get the image record from the database
@@ -123,6 +193,7 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 
 			// update/set the image ImageDate field
 			img.ImageDate = meta.TakenAt.UTC().Format(time.RFC3339)
+			img.ImageDateKey = api.BuildImageDateKey(img.ImageDate)
 
 			// set the directory to the year from the image date -> ObjectKey
 			dir = year
@@ -144,21 +215,39 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 			img.Height = meta.Height
 		}
 
-		// TODO add gps coordinates to image data
-
 		// generate src set of different image resolutions + blur/placeholder
-		src, _, err := image.Decode(r)
+		src, err := decodeImage(r)
 		if err != nil {
-			return fmt.Errorf("failed to image-format-decode (jpeg/png) image for object %s: %v", img.ObjectKey, err)
+			return fmt.Errorf("failed to decode image for object %s: %v", img.ObjectKey, err)
 		}
 
 		// apply orientation if needed -> default is zero, so dont need to check if exif existed.
 		src = rotateImage(src, meta.Rotation)
 
+		// read the color profile so it survives sanitization of the display original.
+		// failure is not fatal: the display original falls back to the default (sRGB) profile.
+		icc, err := readIccProfile(r)
+		if err != nil {
+			log.Warn("failed to read color profile for image", "image_slug", slug, "err", err.Error())
+		}
+
+		// downscale once: used for both the blur/placeholder image and the inline placeholders
+		blur := resizeToLongestSide(src)
+
+		// compute the inline placeholders returned with image data so clients do not need
+		// to fetch the blur image.  Failure is not fatal: the blur image is still uploaded.
+		blurHash, color, err := buildPlaceholders(blur)
+		if err != nil {
+			log.Warn("failed to build inline placeholders for image", "image_slug", slug, "err", err.Error())
+		} else {
+			img.BlurHash = blurHash
+			img.DominantColor = color
+		}
+
 		// concurrently generate and upload the different resolution images, tiles, and blur/placeholder
 		var (
 			wg    sync.WaitGroup
-			errCh = make(chan error, len(util.ResolutionWidthsImages)+len(util.ResolutionWidthsTiles)+2)
+			errCh = make(chan error, len(util.ResolutionWidthsImages)+len(util.ResolutionWidthsTiles)+3)
 		)
 
 		// generate and upload the different resolution images for src set
@@ -213,7 +302,6 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 
 			defer wg.Done()
 
-			blur := resizeToLongestSide(src)
 			encoded, err := encodeToJpeg(blur, JpegQuality)
 			if err != nil {
 				ch <- fmt.Errorf("failed to encode blur/placeholder image to jpeg for uploaded object %s: %v", img.ObjectKey, err)
@@ -230,13 +318,35 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 			log.Info("upload successfully processed blur/placeholder", "image_object_key", blurKey)
 		}(errCh, &wg)
 
+		// generate and upload the sanitized display original: the untouched original
+		// keeps its gps/maker notes/serials and is never served to patrons without download permission
+		wg.Add(1)
+		go func(ch chan error, wg *sync.WaitGroup) {
+
+			defer wg.Done()
+
+			encoded, err := buildDisplayOriginal(src, icc)
+			if err != nil {
+				ch <- fmt.Errorf("failed to build display original for uploaded object %s: %v", img.ObjectKey, err)
+				return
+			}
+
+			displayKey := fmt.Sprintf("%s/%s_display%s", filepath.Dir(img.ObjectKey), slug, ext)
+			if err := p.objStore.PutObject(itemCtx, displayKey, encoded, img.FileType); err != nil {
+				ch <- fmt.Errorf("failed to upload display original %s to object storage: %v", displayKey, err)
+				return
+			}
+
+			log.Info("upload successfully processed display original", "image_object_key", displayKey)
+		}(errCh, &wg)
+
 		// move the image to the correct directory in object storage
 		wg.Add(1)
 		go func(ch chan error, wg *sync.WaitGroup) {
 			defer wg.Done()
 
 			if err := p.objStore.MoveObject(itemCtx, uploadKey, img.ObjectKey); err != nil {
-				ch <- fmt.Errorf("failed to move uploaded object %s to new location %s in object storage: %v", webhook.MinioKey, img.ObjectKey, err)
+				ch <- fmt.Errorf("failed to move uploaded object %s to new location %s in object storage: %v", objectKey, img.ObjectKey, err)
 				return
 			}
 
@@ -257,7 +367,7 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 			for err := range errCh {
 				errs = append(errs, err)
 			}
-			return fmt.Errorf("one or more errors occurred during image processing for object %s: %v", webhook.MinioKey, errors.Join(errs...))
+			return fmt.Errorf("one or more errors occurred during image processing for object %s: %v", objectKey, errors.Join(errs...))
 		}
 
 		// check if directroy is a year  or if it is 'staging' and set is_published flag accordingly
@@ -273,6 +383,18 @@ func (p *imagePipeline) processImgUpload(ctx context.Context, webhook storage.We
 			return err
 		}
 
+		// record gps presence so smart albums can filter on it: the coordinates themselves are not stored
+		if meta.Latitude != nil && meta.Longitude != nil {
+			if err := p.db.UpdateImageHasGps(img.Id, true); err != nil {
+				return fmt.Errorf("failed to update gps flag for image with id %s: %v", img.Id, err)
+			}
+		}
+
+		// record the display original so the backfill at startup does not need to look for it
+		if err := p.db.UpdateImageHasDisplayOriginal(img.Id); err != nil {
+			return fmt.Errorf("failed to update display original flag for image with id %s: %v", img.Id, err)
+		}
+
 		log.Info("successfully processed image", "image_slug", slug)
 
 		return nil
@@ -422,7 +544,8 @@ func (p *imagePipeline) linkToAlbum(title string, img *api.ImageRecord) error {
 
 	// check if album with title already exists
 	var albumId string
-	if a, ok := albumMap[title]; ok {
+	a, existing := albumMap[title]
+	if existing {
 		albumId = a.Id
 	} else {
 		// create a new album record
@@ -452,6 +575,7 @@ func (p *imagePipeline) linkToAlbum(title string, img *api.ImageRecord) error {
 			CreatedAt:   data.CustomTime{Time: time.Now().UTC()},
 			UpdatedAt:   data.CustomTime{Time: time.Now().UTC()},
 			IsArchived:  false,
+			Version:     1,
 		}
 
 		// encrypt the album record fields before inserting
@@ -475,7 +599,10 @@ func (p *imagePipeline) linkToAlbum(title string, img *api.ImageRecord) error {
 		ImageId:   img.Id,
 		CreatedAt: data.CustomTime{Time: time.Now().UTC()},
 	}
-	if err := p.db.InsertAlbumImageXref(xref); err != nil {
+
+	// the image inherits the album's permissions, if the album passes them on, in the same transaction
+	// note: a newly created album has no permissions to inherit
+	if err := p.db.InsertAlbumImageXref(xref, existing); err != nil {
 		return fmt.Errorf("failed to link image with id %s to album with id %s: %v", img.Id, albumId, err)
 	}
 
@@ -263,6 +263,7 @@ func TestImagePipeline_LinkToAlbum(t *testing.T) {
 		wantInsertNew  bool
 		wantXrefCalled bool
 		wantAlbumId    string // only checked when wantXrefCalled
+		wantInherit    bool   // image inherits the album's permissions, only checked when wantXrefCalled
 	}{
 		{
 			name:    "empty title is rejected",
@@ -332,6 +333,7 @@ func TestImagePipeline_LinkToAlbum(t *testing.T) {
 			cryptor:        &mockCryptor{},
 			wantXrefCalled: true,
 			wantAlbumId:    "existing-album-id",
+			wantInherit:    true,
 		},
 		{
 			name:  "xref insert error on existing album propagates",
@@ -341,7 +343,7 @@ func TestImagePipeline_LinkToAlbum(t *testing.T) {
 				findAllAlbumsFn: func() ([]api.AlbumRecord, error) {
 					return []api.AlbumRecord{{Id: "existing-album-id", Title: "2024"}}, nil
 				},
-				insertAlbumImageXrefFn: func(xref api.AlbumImageXref) error { return fmt.Errorf("fk violation") },
+				insertAlbumImageXrefFn: func(xref api.AlbumImageXref, inherit bool) error { return fmt.Errorf("fk violation") },
 			},
 			cryptor: &mockCryptor{},
 			wantErr: true,
@@ -379,6 +381,7 @@ func TestImagePipeline_LinkToAlbum(t *testing.T) {
 			cryptor:        &mockCryptor{},
 			wantInsertNew:  true,
 			wantXrefCalled: true,
+			wantInherit:    false,
 		},
 	}
 
@@ -400,6 +403,9 @@ func TestImagePipeline_LinkToAlbum(t *testing.T) {
 			if got := len(tt.repo.insertAlbumXrefCalls); (got == 1) != tt.wantXrefCalled {
 				t.Errorf("InsertAlbumImageXref call count = %d, want called = %v", got, tt.wantXrefCalled)
 			}
+			if len(tt.repo.insertAlbumXrefInherits) == 1 && tt.repo.insertAlbumXrefInherits[0] != tt.wantInherit {
+				t.Errorf("InsertAlbumImageXref inherit = %v, want %v", tt.repo.insertAlbumXrefInherits[0], tt.wantInherit)
+			}
 			if tt.wantAlbumId != "" && len(tt.repo.insertAlbumXrefCalls) == 1 {
 				if got := tt.repo.insertAlbumXrefCalls[0].AlbumId; got != tt.wantAlbumId {
 					t.Errorf("xref AlbumId = %q, want %q", got, tt.wantAlbumId)
@@ -553,9 +559,9 @@ func TestImagePipeline_ProcessImgUpload(t *testing.T) {
 				t.Errorf("ObjectKey = %q, want %q", updated.ObjectKey, tt.wantObjectKey)
 			}
 
-			// every configured resolution, tile, and blur derivative plus the
-			// original move should have been attempted.
-			wantPuts := len(util.ResolutionWidthsImages) + len(util.ResolutionWidthsTiles) + 1
+			// every configured resolution, tile, blur, and display original derivative
+			// plus the original move should have been attempted.
+			wantPuts := len(util.ResolutionWidthsImages) + len(util.ResolutionWidthsTiles) + 2
 			if got := len(objStore.putObjectCalls); got != wantPuts {
 				t.Errorf("PutObject call count = %d, want %d", got, wantPuts)
 			}
@@ -611,3 +617,127 @@ func TestImagePipeline_UploadQueue(t *testing.T) {
 	cancel()
 	wg.Wait() // must return promptly once ctx is cancelled
 }
+
+func TestIsDuplicateUpload(t *testing.T) {
+
+	now := time.Now()
+	key := "uploads/" + testUUID2 + ".jpg"
+
+	tests := []struct {
+		name      string
+		recent    map[string]time.Time
+		objectKey string
+		want      bool
+		wantKeys  int
+	}{
+		{
+			name:      "first upload is not a duplicate",
+			recent:    map[string]time.Time{},
+			objectKey: key,
+			want:      false,
+			wantKeys:  1,
+		},
+		{
+			name:      "direct upload then notification with bucket prefix",
+			recent:    map[string]time.Time{key: now.Add(-time.Minute)},
+			objectKey: "gallery-bucket/" + key,
+			want:      true,
+			wantKeys:  1,
+		},
+		{
+			name:      "upload outside the window is processed again",
+			recent:    map[string]time.Time{key: now.Add(-UploadDedupeWindow - time.Second)},
+			objectKey: key,
+			want:      false,
+			wantKeys:  1,
+		},
+		{
+			name:      "different file is not a duplicate",
+			recent:    map[string]time.Time{key: now.Add(-time.Minute)},
+			objectKey: "uploads/" + testUUID + ".jpg",
+			want:      false,
+			wantKeys:  2,
+		},
+		{
+			name:      "unparseable key is left to processing to reject",
+			recent:    map[string]time.Time{},
+			objectKey: "uploads/not-an-image",
+			want:      false,
+			wantKeys:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isDuplicateUpload(tt.recent, tt.objectKey, now); got != tt.want {
+				t.Errorf("isDuplicateUpload() = %v, want %v", got, tt.want)
+			}
+			if got := len(tt.recent); got != tt.wantKeys {
+				t.Errorf("recent uploads = %d, want %d", got, tt.wantKeys)
+			}
+		})
+	}
+}
+
+func TestImagePipeline_UploadQueue_SkipsDuplicateUploads(t *testing.T) {
+
+	up := make(chan storage.WebhookPutObject, 1)
+	du := make(chan UploadCmd, 1)
+	repo := &mockRepository{
+		findImageFn: func(slugIndex string) (*api.ImageRecord, error) {
+			img := baseImageRecord()
+			return &img, nil
+		},
+	}
+	objStore := &mockObjectStorage{
+		withObjectFn: func(ctx context.Context, key string, fn func(r storage.ReadSeekCloser) error) error {
+			return fn(newFakeReadSeekCloser(noExifJpeg(t, 20, 20)))
+		},
+	}
+
+	var wg sync.WaitGroup
+	p := &imagePipeline{
+		uploadQueue:       up,
+		directUploadQueue: du,
+		wg:                &wg,
+		db:                repo,
+		indexer:           &mockIndexer{},
+		cryptor:           &mockCryptor{},
+		objStore:          objStore,
+		logger:            newDiscardLogger(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg.Add(1)
+	go p.UploadQueue(ctx)
+
+	// a file streamed through pixie, then object storage's notification of the same put
+	du <- UploadCmd{ObjectKey: "uploads/" + testUUID2 + ".jpg"}
+	up <- storage.WebhookPutObject{MinioKey: "gallery-bucket/uploads/" + testUUID2 + ".jpg"}
+
+	deadline := time.After(2 * time.Second)
+	for repo.updateImageCallCount() == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for UploadQueue to process the direct upload")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	// wait for the notification to be drained, then give it a moment to be (not) processed
+	for len(up) > 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for UploadQueue to drain the webhook")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+	wg.Wait()
+
+	if got := repo.updateImageCallCount(); got != 1 {
+		t.Errorf("UpdateImage call count = %d, want 1", got)
+	}
+}
@@ -3,6 +3,7 @@ package pipeline
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -45,15 +46,30 @@ type mockRepository struct {
 	findImageFn            func(slugIndex string) (*api.ImageRecord, error)
 	findImageAlbumsFn      func(imageId string) ([]api.AlbumRecord, error)
 	insertAlbumFn          func(record api.AlbumRecord) error
-	insertAlbumImageXrefFn func(xref api.AlbumImageXref) error
+	insertAlbumImageXrefFn func(xref api.AlbumImageXref, inherit bool) error
 	updateImageFn          func(record api.ImageRecord) error
 
+	findImagesMissingPlaceholdersFn func() ([]api.ImageRecord, error)
+	findMissingDisplayOriginalFn    func() ([]api.ImageRecord, error)
+	updateImagePlaceholdersFn       func(imageId, blurHash, dominantColor string) error
+	findImagesMissingDateKeyFn      func() ([]api.ImageRecord, error)
+	updateImageDateKeyFn            func(imageId, dateKey string) error
+	updateImageHasGpsFn             func(imageId string, hasGps bool) error
+	updateHasDisplayOriginalFn      func(imageId string) error
+
 	insertAlbumCalls     []api.AlbumRecord
 	insertAlbumXrefCalls []api.AlbumImageXref
 	updateImageCalls     []api.ImageRecord
 	findImageAlbumsCalls []string
 	findImageCalls       []string
 	findAllAlbumsCalls   int
+
+	updatePlaceholdersCalls []string // image ids
+	updateDateKeyCalls      []string // image id + "=" + date key
+	updateHasGpsCalls       []string // image ids
+	updateHasDisplayCalls   []string // image ids
+
+	insertAlbumXrefInherits []bool // whether each xref insert inherits the album's permissions
 }
 
 var _ Repository = (*mockRepository)(nil)
@@ -102,13 +118,14 @@ func (m *mockRepository) InsertAlbum(record api.AlbumRecord) error {
 	return nil
 }
 
-func (m *mockRepository) InsertAlbumImageXref(xref api.AlbumImageXref) error {
+func (m *mockRepository) InsertAlbumImageXref(xref api.AlbumImageXref, inherit bool) error {
 	m.mu.Lock()
 	m.insertAlbumXrefCalls = append(m.insertAlbumXrefCalls, xref)
+	m.insertAlbumXrefInherits = append(m.insertAlbumXrefInherits, inherit)
 	m.mu.Unlock()
 
 	if m.insertAlbumImageXrefFn != nil {
-		return m.insertAlbumImageXrefFn(xref)
+		return m.insertAlbumImageXrefFn(xref, inherit)
 	}
 	return nil
 }
@@ -140,6 +157,71 @@ func (m *mockRepository) UpdateImage(record api.ImageRecord) error {
 	return nil
 }
 
+func (m *mockRepository) FindImagesMissingPlaceholders() ([]api.ImageRecord, error) {
+	if m.findImagesMissingPlaceholdersFn != nil {
+		return m.findImagesMissingPlaceholdersFn()
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) FindImagesMissingDisplayOriginal() ([]api.ImageRecord, error) {
+	if m.findMissingDisplayOriginalFn != nil {
+		return m.findMissingDisplayOriginalFn()
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) UpdateImagePlaceholders(imageId, blurHash, dominantColor string) error {
+	m.mu.Lock()
+	m.updatePlaceholdersCalls = append(m.updatePlaceholdersCalls, imageId)
+	m.mu.Unlock()
+
+	if m.updateImagePlaceholdersFn != nil {
+		return m.updateImagePlaceholdersFn(imageId, blurHash, dominantColor)
+	}
+	return nil
+}
+
+func (m *mockRepository) FindImagesMissingDateKey() ([]api.ImageRecord, error) {
+	if m.findImagesMissingDateKeyFn != nil {
+		return m.findImagesMissingDateKeyFn()
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) UpdateImageDateKey(imageId, dateKey string) error {
+	m.mu.Lock()
+	m.updateDateKeyCalls = append(m.updateDateKeyCalls, imageId+"="+dateKey)
+	m.mu.Unlock()
+
+	if m.updateImageDateKeyFn != nil {
+		return m.updateImageDateKeyFn(imageId, dateKey)
+	}
+	return nil
+}
+
+func (m *mockRepository) UpdateImageHasGps(imageId string, hasGps bool) error {
+	m.mu.Lock()
+	m.updateHasGpsCalls = append(m.updateHasGpsCalls, imageId)
+	m.mu.Unlock()
+
+	if m.updateImageHasGpsFn != nil {
+		return m.updateImageHasGpsFn(imageId, hasGps)
+	}
+	return nil
+}
+
+func (m *mockRepository) UpdateImageHasDisplayOriginal(imageId string) error {
+	m.mu.Lock()
+	m.updateHasDisplayCalls = append(m.updateHasDisplayCalls, imageId)
+	m.mu.Unlock()
+
+	if m.updateHasDisplayOriginalFn != nil {
+		return m.updateHasDisplayOriginalFn(imageId)
+	}
+	return nil
+}
+
 // ------------------------------------------------------------------
 // data.Indexer mock
 // ------------------------------------------------------------------
@@ -179,6 +261,7 @@ type mockCryptor struct {
 	encryptImageDataFn   func(i *api.ImageData) error
 	decryptImageDataFn   func(i *api.ImageData) error
 	decryptImageRecordFn func(i *api.ImageRecord) error
+	decryptImageFieldFn  func(ciphertext string) (string, error)
 }
 
 func (m *mockCryptor) EncryptAlbumRecord(a *api.AlbumRecord) error {
@@ -209,6 +292,20 @@ func (m *mockCryptor) DecryptAlbum(a *api.Album) error {
 	return nil
 }
 
+// smart album rules round trip through plain json by default
+func (m *mockCryptor) EncryptSmartAlbumRules(r api.SmartAlbumRules) (string, error) {
+	b, err := json.Marshal(r)
+	return string(b), err
+}
+
+func (m *mockCryptor) DecryptSmartAlbumRules(ciphertext string) (*api.SmartAlbumRules, error) {
+	var r api.SmartAlbumRules
+	if err := json.Unmarshal([]byte(ciphertext), &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
 func (m *mockCryptor) DecryptAlbumImage(r *api.AlbumImageRecord) error {
 	if m.decryptAlbumImageFn != nil {
 		return m.decryptAlbumImageFn(r)
@@ -244,6 +341,13 @@ func (m *mockCryptor) DecryptImageRecord(i *api.ImageRecord) error {
 	return nil
 }
 
+func (m *mockCryptor) DecryptImageField(ciphertext string) (string, error) {
+	if m.decryptImageFieldFn != nil {
+		return m.decryptImageFieldFn(ciphertext)
+	}
+	return ciphertext, nil
+}
+
 // ------------------------------------------------------------------
 // storage.ObjectStorage mock
 // ------------------------------------------------------------------
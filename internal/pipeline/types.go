@@ -25,6 +25,7 @@ import (
 	"github.com/tdeslauriers/pixie/pkg/api"
 
 	redraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/tiff" // registers the tiff decoder, eg, for high resolution scans
 )
 
 // ImagePipeline provides methods for processing image files submitted to the pipeline.
@@ -33,6 +34,7 @@ type ImagePipline interface {
 	// UploadQueue processes images submitted to the pipeline queue, parsing the webhook,
 	// reading the exif data if it exists, generating thumbnails, and moving the image to the correct
 	// directory in object storage, typically based on the image year date.
+	// Images uploaded through pixie, rather than directly to object storage, are processed the same way.
 	UploadQueue(ctx context.Context)
 
 	// ReprocessQueue reprocesses images in the pipeline queue, based on the ReprocessCmd instructions/criteria.
@@ -46,12 +48,25 @@ type ImagePipline interface {
 	// cannot be reconciled easily.  It can also be used to delete any image but that is a more rare use case and images can
 	// be archived instead of deleted in most cases.
 	DeletionQueue(ctx context.Context)
+
+	// BackfillPlaceholders finds processed images which are missing their inline blur hash/dominant color
+	// placeholders and submits a reprocess command for each to the reprocess queue.
+	BackfillPlaceholders(ctx context.Context)
+
+	// BackfillDisplayOriginals finds processed images which are missing their sanitized display original
+	// in object storage and submits a reprocess command for each to the reprocess queue.
+	BackfillDisplayOriginals(ctx context.Context)
+
+	// BackfillDateKeys finds images which have an image date but are missing their sortable
+	// image date key and sets it from the decrypted image date.
+	BackfillDateKeys(ctx context.Context)
 }
 
 // NewImagePipeline creates a new instance of ImageProcessor, returning
 // a pointer to the concrete implementation.
 func NewImagePipeline(
 	up chan storage.WebhookPutObject,
+	du chan UploadCmd,
 	re chan ReprocessCmd,
 	de chan DeletionCmd,
 	wg *sync.WaitGroup,
@@ -62,10 +77,11 @@ func NewImagePipeline(
 ) ImagePipline {
 
 	return &imagePipeline{
-		uploadQueue:    up,
-		reprocessQueue: re,
-		deletionQueue:  de,
-		wg:             wg,
+		uploadQueue:       up,
+		directUploadQueue: du,
+		reprocessQueue:    re,
+		deletionQueue:     de,
+		wg:                wg,
 
 		db:       db,
 		indexer:  i,
@@ -83,10 +99,11 @@ var _ ImagePipline = (*imagePipeline)(nil)
 // imagePipeline is the concrete implementation of the ImageProcessor interface, which
 // provides methods for processing image files submitted to the pipeline.
 type imagePipeline struct {
-	uploadQueue    chan storage.WebhookPutObject
-	reprocessQueue chan ReprocessCmd
-	deletionQueue  chan DeletionCmd
-	wg             *sync.WaitGroup
+	uploadQueue       chan storage.WebhookPutObject
+	directUploadQueue chan UploadCmd
+	reprocessQueue    chan ReprocessCmd
+	deletionQueue     chan DeletionCmd
+	wg                *sync.WaitGroup
 
 	db       Repository
 	indexer  data.Indexer
@@ -101,6 +118,20 @@ const (
 	BlurLongSide int = 32 // long side in pixels for blur/placeholder image
 )
 
+// MaxDecodePixels is the most pixels an image may have for the pipeline to decode it: images are decoded
+// in memory, at up to 8 bytes per pixel for 16 bit tiffs, so a larger image is rejected from its header.
+const MaxDecodePixels int64 = 100_000_000
+
+// UploadDedupeWindow is how long an upload is remembered by the upload queue, so a repeat of it, eg,
+// a second notification for the same object, is skipped rather than processed again.
+const UploadDedupeWindow = 10 * time.Minute
+
+// UploadCmd represents an image file uploaded through pixie, eg, a multipart/form-data upload, rather than
+// directly to object storage with a presigned url, so no upload notification is sent for it.
+type UploadCmd struct {
+	ObjectKey string // eg, "uploads/slug.jpg"
+}
+
 // DeletionCmd represents a request to delete an existing picture.
 type DeletionCmd struct {
 	Id        string
@@ -122,6 +153,19 @@ type ReprocessCmd struct {
 	UpdatedObjKey string
 	MoveRequired  bool
 	RetryCount    int
+
+	// PlaceholdersRequired indicates the blur hash and dominant color need to be
+	// (re)computed, eg, backfilling images processed before placeholders existed.
+	PlaceholdersRequired bool
+
+	// DisplayRequired indicates the sanitized display original needs to be (re)built
+	// in place, eg, backfilling images processed before display originals existed.
+	// Note: the move flow always moves or (re)builds the display original.
+	DisplayRequired bool
+
+	// NoYearAlbum indicates the image's date spans several years, eg, a decade,
+	// so it is not linked to the year album of its object key's directory.
+	NoYearAlbum bool
 }
 
 // ParseObjectKey is a helper which parses the object key from the webhook
@@ -285,6 +329,32 @@ func convertToDegrees(orientation int) int {
 	}
 }
 
+// decodeImage is a helper which decodes an image from the start of the reader, first reading its dimensions
+// from its header so an image too large to decode in memory is rejected before any pixels are decoded.
+func decodeImage(r io.ReadSeeker) (image.Image, error) {
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind reader: %v", err)
+	}
+
+	config, format, err := image.DecodeConfig(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image header: %v", err)
+	}
+
+	if pixels := int64(config.Width) * int64(config.Height); pixels > MaxDecodePixels {
+		return nil, fmt.Errorf("%s image is %dx%d pixels: larger than the %d pixels that may be decoded",
+			format, config.Width, config.Height, MaxDecodePixels)
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind reader: %v", err)
+	}
+
+	src, _, err := image.Decode(r)
+	return src, err
+}
+
 // rotateImage rotates an image based on the provided rotation in degrees.
 func rotateImage(src image.Image, degrees int) image.Image {
 	switch ((degrees % 360) + 360) % 360 { // normalize degrees to [0, 360) -> accounts for negative degrees
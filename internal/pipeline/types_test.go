@@ -2,13 +2,16 @@ package pipeline
 
 import (
 	"bytes"
+	"encoding/binary"
 	"image"
 	"image/color"
 	"image/jpeg"
+	"strings"
 	"sync"
 	"testing"
 
 	"github.com/tdeslauriers/carapace/pkg/storage"
+	"golang.org/x/image/tiff"
 )
 
 // testUUID/testUUID2 are fixed, deterministic UUID-shaped strings (they only
@@ -17,6 +20,7 @@ import (
 const (
 	testUUID  = "11111111-1111-1111-1111-111111111111"
 	testUUID2 = "22222222-2222-2222-2222-222222222222"
+	testUUID3 = "33333333-3333-3333-3333-333333333333"
 )
 
 func TestParseObjectKey(t *testing.T) {
@@ -176,6 +180,89 @@ func TestReadExif(t *testing.T) {
 	})
 }
 
+func TestDecodeImage(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		data     func(t *testing.T) []byte
+		wantSize image.Point
+		wantErr  string
+	}{
+		{
+			name:     "jpeg decodes",
+			data:     func(t *testing.T) []byte { return encodeJpeg(t, 64, 32, color.White) },
+			wantSize: image.Pt(64, 32),
+		},
+		{
+			name:     "tiff decodes",
+			data:     func(t *testing.T) []byte { return encodeTiff(t, 48, 24) },
+			wantSize: image.Pt(48, 24),
+		},
+		{
+			name: "tiff too large to decode is rejected from its header",
+			data: func(t *testing.T) []byte {
+				// 20000x20000 is over the limit, but only the header claims it
+				return setTiffDimensions(t, encodeTiff(t, 4, 4), 20000, 20000)
+			},
+			wantErr: "larger than the",
+		},
+		{
+			name:    "undecodable bytes are rejected",
+			data:    func(t *testing.T) []byte { return []byte("not an image") },
+			wantErr: "failed to decode image header",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := decodeImage(newFakeReadSeekCloser(tt.data(t)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("decodeImage() error = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeImage() unexpected error: %v", err)
+			}
+			if got := src.Bounds().Size(); got != tt.wantSize {
+				t.Errorf("decodeImage() size = %v, want %v", got, tt.wantSize)
+			}
+		})
+	}
+}
+
+// encodeTiff encodes a solid grey tiff of the given dimensions.
+func encodeTiff(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	var buf bytes.Buffer
+	if err := tiff.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("failed to encode tiff: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// setTiffDimensions overwrites the width and height tags of a little endian tiff written by tiff.Encode,
+// which stores both as a single short.
+func setTiffDimensions(t *testing.T, data []byte, w, h uint16) []byte {
+	t.Helper()
+
+	for tag, value := range map[uint16]uint16{256: w, 257: h} {
+		entry := make([]byte, 8)
+		binary.LittleEndian.PutUint16(entry[0:], tag)
+		binary.LittleEndian.PutUint16(entry[2:], 3) // short
+		binary.LittleEndian.PutUint32(entry[4:], 1) // count
+		i := bytes.Index(data, entry)
+		if i < 0 {
+			t.Fatalf("tiff tag %d not found", tag)
+		}
+		binary.LittleEndian.PutUint16(data[i+8:], value)
+	}
+	return data
+}
+
 func TestConvertToDegrees(t *testing.T) {
 
 	tests := []struct {
@@ -433,6 +520,7 @@ func TestEncodeToJpeg(t *testing.T) {
 func TestNewImagePipeline(t *testing.T) {
 
 	up := make(chan storage.WebhookPutObject)
+	du := make(chan UploadCmd)
 	re := make(chan ReprocessCmd)
 	de := make(chan DeletionCmd)
 	var wg sync.WaitGroup
@@ -442,7 +530,7 @@ func TestNewImagePipeline(t *testing.T) {
 	cryptor := &mockCryptor{}
 	objStore := &mockObjectStorage{}
 
-	got := NewImagePipeline(up, re, de, &wg, repo, indexer, cryptor, objStore)
+	got := NewImagePipeline(up, du, re, de, &wg, repo, indexer, cryptor, objStore)
 	if got == nil {
 		t.Fatal("NewImagePipeline() returned nil")
 	}
@@ -451,8 +539,8 @@ func TestNewImagePipeline(t *testing.T) {
 	if !ok {
 		t.Fatalf("expected concrete type *imagePipeline, got %T", got)
 	}
-	if impl.uploadQueue == nil || impl.reprocessQueue == nil || impl.deletionQueue == nil {
-		t.Error("expected all three queues to be wired to the provided channels")
+	if impl.uploadQueue == nil || impl.directUploadQueue == nil || impl.reprocessQueue == nil || impl.deletionQueue == nil {
+		t.Error("expected all four queues to be wired to the provided channels")
 	}
 	if impl.wg != &wg {
 		t.Error("expected wg to be the exact pointer passed in")
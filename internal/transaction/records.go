@@ -0,0 +1,76 @@
+package transaction
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// The helpers below mirror the carapace data package's record helpers, which only accept a *sql.DB,
+// so repositories can read and write records within a unit of work the same way they do outside of one.
+
+// SelectRecords executes a query within the transaction, scanning each row into a record of type T.
+// Note: columns are scanned positionally, so the query must select them in the order of T's fields.
+func SelectRecords[T any](ctx context.Context, tx Tx, query string, args ...interface{}) ([]T, error) {
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []T
+	for rows.Next() {
+		var record T
+		if err := rows.Scan(fieldPointers(&record)...); err != nil {
+			return nil, fmt.Errorf("failed to scan record: %v", err)
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// InsertRecord executes an insert within the transaction, passing each of the record's fields as args.
+// Note: the fields are passed positionally, so the query's placeholders must be in the order of T's fields.
+func InsertRecord[T any](ctx context.Context, tx Tx, query string, record T) error {
+
+	v := reflect.ValueOf(record)
+	args := make([]interface{}, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		args = append(args, v.Field(i).Interface())
+	}
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// UpdateRecord executes an update within the transaction.
+func UpdateRecord(ctx context.Context, tx Tx, query string, args ...interface{}) error {
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// DeleteRecord executes a delete within the transaction.
+func DeleteRecord(ctx context.Context, tx Tx, query string, args ...interface{}) error {
+
+	_, err := tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// fieldPointers is a helper which returns pointers to each of a record's fields, in order, to scan a row into.
+func fieldPointers(record interface{}) []interface{} {
+
+	v := reflect.ValueOf(record).Elem()
+	ptrs := make([]interface{}, 0, v.NumField())
+	for i := 0; i < v.NumField(); i++ {
+		ptrs = append(ptrs, v.Field(i).Addr().Interface())
+	}
+
+	return ptrs
+}
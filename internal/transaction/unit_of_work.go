@@ -0,0 +1,99 @@
+package transaction
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Tx is the interface for a database transaction shared by the repositories taking part in a unit of work.
+// Writes made through it are only visible to other connections once the unit of work commits.
+type Tx interface {
+
+	// ExecContext executes a statement within the transaction.
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+
+	// QueryContext executes a query within the transaction, seeing the transaction's own uncommitted writes.
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+
+	// AfterCommit registers a function to run once the transaction has committed, eg,
+	// sending a command to a pipeline queue.  It is never run if the transaction rolls back.
+	AfterCommit(fn func())
+}
+
+// UnitOfWork is the interface for running writes spanning several repositories atomically.
+type UnitOfWork interface {
+
+	// Run begins a transaction and calls fn with it.  If fn returns an error, or panics, the transaction
+	// is rolled back, otherwise it is committed and the functions registered with AfterCommit are run, in order.
+	Run(ctx context.Context, fn func(tx Tx) error) error
+}
+
+// NewUnitOfWork creates a new UnitOfWork instance, returning a pointer to the concrete implementation.
+func NewUnitOfWork(db *sql.DB) UnitOfWork {
+	return &unitOfWork{
+		db: db,
+	}
+}
+
+var _ UnitOfWork = (*unitOfWork)(nil)
+
+// unitOfWork is the concrete implementation of the UnitOfWork interface.
+type unitOfWork struct {
+	db *sql.DB
+}
+
+// Run is the concrete implementation of the interface method which begins a transaction and calls fn with it,
+// committing if fn succeeds and rolling back if it does not.
+func (u *unitOfWork) Run(ctx context.Context, fn func(tx Tx) error) (err error) {
+
+	sqlTx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %v", err)
+	}
+
+	t := &tx{Tx: sqlTx}
+
+	// roll back on panic, then re-panic: the caller's recovery, if any, decides what happens next
+	defer func() {
+		if p := recover(); p != nil {
+			_ = sqlTx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(t); err != nil {
+		if rbErr := sqlTx.Rollback(); rbErr != nil {
+			return errors.Join(err, fmt.Errorf("failed to roll back transaction: %v", rbErr))
+		}
+		return err
+	}
+
+	if err := sqlTx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %v", err)
+	}
+
+	// only now are the writes visible, so it is safe for downstream consumers to act on them
+	for _, hook := range t.afterCommit {
+		hook()
+	}
+
+	return nil
+}
+
+var _ Tx = (*tx)(nil)
+
+// tx is the concrete implementation of the Tx interface, wrapping a sql transaction.
+// Note: it is not safe to register AfterCommit hooks from multiple goroutines.
+type tx struct {
+	*sql.Tx
+
+	afterCommit []func()
+}
+
+// AfterCommit is the concrete implementation of the interface method which registers
+// a function to run once the transaction has committed.
+func (t *tx) AfterCommit(fn func()) {
+	t.afterCommit = append(t.afterCommit, fn)
+}
@@ -1,7 +1,48 @@
 package util
 
+import "time"
+
 // source set resolution widths
 var (
 	ResolutionWidthsImages []int = []int{384, 640, 750, 828, 1200, 1920, 2048, 3840} // nextjs default sizes
 	ResolutionWidthsTiles  []int = []int{64, 128, 256, 384}
 )
+
+// on-demand rendition allowlists: requested parameters are snapped to these values
+// so that clients cannot fill object storage with arbitrary sizes.
+// Note: a height-only or height-limited rendition can be wider than these sizes, so the source width
+// is capped at the view-only max width for view-only users, and at the largest stored rendition for everyone.
+var (
+	OnDemandSizes     []int = []int{64, 96, 128, 160, 240, 320, 480, 600, 630, 800, 960, 1080, 1200, 1600}
+	OnDemandQualities []int = []int{60, 75, 85}
+)
+
+// DefaultViewOnlyMaxWidth is the widest resolution served to users who may view, but not download, an image,
+// if ViewOnlyMaxWidthEnv is not set.
+const DefaultViewOnlyMaxWidth = 1920
+
+// ViewOnlyMaxWidthEnv is the environment variable which overrides the widest resolution, in pixels, served to users
+// who may view, but not download, an image, eg "2048".  Must be within the range of ResolutionWidthsImages.
+const ViewOnlyMaxWidthEnv = "PIXIE_VIEW_ONLY_MAX_WIDTH"
+
+// DownloadUrlExpiry is how long a signed URL to download an original image is valid.
+const DownloadUrlExpiry = 1 * time.Minute
+
+// RenditionCacheMaxAge is how long browsers may cache a streamed rendition requested by its
+// content-addressed (versioned) url.
+const RenditionCacheMaxAge = 365 * 24 * time.Hour
+
+// MemoriesTimezoneEnv is the environment variable which sets the default IANA timezone used to
+// determine "today" for the memories feed, eg "America/Chicago".  Defaults to UTC if not set.
+const MemoriesTimezoneEnv = "PIXIE_MEMORIES_TIMEZONE"
+
+// ImageSizeLimitsEnv is the environment variable which overrides the largest image file, in megabytes, that may
+// be uploaded for a file type, eg "image/tiff=1024,image/png=300".  Defaults to api.DefaultImageSizeLimits if not set.
+const ImageSizeLimitsEnv = "PIXIE_IMAGE_SIZE_LIMITS"
+
+// multipart uploads of files too large for a single presigned put
+const (
+	MultipartPartUrlExpiry   = 1 * time.Hour  // how long a signed URL to upload a part is valid: parts are large and connections may be slow
+	MultipartUploadExpiry    = 24 * time.Hour // how long a multipart upload may be in progress before it is considered abandoned
+	MultipartCleanupInterval = 1 * time.Hour  // how often abandoned multipart uploads are aborted
+)
@@ -0,0 +1,40 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// VersionETag builds the strong ETag header value for a record's version, eg, "3".
+func VersionETag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// ParseIfMatch parses the record version from the request's If-Match header.
+// Returns an error if the header is missing, or is not a single strong ETag built by VersionETag:
+// weak tags and "*" cannot guard an edit against a stale read.
+func ParseIfMatch(r *http.Request) (int, error) {
+
+	h := strings.TrimSpace(r.Header.Get("If-Match"))
+	if h == "" {
+		return 0, fmt.Errorf("If-Match header is required")
+	}
+
+	if strings.HasPrefix(h, "W/") || h == "*" || strings.Contains(h, ",") {
+		return 0, fmt.Errorf("If-Match header must be a single strong ETag from a previous response")
+	}
+
+	unquoted, err := strconv.Unquote(h)
+	if err != nil {
+		return 0, fmt.Errorf("If-Match header must be a quoted ETag")
+	}
+
+	version, err := strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("If-Match header is not a valid ETag")
+	}
+
+	return version, nil
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVersionETag(t *testing.T) {
+
+	if got := VersionETag(3); got != `"3"` {
+		t.Fatalf("VersionETag(3) = %s, want \"3\"", got)
+	}
+
+	// the ETag sent in a response must parse back to its version when returned as If-Match
+	r := httptest.NewRequest("PUT", "/", nil)
+	r.Header.Set("If-Match", VersionETag(42))
+	got, err := ParseIfMatch(r)
+	if err != nil || got != 42 {
+		t.Fatalf("ParseIfMatch(VersionETag(42)) = %d, %v, want 42, nil", got, err)
+	}
+}
+
+func TestParseIfMatch(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		header  string
+		want    int
+		wantErr string
+	}{
+		{name: "strong etag", header: `"3"`, want: 3},
+		{name: "surrounding whitespace", header: ` "7" `, want: 7},
+		{name: "missing", header: "", wantErr: "is required"},
+		{name: "whitespace only", header: "   ", wantErr: "is required"},
+		{name: "weak etag", header: `W/"3"`, wantErr: "single strong ETag"},
+		{name: "wildcard", header: "*", wantErr: "single strong ETag"},
+		{name: "list", header: `"3", "4"`, wantErr: "single strong ETag"},
+		{name: "unquoted", header: "3", wantErr: "must be a quoted ETag"},
+		{name: "not a version", header: `"abc"`, wantErr: "not a valid ETag"},
+		{name: "zero version", header: `"0"`, wantErr: "not a valid ETag"},
+		{name: "negative version", header: `"-1"`, wantErr: "not a valid ETag"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/", nil)
+			if tc.header != "" {
+				r.Header.Set("If-Match", tc.header)
+			}
+
+			got, err := ParseIfMatch(r)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ParseIfMatch() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseIfMatch() error = %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseIfMatch() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DownloadFileName builds the human readable file name for a downloaded image file,
+// eg, "2024-07-04 Fourth of July.jpg".  The date prefix is omitted if the image date is not known,
+// and the slug is used if the title is empty.
+func DownloadFileName(title, imageDate, slug, ext string) string {
+
+	// strip characters which are not allowed in file names on common operating systems
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r < 0x20 || r == 0x7f:
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '-'
+		}
+		return r
+	}, strings.TrimSpace(title))
+
+	if name == "" {
+		name = slug
+	}
+
+	if imageDate != "" {
+		if taken, err := time.Parse(time.RFC3339, imageDate); err == nil {
+			name = fmt.Sprintf("%s %s", taken.Format("2006-01-02"), name)
+		}
+	}
+
+	return name + ext
+}
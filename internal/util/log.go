@@ -12,6 +12,7 @@ const (
 	PackageService      = "service"
 	PackageNotification = "notification"
 	PackagePipeline     = "image processing pipeline"
+	PackageBackup       = "backup"
 
 	// component keys
 	ComponentKey = "component"
@@ -20,6 +21,7 @@ const (
 	ComponentAlbumImageCryptor   = "album image cryptor"
 	ComponentAlbumHandler        = "album handler"
 	ComponentAlbumImageService   = "album image service"
+	ComponentAlbumPermissions    = "album permissions"
 	ComponentAlbumSerivce        = "album service"
 	ComponentMain                = "main"
 	ComponentImage               = "image"
@@ -34,6 +36,11 @@ const (
 	ComponentPatronRegister      = "patron register"
 	ComponentNotificationHandler = "notification handler"
 	ComponentStagedImageService  = "staged image service"
+	ComponentTimelineHandler     = "timeline handler"
+	ComponentUploadSession       = "upload session"
+	ComponentMultipart           = "multipart upload"
+	ComponentBackup              = "backup"
+	ComponentAdmin               = "admin"
 
 	// service keys
 	ServiceKey = "service"
@@ -0,0 +1,131 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/tdeslauriers/pixie/pkg/api"
+)
+
+// Paginate returns the page of already sorted items after the query's cursor, and the cursor for the next page,
+// which is empty if this is the last page.  The id function returns the unique id of an item, which is used to
+// find the cursor's position so that inserts and deletes before it do not shift the page boundaries.
+// Note: the query must already have been validated.
+func Paginate[T any](items []T, id func(T) string, q api.PageQuery) ([]T, string, error) {
+
+	limit := q.Limit
+	if limit <= 0 {
+		limit = api.DefaultPageLimit
+	}
+
+	start := 0
+	if q.Cursor != "" {
+		c, err := api.DecodePageCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		// find the last item of the previous page, falling back to the offset
+		// if it has since been deleted or is no longer visible to the user
+		start = min(c.Offset, len(items))
+		for i := range items {
+			if id(items[i]) == c.Id {
+				start = i + 1
+				break
+			}
+		}
+	}
+
+	end := min(start+limit, len(items))
+	page := items[start:end]
+
+	if end >= len(items) || len(page) == 0 {
+		return page, "", nil
+	}
+
+	next := api.PageCursor{
+		Sort:   q.Sort,
+		Desc:   q.Desc,
+		Id:     id(page[len(page)-1]),
+		Offset: end,
+	}
+
+	return page, next.Encode(), nil
+}
+
+// CompareSortKeys compares two sort keys, breaking ties by id so that the order is total and
+// stable across requests, which cursor pagination depends on.  Returns a negative number if a sorts
+// before b, positive if after, and desc reverses the order of the keys, but not the id tiebreak.
+func CompareSortKeys(aKey, aId, bKey, bId string, desc bool) int {
+
+	if aKey != bKey {
+		if (aKey < bKey) != desc {
+			return -1
+		}
+		return 1
+	}
+
+	switch {
+	case aId < bId:
+		return -1
+	case aId > bId:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ValidatePageQuery applies the default limit and sort to the page query, then validates it.
+func ValidatePageQuery(q *api.PageQuery, defaultSort string) error {
+
+	if q == nil {
+		return fmt.Errorf("page query is required")
+	}
+
+	if q.Limit == 0 {
+		q.Limit = api.DefaultPageLimit
+	}
+
+	if q.Sort == "" {
+		q.Sort = defaultSort
+	}
+
+	return q.Validate()
+}
+
+// ParsePageQuery parses the limit, cursor, sort, order, and from/to query parameters from the request.
+// Returns nil if none of them are present so callers can preserve their un-paginated responses.
+func ParsePageQuery(r *http.Request) (*api.PageQuery, error) {
+
+	params := r.URL.Query()
+	if !params.Has("limit") && !params.Has("cursor") && !params.Has("sort") && !params.Has("order") &&
+		!params.Has("from") && !params.Has("to") {
+		return nil, nil
+	}
+
+	q := &api.PageQuery{
+		Cursor: params.Get("cursor"),
+		Sort:   params.Get("sort"),
+		From:   params.Get("from"),
+		To:     params.Get("to"),
+	}
+
+	if l := params.Get("limit"); l != "" {
+		limit, err := strconv.Atoi(l)
+		if err != nil || limit < 1 {
+			return nil, fmt.Errorf("limit must be a positive integer")
+		}
+		q.Limit = limit
+	}
+
+	switch params.Get("order") {
+	case "", "asc":
+	case "desc":
+		q.Desc = true
+	default:
+		return nil, fmt.Errorf("order must be 'asc' or 'desc'")
+	}
+
+	return q, nil
+}
@@ -7,4 +7,6 @@ const (
 	PermissionFriend    = "FRIEND"    // Can view all non-archived, published images of friends
 	PermissionColleague = "COLLEAGUE" // Can view all non-archived, published images of colleagues
 	PermissionPublic    = "DEMO"      // Can view all non-archived, published images marked as public/demo
+
+	PermissionDownloadOriginal = "DOWNLOAD_ORIGINAL" // Can access untouched originals, including their exif/gps metadata
 )
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/carapace/pkg/permissions"
 	"github.com/tdeslauriers/carapace/pkg/validate"
 )
 
@@ -22,12 +23,19 @@ const (
 var (
 	albumTitleRegex       = regexp.MustCompile(AlbumTitleRegex)
 	albumDescriptionRegex = regexp.MustCompile(AlbumDescriptionRegex)
+	yearAlbumTitleRegex   = regexp.MustCompile(`^\d{4}$`)
 )
 
 func ValidateAlbumTitle(title string) bool {
 	return albumTitleRegex.MatchString(strings.TrimSpace(title))
 }
 
+// IsYearAlbumTitle checks if an album title is a year, eg "2019", ie, the title of the albums
+// the image processing pipeline generates and links images to by their image date.
+func IsYearAlbumTitle(title string) bool {
+	return yearAlbumTitleRegex.MatchString(strings.TrimSpace(title))
+}
+
 // Album is a model which represents an album in the API response.
 type Album struct {
 	Csrf        string          `json:"csrf,omitempty"` // CSRF token, if required, or if present
@@ -39,6 +47,20 @@ type Album struct {
 	UpdatedAt   data.CustomTime `json:"updated_at,omitempty"`
 	IsArchived  bool            `json:"is_archived"`
 	Images      []ImageData     `json:"images,omitempty"` // image metadata records + their thumbnails signed urls
+
+	// CoverImageId is the curator selected cover image, only present if the user has permission to view it.
+	// Cover is the cover image the user will see: the selected cover, or if there is none or the user may not
+	// view it, the most recent image in the album the user has permission to view.  Only present in listings.
+	CoverImageId string     `json:"cover_image_id,omitempty"`
+	Cover        *ImageData `json:"cover,omitempty"`
+
+	ParentId string `json:"parent_id,omitempty"` // parent album uuid, empty if a top level album
+
+	Version int `json:"version,omitempty"` // version of the album's metadata, also returned as the ETag
+
+	// only present when the album's images are requested a page at a time
+	NextCursor  string `json:"next_cursor,omitempty"`  // cursor for the next page of images, empty if this is the last page
+	TotalImages int    `json:"total_images,omitempty"` // total number of images in the album the user has permission to view
 }
 
 // Validate validates the Album -> input validation.
@@ -124,10 +146,11 @@ func (cmd *AddAlbumCmd) Validate() error {
 
 // AlbumUpdateCmd is a model which represents the command to update an existing album record.
 type AlbumUpdateCmd struct {
-	Csrf        string `json:"csrf,omitempty"` // this may not always be required
-	Title       string `json:"title"`
-	Description string `json:"description"`
-	IsArchived  bool   `json:"is_archived"`
+	Csrf         string `json:"csrf,omitempty"` // this may not always be required
+	Title        string `json:"title"`
+	Description  string `json:"description"`
+	IsArchived   bool   `json:"is_archived"`
+	CoverImageId string `json:"cover_image_id,omitempty"` // image uuid in the album, empty to use the most recent image
 }
 
 func (cmd *AlbumUpdateCmd) Validate() error {
@@ -157,9 +180,228 @@ func (cmd *AlbumUpdateCmd) Validate() error {
 		return fmt.Errorf("description must be alphanumeric, spaces, and punctuation, min %d chars, max %d chars", AlbumDescriptionMinLength, AlbumDescriptionMaxLength)
 	}
 
+	// validate cover image id if present
+	if cmd.CoverImageId != "" {
+		if err := validate.ValidateUuid(cmd.CoverImageId); err != nil {
+			return fmt.Errorf("invalid cover image id: %s", cmd.CoverImageId)
+		}
+	}
+
+	return nil
+}
+
+// MaxAlbumOrderImages is the maximum number of images which can be moved in a single album order command.
+const MaxAlbumOrderImages = 1000
+
+// AlbumOrderCmd is a model which represents the command to manually order the images within an album.
+// The images are moved, in the order listed, to directly after the After image, or to the start of
+// the album if After is empty.  Images not listed keep their relative order, so a partial list only
+// moves the listed images.
+type AlbumOrderCmd struct {
+	Csrf   string   `json:"csrf,omitempty"`  // this may not always be required
+	Images []string `json:"images"`          // image slugs in the desired order
+	After  string   `json:"after,omitempty"` // image slug the images are moved to directly after
+}
+
+// Validate validates the AlbumOrderCmd -> input validation.
+func (cmd *AlbumOrderCmd) Validate() error {
+
+	// validate CSRF token, if present -> not always required
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("invalid CSRF token")
+		}
+	}
+
+	if len(cmd.Images) == 0 {
+		return fmt.Errorf("at least one image slug is required")
+	}
+
+	if len(cmd.Images) > MaxAlbumOrderImages {
+		return fmt.Errorf("images must not include more than %d image slugs", MaxAlbumOrderImages)
+	}
+
+	seen := make(map[string]struct{}, len(cmd.Images))
+	for _, slug := range cmd.Images {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return fmt.Errorf("invalid image slug: %s", slug)
+		}
+		if _, ok := seen[slug]; ok {
+			return fmt.Errorf("image slug %s must only be listed once", slug)
+		}
+		seen[slug] = struct{}{}
+	}
+
+	if cmd.After != "" {
+		if err := validate.ValidateUuid(cmd.After); err != nil {
+			return fmt.Errorf("invalid after image slug: %s", cmd.After)
+		}
+		if _, ok := seen[cmd.After]; ok {
+			return fmt.Errorf("after image slug must not be one of the images being moved")
+		}
+	}
+
 	return nil
 }
 
+// AlbumParentCmd is a model which represents the command to move an album into a parent album,
+// eg, a per-trip album into a "Vacations" collection, or back to the top level.
+type AlbumParentCmd struct {
+	Csrf       string `json:"csrf,omitempty"`        // this may not always be required
+	ParentSlug string `json:"parent_slug,omitempty"` // slug of the new parent album, empty to move to the top level
+}
+
+// Validate validates the AlbumParentCmd -> input validation.
+func (cmd *AlbumParentCmd) Validate() error {
+
+	// validate CSRF token, if present -> not always required
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("invalid CSRF token")
+		}
+	}
+
+	if cmd.ParentSlug != "" {
+		if err := validate.ValidateUuid(cmd.ParentSlug); err != nil {
+			return fmt.Errorf("invalid parent album slug: %s", cmd.ParentSlug)
+		}
+	}
+
+	return nil
+}
+
+// AlbumPermissions is a model which represents the permissions granted at the album level.
+type AlbumPermissions struct {
+	AlbumSlug          string                   `json:"album_slug"`
+	InheritPermissions bool                     `json:"inherit_permissions"` // images added to the album inherit its permissions
+	Permissions        []permissions.Permission `json:"permissions"`
+}
+
+// AlbumPermissionsCmd is a model which represents the command to replace the permissions granted at the album level,
+// and optionally apply the added and removed permissions to the images already in the album.
+type AlbumPermissionsCmd struct {
+	Csrf               string   `json:"csrf,omitempty"`      // this may not always be required
+	PermissionSlugs    []string `json:"permission_slugs"`    // the album's complete set of permissions
+	InheritPermissions bool     `json:"inherit_permissions"` // images added to the album later inherit its permissions
+	ApplyToImages      bool     `json:"apply_to_images"`     // also add/remove the changed permissions on the album's existing images
+	Preview            bool     `json:"preview"`             // report the changes and affected images without making them
+}
+
+// Validate validates the AlbumPermissionsCmd -> input validation.
+func (cmd *AlbumPermissionsCmd) Validate() error {
+
+	// validate CSRF token, if present -> not always required
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("invalid CSRF token")
+		}
+	}
+
+	seen := make(map[string]struct{}, len(cmd.PermissionSlugs))
+	for _, slug := range cmd.PermissionSlugs {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return fmt.Errorf("invalid permission slug: %s", slug)
+		}
+		if _, ok := seen[slug]; ok {
+			return fmt.Errorf("permission slug %s is listed more than once", slug)
+		}
+		seen[slug] = struct{}{}
+	}
+
+	return nil
+}
+
+// AlbumPermissionsChange is a model which represents the result, or the preview, of changing an album's permissions.
+type AlbumPermissionsChange struct {
+	AlbumSlug          string                   `json:"album_slug"`
+	InheritPermissions bool                     `json:"inherit_permissions"`
+	Added              []permissions.Permission `json:"added"`   // permissions granted to the album
+	Removed            []permissions.Permission `json:"removed"` // permissions revoked from the album
+	Images             []ImagePermissionsChange `json:"images"`  // existing images affected, empty unless applied to images
+	Applied            bool                     `json:"applied"` // false if this is a preview
+}
+
+// ImagePermissionsChange is a model which represents the permissions added to and removed from an image
+// when an album's permission change is applied to its existing images.
+type ImagePermissionsChange struct {
+	Id      string   `json:"-"`
+	Slug    string   `json:"slug"`
+	Title   string   `json:"title"`
+	Added   []string `json:"added,omitempty"`   // slugs of the permissions added to the image
+	Removed []string `json:"removed,omitempty"` // slugs of the permissions removed from the image
+}
+
+// AlbumNode is a model which represents an album in the album tree along with its sub-albums.
+type AlbumNode struct {
+	Album
+	ImageCount      int         `json:"image_count"`        // images directly in the album the user has permission to view
+	TotalImageCount int         `json:"total_image_count"`  // images in the album and all of its sub-albums the user has permission to view
+	Children        []AlbumNode `json:"children,omitempty"` // sub-albums, sorted by title
+}
+
+// Album deletion modes: what happens to the images in an album when it is deleted.
+const (
+	AlbumDeleteUnlink = "unlink"        // remove the images from the album, they remain in the gallery
+	AlbumDeleteMove   = "move"          // move the images to the target album
+	AlbumDeleteImages = "delete_images" // delete the images which belong to no other album, unlink the rest
+)
+
+// AlbumDeletionQuery is a model which represents the query parameters of a request to delete an album.
+type AlbumDeletionQuery struct {
+	Mode       string `json:"mode"`                  // required: unlink, move, or delete_images
+	TargetSlug string `json:"target_slug,omitempty"` // album to move the images to, required for move
+	DryRun     bool   `json:"dry_run,omitempty"`     // report what would happen without doing it
+	Confirm    string `json:"confirm,omitempty"`     // the album's title, required to delete a year album
+}
+
+// Validate validates the AlbumDeletionQuery -> input validation.
+func (q *AlbumDeletionQuery) Validate() error {
+
+	switch q.Mode {
+	case AlbumDeleteUnlink, AlbumDeleteImages:
+		if q.TargetSlug != "" {
+			return fmt.Errorf("target album is only valid for mode '%s'", AlbumDeleteMove)
+		}
+	case AlbumDeleteMove:
+		if err := validate.ValidateUuid(q.TargetSlug); err != nil {
+			return fmt.Errorf("invalid target album slug: %s", q.TargetSlug)
+		}
+	case "":
+		return fmt.Errorf("deletion mode is required: one of %s, %s, %s", AlbumDeleteUnlink, AlbumDeleteMove, AlbumDeleteImages)
+	default:
+		return fmt.Errorf("invalid deletion mode '%s': must be one of %s, %s, %s", q.Mode, AlbumDeleteUnlink, AlbumDeleteMove, AlbumDeleteImages)
+	}
+
+	if len(q.Confirm) > AlbumTitleMaxLength {
+		return fmt.Errorf("confirmation cannot be longer than %d characters", AlbumTitleMaxLength)
+	}
+
+	return nil
+}
+
+// AlbumDeletion is a model which represents the result, or the dry run, of deleting an album.
+type AlbumDeletion struct {
+	AlbumSlug  string               `json:"album_slug"`
+	AlbumTitle string               `json:"album_title"`
+	Mode       string               `json:"mode"`
+	TargetSlug string               `json:"target_slug,omitempty"`
+	Unlinked   []AlbumDeletionImage `json:"unlinked"`   // images removed from the album which remain in the gallery
+	Moved      []AlbumDeletionImage `json:"moved"`      // images moved to the target album
+	Deleted    []AlbumDeletionImage `json:"deleted"`    // images deleted because they belonged to no other album
+	SubAlbums  []string             `json:"sub_albums"` // slugs of the sub-albums moved up to the deleted album's parent
+	Applied    bool                 `json:"applied"`    // false if this is a dry run
+
+	// ConfirmationRequired is true for year albums, which can only be deleted if confirmed by their title.
+	ConfirmationRequired bool `json:"confirmation_required"`
+}
+
+// AlbumDeletionImage is a model which represents an image affected by an album deletion.
+type AlbumDeletionImage struct {
+	Id    string `json:"-"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+}
+
 // AlbumRecord is a model which represents an album record in the database.
 type AlbumRecord struct {
 	Id          string          `db:"uuid" json:"id,omitempty"`
@@ -170,6 +412,11 @@ type AlbumRecord struct {
 	CreatedAt   data.CustomTime `db:"created_at" json:"created_at,omitempty"`
 	UpdatedAt   data.CustomTime `db:"updated_at" json:"updated_at,omitempty"`
 	IsArchived  bool            `db:"is_archived" json:"is_archived"`
+
+	CoverImageId string `db:"cover_image_uuid" json:"cover_image_id,omitempty"` // curator selected cover, empty if none
+	ParentId     string `db:"parent_uuid" json:"parent_id,omitempty"`           // parent album uuid, empty if a top level album
+
+	Version int `db:"version" json:"version"` // incremented on every metadata edit, for optimistic concurrency control
 }
 
 // Validate validates the AlbumRecord -> input validation.
@@ -207,6 +454,20 @@ func (a *AlbumRecord) Validate() error {
 		}
 	}
 
+	// validate cover image id if present
+	if a.CoverImageId != "" {
+		if err := validate.ValidateUuid(a.CoverImageId); err != nil {
+			return fmt.Errorf("invalid cover image id: %s", a.CoverImageId)
+		}
+	}
+
+	// validate parent id if present
+	if a.ParentId != "" {
+		if err := validate.ValidateUuid(a.ParentId); err != nil {
+			return fmt.Errorf("invalid parent album id: %s", a.ParentId)
+		}
+	}
+
 	return nil
 }
 
@@ -235,6 +496,17 @@ type AlbumImageRecord struct {
 	ImageUpdatedAt   string          `db:"image_updated_at"`   // Timestamp when the image was last updated
 	ImageIsArchived  bool            `db:"image_is_archived"`  // Indicates if the image is archived
 	ImageIsPublished bool            `db:"image_is_published"` // Indicates if the image is published and visible to users
+	BlurHash         string          `db:"blur_hash"`          // encrypted: BlurHash placeholder string
+	DominantColor    string          `db:"dominant_color"`     // encrypted: dominant color hex string
+	ImageDateKey     string          `db:"image_date_key"`     // sortable year-month of the image date, eg "2024-03"
+
+	ImageDatePrecision string `db:"image_date_precision"` // how precisely the image date is known, empty if exact
+	ImageDateEndYear   int    `db:"image_date_end_year"`  // last year of a circa range, 0 otherwise
+
+	AlbumCoverId  string `db:"album_cover_image_uuid"` // curator selected cover image uuid, empty if none
+	ImagePosition int    `db:"image_position"`         // manual order of the image within the album, 0 if not ordered
+	AlbumParentId string `db:"album_parent_uuid"`      // parent album uuid, empty if a top level album
+	AlbumVersion  int    `db:"album_version"`          // version of the album's metadata
 }
 
 // AlbumImageXref is a model which represents a record in the album_image cross-reference table.
@@ -0,0 +1,58 @@
+package api
+
+import "fmt"
+
+// AlbumExportManifestName is the name of the json manifest of the images' metadata in an album export archive.
+const AlbumExportManifestName = "manifest.json"
+
+// AlbumExportQuery is a model which represents the query parameters of a request to export an album as a zip archive.
+// Exactly one of original or width is required.
+type AlbumExportQuery struct {
+	Original bool `json:"original,omitempty"` // export the untouched original files: requires download rights
+	Width    int  `json:"width,omitempty"`    // export the renditions of this width, one of the stored resolution widths
+}
+
+// Validate validates the AlbumExportQuery -> input validation.
+// Note: the width is checked against the stored resolution widths by the service.
+func (q *AlbumExportQuery) Validate() error {
+
+	if q.Original && q.Width != 0 {
+		return fmt.Errorf("export must be either the original files or a rendition width, not both")
+	}
+
+	if !q.Original && q.Width == 0 {
+		return fmt.Errorf("export must be either the original files or a rendition width")
+	}
+
+	if q.Width < 0 {
+		return fmt.Errorf("rendition width must be greater than 0")
+	}
+
+	return nil
+}
+
+// AlbumExportManifest is a model which represents the json manifest of an album export archive.
+type AlbumExportManifest struct {
+	AlbumSlug        string             `json:"album_slug"`
+	AlbumTitle       string             `json:"album_title"`
+	AlbumDescription string             `json:"album_description,omitempty"`
+	Original         bool               `json:"original"`        // true if the files are the untouched originals
+	Width            int                `json:"width,omitempty"` // rendition width of the files, if not the originals
+	ExportedAt       string             `json:"exported_at"`
+	Images           []AlbumExportImage `json:"images"`
+	Omitted          []AlbumExportImage `json:"omitted,omitempty"` // images the user may view but which could not be exported
+}
+
+// AlbumExportImage is a model which represents the metadata of an image in an album export archive.
+type AlbumExportImage struct {
+	FileName    string       `json:"file_name,omitempty"` // name of the file in the archive, empty if omitted
+	Slug        string       `json:"slug"`
+	Title       string       `json:"title"`
+	Description string       `json:"description,omitempty"`
+	FileType    string       `json:"file_type,omitempty"` // MIME type of the file in the archive
+	Width       int          `json:"width,omitempty"`     // width of the original image in pixels
+	Height      int          `json:"height,omitempty"`    // height of the original image in pixels
+	ImageDate   string       `json:"image_date,omitempty"`
+	Date        *PartialDate `json:"date,omitempty"`   // structured image date, including how precisely it is known
+	Reason      string       `json:"reason,omitempty"` // why the image was omitted
+}
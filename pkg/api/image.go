@@ -1,8 +1,11 @@
 package api
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,14 +23,41 @@ const (
 	ImageDescriptionMaxLength = 255                         // Maximum length for image description
 	ImageDescriptionRegex     = `^[\w\s.,!?'"()&-]{0,255}$` // Regex for image description, allows alphanumeric, spaces, punctuation, max 255 chars
 
-	ImageMaxSize = 10 * 1024 * 1024 // Maximum size for image file, 10 MB
+	ImageMaxSize = 10 * 1024 * 1024 // Maximum size for image file uploaded with a single presigned PUT, 10 MB: larger files are uploaded in parts
+
+	ImageDateKeyLayout = "2006-01"                 // Layout for the sortable image date key, year-month granularity
+	ImageDateKeyRegex  = `^\d{4}-(0[1-9]|1[0-2])$` // Regex for the sortable image date key, eg, "2024-03"
 )
 
 var (
 	imageTitleRegex       = regexp.MustCompile(ImageTitleRegex)
 	imageDescriptionRegex = regexp.MustCompile(ImageDescriptionRegex)
+	imageDateKeyRegex     = regexp.MustCompile(ImageDateKeyRegex)
 )
 
+// BuildImageDateKey builds the sortable image date key from an RFC3339 image date.
+// The key is deliberately coarse (year-month) so it can be stored in plaintext and
+// used to order and range-filter images in sql, since the image date itself is encrypted.
+// Returns an empty string if the image date is empty or not RFC3339.
+func BuildImageDateKey(imageDate string) string {
+
+	if imageDate == "" {
+		return ""
+	}
+
+	t, err := time.Parse(time.RFC3339, imageDate)
+	if err != nil {
+		return ""
+	}
+
+	return t.UTC().Format(ImageDateKeyLayout)
+}
+
+// ValidateImageDateKey checks if a string is a valid year-month image date key, eg, "2024-03".
+func ValidateImageDateKey(key string) bool {
+	return imageDateKeyRegex.MatchString(key)
+}
+
 var AllowedFileTypes = []string{
 	"image/jpeg",    // JPEG image format
 	"image/png",     // PNG image format
@@ -73,21 +103,28 @@ var extensionMap = map[string]string{
 // but also the signed url from the object storage service and other metadata.
 // It is used to return image data to the client in a single response.
 type ImageData struct {
-	Id          string `db:"uuid" json:"id,omitempty"`               // Unique identifier for the image record
-	Title       string `db:"title" json:"title"`                     // Title of the image
-	Description string `db:"description" json:"description"`         // Description of the image
-	FileName    string `db:"file_name" json:"file_name,omitempty"`   // name of the file with it's extension, eg, "slug.jpg"   // MIME type of the image, eg, "image/jpeg", "image/png"
-	FileType    string `db:"file_type" json:"file_type,omitempty"`   // MIME type of the image, eg, "image/jpeg", "image/png"
-	ObjectKey   string `db:"object_key" json:"object_key,omitempty"` // The key used to store the image in object storage, eg, "2025/slug.jpg"
-	Slug        string `db:"slug" json:"slug,omitempty"`             // unique slug for the image, used in URLs
-	Width       int    `db:"width" json:"width,omitempty"`           // Width of the image in pixels
-	Height      int    `db:"height" json:"height,omitempty"`         // Height of the image in pixels
-	Size        int64  `db:"size" json:"size,omitempty"`             // Size of the image file in bytes
-	ImageDate   string `db:"image_date" json:"image_date,omitempty"` // Date when the image was taken or created, ie, from exif metadata
-	CreatedAt   string `db:"created_at" json:"created_at,omitempty"` // Timestamp when the image was created
-	UpdatedAt   string `db:"updated_at" json:"updated_at,omitempty"` // Timestamp when the image was last updated
-	IsArchived  bool   `db:"is_archived" json:"is_archived"`         // Indicates if the image is archived
-	IsPublished bool   `db:"is_published" json:"is_published"`       // Indicates if the image is published and visible to users
+	Id          string       `db:"uuid" json:"id,omitempty"`               // Unique identifier for the image record
+	Title       string       `db:"title" json:"title"`                     // Title of the image
+	Description string       `db:"description" json:"description"`         // Description of the image
+	FileName    string       `db:"file_name" json:"file_name,omitempty"`   // name of the file with it's extension, eg, "slug.jpg"   // MIME type of the image, eg, "image/jpeg", "image/png"
+	FileType    string       `db:"file_type" json:"file_type,omitempty"`   // MIME type of the image, eg, "image/jpeg", "image/png"
+	ObjectKey   string       `db:"object_key" json:"object_key,omitempty"` // The key used to store the image in object storage, eg, "2025/slug.jpg"
+	Slug        string       `db:"slug" json:"slug,omitempty"`             // unique slug for the image, used in URLs
+	Width       int          `db:"width" json:"width,omitempty"`           // Width of the image in pixels
+	Height      int          `db:"height" json:"height,omitempty"`         // Height of the image in pixels
+	Size        int64        `db:"size" json:"size,omitempty"`             // Size of the image file in bytes
+	ImageDate   string       `db:"image_date" json:"image_date,omitempty"` // Date when the image was taken or created, ie, from exif metadata
+	Date        *PartialDate `json:"date,omitempty"`                       // structured image date, including how precisely it is known
+	CreatedAt   string       `db:"created_at" json:"created_at,omitempty"` // Timestamp when the image was created
+	UpdatedAt   string       `db:"updated_at" json:"updated_at,omitempty"` // Timestamp when the image was last updated
+	IsArchived  bool         `db:"is_archived" json:"is_archived"`         // Indicates if the image is archived
+	IsPublished bool         `db:"is_published" json:"is_published"`       // Indicates if the image is published and visible to users
+	Version     int          `db:"version" json:"version,omitempty"`       // version of the image's metadata, also returned as the ETag
+
+	// inline placeholders computed by the image processing pipeline so that grids can render
+	// a placeholder without an additional round trip to object storage.
+	BlurHash      string `db:"blur_hash" json:"blur_hash,omitempty"`           // BlurHash string of the image, eg, "LEHV6nWB2yk8pyo0adR*.7kCMdnj"
+	DominantColor string `db:"dominant_color" json:"dominant_color,omitempty"` // Dominant color of the image as a hex string, eg, "#a1b2c3"
 
 	// pre-signed GET URLs for the browser to access the image in object storage at various resolutions.
 	// This field is dynamically generated and not stored in the database.
@@ -95,9 +132,23 @@ type ImageData struct {
 	ImageTargets []ImageTarget `json:"image_targets,omitempty"` // The signed URL for the image, used to access the image in object storage
 	BlurUrl      string        `json:"blur_url,omitempty"`      // The signed URL for the blurred placeholder image, used for lazy loading in the browser
 
+	// Downloadable indicates the requesting user may download the untouched original.
+	// If false, the user is view-only and ImageTargets are capped at the configured view-only max width.
+	Downloadable bool `json:"downloadable"`
+
 	// these fields may or may not be present, depending on the context, access, query, etc.
-	Albums      []Album                  `json:"albums,omitempty"`      // Albums to which the image belongs
-	Permissions []permissions.Permission `json:"permissions,omitempty"` // Permissions associated with the image
+	Albums                  []Album                  `json:"albums,omitempty"`                    // Albums to which the image belongs
+	Permissions             []permissions.Permission `json:"permissions,omitempty"`               // Permissions associated with the image
+	DownloadPermissionSlugs []string                 `json:"download_permission_slugs,omitempty"` // Slugs of the image's permissions which may download the original
+}
+
+// ImageDownload is a model representing a short-lived signed URL to download the untouched original
+// image file, along with the attachment filename built from the image's title and date.
+type ImageDownload struct {
+	SignedUrl          string `json:"signed_url"`          // short-lived pre-signed GET URL to the original in object storage, served as an attachment
+	FileName           string `json:"file_name"`           // attachment filename, eg, "2024-07-04 Fourth of July.jpg"
+	ContentDisposition string `json:"content_disposition"` // Content-Disposition header value object storage responds with
+	ExpiresAt          string `json:"expires_at"`          // RFC3339 timestamp when the signed URL expires
 }
 
 // ImageTarget represents the model representing the width and signed url for accessing an image in object storage.
@@ -157,8 +208,9 @@ func (cmd *AddMetaDataCmd) Validate() error {
 	}
 
 	// validate the size
-	if cmd.Size <= 0 || cmd.Size > ImageMaxSize {
-		return fmt.Errorf("image size must be greater than 0 and less than or equal to %d bytes", ImageMaxSize)
+	// Note: the configured limit for the file type is checked by the service
+	if cmd.Size <= 0 || cmd.Size > ImageUploadMaxSize {
+		return fmt.Errorf("image size must be greater than 0 and less than or equal to %d bytes", ImageUploadMaxSize)
 	}
 
 	// validate the albums
@@ -210,6 +262,8 @@ type Placeholder struct {
 	IsArchived  bool   `db:"is_archived" json:"is_archived"`         // Indicates if the image is archived
 	IsPublished bool   `db:"is_published" json:"is_published"`       // Indicates if the image is published and visible to users
 	SignedUrl   string `json:"signed_url,omitempty"`                 // The signed PUT URL for uploading the image to object storage
+
+	Multipart *MultipartUpload `json:"multipart,omitempty"` // set instead of the signed url if the file is too large for a single PUT
 }
 
 // UpdateMetadataCmd is a model that represents the command to update metadata of an image record.
@@ -224,9 +278,18 @@ type UpdateMetadataCmd struct {
 	IsPublished    bool   `json:"is_published,omitempty"`     // Indicates if the image is published and visible to users
 	IsArchived     bool   `json:"is_archived,omitempty"`      // Indicates if the image is archived
 
+	// how precisely the image date is known, eg, only the year of a scanned print: defaults to day.
+	// Only the date fields known at the precision may be set.
+	ImageDatePrecision string `json:"image_date_precision,omitempty"` // one of the DatePrecision* constants, except exact
+	ImageDateEndYear   int    `json:"image_date_end_year,omitempty"`  // last year of a circa range
+
 	// addition fields will be added, albums, permissions, image size, etc.
 	AlbumSlugs      []string `json:"album_slugs,omitempty"`      // Slugs of the albums to associate with the image
 	PermissionSlugs []string `json:"permission_slugs,omitempty"` // Slugs of the permissions to associate with the image
+
+	// DownloadPermissionSlugs is the subset of PermissionSlugs whose holders may download the original.
+	// Any permission not included is view-only for this image.
+	DownloadPermissionSlugs []string `json:"download_permission_slugs,omitempty"`
 }
 
 // Validate checks the UpdateMetadataCmd for valid data.
@@ -264,16 +327,13 @@ func (cmd *UpdateMetadataCmd) Validate() error {
 	}
 
 	// validate the image date
-	if cmd.ImageDateMonth < 1 || cmd.ImageDateMonth > 12 {
-		return fmt.Errorf("image date month must be between 1 and 12")
-	}
-	if cmd.ImageDateDay < 1 || cmd.ImageDateDay > 31 {
-		return fmt.Errorf("image date day must be between 1 and 31")
+	// exact dates, ie, with a time, only come from exif metadata
+	if cmd.ImageDatePrecision == DatePrecisionExact {
+		return fmt.Errorf("image date precision must not be %s: exact dates are read from the image's exif metadata", DatePrecisionExact)
 	}
 
-	now := time.Now().UTC()
-	if cmd.ImageDateYear <= 1826 || cmd.ImageDateYear > now.Year() {
-		return fmt.Errorf("image date year must be 4 digits and between 1826, the year of the oldest known photograph, and now.")
+	if err := cmd.PartialDate().Validate(); err != nil {
+		return err
 	}
 
 	// validate that both the archived and published flags are not set to true at the same time
@@ -289,15 +349,271 @@ func (cmd *UpdateMetadataCmd) Validate() error {
 	}
 
 	// validate the permission slugs if any are provided
+	permissionSlugs := make(map[string]struct{}, len(cmd.PermissionSlugs))
 	for _, slug := range cmd.PermissionSlugs {
 		if err := validate.ValidateUuid(slug); err != nil {
 			return fmt.Errorf("invalid permission slug: %s", slug)
 		}
+		permissionSlugs[slug] = struct{}{}
+	}
+
+	// validate the download permission slugs are a subset of the permission slugs:
+	// downloading is a dimension of viewing, not a separate grant
+	for _, slug := range cmd.DownloadPermissionSlugs {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return fmt.Errorf("invalid download permission slug: %s", slug)
+		}
+		if _, ok := permissionSlugs[slug]; !ok {
+			return fmt.Errorf("download permission slug %s must also be one of the image's permission slugs", slug)
+		}
 	}
 
 	return nil
 }
 
+// PartialDate builds the image date from the command's date fields, at day precision if none is given.
+func (cmd *UpdateMetadataCmd) PartialDate() *PartialDate {
+
+	precision := cmd.ImageDatePrecision
+	if precision == "" {
+		precision = DatePrecisionDay
+	}
+
+	d := &PartialDate{
+		Precision: precision,
+		Year:      cmd.ImageDateYear,
+		Month:     cmd.ImageDateMonth,
+		Day:       cmd.ImageDateDay,
+		EndYear:   cmd.ImageDateEndYear,
+	}
+	d.Display = d.String()
+
+	return d
+}
+
+// MergePatchContentType is the media type of a JSON merge-patch (RFC 7396) request body.
+const MergePatchContentType = "application/merge-patch+json"
+
+// PatchMetadataCmd is a model representing a JSON merge-patch of an image's metadata.
+// Only the fields present are changed: absent fields keep their current value.
+// Albums and permissions are changed with explicit add and remove lists rather than being replaced.
+type PatchMetadataCmd struct {
+	Csrf               string  `json:"csrf,omitempty"`                 // CSRF token for security -> needed by downstream services
+	Title              *string `json:"title,omitempty"`                // Title of the image
+	Description        *string `json:"description,omitempty"`          // Description of the image
+	ImageDateYear      *int    `json:"image_date_year,omitempty"`      // Year of the image date, 4 digits
+	ImageDateMonth     *int    `json:"image_date_month,omitempty"`     // Month of the image date, 1-12
+	ImageDateDay       *int    `json:"image_date_day,omitempty"`       // Day of the image date, 1-31
+	ImageDatePrecision *string `json:"image_date_precision,omitempty"` // one of the DatePrecision* constants, except exact
+	ImageDateEndYear   *int    `json:"image_date_end_year,omitempty"`  // last year of a circa range
+	IsPublished        *bool   `json:"is_published,omitempty"`         // Indicates if the image is published and visible to users
+	IsArchived         *bool   `json:"is_archived,omitempty"`          // Indicates if the image is archived
+
+	AddAlbumSlugs    []string `json:"add_album_slugs,omitempty"`    // Slugs of albums to add the image to
+	RemoveAlbumSlugs []string `json:"remove_album_slugs,omitempty"` // Slugs of albums to remove the image from
+
+	AddPermissionSlugs    []string `json:"add_permission_slugs,omitempty"`    // Slugs of permissions to grant on the image
+	RemovePermissionSlugs []string `json:"remove_permission_slugs,omitempty"` // Slugs of permissions to revoke from the image
+
+	// download permissions must be one of the image's permissions once the patch is applied
+	AddDownloadPermissionSlugs    []string `json:"add_download_permission_slugs,omitempty"`
+	RemoveDownloadPermissionSlugs []string `json:"remove_download_permission_slugs,omitempty"`
+}
+
+// UnmarshalJSON decodes a merge-patch, rejecting unknown fields so a misspelled field is not silently ignored,
+// and null values: in a merge-patch null removes a field, but none of an image's metadata fields are removable.
+func (cmd *PatchMetadataCmd) UnmarshalJSON(b []byte) error {
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return fmt.Errorf("patch must be a JSON object")
+	}
+
+	for name, value := range fields {
+		if string(bytes.TrimSpace(value)) == "null" {
+			return fmt.Errorf("field %s cannot be removed", name)
+		}
+	}
+
+	// alias type drops this method, so decoding into it does not recurse
+	type patch PatchMetadataCmd
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+
+	var p patch
+	if err := dec.Decode(&p); err != nil {
+		return fmt.Errorf("failed to decode patch: %v", err)
+	}
+
+	*cmd = PatchMetadataCmd(p)
+
+	return nil
+}
+
+// IsEmpty returns true if the patch changes nothing.
+func (cmd *PatchMetadataCmd) IsEmpty() bool {
+	return cmd.Title == nil &&
+		cmd.Description == nil &&
+		!cmd.HasImageDate() &&
+		cmd.IsPublished == nil &&
+		cmd.IsArchived == nil &&
+		len(cmd.AddAlbumSlugs) == 0 &&
+		len(cmd.RemoveAlbumSlugs) == 0 &&
+		len(cmd.AddPermissionSlugs) == 0 &&
+		len(cmd.RemovePermissionSlugs) == 0 &&
+		len(cmd.AddDownloadPermissionSlugs) == 0 &&
+		len(cmd.RemoveDownloadPermissionSlugs) == 0
+}
+
+// HasImageDate returns true if the patch changes any part of the image date.
+func (cmd *PatchMetadataCmd) HasImageDate() bool {
+	return cmd.ImageDateYear != nil || cmd.ImageDateMonth != nil || cmd.ImageDateDay != nil ||
+		cmd.ImageDatePrecision != nil || cmd.ImageDateEndYear != nil
+}
+
+// Validate checks the fields present in the PatchMetadataCmd for valid data.
+// Note: the image date and the archived/published flags can only be fully validated once merged
+// with the current image, see MergeImageDate.
+func (cmd *PatchMetadataCmd) Validate() error {
+
+	// validate the csrf token
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("csrf token must be a valid UUID")
+		}
+	}
+
+	if cmd.IsEmpty() {
+		return fmt.Errorf("patch must change at least one field")
+	}
+
+	return cmd.validateFields()
+}
+
+// validateFields is a helper which checks the fields present in the PatchMetadataCmd for valid data,
+// without requiring any to be present, eg, for a bulk operation which only shifts the image date.
+func (cmd *PatchMetadataCmd) validateFields() error {
+
+	if cmd.Title != nil && !imageTitleRegex.MatchString(strings.TrimSpace(*cmd.Title)) {
+		return fmt.Errorf("title must be alphanumeric and spaces, min %d chars, max %d chars", ImageTitleMinLength, ImageTitleMaxLength)
+	}
+
+	if cmd.Description != nil && !imageDescriptionRegex.MatchString(strings.TrimSpace(*cmd.Description)) {
+		return fmt.Errorf("description must be alphanumeric, spaces, and punctuation, min %d chars, max %d chars", ImageDescriptionMinLength, ImageDescriptionMaxLength)
+	}
+
+	if cmd.IsArchived != nil && cmd.IsPublished != nil && *cmd.IsArchived && *cmd.IsPublished {
+		return fmt.Errorf("image cannot be both archived and published at the same time")
+	}
+
+	// exact dates, ie, with a time, only come from exif metadata
+	if cmd.ImageDatePrecision != nil && *cmd.ImageDatePrecision == DatePrecisionExact {
+		return fmt.Errorf("image date precision must not be %s: exact dates are read from the image's exif metadata", DatePrecisionExact)
+	}
+
+	lists := []struct {
+		name        string
+		add, remove []string
+	}{
+		{"album", cmd.AddAlbumSlugs, cmd.RemoveAlbumSlugs},
+		{"permission", cmd.AddPermissionSlugs, cmd.RemovePermissionSlugs},
+		{"download permission", cmd.AddDownloadPermissionSlugs, cmd.RemoveDownloadPermissionSlugs},
+	}
+
+	for _, l := range lists {
+		added := make(map[string]struct{}, len(l.add))
+		for _, slug := range l.add {
+			if err := validate.ValidateUuid(slug); err != nil {
+				return fmt.Errorf("invalid %s slug: %s", l.name, slug)
+			}
+			added[slug] = struct{}{}
+		}
+
+		for _, slug := range l.remove {
+			if err := validate.ValidateUuid(slug); err != nil {
+				return fmt.Errorf("invalid %s slug: %s", l.name, slug)
+			}
+			if _, ok := added[slug]; ok {
+				return fmt.Errorf("%s slug %s must not be both added and removed", l.name, slug)
+			}
+		}
+	}
+
+	// a permission being revoked cannot also be granted download access
+	for _, slug := range cmd.AddDownloadPermissionSlugs {
+		if slices.Contains(cmd.RemovePermissionSlugs, slug) {
+			return fmt.Errorf("download permission slug %s must not be a permission slug being removed", slug)
+		}
+	}
+
+	return nil
+}
+
+// MergeImageDate merges the image date fields present in the patch into the current image date, which is nil
+// if the image has no date, returning the merged date.  Changing the precision drops the fields it does not keep,
+// eg, month and day when changing to year, and editing any part of an exact date makes it day precision.
+// An image with no date must be given a full date at the patched precision.
+func (cmd *PatchMetadataCmd) MergeImageDate(current *PartialDate) (*PartialDate, error) {
+
+	merged := PartialDate{Precision: DatePrecisionDay}
+	if current != nil {
+		merged = PartialDate{
+			Precision: current.Precision,
+			Year:      current.Year,
+			Month:     current.Month,
+			Day:       current.Day,
+			EndYear:   current.EndYear,
+		}
+	}
+
+	// the time of an exact date is not editable, so it becomes a day
+	if merged.Precision == DatePrecisionExact {
+		merged.Precision = DatePrecisionDay
+	}
+
+	if cmd.ImageDatePrecision != nil {
+		merged.Precision = *cmd.ImageDatePrecision
+	}
+
+	// drop the fields the precision does not keep
+	switch merged.Precision {
+	case DatePrecisionMonth:
+		merged.Day = 0
+		merged.EndYear = 0
+	case DatePrecisionYear, DatePrecisionDecade:
+		merged.Month, merged.Day = 0, 0
+		merged.EndYear = 0
+	case DatePrecisionCirca:
+		merged.Month, merged.Day = 0, 0
+	default:
+		merged.EndYear = 0
+	}
+
+	if cmd.ImageDateYear != nil {
+		merged.Year = *cmd.ImageDateYear
+	}
+	if cmd.ImageDateMonth != nil {
+		merged.Month = *cmd.ImageDateMonth
+	}
+	if cmd.ImageDateDay != nil {
+		merged.Day = *cmd.ImageDateDay
+	}
+	if cmd.ImageDateEndYear != nil {
+		merged.EndYear = *cmd.ImageDateEndYear
+	}
+
+	if err := merged.Validate(); err != nil {
+		if current == nil {
+			return nil, fmt.Errorf("%v: the image has no date to merge with", err)
+		}
+		return nil, err
+	}
+
+	merged.Display = merged.String()
+
+	return &merged, nil
+}
+
 // ImageRecord is a model that represents the image record in the database.
 // It contains the fields that are stored in the database, such as the image slug,
 // metadata, and any other relevant information.
@@ -319,6 +635,15 @@ type ImageRecord struct {
 	UpdatedAt   data.CustomTime `db:"updated_at" json:"updated_at"`     // Timestamp when the image was last updated
 	IsArchived  bool            `db:"is_archived" json:"is_archived"`   // Indicates if the image is archived
 	IsPublished bool            `db:"is_published" json:"is_published"` // Indicates if the image is published and visible to users
+
+	BlurHash      string `db:"blur_hash" json:"blur_hash"`           // ENCRYPTED: BlurHash placeholder string, computed by the image processing pipeline
+	DominantColor string `db:"dominant_color" json:"dominant_color"` // ENCRYPTED: dominant color hex string, eg "#a1b2c3", computed by the image processing pipeline
+	ImageDateKey  string `db:"image_date_key" json:"image_date_key"` // NOT encrypted: sortable year-month of the image date, eg "2024-03", empty if no image date
+
+	ImageDatePrecision string `db:"image_date_precision" json:"image_date_precision"` // NOT encrypted: one of the DatePrecision* constants, empty if exact
+	ImageDateEndYear   int    `db:"image_date_end_year" json:"image_date_end_year"`   // NOT encrypted: last year of a circa range, 0 otherwise
+
+	Version int `db:"version" json:"version"` // incremented on every metadata edit, for optimistic concurrency control
 }
 
 // Validate checks the ImageRecord for valid data before storing it in the database.
@@ -365,8 +690,8 @@ func (r *ImageRecord) Validate() error {
 	}
 
 	// validate the size
-	if r.Size <= 0 || r.Size > ImageMaxSize {
-		return fmt.Errorf("image size must be greater than 0 and less than or equal to %d bytes", ImageMaxSize)
+	if r.Size <= 0 || r.Size > ImageUploadMaxSize {
+		return fmt.Errorf("image size must be greater than 0 and less than or equal to %d bytes", ImageUploadMaxSize)
 	}
 
 	// width and height are optional, but if provided, they must be positive integers
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tdeslauriers/carapace/pkg/validate"
+)
+
+// BulkImagesMax is the maximum number of images a single bulk operation may change.
+const BulkImagesMax int = 500
+
+// BulkImageCmd is a model representing a set of operations to apply to each of a list of images, eg,
+// publishing and filing a batch of imported photos in one request.
+type BulkImageCmd struct {
+	Csrf       string              `json:"csrf,omitempty"` // CSRF token for security -> needed by downstream services
+	ImageSlugs []string            `json:"image_slugs"`    // slugs of the images to apply the operations to
+	Operations BulkImageOperations `json:"operations"`     // operations applied to every image
+}
+
+// BulkImageOperations is a model representing the operations of a bulk image command.
+// Absent operations leave the images' current values as they are.
+type BulkImageOperations struct {
+	IsPublished *bool `json:"is_published,omitempty"` // publishes, or unpublishes, the images
+	IsArchived  *bool `json:"is_archived,omitempty"`  // archives, or un-archives, the images
+
+	AddAlbumSlugs    []string `json:"add_album_slugs,omitempty"`    // Slugs of albums to add the images to
+	RemoveAlbumSlugs []string `json:"remove_album_slugs,omitempty"` // Slugs of albums to remove the images from
+
+	AddPermissionSlugs    []string `json:"add_permission_slugs,omitempty"`    // Slugs of permissions to grant on the images
+	RemovePermissionSlugs []string `json:"remove_permission_slugs,omitempty"` // Slugs of permissions to revoke from the images
+
+	// the image date is either set to the same date for every image, or each image's date is shifted, eg,
+	// correcting a camera clock that was a year behind.  Not both.
+	SetImageDate   *BulkImageDate  `json:"set_image_date,omitempty"`
+	ShiftImageDate *ImageDateShift `json:"shift_image_date,omitempty"`
+}
+
+// BulkImageDate is a model representing the image date a bulk operation sets on every image.
+type BulkImageDate struct {
+	Year      int    `json:"year"`                // Year of the image date, 4 digits
+	Month     int    `json:"month,omitempty"`     // Month of the image date, 1-12
+	Day       int    `json:"day,omitempty"`       // Day of the image date, 1-31
+	Precision string `json:"precision,omitempty"` // one of the DatePrecision* constants, except exact: defaults to day
+	EndYear   int    `json:"end_year,omitempty"`  // last year of a circa range
+}
+
+// ImageDateShift is a model representing an amount of time to move an image date by, eg, -1 year.
+type ImageDateShift struct {
+	Years  int `json:"years,omitempty"`
+	Months int `json:"months,omitempty"`
+	Days   int `json:"days,omitempty"`
+}
+
+// Validate checks the BulkImageCmd for valid data.
+func (cmd *BulkImageCmd) Validate() error {
+
+	// validate the csrf token
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("csrf token must be a valid UUID")
+		}
+	}
+
+	// validate the image slugs
+	if len(cmd.ImageSlugs) == 0 {
+		return fmt.Errorf("at least one image slug is required")
+	}
+
+	if len(cmd.ImageSlugs) > BulkImagesMax {
+		return fmt.Errorf("a bulk operation must be %d images or fewer, got %d", BulkImagesMax, len(cmd.ImageSlugs))
+	}
+
+	slugs := make(map[string]struct{}, len(cmd.ImageSlugs))
+	for _, slug := range cmd.ImageSlugs {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return fmt.Errorf("invalid image slug: %s", slug)
+		}
+		if _, ok := slugs[slug]; ok {
+			return fmt.Errorf("image slug %s must only be listed once", slug)
+		}
+		slugs[slug] = struct{}{}
+	}
+
+	// validate the operations
+	ops := cmd.Operations
+	patch := ops.Patch()
+	if patch.IsEmpty() && ops.ShiftImageDate == nil {
+		return fmt.Errorf("at least one operation is required")
+	}
+
+	if ops.SetImageDate != nil && ops.ShiftImageDate != nil {
+		return fmt.Errorf("image date must be either set or shifted, not both")
+	}
+
+	// checks the album and permission slugs, and, with its date fields set, the image date
+	// Note: the patch may be empty if the only operation is a date shift
+	if err := patch.validateFields(); err != nil {
+		return err
+	}
+
+	if ops.SetImageDate != nil {
+		if _, err := patch.MergeImageDate(nil); err != nil {
+			return err
+		}
+	}
+
+	if ops.ShiftImageDate != nil {
+		if err := ops.ShiftImageDate.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Patch builds the merge-patch of the operations applied to each image, except a date shift,
+// which depends on each image's current date.
+func (ops *BulkImageOperations) Patch() PatchMetadataCmd {
+
+	patch := PatchMetadataCmd{
+		IsPublished:           ops.IsPublished,
+		IsArchived:            ops.IsArchived,
+		AddAlbumSlugs:         ops.AddAlbumSlugs,
+		RemoveAlbumSlugs:      ops.RemoveAlbumSlugs,
+		AddPermissionSlugs:    ops.AddPermissionSlugs,
+		RemovePermissionSlugs: ops.RemovePermissionSlugs,
+	}
+
+	// every date field is set, so the images' current dates are replaced rather than merged
+	if d := ops.SetImageDate; d != nil {
+		precision := d.Precision
+		if precision == "" {
+			precision = DatePrecisionDay
+		}
+		year, month, day, endYear := d.Year, d.Month, d.Day, d.EndYear
+		patch.ImageDatePrecision = &precision
+		patch.ImageDateYear = &year
+		patch.ImageDateMonth = &month
+		patch.ImageDateDay = &day
+		patch.ImageDateEndYear = &endYear
+	}
+
+	return patch
+}
+
+// Validate checks the ImageDateShift moves the date and is within the range of photographic history.
+func (s *ImageDateShift) Validate() error {
+
+	if s.Years == 0 && s.Months == 0 && s.Days == 0 {
+		return fmt.Errorf("image date shift must move the date by at least one day, month, or year")
+	}
+
+	// the oldest known photograph is ~200 years old, so a larger shift is always a mistake
+	maxYears := time.Now().UTC().Year() - oldestPhotographYear
+	if s.Years < -maxYears || s.Years > maxYears ||
+		s.Months < -maxYears*12 || s.Months > maxYears*12 ||
+		s.Days < -maxYears*366 || s.Days > maxYears*366 {
+		return fmt.Errorf("image date shift must be at most %d years", maxYears)
+	}
+
+	return nil
+}
+
+// Apply shifts the image date, keeping its precision, and the time of an exact date.
+// A day past the end of the shifted month is kept to the month's last day, eg, 29 February 2021 plus a year.
+// Returns an error if the shift is finer than the date is known, eg, shifting a date known only
+// to the year by days, or if the shifted date is not valid, eg, in the future.
+func (s *ImageDateShift) Apply(d *PartialDate) (*PartialDate, error) {
+
+	if d == nil {
+		return nil, fmt.Errorf("image has no image date to shift")
+	}
+
+	if s.Days != 0 && !d.IsDayKnown() {
+		return nil, fmt.Errorf("image date must be known to the day to shift it by days: it is %s", d.Display)
+	}
+
+	if s.Months != 0 && d.Month == 0 {
+		return nil, fmt.Errorf("image date must be known to the month to shift it by months: it is %s", d.Display)
+	}
+
+	start, err := time.Parse(time.RFC3339, d.ImageDate())
+	if err != nil {
+		return nil, fmt.Errorf("image date %s is not valid: %v", d.ImageDate(), err)
+	}
+
+	endYear := d.EndYear
+	if d.Precision == DatePrecisionCirca {
+		endYear += s.Years
+	}
+
+	// years and months are shifted from the first of the month so the day is kept within the shifted month,
+	// eg, 31 March less a month is 29 February, rather than overflowing into 2 March
+	month := time.Date(start.Year(), start.Month(), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), start.Location()).
+		AddDate(s.Years, s.Months, 0)
+	day := min(start.Day(), month.AddDate(0, 1, -1).Day())
+
+	shifted := BuildPartialDate(month.AddDate(0, 0, day-1+s.Days).Format(time.RFC3339), d.Precision, endYear)
+	if shifted == nil {
+		return nil, fmt.Errorf("image date could not be shifted")
+	}
+
+	if err := shifted.Validate(); err != nil {
+		return nil, err
+	}
+
+	return shifted, nil
+}
+
+// BulkImageResult is a model representing the outcome of a bulk operation for one image.
+type BulkImageResult struct {
+	Slug       string `json:"slug"`
+	StatusCode int    `json:"status_code"`       // http status code the image's operations would have returned on their own, eg, 404
+	Message    string `json:"message,omitempty"` // the reason the operations failed, if they did
+	Version    int    `json:"version,omitempty"` // the image's new version, ie, ETag, if the operations succeeded
+}
+
+// BulkImageResponse is a model representing the outcome of a bulk operation, with a result for each image,
+// in the order of the command's image slugs.  Each image is changed, or not, on its own:
+// one image failing does not undo the changes to the others.
+type BulkImageResponse struct {
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []BulkImageResult `json:"results"`
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+const (
+	testSlug  = "11111111-1111-1111-1111-111111111111"
+	testSlug2 = "22222222-2222-2222-2222-222222222222"
+)
+
+func TestBulkImageCmd_Validate(t *testing.T) {
+
+	published := true
+
+	tests := []struct {
+		name    string
+		cmd     BulkImageCmd
+		wantErr string
+	}{
+		{
+			name: "shift only",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug},
+				Operations: BulkImageOperations{ShiftImageDate: &ImageDateShift{Years: -1}},
+			},
+		},
+		{
+			name: "shift with other operations",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug, testSlug2},
+				Operations: BulkImageOperations{
+					IsPublished:    &published,
+					AddAlbumSlugs:  []string{testSlug2},
+					ShiftImageDate: &ImageDateShift{Months: 3},
+				},
+			},
+		},
+		{
+			name: "set only",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug},
+				Operations: BulkImageOperations{SetImageDate: &BulkImageDate{Year: 1974, Month: 7, Precision: DatePrecisionMonth}},
+			},
+		},
+		{
+			name: "set and shift",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug},
+				Operations: BulkImageOperations{
+					SetImageDate:   &BulkImageDate{Year: 1974, Precision: DatePrecisionYear},
+					ShiftImageDate: &ImageDateShift{Years: 1},
+				},
+			},
+			wantErr: "either set or shifted",
+		},
+		{
+			name:    "no operations",
+			cmd:     BulkImageCmd{ImageSlugs: []string{testSlug}},
+			wantErr: "at least one operation",
+		},
+		{
+			name: "no images",
+			cmd: BulkImageCmd{
+				Operations: BulkImageOperations{ShiftImageDate: &ImageDateShift{Days: 1}},
+			},
+			wantErr: "at least one image slug",
+		},
+		{
+			name: "duplicate image",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug, testSlug},
+				Operations: BulkImageOperations{ShiftImageDate: &ImageDateShift{Days: 1}},
+			},
+			wantErr: "only be listed once",
+		},
+		{
+			name: "shift by nothing",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug},
+				Operations: BulkImageOperations{ShiftImageDate: &ImageDateShift{}},
+			},
+			wantErr: "at least one day, month, or year",
+		},
+		{
+			name: "shift with invalid album slug",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug},
+				Operations: BulkImageOperations{
+					AddAlbumSlugs:  []string{"not-a-slug"},
+					ShiftImageDate: &ImageDateShift{Days: 1},
+				},
+			},
+			wantErr: "invalid album slug",
+		},
+		{
+			name: "set invalid date",
+			cmd: BulkImageCmd{
+				ImageSlugs: []string{testSlug},
+				Operations: BulkImageOperations{SetImageDate: &BulkImageDate{Year: 2021, Month: 2, Day: 30}},
+			},
+			wantErr: "not valid",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cmd.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Validate() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestImageDateShift_Apply(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		date      *PartialDate
+		shift     ImageDateShift
+		want      string // RFC3339 image date of the shifted date
+		wantKey   string
+		wantEnd   int
+		wantErr   string
+		precision string
+	}{
+		{
+			name:      "years back",
+			date:      BuildPartialDate("2020-06-15T00:00:00Z", DatePrecisionDay, 0),
+			shift:     ImageDateShift{Years: -1},
+			want:      "2019-06-15T00:00:00Z",
+			wantKey:   "2019-06",
+			precision: DatePrecisionDay,
+		},
+		{
+			name:      "month underflow into previous year",
+			date:      BuildPartialDate("2020-01-01T00:00:00Z", DatePrecisionMonth, 0),
+			shift:     ImageDateShift{Months: -1},
+			want:      "2019-12-01T00:00:00Z",
+			wantKey:   "2019-12",
+			precision: DatePrecisionMonth,
+		},
+		{
+			name:      "month underflow keeps day within shorter month",
+			date:      BuildPartialDate("2021-03-31T00:00:00Z", DatePrecisionDay, 0),
+			shift:     ImageDateShift{Months: -1},
+			want:      "2021-02-28T00:00:00Z",
+			wantKey:   "2021-02",
+			precision: DatePrecisionDay,
+		},
+		{
+			name:      "month underflow into leap february",
+			date:      BuildPartialDate("2020-03-31T00:00:00Z", DatePrecisionDay, 0),
+			shift:     ImageDateShift{Months: -1},
+			want:      "2020-02-29T00:00:00Z",
+			wantKey:   "2020-02",
+			precision: DatePrecisionDay,
+		},
+		{
+			name:      "leap day plus a year",
+			date:      BuildPartialDate("2020-02-29T00:00:00Z", DatePrecisionDay, 0),
+			shift:     ImageDateShift{Years: 1},
+			want:      "2021-02-28T00:00:00Z",
+			wantKey:   "2021-02",
+			precision: DatePrecisionDay,
+		},
+		{
+			name:      "day underflow into previous month",
+			date:      BuildPartialDate("2020-03-01T00:00:00Z", DatePrecisionDay, 0),
+			shift:     ImageDateShift{Days: -1},
+			want:      "2020-02-29T00:00:00Z",
+			wantKey:   "2020-02",
+			precision: DatePrecisionDay,
+		},
+		{
+			name:      "exact keeps time",
+			date:      BuildPartialDate("2020-01-15T10:30:00Z", "", 0),
+			shift:     ImageDateShift{Days: 1},
+			want:      "2020-01-16T10:30:00Z",
+			wantKey:   "2020-01",
+			precision: DatePrecisionExact,
+		},
+		{
+			name:      "circa shifts end year",
+			date:      BuildPartialDate("1948-01-01T00:00:00Z", DatePrecisionCirca, 1952),
+			shift:     ImageDateShift{Years: 2},
+			want:      "1950-01-01T00:00:00Z",
+			wantKey:   "1950-00",
+			wantEnd:   1954,
+			precision: DatePrecisionCirca,
+		},
+		{
+			name:    "days on year precision",
+			date:    BuildPartialDate("1974-01-01T00:00:00Z", DatePrecisionYear, 0),
+			shift:   ImageDateShift{Days: 1},
+			wantErr: "known to the day",
+		},
+		{
+			name:    "months on year precision",
+			date:    BuildPartialDate("1974-01-01T00:00:00Z", DatePrecisionYear, 0),
+			shift:   ImageDateShift{Months: 1},
+			wantErr: "known to the month",
+		},
+		{
+			name:    "into the future",
+			date:    BuildPartialDate("2020-01-01T00:00:00Z", DatePrecisionYear, 0),
+			shift:   ImageDateShift{Years: 100},
+			wantErr: "year must be",
+		},
+		{
+			name:    "no date",
+			shift:   ImageDateShift{Years: 1},
+			wantErr: "no image date",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.shift.Apply(tc.date)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Apply() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+			if got.ImageDate() != tc.want {
+				t.Errorf("ImageDate() = %s, want %s", got.ImageDate(), tc.want)
+			}
+			if got.DateKey() != tc.wantKey {
+				t.Errorf("DateKey() = %s, want %s", got.DateKey(), tc.wantKey)
+			}
+			if got.Precision != tc.precision {
+				t.Errorf("Precision = %s, want %s", got.Precision, tc.precision)
+			}
+			if got.EndYear != tc.wantEnd {
+				t.Errorf("EndYear = %d, want %d", got.EndYear, tc.wantEnd)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// image date precisions: how much of an image's date is known.
+// Exact dates come from exif metadata, the others are set by curators, eg, for scanned prints.
+const (
+	DatePrecisionExact  string = "exact"  // date and time, ie, from exif metadata
+	DatePrecisionDay    string = "day"    // eg, 4 July 1976
+	DatePrecisionMonth  string = "month"  // eg, July 1974
+	DatePrecisionYear   string = "year"   // eg, summer 1974 -> 1974
+	DatePrecisionDecade string = "decade" // eg, the 1950s
+	DatePrecisionCirca  string = "circa"  // a range of years, eg, circa 1948-1952
+
+	DateCircaMaxYears int = 25 // maximum span of a circa range, in years
+
+	// the month of the sortable image date key of a date known only to the year, or less precisely.
+	// It sorts before the year's months, so these images lead their year.
+	imageDateKeyYearMonth string = "00"
+
+	oldestPhotographYear int = 1826 // the year of the oldest known photograph
+)
+
+// PartialDate is a model representing an image date which may only be partially known, eg,
+// the decade a scanned print was taken in.  Only the fields known at its precision are set.
+type PartialDate struct {
+	Precision string `json:"precision"`          // one of the DatePrecision* constants
+	Year      int    `json:"year"`               // year, or the first year of a decade or circa range
+	Month     int    `json:"month,omitempty"`    // 1-12: exact, day, and month precision only
+	Day       int    `json:"day,omitempty"`      // 1-31: exact and day precision only
+	EndYear   int    `json:"end_year,omitempty"` // last year of a circa range: circa precision only
+	Display   string `json:"display,omitempty"`  // human readable date, eg, "July 1974", "1950s", "circa 1948-1952"
+
+	// the image date and time of an exact date, which is kept as is
+	exact string
+}
+
+// BuildPartialDate builds the partial date of an image from its stored RFC3339 image date, which is the
+// start of the date's range, its precision, and, for a circa range, its end year.
+// An empty precision is an exact date: image dates recorded before precisions were stored are all exact.
+// Returns nil if the image date is empty or not RFC3339.
+func BuildPartialDate(imageDate, precision string, endYear int) *PartialDate {
+
+	if imageDate == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, imageDate)
+	if err != nil {
+		return nil
+	}
+	t = t.UTC()
+
+	d := &PartialDate{Precision: precision, Year: t.Year()}
+	switch precision {
+	case "", DatePrecisionExact:
+		d.Precision = DatePrecisionExact
+		d.Month, d.Day = int(t.Month()), t.Day()
+		d.exact = imageDate
+	case DatePrecisionDay:
+		d.Month, d.Day = int(t.Month()), t.Day()
+	case DatePrecisionMonth:
+		d.Month = int(t.Month())
+	case DatePrecisionYear, DatePrecisionDecade:
+	case DatePrecisionCirca:
+		d.EndYear = endYear
+	default:
+		return nil
+	}
+
+	d.Display = d.String()
+
+	return d
+}
+
+// Validate checks the PartialDate has exactly the fields its precision requires, and that they are a real date.
+func (d *PartialDate) Validate() error {
+
+	now := time.Now().UTC()
+
+	switch d.Precision {
+	case DatePrecisionExact, DatePrecisionDay:
+		if d.Month < 1 || d.Month > 12 {
+			return fmt.Errorf("image date month must be between 1 and 12")
+		}
+		// time.Date normalizes an out of range day, eg, February 30th into March, so check it round trips
+		if d.Day < 1 || time.Date(d.Year, time.Month(d.Month), d.Day, 0, 0, 0, 0, time.UTC).Day() != d.Day {
+			return fmt.Errorf("image date day %d is not valid for %d-%02d", d.Day, d.Year, d.Month)
+		}
+	case DatePrecisionMonth:
+		if d.Month < 1 || d.Month > 12 {
+			return fmt.Errorf("image date month must be between 1 and 12")
+		}
+		if d.Day != 0 {
+			return fmt.Errorf("image date day must not be set for %s precision", d.Precision)
+		}
+	case DatePrecisionYear, DatePrecisionDecade, DatePrecisionCirca:
+		if d.Month != 0 || d.Day != 0 {
+			return fmt.Errorf("image date month and day must not be set for %s precision", d.Precision)
+		}
+	default:
+		return fmt.Errorf("image date precision must be one of %s", strings.Join([]string{
+			DatePrecisionExact, DatePrecisionDay, DatePrecisionMonth, DatePrecisionYear, DatePrecisionDecade, DatePrecisionCirca,
+		}, ", "))
+	}
+
+	// a decade may start before the oldest photograph, as long as it includes it
+	switch d.Precision {
+	case DatePrecisionDecade:
+		if d.Year%10 != 0 {
+			return fmt.Errorf("image date year must be the first year of the decade, eg, 1950")
+		}
+		if d.Year+9 < oldestPhotographYear || d.Year > now.Year() {
+			return fmt.Errorf("image date decade must be between the 1820s, the decade of the oldest known photograph, and now")
+		}
+	default:
+		if d.Year < oldestPhotographYear || d.Year > now.Year() {
+			return fmt.Errorf("image date year must be 4 digits and between %d, the year of the oldest known photograph, and now.", oldestPhotographYear)
+		}
+	}
+
+	if d.Precision == DatePrecisionCirca {
+		if d.EndYear <= d.Year || d.EndYear > now.Year() {
+			return fmt.Errorf("image date end year must be after the start year and not after now")
+		}
+		if d.EndYear-d.Year > DateCircaMaxYears {
+			return fmt.Errorf("image date circa range must be at most %d years", DateCircaMaxYears)
+		}
+	} else if d.EndYear != 0 {
+		return fmt.Errorf("image date end year must only be set for %s precision", DatePrecisionCirca)
+	}
+
+	return nil
+}
+
+// ImageDate returns the RFC3339 image date to store: the start of the date's range, eg,
+// the first of the month for month precision, or the date and time itself if exact.
+func (d *PartialDate) ImageDate() string {
+
+	if d.Precision == DatePrecisionExact && d.exact != "" {
+		return d.exact
+	}
+
+	month, day := d.Month, d.Day
+	if month == 0 {
+		month = 1
+	}
+	if day == 0 {
+		day = 1
+	}
+
+	return time.Date(d.Year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+}
+
+// DateKey returns the sortable image date key of the date: its year-month, or, if the month is not known,
+// the year with month "00", eg, "1950-00" for the 1950s, so the image sorts at the start of its year.
+func (d *PartialDate) DateKey() string {
+
+	if d.Month == 0 {
+		return fmt.Sprintf("%04d-%s", d.Year, imageDateKeyYearMonth)
+	}
+
+	return fmt.Sprintf("%04d-%02d", d.Year, d.Month)
+}
+
+// YearAlbum returns the title of the year album the image belongs in, and false if the date spans
+// several years, ie, a decade or circa range, so the image belongs in no year album.
+func (d *PartialDate) YearAlbum() (string, bool) {
+
+	if d.Precision == DatePrecisionDecade || d.Precision == DatePrecisionCirca {
+		return "", false
+	}
+
+	return strconv.Itoa(d.Year), true
+}
+
+// IsDayKnown returns true if the calendar day of the date is known, eg, so it can be an "on this day" memory.
+func (d *PartialDate) IsDayKnown() bool {
+	return d.Precision == DatePrecisionExact || d.Precision == DatePrecisionDay
+}
+
+// String returns the human readable date, eg, "4 July 1976", "July 1974", "1974", "1950s", "circa 1948-1952".
+func (d *PartialDate) String() string {
+
+	switch d.Precision {
+	case DatePrecisionExact, DatePrecisionDay:
+		return fmt.Sprintf("%d %s %d", d.Day, time.Month(d.Month), d.Year)
+	case DatePrecisionMonth:
+		return fmt.Sprintf("%s %d", time.Month(d.Month), d.Year)
+	case DatePrecisionDecade:
+		return fmt.Sprintf("%ds", d.Year)
+	case DatePrecisionCirca:
+		return fmt.Sprintf("circa %d-%d", d.Year, d.EndYear)
+	default:
+		return strconv.Itoa(d.Year)
+	}
+}
+
+// ParseImageDateKey parses a sortable image date key, returning its year, and its month, or 0 if the
+// key is for a date known only to the year, or less precisely.
+func ParseImageDateKey(key string) (int, int, error) {
+
+	year, month, ok := strings.Cut(key, "-")
+	if ok && month == imageDateKeyYearMonth {
+		y, err := strconv.Atoi(year)
+		if err != nil || len(year) != 4 {
+			return 0, 0, fmt.Errorf("image date key %s is not valid", key)
+		}
+		return y, 0, nil
+	}
+
+	t, err := time.Parse(ImageDateKeyLayout, key)
+	if err != nil {
+		return 0, 0, fmt.Errorf("image date key %s is not valid: %v", key, err)
+	}
+
+	return t.Year(), int(t.Month()), nil
+}
+
+// ImageDateKeyLowerBound returns the image date key to use as an inclusive lower bound for a year-month filter,
+// eg, "from 1974-01": a filter from the start of a year must include the images known only to that year.
+func ImageDateKeyLowerBound(from string) string {
+
+	if year, month, ok := strings.Cut(from, "-"); ok && month == "01" {
+		return year + "-" + imageDateKeyYearMonth
+	}
+
+	return from
+}
@@ -0,0 +1,257 @@
+package api
+
+import (
+	"strings"
+	"testing"
+)
+
+func intPtr(i int) *int { return &i }
+
+func strPtr(s string) *string { return &s }
+
+func TestPartialDate_Validate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		date    PartialDate
+		wantErr string
+	}{
+		{"exact", PartialDate{Precision: DatePrecisionExact, Year: 2020, Month: 1, Day: 15}, ""},
+		{"day", PartialDate{Precision: DatePrecisionDay, Year: 1976, Month: 7, Day: 4}, ""},
+		{"day leap february", PartialDate{Precision: DatePrecisionDay, Year: 2020, Month: 2, Day: 29}, ""},
+		{"day february 30", PartialDate{Precision: DatePrecisionDay, Year: 2021, Month: 2, Day: 30}, "not valid for 2021-02"},
+		{"day february 29 not leap", PartialDate{Precision: DatePrecisionDay, Year: 2021, Month: 2, Day: 29}, "not valid for 2021-02"},
+		{"day missing day", PartialDate{Precision: DatePrecisionDay, Year: 1976, Month: 7}, "not valid"},
+		{"day month 13", PartialDate{Precision: DatePrecisionDay, Year: 1976, Month: 13, Day: 1}, "month must be between 1 and 12"},
+		{"month", PartialDate{Precision: DatePrecisionMonth, Year: 1974, Month: 7}, ""},
+		{"month with day", PartialDate{Precision: DatePrecisionMonth, Year: 1974, Month: 7, Day: 4}, "day must not be set"},
+		{"month missing month", PartialDate{Precision: DatePrecisionMonth, Year: 1974}, "month must be between 1 and 12"},
+		{"year", PartialDate{Precision: DatePrecisionYear, Year: 1974}, ""},
+		{"year with month", PartialDate{Precision: DatePrecisionYear, Year: 1974, Month: 7}, "month and day must not be set"},
+		{"year before oldest photograph", PartialDate{Precision: DatePrecisionYear, Year: 1825}, "year must be 4 digits"},
+		{"year in the future", PartialDate{Precision: DatePrecisionYear, Year: 9999}, "year must be 4 digits"},
+		{"year with end year", PartialDate{Precision: DatePrecisionYear, Year: 1974, EndYear: 1975}, "end year must only be set"},
+		{"decade", PartialDate{Precision: DatePrecisionDecade, Year: 1950}, ""},
+		{"decade including oldest photograph", PartialDate{Precision: DatePrecisionDecade, Year: 1820}, ""},
+		{"decade before oldest photograph", PartialDate{Precision: DatePrecisionDecade, Year: 1810}, "decade must be between"},
+		{"decade not first year", PartialDate{Precision: DatePrecisionDecade, Year: 1955}, "first year of the decade"},
+		{"circa", PartialDate{Precision: DatePrecisionCirca, Year: 1948, EndYear: 1952}, ""},
+		{"circa missing end year", PartialDate{Precision: DatePrecisionCirca, Year: 1948}, "end year must be after the start year"},
+		{"circa end before start", PartialDate{Precision: DatePrecisionCirca, Year: 1952, EndYear: 1948}, "end year must be after the start year"},
+		{"circa too long", PartialDate{Precision: DatePrecisionCirca, Year: 1900, EndYear: 1926}, "at most 25 years"},
+		{"circa with month", PartialDate{Precision: DatePrecisionCirca, Year: 1948, Month: 1, EndYear: 1952}, "month and day must not be set"},
+		{"unknown precision", PartialDate{Precision: "season", Year: 1974}, "precision must be one of"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.date.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Validate() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildPartialDate(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		imageDate string
+		precision string
+		endYear   int
+		wantDate  string // RFC3339 image date to store
+		wantKey   string
+		wantStr   string
+	}{
+		{"legacy exact", "2020-01-15T10:30:00Z", "", 0, "2020-01-15T10:30:00Z", "2020-01", "15 January 2020"},
+		{"exact", "2020-01-15T10:30:00Z", DatePrecisionExact, 0, "2020-01-15T10:30:00Z", "2020-01", "15 January 2020"},
+		{"day", "1976-07-04T00:00:00Z", DatePrecisionDay, 0, "1976-07-04T00:00:00Z", "1976-07", "4 July 1976"},
+		{"month", "1974-07-01T00:00:00Z", DatePrecisionMonth, 0, "1974-07-01T00:00:00Z", "1974-07", "July 1974"},
+		{"year", "1974-01-01T00:00:00Z", DatePrecisionYear, 0, "1974-01-01T00:00:00Z", "1974-00", "1974"},
+		{"decade", "1950-01-01T00:00:00Z", DatePrecisionDecade, 0, "1950-01-01T00:00:00Z", "1950-00", "1950s"},
+		{"circa", "1948-01-01T00:00:00Z", DatePrecisionCirca, 1952, "1948-01-01T00:00:00Z", "1948-00", "circa 1948-1952"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := BuildPartialDate(tc.imageDate, tc.precision, tc.endYear)
+			if d == nil {
+				t.Fatal("BuildPartialDate() = nil")
+			}
+			if d.ImageDate() != tc.wantDate {
+				t.Errorf("ImageDate() = %s, want %s", d.ImageDate(), tc.wantDate)
+			}
+			if d.DateKey() != tc.wantKey {
+				t.Errorf("DateKey() = %s, want %s", d.DateKey(), tc.wantKey)
+			}
+			if d.String() != tc.wantStr || d.Display != tc.wantStr {
+				t.Errorf("String() = %s, Display = %s, want %s", d.String(), d.Display, tc.wantStr)
+			}
+		})
+	}
+
+	for _, bad := range []struct{ imageDate, precision string }{
+		{"", DatePrecisionDay},
+		{"1974-07-04", DatePrecisionDay},
+		{"1974-07-04T00:00:00Z", "season"},
+	} {
+		if d := BuildPartialDate(bad.imageDate, bad.precision, 0); d != nil {
+			t.Errorf("BuildPartialDate(%q, %q) = %+v, want nil", bad.imageDate, bad.precision, d)
+		}
+	}
+}
+
+func TestParseImageDateKey(t *testing.T) {
+
+	tests := []struct {
+		key       string
+		wantYear  int
+		wantMonth int
+		wantErr   bool
+	}{
+		{"1974-07", 1974, 7, false},
+		{"1974-12", 1974, 12, false},
+		{"1950-00", 1950, 0, false},
+		{"1974-13", 0, 0, true},
+		{"74-00", 0, 0, true},
+		{"abcd-00", 0, 0, true},
+		{"1974", 0, 0, true},
+		{"", 0, 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.key, func(t *testing.T) {
+			year, month, err := ParseImageDateKey(tc.key)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("ParseImageDateKey(%q) error = nil, want error", tc.key)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseImageDateKey(%q) error = %v", tc.key, err)
+			}
+			if year != tc.wantYear || month != tc.wantMonth {
+				t.Errorf("ParseImageDateKey(%q) = %d, %d, want %d, %d", tc.key, year, month, tc.wantYear, tc.wantMonth)
+			}
+		})
+	}
+}
+
+func TestImageDateKeyLowerBound(t *testing.T) {
+
+	tests := []struct {
+		from string
+		want string
+	}{
+		{"1974-01", "1974-00"}, // the start of a year includes the images known only to the year
+		{"1974-07", "1974-07"},
+		{"1974-12", "1974-12"},
+		{"1974-00", "1974-00"},
+	}
+
+	for _, tc := range tests {
+		if got := ImageDateKeyLowerBound(tc.from); got != tc.want {
+			t.Errorf("ImageDateKeyLowerBound(%q) = %s, want %s", tc.from, got, tc.want)
+		}
+	}
+}
+
+func TestPatchMetadataCmd_MergeImageDate(t *testing.T) {
+
+	exact := BuildPartialDate("2020-01-15T10:30:00Z", DatePrecisionExact, 0)
+	day := BuildPartialDate("1976-07-04T00:00:00Z", DatePrecisionDay, 0)
+	circa := BuildPartialDate("1948-01-01T00:00:00Z", DatePrecisionCirca, 1952)
+
+	tests := []struct {
+		name    string
+		current *PartialDate
+		cmd     PatchMetadataCmd
+		want    PartialDate
+		wantErr string
+	}{
+		{
+			name:    "edit exact day becomes day precision",
+			current: exact,
+			cmd:     PatchMetadataCmd{ImageDateDay: intPtr(16)},
+			want:    PartialDate{Precision: DatePrecisionDay, Year: 2020, Month: 1, Day: 16, Display: "16 January 2020"},
+		},
+		{
+			name:    "day to month drops day",
+			current: day,
+			cmd:     PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionMonth)},
+			want:    PartialDate{Precision: DatePrecisionMonth, Year: 1976, Month: 7, Display: "July 1976"},
+		},
+		{
+			name:    "day to year drops month and day",
+			current: day,
+			cmd:     PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionYear)},
+			want:    PartialDate{Precision: DatePrecisionYear, Year: 1976, Display: "1976"},
+		},
+		{
+			name:    "day to decade",
+			current: day,
+			cmd:     PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionDecade), ImageDateYear: intPtr(1970)},
+			want:    PartialDate{Precision: DatePrecisionDecade, Year: 1970, Display: "1970s"},
+		},
+		{
+			name:    "day to circa",
+			current: day,
+			cmd:     PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionCirca), ImageDateEndYear: intPtr(1980)},
+			want:    PartialDate{Precision: DatePrecisionCirca, Year: 1976, EndYear: 1980, Display: "circa 1976-1980"},
+		},
+		{
+			name:    "circa to year drops end year",
+			current: circa,
+			cmd:     PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionYear)},
+			want:    PartialDate{Precision: DatePrecisionYear, Year: 1948, Display: "1948"},
+		},
+		{
+			name:    "day to month needs no day",
+			current: day,
+			cmd:     PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionMonth), ImageDateDay: intPtr(4)},
+			wantErr: "day must not be set",
+		},
+		{
+			name:    "february 30",
+			current: day,
+			cmd:     PatchMetadataCmd{ImageDateMonth: intPtr(2), ImageDateDay: intPtr(30)},
+			wantErr: "not valid for 1976-02",
+		},
+		{
+			name: "no current date with full date",
+			cmd:  PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionMonth), ImageDateYear: intPtr(1974), ImageDateMonth: intPtr(7)},
+			want: PartialDate{Precision: DatePrecisionMonth, Year: 1974, Month: 7, Display: "July 1974"},
+		},
+		{
+			name:    "no current date with partial date",
+			cmd:     PatchMetadataCmd{ImageDateYear: intPtr(1974)},
+			wantErr: "the image has no date to merge with",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.cmd.MergeImageDate(tc.current)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("MergeImageDate() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("MergeImageDate() error = %v", err)
+			}
+			if *got != tc.want {
+				t.Errorf("MergeImageDate() = %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
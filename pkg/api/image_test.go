@@ -0,0 +1,111 @@
+package api
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestPatchMetadataCmd_UnmarshalJSON(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		body    string
+		check   func(t *testing.T, cmd PatchMetadataCmd)
+		wantErr string
+	}{
+		{
+			name: "present fields only",
+			body: `{"title":"New Title","is_published":false}`,
+			check: func(t *testing.T, cmd PatchMetadataCmd) {
+				if cmd.Title == nil || *cmd.Title != "New Title" {
+					t.Errorf("Title = %v, want New Title", cmd.Title)
+				}
+				if cmd.IsPublished == nil || *cmd.IsPublished {
+					t.Errorf("IsPublished = %v, want false", cmd.IsPublished)
+				}
+				if cmd.Description != nil || cmd.IsArchived != nil || cmd.HasImageDate() {
+					t.Errorf("absent fields must stay nil: %+v", cmd)
+				}
+			},
+		},
+		{
+			name: "empty object",
+			body: `{}`,
+			check: func(t *testing.T, cmd PatchMetadataCmd) {
+				if !cmd.IsEmpty() {
+					t.Errorf("IsEmpty() = false, want true")
+				}
+			},
+		},
+		{name: "null field", body: `{"title":null}`, wantErr: "field title cannot be removed"},
+		{name: "null field with whitespace", body: `{"description": null }`, wantErr: "field description cannot be removed"},
+		{name: "unknown field", body: `{"titel":"New Title"}`, wantErr: "unknown field"},
+		{name: "wrong type", body: `{"image_date_year":"1974"}`, wantErr: "failed to decode patch"},
+		{name: "not an object", body: `["title"]`, wantErr: "must be a JSON object"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var cmd PatchMetadataCmd
+			err := json.Unmarshal([]byte(tc.body), &cmd)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("Unmarshal() error = %v, want containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+			tc.check(t, cmd)
+		})
+	}
+}
+
+func TestPatchMetadataCmd_Validate(t *testing.T) {
+
+	published, archived := true, true
+
+	tests := []struct {
+		name    string
+		cmd     PatchMetadataCmd
+		wantErr string
+	}{
+		{name: "title", cmd: PatchMetadataCmd{Title: strPtr("New Title")}},
+		{name: "add album", cmd: PatchMetadataCmd{AddAlbumSlugs: []string{testSlug}}},
+		{name: "image date", cmd: PatchMetadataCmd{ImageDateYear: intPtr(1974)}},
+		{name: "empty patch", cmd: PatchMetadataCmd{}, wantErr: "at least one field"},
+		{name: "csrf only", cmd: PatchMetadataCmd{Csrf: testSlug}, wantErr: "at least one field"},
+		{name: "invalid csrf", cmd: PatchMetadataCmd{Csrf: "not-a-uuid", Title: strPtr("New Title")}, wantErr: "csrf token"},
+		{name: "invalid title", cmd: PatchMetadataCmd{Title: strPtr("<script>")}, wantErr: "title must be"},
+		{name: "archived and published", cmd: PatchMetadataCmd{IsPublished: &published, IsArchived: &archived}, wantErr: "both archived and published"},
+		{name: "exact precision", cmd: PatchMetadataCmd{ImageDatePrecision: strPtr(DatePrecisionExact)}, wantErr: "must not be exact"},
+		{name: "invalid album slug", cmd: PatchMetadataCmd{AddAlbumSlugs: []string{"not-a-slug"}}, wantErr: "invalid album slug"},
+		{
+			name:    "album added and removed",
+			cmd:     PatchMetadataCmd{AddAlbumSlugs: []string{testSlug}, RemoveAlbumSlugs: []string{testSlug}},
+			wantErr: "both added and removed",
+		},
+		{
+			name:    "download permission being revoked",
+			cmd:     PatchMetadataCmd{AddDownloadPermissionSlugs: []string{testSlug}, RemovePermissionSlugs: []string{testSlug}},
+			wantErr: "must not be a permission slug being removed",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cmd.Validate()
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("Validate() error = %v, want containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
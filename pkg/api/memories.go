@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+// MemoriesMaxWindowDays is the maximum number of days either side of the requested date
+// which can be included in the memories feed.
+const MemoriesMaxWindowDays int = 14
+
+// MemoriesQuery is a model representing the parameters for the "on this day" memories feed.
+type MemoriesQuery struct {
+	Date     string `json:"date,omitempty"`     // date to find memories for, eg "2024-06-21", defaults to today in the timezone
+	Window   int    `json:"window"`             // number of days either side of the date to include, defaults to 0
+	Timezone string `json:"timezone,omitempty"` // IANA timezone used to determine today, eg "America/Chicago", defaults to the service's
+}
+
+// Validate validates the MemoriesQuery -> input validation.
+func (q *MemoriesQuery) Validate() error {
+
+	if q.Date != "" {
+		if _, err := time.Parse(time.DateOnly, q.Date); err != nil {
+			return fmt.Errorf("date must be a valid date, eg, '2024-06-21'")
+		}
+	}
+
+	if q.Window < 0 || q.Window > MemoriesMaxWindowDays {
+		return fmt.Errorf("window must be between 0 and %d days", MemoriesMaxWindowDays)
+	}
+
+	if q.Timezone != "" {
+		if _, err := time.LoadLocation(q.Timezone); err != nil {
+			return fmt.Errorf("timezone must be a valid IANA timezone, eg, 'America/Chicago'")
+		}
+	}
+
+	return nil
+}
+
+// MemoriesYear is a model representing the memories from a single earlier year.
+type MemoriesYear struct {
+	Year     int         `json:"year"`
+	YearsAgo int         `json:"years_ago"`
+	Images   []ImageData `json:"images"` // image metadata records + their thumbnails signed urls, oldest first
+}
+
+// Memories is a model representing the "on this day" memories feed: images the user has permission
+// to view which were taken on the same date in earlier years, grouped by year, most recent year first.
+type Memories struct {
+	Date     string         `json:"date"`     // the date memories were found for, eg "2024-06-21"
+	Timezone string         `json:"timezone"` // the timezone the date was determined in
+	Window   int            `json:"window"`   // number of days either side of the date included
+	Years    []MemoriesYear `json:"years"`
+}
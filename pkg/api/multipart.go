@@ -0,0 +1,185 @@
+package api
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/tdeslauriers/carapace/pkg/validate"
+)
+
+const (
+	ImageUploadMaxSize = 2 * 1024 * 1024 * 1024 // Hard cap for an image file of any type, 2 GB: the configured per-type limits cannot exceed it
+
+	MultipartPartSize     = 16 * 1024 * 1024 // Size of each part of a multipart upload, except the last, 16 MB: the S3 api minimum is 5 MB
+	MultipartMaxPartCount = 10000            // Maximum number of parts in a multipart upload, set by the S3 api
+)
+
+// DefaultImageSizeLimits are the largest image files, by file type, a placeholder may be created for.
+// Files larger than ImageMaxSize are uploaded in parts.  The limits may be overridden by configuration.
+var DefaultImageSizeLimits = map[string]int64{
+	"image/jpeg":    100 * 1024 * 1024,
+	"image/png":     200 * 1024 * 1024,
+	"image/gif":     50 * 1024 * 1024,
+	"image/webp":    100 * 1024 * 1024,
+	"image/tiff":    ImageUploadMaxSize, // high resolution scans
+	"image/bmp":     500 * 1024 * 1024,
+	"image/svg+xml": ImageMaxSize,
+}
+
+// ParseImageSizeLimits parses per file type size limit overrides, in megabytes, from a comma separated
+// list of file type=size pairs, eg, "image/tiff=1024,image/png=300", over the default limits.
+func ParseImageSizeLimits(s string) (map[string]int64, error) {
+
+	limits := make(map[string]int64, len(DefaultImageSizeLimits))
+	for fileType, limit := range DefaultImageSizeLimits {
+		limits[fileType] = limit
+	}
+
+	if strings.TrimSpace(s) == "" {
+		return limits, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+
+		fileType, size, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("image size limit '%s' must be in the format file type=megabytes, eg, 'image/tiff=1024'", pair)
+		}
+
+		fileType = strings.TrimSpace(fileType)
+		if !ValidateFiletype(fileType) {
+			return nil, fmt.Errorf("image size limit file type must be one of: %s", strings.Join(AllowedFileTypes, ", "))
+		}
+
+		mb, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64)
+		if err != nil || mb <= 0 {
+			return nil, fmt.Errorf("image size limit for %s must be a positive number of megabytes", fileType)
+		}
+
+		if mb*1024*1024 > ImageUploadMaxSize {
+			return nil, fmt.Errorf("image size limit for %s must be less than or equal to %d megabytes", fileType, ImageUploadMaxSize/(1024*1024))
+		}
+
+		limits[fileType] = mb * 1024 * 1024
+	}
+
+	return limits, nil
+}
+
+// MultipartPartCount returns the number of MultipartPartSize parts an image file of the given size is uploaded in.
+func MultipartPartCount(size int64) int {
+	return int((size + MultipartPartSize - 1) / MultipartPartSize)
+}
+
+// MultipartUpload is a model representing a multipart upload of an image file too large for a single presigned PUT.
+// The client requests presigned urls for the parts, uploads them, in any order and retrying as needed,
+// then completes the upload with the entity tag object storage returned for each part.
+type MultipartUpload struct {
+	UploadId  string          `json:"upload_id"`
+	PartSize  int64           `json:"part_size"`  // size of every part but the last, which is the remainder
+	PartCount int             `json:"part_count"` // parts are numbered 1 to part count
+	Parts     []MultipartPart `json:"parts,omitempty"`
+}
+
+// MultipartPart is a model representing a part of a multipart upload which has been uploaded.
+type MultipartPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`           // entity tag returned by object storage when the part was uploaded
+	Size       int64  `json:"size,omitempty"` // only reported by object storage, not required to complete the upload
+}
+
+// MultipartPartUrl is a model representing a presigned PUT url for uploading a part of a multipart upload.
+type MultipartPartUrl struct {
+	PartNumber int    `json:"part_number"`
+	SignedUrl  string `json:"signed_url"`
+}
+
+// MultipartPartsCmd is a command requesting presigned PUT urls for parts of a multipart upload, eg,
+// the next few parts to upload, or the parts that failed to upload.
+type MultipartPartsCmd struct {
+	Csrf        string `json:"csrf,omitempty"` // CSRF token for security
+	PartNumbers []int  `json:"part_numbers"`
+}
+
+// Validate checks the MultipartPartsCmd for valid data.
+// Note: the part numbers are checked against the image's part count by the service.
+func (cmd *MultipartPartsCmd) Validate() error {
+
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("csrf token must be a valid UUID")
+		}
+	}
+
+	if len(cmd.PartNumbers) == 0 {
+		return fmt.Errorf("at least one part number is required")
+	}
+
+	if len(cmd.PartNumbers) > MultipartMaxPartCount {
+		return fmt.Errorf("part numbers must be %d or fewer, got %d", MultipartMaxPartCount, len(cmd.PartNumbers))
+	}
+
+	seen := make(map[int]struct{}, len(cmd.PartNumbers))
+	for _, n := range cmd.PartNumbers {
+		if n < 1 || n > MultipartMaxPartCount {
+			return fmt.Errorf("part number must be between 1 and %d, got %d", MultipartMaxPartCount, n)
+		}
+		if _, ok := seen[n]; ok {
+			return fmt.Errorf("part number %d is listed more than once", n)
+		}
+		seen[n] = struct{}{}
+	}
+
+	return nil
+}
+
+// CompleteMultipartCmd is a command completing a multipart upload with the entity tag of every part.
+type CompleteMultipartCmd struct {
+	Csrf  string          `json:"csrf,omitempty"` // CSRF token for security
+	Parts []MultipartPart `json:"parts"`
+}
+
+// Validate checks the CompleteMultipartCmd for valid data.
+// Note: the parts are checked against the image's part count, and the parts uploaded, by the service.
+func (cmd *CompleteMultipartCmd) Validate() error {
+
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("csrf token must be a valid UUID")
+		}
+	}
+
+	if len(cmd.Parts) == 0 {
+		return fmt.Errorf("at least one part is required")
+	}
+
+	if len(cmd.Parts) > MultipartMaxPartCount {
+		return fmt.Errorf("parts must be %d or fewer, got %d", MultipartMaxPartCount, len(cmd.Parts))
+	}
+
+	seen := make(map[int]struct{}, len(cmd.Parts))
+	for _, p := range cmd.Parts {
+		if p.PartNumber < 1 || p.PartNumber > MultipartMaxPartCount {
+			return fmt.Errorf("part number must be between 1 and %d, got %d", MultipartMaxPartCount, p.PartNumber)
+		}
+		if _, ok := seen[p.PartNumber]; ok {
+			return fmt.Errorf("part number %d is listed more than once", p.PartNumber)
+		}
+		seen[p.PartNumber] = struct{}{}
+
+		if strings.TrimSpace(p.ETag) == "" {
+			return fmt.Errorf("etag is required for part %d", p.PartNumber)
+		}
+	}
+
+	return nil
+}
+
+// MultipartImageRecord is a model representing the fields of a placeholder image needed to manage its multipart upload.
+type MultipartImageRecord struct {
+	Id        string `db:"uuid"`
+	FileType  string `db:"file_type"`
+	ObjectKey string `db:"object_key"` // encrypted
+	Size      int64  `db:"size"`
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tdeslauriers/carapace/pkg/validate"
+)
+
+// sort options for paginated listings
+const (
+	SortImageDate  string = "image_date"  // date the image was taken, ie, from exif metadata
+	SortUploadDate string = "upload_date" // date the record was created
+	SortTitle      string = "title"       // alphabetical by title
+	SortPosition   string = "position"    // manual album order set by curators, then image date: album images only
+
+	DefaultPageLimit int = 48  // default number of items per page
+	MaxPageLimit     int = 200 // maximum number of items per page
+)
+
+// PageQuery is a model representing the pagination and sort parameters for a listing request.
+type PageQuery struct {
+	Limit  int    `json:"limit"`            // number of items to return, defaults to DefaultPageLimit
+	Cursor string `json:"cursor,omitempty"` // opaque cursor from the previous page's next_cursor
+	Sort   string `json:"sort,omitempty"`   // one of the Sort* constants
+	Desc   bool   `json:"desc"`             // sort descending
+
+	// optional inclusive year-month bounds on the image date key, eg "2019-06": image listings only
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// Validate validates the PageQuery -> input validation.
+// Note: the cursor is decoded to make sure it was issued for the same sort order.
+func (q *PageQuery) Validate() error {
+
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+	}
+
+	switch q.Sort {
+	case SortImageDate, SortUploadDate, SortTitle, SortPosition:
+	default:
+		return fmt.Errorf("sort must be one of '%s', '%s', '%s', or '%s'", SortImageDate, SortUploadDate, SortTitle, SortPosition)
+	}
+
+	if q.From != "" && !ValidateImageDateKey(q.From) {
+		return fmt.Errorf("from must be a year-month, eg, '2019-06'")
+	}
+
+	if q.To != "" && !ValidateImageDateKey(q.To) {
+		return fmt.Errorf("to must be a year-month, eg, '2019-06'")
+	}
+
+	if q.From != "" && q.To != "" && q.From > q.To {
+		return fmt.Errorf("from must not be after to")
+	}
+
+	if q.Cursor != "" {
+		c, err := DecodePageCursor(q.Cursor)
+		if err != nil {
+			return err
+		}
+
+		if c.Sort != q.Sort || c.Desc != q.Desc {
+			return fmt.Errorf("cursor is not valid for the requested sort order")
+		}
+	}
+
+	return nil
+}
+
+// PageCursor is the decoded position in a paginated listing.  It is handed to clients as an opaque,
+// url-safe string.  The id of the last item on the previous page is used to find the next page so that
+// inserts and deletes do not shift the page boundaries; the offset is a fallback in case that item
+// no longer exists or is no longer visible to the user.
+// Listings sorted in the database also carry the last item's sort keys for a keyset query.
+type PageCursor struct {
+	Sort   string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Id     string `json:"i"`
+	Offset int    `json:"o"`
+
+	DateKey   string `json:"k,omitempty"` // image date key, eg "2019-06"
+	CreatedAt string `json:"c,omitempty"` // upload timestamp, eg "2019-07-01 12:00:00"
+	Position  int    `json:"p,omitempty"` // manual album position, 0 if not ordered
+}
+
+// Encode encodes the cursor into an opaque, url-safe string.
+func (c PageCursor) Encode() string {
+	b, _ := json.Marshal(c) // cannot fail: only string, bool, and int fields
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodePageCursor decodes an opaque cursor string issued by PageCursor.Encode.
+func DecodePageCursor(cursor string) (*PageCursor, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	var c PageCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	if err := validate.ValidateUuid(c.Id); err != nil {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	if c.Offset < 0 {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	return &c, nil
+}
+
+// AlbumPage is a model representing a page of albums the user has permission to view.
+type AlbumPage struct {
+	Albums     []Album `json:"albums"`                // albums on this page, each with a single cover image
+	NextCursor string  `json:"next_cursor,omitempty"` // cursor for the next page, empty if this is the last page
+	Total      int     `json:"total"`                 // total number of albums the user has permission to view
+}
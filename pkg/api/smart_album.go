@@ -0,0 +1,157 @@
+package api
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+)
+
+const (
+	MaxSmartAlbumRuleValues = 50 // maximum number of values in any one smart album rule, eg, permission slugs
+
+	// UntitledImageTitleRegex matches image titles which are blank or a camera's default file name,
+	// eg, "IMG 1234", "DSC01234", "PXL 20240101 123456789": images no one has given a title yet.
+	UntitledImageTitleRegex = `(?i)^\s*((img|dsc|dscn|dscf|pxl|gopr|dji)[ _]?\d[\d ]*)?\s*$`
+)
+
+var untitledImageTitleRegex = regexp.MustCompile(UntitledImageTitleRegex)
+
+// IsUntitledImageTitle checks if a decrypted image title is blank or a camera's default file name.
+func IsUntitledImageTitle(title string) bool {
+	return untitledImageTitleRegex.MatchString(title)
+}
+
+// SmartAlbumRules is a model which represents the stored rules of a smart album.
+// An image is in the smart album if it matches every rule which is set: unset rules match every image.
+// Note: the rules are evaluated when the album is read, and the results are still filtered by the reader's permissions.
+type SmartAlbumRules struct {
+	From            string   `json:"from,omitempty"`               // inclusive year-month lower bound of the image date, eg "2015-01"
+	To              string   `json:"to,omitempty"`                 // inclusive year-month upper bound of the image date, eg "2018-12"
+	PermissionSlugs []string `json:"permission_slugs,omitempty"`   // image has at least one of these permissions
+	FileTypes       []string `json:"file_types,omitempty"`         // image is one of these file types, eg "image/png"
+	Published       *bool    `json:"published,omitempty"`          // image is, or is not, published, eg, false for staged images
+	Archived        *bool    `json:"archived,omitempty"`           // image is, or is not, archived
+	InAlbumSlugs    []string `json:"in_album_slugs,omitempty"`     // image is in at least one of these albums
+	NotInAlbumSlugs []string `json:"not_in_album_slugs,omitempty"` // image is in none of these albums
+	HasGps          *bool    `json:"has_gps,omitempty"`            // image's exif data does, or does not, contain gps coordinates
+	Untitled        bool     `json:"untitled,omitempty"`           // image title is blank or a camera's default file name
+}
+
+// Validate validates the SmartAlbumRules -> input validation.
+func (r *SmartAlbumRules) Validate() error {
+
+	if r.From == "" &&
+		r.To == "" &&
+		len(r.PermissionSlugs) == 0 &&
+		len(r.FileTypes) == 0 &&
+		r.Published == nil &&
+		r.Archived == nil &&
+		len(r.InAlbumSlugs) == 0 &&
+		len(r.NotInAlbumSlugs) == 0 &&
+		r.HasGps == nil &&
+		!r.Untitled {
+		return fmt.Errorf("a smart album must have at least one rule")
+	}
+
+	if r.From != "" && !ValidateImageDateKey(r.From) {
+		return fmt.Errorf("invalid from date: %s, must be year-month, eg 2015-01", r.From)
+	}
+
+	if r.To != "" && !ValidateImageDateKey(r.To) {
+		return fmt.Errorf("invalid to date: %s, must be year-month, eg 2018-12", r.To)
+	}
+
+	// date keys are zero padded, so they compare as strings
+	if r.From != "" && r.To != "" && r.From > r.To {
+		return fmt.Errorf("from date %s cannot be after to date %s", r.From, r.To)
+	}
+
+	if err := validateRuleSlugs("permission", r.PermissionSlugs); err != nil {
+		return err
+	}
+
+	if len(r.FileTypes) > MaxSmartAlbumRuleValues {
+		return fmt.Errorf("a smart album rule cannot have more than %d file types", MaxSmartAlbumRuleValues)
+	}
+	for _, ft := range r.FileTypes {
+		if !ValidateFiletype(ft) {
+			return fmt.Errorf("file type must be one of: %s", strings.Join(AllowedFileTypes, ", "))
+		}
+	}
+
+	if err := validateRuleSlugs("album", r.InAlbumSlugs); err != nil {
+		return err
+	}
+
+	if err := validateRuleSlugs("album", r.NotInAlbumSlugs); err != nil {
+		return err
+	}
+
+	for _, slug := range r.InAlbumSlugs {
+		for _, not := range r.NotInAlbumSlugs {
+			if slug == not {
+				return fmt.Errorf("album slug %s cannot be both included and excluded", slug)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateRuleSlugs is a helper which validates the slugs of a smart album rule are well formed and not repeated.
+func validateRuleSlugs(kind string, slugs []string) error {
+
+	if len(slugs) > MaxSmartAlbumRuleValues {
+		return fmt.Errorf("a smart album rule cannot have more than %d %s slugs", MaxSmartAlbumRuleValues, kind)
+	}
+
+	seen := make(map[string]struct{}, len(slugs))
+	for _, slug := range slugs {
+		if err := validate.ValidateUuid(slug); err != nil {
+			return fmt.Errorf("invalid %s slug: %s", kind, slug)
+		}
+		if _, ok := seen[slug]; ok {
+			return fmt.Errorf("%s slug %s is listed more than once", kind, slug)
+		}
+		seen[slug] = struct{}{}
+	}
+
+	return nil
+}
+
+// SmartAlbumRulesCmd is a model which represents the command to set the rules of a smart album,
+// eg, to turn an empty album into a smart album, or to change an existing smart album's rules.
+type SmartAlbumRulesCmd struct {
+	Csrf  string          `json:"csrf,omitempty"` // this may not always be required
+	Rules SmartAlbumRules `json:"rules"`
+}
+
+// Validate validates the SmartAlbumRulesCmd -> input validation.
+func (cmd *SmartAlbumRulesCmd) Validate() error {
+
+	// validate CSRF token, if present -> not always required
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("invalid CSRF token")
+		}
+	}
+
+	return cmd.Rules.Validate()
+}
+
+// SmartAlbum is a model which represents the stored rules of a smart album in the API response.
+type SmartAlbum struct {
+	AlbumSlug string          `json:"album_slug"`
+	Rules     SmartAlbumRules `json:"rules"`
+	CreatedAt data.CustomTime `json:"created_at"`
+	UpdatedAt data.CustomTime `json:"updated_at"`
+}
+
+// SmartAlbumFreeze is a model which represents the result of freezing a smart album into a regular album.
+type SmartAlbumFreeze struct {
+	AlbumSlug  string `json:"album_slug"`
+	ImageCount int    `json:"image_count"` // images added to the album: every image matching the rules when it was frozen
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/tdeslauriers/carapace/pkg/validate"
+)
+
+// timeline paging directions
+const (
+	TimelineOlder string = "older" // towards earlier image dates, the default
+	TimelineNewer string = "newer" // towards later image dates
+)
+
+// TimelineQuery is a model representing the parameters for browsing the timeline of images.
+// The timeline is ordered newest first by the sortable image date key, then upload date.
+type TimelineQuery struct {
+	Limit     int    `json:"limit"`               // number of images to return, defaults to DefaultPageLimit
+	Cursor    string `json:"cursor,omitempty"`    // opaque cursor from the previous page's older_cursor or newer_cursor
+	Direction string `json:"direction,omitempty"` // one of the Timeline* directions, defaults to older
+	At        string `json:"at,omitempty"`        // jump to year-month, eg "2019-06": the page starts at the newest image in or before it
+	From      string `json:"from,omitempty"`      // inclusive year-month lower bound
+	To        string `json:"to,omitempty"`        // inclusive year-month upper bound
+}
+
+// Validate validates the TimelineQuery -> input validation.
+func (q *TimelineQuery) Validate() error {
+
+	if q.Limit < 0 || q.Limit > MaxPageLimit {
+		return fmt.Errorf("limit must be between 1 and %d", MaxPageLimit)
+	}
+
+	switch q.Direction {
+	case "", TimelineOlder, TimelineNewer:
+	default:
+		return fmt.Errorf("direction must be '%s' or '%s'", TimelineOlder, TimelineNewer)
+	}
+
+	if q.At != "" && !ValidateImageDateKey(q.At) {
+		return fmt.Errorf("at must be a year-month, eg, '2019-06'")
+	}
+
+	if q.From != "" && !ValidateImageDateKey(q.From) {
+		return fmt.Errorf("from must be a year-month, eg, '2019-06'")
+	}
+
+	if q.To != "" && !ValidateImageDateKey(q.To) {
+		return fmt.Errorf("to must be a year-month, eg, '2019-06'")
+	}
+
+	if q.From != "" && q.To != "" && q.From > q.To {
+		return fmt.Errorf("from must not be after to")
+	}
+
+	if q.Cursor != "" && q.At != "" {
+		return fmt.Errorf("cursor and at must not both be provided")
+	}
+
+	if q.Cursor != "" {
+		if _, err := DecodeTimelineCursor(q.Cursor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// TimelineCursor is the decoded position in the timeline: the sort key of an image on the edge
+// of a page.  It is handed to clients as an opaque, url-safe string.  Because the position is the
+// sort key itself rather than an offset, the next page can be found with a keyset query in sql.
+type TimelineCursor struct {
+	DateKey   string `json:"k"` // image date key, eg "2019-06"
+	CreatedAt string `json:"c"` // image upload timestamp, eg "2019-07-01 12:00:00"
+	Id        string `json:"i"` // image uuid, tiebreak
+}
+
+// Encode encodes the cursor into an opaque, url-safe string.
+func (c TimelineCursor) Encode() string {
+	b, _ := json.Marshal(c) // cannot fail: only string fields
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeTimelineCursor decodes an opaque cursor string issued by TimelineCursor.Encode.
+func DecodeTimelineCursor(cursor string) (*TimelineCursor, error) {
+
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	var c TimelineCursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	if !ValidateImageDateKey(c.DateKey) || c.CreatedAt == "" {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	if err := validate.ValidateUuid(c.Id); err != nil {
+		return nil, fmt.Errorf("cursor is not well-formed")
+	}
+
+	return &c, nil
+}
+
+// TimelineMonthCount is a model representing the number of images the user has permission
+// to view in a given month, eg, for a timeline scrubber.
+type TimelineMonthCount struct {
+	DateKey string `db:"image_date_key" json:"date_key"` // year-month, eg "2019-06"
+	Count   int    `db:"image_count" json:"count"`
+}
+
+// TimelineMonth is a model representing a group of timeline images from the same month.
+type TimelineMonth struct {
+	DateKey string      `json:"date_key"` // year-month, eg "2019-06"
+	Year    int         `json:"year"`
+	Month   int         `json:"month"`  // 1-12, or 0 for images known only to the year, or less precisely
+	Images  []ImageData `json:"images"` // image metadata records + their thumbnails signed urls
+}
+
+// Timeline is a model representing a page of the timeline of images the user has permission to view,
+// grouped by year and month, newest first.
+type Timeline struct {
+	Months      []TimelineMonth      `json:"months"`
+	OlderCursor string               `json:"older_cursor,omitempty"` // cursor for the page of older images, empty if there are none
+	NewerCursor string               `json:"newer_cursor,omitempty"` // cursor for the page of newer images, empty if there are none
+	Counts      []TimelineMonthCount `json:"counts"`                 // per-month image counts across the whole (filtered) timeline
+	Total       int                  `json:"total"`                  // total number of images across the whole (filtered) timeline
+}
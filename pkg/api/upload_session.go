@@ -0,0 +1,128 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/tdeslauriers/carapace/pkg/data"
+	"github.com/tdeslauriers/carapace/pkg/permissions"
+	"github.com/tdeslauriers/carapace/pkg/validate"
+)
+
+// UploadSessionMaxImages is the maximum number of placeholders a single upload session may create.
+const UploadSessionMaxImages int = 500
+
+// upload session image statuses: where an image of an upload session is in the upload pipeline.
+const (
+	UploadStatusPending   string = "pending"   // placeholder created, the file has not been uploaded yet
+	UploadStatusUploaded  string = "uploaded"  // the file is in object storage, waiting to be processed
+	UploadStatusProcessed string = "processed" // processed and filed under the year of its exif date
+	UploadStatusStaged    string = "staged"    // processed, but no exif date was found: it needs a date before it is published
+)
+
+// UploadSessionCmd is a command that creates an image placeholder for each of a batch of files, eg,
+// all the files selected in the uploader, in one request.  The default albums and permissions are shared
+// by every image, in addition to any the image's own command lists.
+type UploadSessionCmd struct {
+	Csrf string `json:"csrf,omitempty"` // CSRF token for security
+
+	Albums      []Album                  `json:"albums,omitempty"`      // default albums for every image
+	Permissions []permissions.Permission `json:"permissions,omitempty"` // default permissions for every image
+	Images      []AddMetaDataCmd         `json:"images"`                // one placeholder is created for each
+}
+
+// Validate checks the UploadSessionCmd for valid data.
+func (cmd *UploadSessionCmd) Validate() error {
+
+	// validate the csrf token
+	if cmd.Csrf != "" {
+		if err := validate.ValidateUuid(cmd.Csrf); err != nil {
+			return fmt.Errorf("csrf token must be a valid UUID")
+		}
+	}
+
+	if len(cmd.Images) == 0 {
+		return fmt.Errorf("at least one image is required")
+	}
+
+	if len(cmd.Images) > UploadSessionMaxImages {
+		return fmt.Errorf("an upload session must be %d images or fewer, got %d", UploadSessionMaxImages, len(cmd.Images))
+	}
+
+	for _, album := range cmd.Albums {
+		if err := album.Validate(); err != nil {
+			return fmt.Errorf("invalid default album: %v", err)
+		}
+	}
+
+	for _, permission := range cmd.Permissions {
+		if err := permission.Validate(); err != nil {
+			return fmt.Errorf("invalid default permission: %v", err)
+		}
+	}
+
+	// the index lets the client match the error to the file
+	for i := range cmd.Images {
+		if err := cmd.Images[i].Validate(); err != nil {
+			return fmt.Errorf("image %d is invalid: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+// UploadSessionRecord is a model representing an upload session record in the database.
+type UploadSessionRecord struct {
+	Id         string          `db:"uuid"`
+	ImageCount int             `db:"image_count"`
+	CreatedAt  data.CustomTime `db:"created_at"`
+}
+
+// UploadSession is a model representing a newly created upload session: a placeholder, with its
+// presigned PUT URL, for each of the command's images, in the order of the command's images.
+type UploadSession struct {
+	Id           string                 `json:"id"` // the session id, to check on its progress
+	CreatedAt    string                 `json:"created_at"`
+	Placeholders []Placeholder          `json:"placeholders"`
+	Failures     []UploadSessionFailure `json:"failures,omitempty"` // images whose placeholder could not be fully created
+}
+
+// UploadSessionFailure is a model representing an image of an upload session whose placeholder could not be created,
+// or could not be added to all of its albums and permissions, in which case the placeholder is still returned.
+type UploadSessionFailure struct {
+	Index   int    `json:"index"` // index of the image in the command's images
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+// UploadSessionImageRecord is a model representing the fields of an upload session's image needed to
+// report the session's progress.
+type UploadSessionImageRecord struct {
+	Id          string `db:"uuid"`
+	Slug        string `db:"slug"`       // encrypted
+	ObjectKey   string `db:"object_key"` // encrypted
+	IsPublished bool   `db:"is_published"`
+}
+
+// UploadSessionProgress is a model representing how far the images of an upload session
+// have got through the upload pipeline.
+type UploadSessionProgress struct {
+	Id        string `json:"id"`
+	CreatedAt string `json:"created_at"`
+
+	Total     int `json:"total"`     // placeholders created in the session which still exist
+	Pending   int `json:"pending"`   // files not uploaded yet
+	Uploaded  int `json:"uploaded"`  // files uploaded, whether or not they have been processed yet
+	Processed int `json:"processed"` // files processed, including those staged
+	Staged    int `json:"staged"`    // files processed, but needing a date: see the staged images
+	Published int `json:"published"`
+
+	Images []UploadSessionImage `json:"images"` // the status of each image, eg, to triage the staged ones
+}
+
+// UploadSessionImage is a model representing where an image of an upload session is in the upload pipeline.
+type UploadSessionImage struct {
+	Id          string `json:"id"`
+	Slug        string `json:"slug"`
+	Status      string `json:"status"` // one of the UploadStatus* constants
+	IsPublished bool   `json:"is_published"`
+}
@@ -15,9 +15,28 @@ CREATE TABLE IF NOT EXISTS image (
     created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
     is_archived BOOLEAN NOT NULL DEFAULT FALSE,
-    is_published BOOLEAN NOT NULL DEFAULT FALSE
+    is_published BOOLEAN NOT NULL DEFAULT FALSE,
+    blur_hash VARCHAR(256) NOT NULL DEFAULT '',
+    dominant_color VARCHAR(128) NOT NULL DEFAULT '',
+    image_date_key CHAR(7) NOT NULL DEFAULT '',
+    has_gps BOOLEAN NOT NULL DEFAULT FALSE,
+    version INT NOT NULL DEFAULT 1,
+    image_date_precision VARCHAR(16) NOT NULL DEFAULT '',
+    image_date_end_year INT NOT NULL DEFAULT 0,
+    upload_session_uuid CHAR(36) NOT NULL DEFAULT ''
 );
 CREATE UNIQUE INDEX IF NOT EXISTS image_slug_index_idx ON image (slug_index);
+ALTER TABLE image ADD COLUMN IF NOT EXISTS blur_hash VARCHAR(256) NOT NULL DEFAULT '';
+ALTER TABLE image ADD COLUMN IF NOT EXISTS dominant_color VARCHAR(128) NOT NULL DEFAULT '';
+ALTER TABLE image ADD COLUMN IF NOT EXISTS image_date_key CHAR(7) NOT NULL DEFAULT '';
+CREATE INDEX IF NOT EXISTS idx_image_date_key ON image (image_date_key);
+ALTER TABLE image ADD COLUMN IF NOT EXISTS has_gps BOOLEAN NOT NULL DEFAULT FALSE; -- exif contains gps coordinates, the coordinates are not stored
+ALTER TABLE image ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1; -- incremented on every metadata edit, returned as the ETag
+ALTER TABLE image ADD COLUMN IF NOT EXISTS image_date_precision VARCHAR(16) NOT NULL DEFAULT ''; -- eg, year for a scanned print, empty for exact (exif) dates
+ALTER TABLE image ADD COLUMN IF NOT EXISTS image_date_end_year INT NOT NULL DEFAULT 0; -- last year of a circa range, plaintext like image_date_key
+ALTER TABLE image ADD COLUMN IF NOT EXISTS upload_session_uuid CHAR(36) NOT NULL DEFAULT ''; -- empty if not uploaded as part of a batch upload session
+ALTER TABLE image ADD COLUMN IF NOT EXISTS has_display_original BOOLEAN NOT NULL DEFAULT FALSE; -- the sanitized display original is in object storage
+CREATE INDEX IF NOT EXISTS idx_image_upload_session ON image (upload_session_uuid);
 
 -- album table
 CREATE TABLE IF NOT EXISTS album (
@@ -28,9 +47,18 @@ CREATE TABLE IF NOT EXISTS album (
     slug_index VARCHAR(128) NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
     updated_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
-    is_archived BOOLEAN NOT NULL DEFAULT FALSE
+    is_archived BOOLEAN NOT NULL DEFAULT FALSE,
+    cover_image_uuid CHAR(36) NOT NULL DEFAULT '',
+    parent_uuid CHAR(36) NOT NULL DEFAULT '',
+    inherit_permissions BOOLEAN NOT NULL DEFAULT TRUE,
+    version INT NOT NULL DEFAULT 1
 );
 CREATE UNIQUE INDEX IF NOT EXISTS album_slug_index_idx ON album (slug_index);
+ALTER TABLE album ADD COLUMN IF NOT EXISTS cover_image_uuid CHAR(36) NOT NULL DEFAULT '';
+ALTER TABLE album ADD COLUMN IF NOT EXISTS parent_uuid CHAR(36) NOT NULL DEFAULT ''; -- empty if a top level album
+CREATE INDEX IF NOT EXISTS idx_album_parent ON album (parent_uuid);
+ALTER TABLE album ADD COLUMN IF NOT EXISTS inherit_permissions BOOLEAN NOT NULL DEFAULT TRUE; -- images added to the album inherit its album_permission grants
+ALTER TABLE album ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1; -- incremented on every metadata edit, returned as the ETag
 
 -- permission table
 CREATE TABLE permission (
@@ -68,12 +96,14 @@ CREATE TABLE IF NOT EXISTS album_image (
     album_uuid CHAR(36) NOT NULL,
     image_uuid CHAR(36) NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
+    position INT NOT NULL DEFAULT 0,
     UNIQUE KEY uq_album_image_pair (album_uuid, image_uuid),
     CONSTRAINT  fk_album_image_uuid FOREIGN KEY (album_uuid) REFERENCES album(uuid),
     CONSTRAINT fk_image_album_uuid FOREIGN KEY (image_uuid) REFERENCES image(uuid)
 );
 CREATE INDEX IF NOT EXISTS idx_album_image ON album_image (album_uuid);
 CREATE INDEX IF NOT EXISTS idx_image_album ON album_image (image_uuid);
+ALTER TABLE album_image ADD COLUMN IF NOT EXISTS position INT NOT NULL DEFAULT 0; -- manual order within the album, 0 if not ordered
 
 
 -- image_permission xref table
@@ -82,12 +112,43 @@ CREATE TABLE IF NOT EXISTS image_permission (
     image_uuid CHAR(36) NOT NULL,
     permission_uuid CHAR(36) NOT NULL,
     created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
+    can_download BOOLEAN NOT NULL DEFAULT FALSE,
     UNIQUE KEY uq_image_permission_pair (image_uuid, permission_uuid),
     CONSTRAINT fk_image_permission_uuid FOREIGN KEY (image_uuid) REFERENCES image(uuid),
     CONSTRAINT fk_permission_image_uuid FOREIGN KEY (permission_uuid) REFERENCES permission(uuid)
 );
 CREATE INDEX IF NOT EXISTS idx_image_permission ON image_permission (image_uuid);
 CREATE INDEX IF NOT EXISTS idx_permission_image ON image_permission (permission_uuid);
+ALTER TABLE image_permission ADD COLUMN IF NOT EXISTS can_download BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- album_permission xref table
+CREATE TABLE IF NOT EXISTS album_permission (
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    album_uuid CHAR(36) NOT NULL,
+    permission_uuid CHAR(36) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
+    UNIQUE KEY uq_album_permission_pair (album_uuid, permission_uuid),
+    CONSTRAINT fk_album_permission_uuid FOREIGN KEY (album_uuid) REFERENCES album(uuid),
+    CONSTRAINT fk_permission_album_uuid FOREIGN KEY (permission_uuid) REFERENCES permission(uuid)
+);
+CREATE INDEX IF NOT EXISTS idx_album_permission ON album_permission (album_uuid);
+CREATE INDEX IF NOT EXISTS idx_permission_album ON album_permission (permission_uuid);
+
+-- smart_album table: the stored rules of albums whose images are evaluated at read time
+CREATE TABLE IF NOT EXISTS smart_album (
+    album_uuid CHAR(36) PRIMARY KEY,
+    rules TEXT NOT NULL, -- encrypted json
+    created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP,
+    CONSTRAINT fk_smart_album_uuid FOREIGN KEY (album_uuid) REFERENCES album(uuid)
+);
+
+-- upload_session table: a batch of image placeholders created together, eg, the files selected in one upload
+CREATE TABLE IF NOT EXISTS upload_session (
+    uuid CHAR(36) PRIMARY KEY,
+    image_count INT NOT NULL DEFAULT 0, -- number of placeholders created in the session
+    created_at TIMESTAMP NOT NULL DEFAULT UTC_TIMESTAMP
+);
 
 -- patron_permission xref table
 CREATE TABLE IF NOT EXISTS patron_permission (